@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	nsqp "github.com/crackcomm/nsqueue/producer"
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/meta"
+)
+
+//lists the requests parked after they ran out of attempts.
+func deadLetters(w http.ResponseWriter, r *http.Request) error {
+	ds, err := carton.DeadLetters()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(ds)
+}
+
+//queues a parked request again on the topic it came from.
+func replayDeadLetter(w http.ResponseWriter, r *http.Request) error {
+	d, err := carton.Replay(r.URL.Query().Get(":id"), requeue)
+	if err == carton.ErrNotParked {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(d)
+}
+
+//queues the request as a pointer, the daemons fetch it by id.
+func requeue(d *carton.DeadLetter) error {
+	pons := nsqp.New()
+	if err := pons.Connect(meta.MC.NSQd[0]); err != nil {
+		return err
+	}
+	defer pons.Stop()
+
+	bytes, err := json.Marshal(carton.Payload{Id: d.Id})
+	if err != nil {
+		return err
+	}
+	return pons.Publish(d.Topic, bytes)
+}
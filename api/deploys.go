@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision"
+)
+
+//lists the deploy history of a box, the box is identified by its assembly id
+//and component id. For a box without components use the assembly id for both.
+//Every unit of a scaled component has its own, a unit beyond the first is
+//asked for by ?unit=N.
+func deploys(w http.ResponseWriter, r *http.Request) error {
+	b := &provision.Box{
+		CartonId: r.URL.Query().Get(":assemblyid"),
+		Id:       r.URL.Query().Get(":componentid"),
+	}
+	if u := r.URL.Query().Get("unit"); len(u) > 0 {
+		unit, err := strconv.Atoi(u)
+		if err != nil || unit < 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "the unit is a number from 0, not " + u}
+		}
+		b.Unit = unit
+	}
+	ds, err := carton.DeployHistory(b)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(ds)
+}
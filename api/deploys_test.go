@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeploysOfAUnit(c *check.C) {
+	carton.SetRepository(carton.NewMemRepository())
+	c.Assert(carton.StoreDeploy("ASM001", "CMPweb", &carton.DeployData{BoxName: "web"}), check.IsNil)
+	c.Assert(carton.StoreDeploy("ASM001", "CMPweb-1", &carton.DeployData{BoxName: "web-1"}), check.IsNil)
+	for url, name := range map[string]string{
+		"/deploys/ASM001/CMPweb?:assemblyid=ASM001&:componentid=CMPweb":        "web",
+		"/deploys/ASM001/CMPweb?:assemblyid=ASM001&:componentid=CMPweb&unit=1": "web-1",
+	} {
+		request, err := http.NewRequest("GET", url, nil)
+		c.Assert(err, check.IsNil)
+		recorder := httptest.NewRecorder()
+		c.Assert(deploys(recorder, request), check.IsNil)
+		var ds []carton.DeployData
+		c.Assert(json.NewDecoder(recorder.Body).Decode(&ds), check.IsNil)
+		c.Assert(ds, check.HasLen, 1)
+		c.Assert(ds[0].BoxName, check.Equals, name)
+	}
+}
+
+func (s *S) TestDeploysOfAnUnparsableUnit(c *check.C) {
+	request, err := http.NewRequest("GET", "/deploys/ASM001/CMPweb?:assemblyid=ASM001&:componentid=CMPweb&unit=web", nil)
+	c.Assert(err, check.IsNil)
+	err = deploys(httptest.NewRecorder(), request)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+)
+
+//shows when an assembly expires.
+func expiry(w http.ResponseWriter, r *http.Request) error {
+	e, err := carton.GetExpiry(r.URL.Query().Get(":catid"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(e)
+}
+
+//makes an assembly expire, either after a ttl or at a time, eg:
+//{"ttl":"72h"} or {"expires_at":"2016-03-03T12:00:00Z"}
+func setExpiry(w http.ResponseWriter, r *http.Request) error {
+	var in struct {
+		TTL       string `json:"ttl"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	now := time.Now()
+	var at time.Time
+	if len(in.TTL) > 0 {
+		ttl, err := time.ParseDuration(in.TTL)
+		if err != nil || ttl <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "expire after a positive ttl, eg: 72h"}
+		}
+		at = now.Add(ttl)
+	} else {
+		var err error
+		if at, err = time.Parse(time.RFC3339, in.ExpiresAt); err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if !at.After(now) {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "expire at a time ahead, not " + in.ExpiresAt}
+		}
+	}
+	e, err := carton.SetExpiry(r.URL.Query().Get(":catid"), at)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(e)
+}
+
+//pushes the expiry of an assembly further, eg: {"by":"24h"}
+func extendExpiry(w http.ResponseWriter, r *http.Request) error {
+	var in struct {
+		By string `json:"by"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	by, err := time.ParseDuration(in.By)
+	if err != nil || by <= 0 {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "extend by a positive duration, eg: 24h"}
+	}
+	e, err := carton.ExtendExpiry(r.URL.Query().Get(":catid"), by)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(e)
+}
+
+//makes an assembly never expire.
+func removeExpiry(w http.ResponseWriter, r *http.Request) error {
+	return carton.RemoveExpiry(r.URL.Query().Get(":catid"))
+}
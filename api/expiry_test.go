@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/megamsys/libgo/errors"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetExpiryRejectsWhatExpiresRightAway(c *check.C) {
+	for _, body := range []string{`{"ttl":"0s"}`, `{"ttl":"-1h"}`, `{"expires_at":"2016-03-03T12:00:00Z"}`} {
+		request, err := http.NewRequest("POST", "/expiry/AMS001?:catid=AMS001", strings.NewReader(body))
+		c.Assert(err, check.IsNil)
+		err = setExpiry(httptest.NewRecorder(), request)
+		e, ok := err.(*errors.HTTP)
+		c.Assert(ok, check.Equals, true, check.Commentf("%s", body))
+		c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+	}
+}
@@ -38,6 +38,25 @@ func NewNegHandler() *negroni.Negroni {
 	m.Add("Get", "/", Handler(index))
 	m.Add("Get", "/logs", Handler(logs))
 	m.Add("Get", "/ping", Handler(ping))
+	m.Add("Get", "/deploys/{assemblyid}/{componentid}", Handler(deploys))
+	m.Add("Get", "/requests/{id}", Handler(requests))
+	m.Add("Get", "/schedules/{catid}", Handler(schedules))
+	m.Add("Post", "/schedules/{catid}", Handler(addSchedule))
+	m.Add("Delete", "/schedules/{catid}/{id}", Handler(removeSchedule))
+	m.Add("Get", "/expiry/{catid}", Handler(expiry))
+	m.Add("Put", "/expiry/{catid}", Handler(setExpiry))
+	m.Add("Post", "/expiry/{catid}/extend", Handler(extendExpiry))
+	m.Add("Delete", "/expiry/{catid}", Handler(removeExpiry))
+	m.Add("Get", "/quotas/{accountid}", Handler(quota))
+	m.Add("Put", "/quotas/{accountid}", Handler(setQuota))
+	m.Add("Get", "/snapshots/{assemblyid}", Handler(snapshots))
+	m.Add("Delete", "/snapshots/{assemblyid}/{id}", Handler(deleteSnapshot))
+	m.Add("Get", "/deadletters", Handler(deadLetters))
+	m.Add("Post", "/deadletters/{id}/replay", Handler(replayDeadLetter))
+	m.Add("Get", "/nodes/{provisioner}", Handler(nodes))
+	m.Add("Post", "/nodes/{provisioner}", Handler(addNode))
+	m.Add("Delete", "/nodes/{provisioner}", Handler(removeNode))
+	m.Add("Get", "/nodes/{provisioner}/health", Handler(nodeHealth))
 	//we can use this as a single click Terminal launch for docker.
 	//m.Add("Get", "/apps/{appname}/shell", websocket.Handler(remoteShellHandler))
 	n := negroni.New()
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/provision"
+)
+
+//the provisioner named in the path, when its nodes can be managed.
+func nodeManager(r *http.Request) (provision.NodeManager, error) {
+	name := r.URL.Query().Get(":provisioner")
+	p, err := provision.Get(name)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	m, ok := p.(provision.NodeManager)
+	if !ok {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("the nodes of %s can't be managed", name)}
+	}
+	return m, nil
+}
+
+//lists the nodes of a provisioner.
+func nodes(w http.ResponseWriter, r *http.Request) error {
+	m, err := nodeManager(r)
+	if err != nil {
+		return err
+	}
+	ns, err := m.Nodes()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(ns)
+}
+
+//adds a node to a provisioner, eg:
+//{"address":"http://dallas:2633/RPC2","metadata":{"userid":"oneadmin","password":"..","zone":"dallas01"}}
+func addNode(w http.ResponseWriter, r *http.Request) error {
+	m, err := nodeManager(r)
+	if err != nil {
+		return err
+	}
+	var in provision.Node
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if in.Address == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "address is mandatory"}
+	}
+	err = m.AddNode(in.Address, in.Metadata)
+	if err == provision.ErrNodeExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+//removes a node of a provisioner, the address is in the query as it is an url.
+func removeNode(w http.ResponseWriter, r *http.Request) error {
+	m, err := nodeManager(r)
+	if err != nil {
+		return err
+	}
+	err = m.RemoveNode(r.URL.Query().Get("address"))
+	if err == provision.ErrNodeNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+//the latest probes of a node of a provisioner, the address is in the query.
+func nodeHealth(w http.ResponseWriter, r *http.Request) error {
+	m, err := nodeManager(r)
+	if err != nil {
+		return err
+	}
+	h, err := m.NodeHealth(r.URL.Query().Get("address"))
+	if err == provision.ErrNodeNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+)
+
+//shows the limits of an account along with its usage.
+func quota(w http.ResponseWriter, r *http.Request) error {
+	q, err := carton.GetQuota(r.URL.Query().Get(":accountid"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(q)
+}
+
+//sets the limits of an account, eg: {"cpushare":4,"memory":8192,"boxes":4}
+//where memory and hdd are in MB. A limit left out (or zero) falls back to
+//the default.
+func setQuota(w http.ResponseWriter, r *http.Request) error {
+	var in struct {
+		Cpushare uint64 `json:"cpushare"`
+		Memory   uint64 `json:"memory"`
+		HDD      uint64 `json:"hdd"`
+		Boxes    uint64 `json:"boxes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	id := r.URL.Query().Get(":accountid")
+	if err := carton.SetQuota(id, carton.Resources{Cpushare: in.Cpushare, Memory: in.Memory, HDD: in.HDD, Boxes: in.Boxes}); err != nil {
+		return err
+	}
+	return quota(w, r)
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/megamsys/vertice/carton"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetQuota(c *check.C) {
+	carton.SetRepository(carton.NewMemRepository())
+	request, err := http.NewRequest("PUT", "/quotas/ACT001?:accountid=ACT001", strings.NewReader(`{"memory":8192,"boxes":4}`))
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	c.Assert(setQuota(recorder, request), check.IsNil)
+
+	var q carton.Quota
+	c.Assert(json.NewDecoder(recorder.Body).Decode(&q), check.IsNil)
+	c.Assert(q.AccountsId, check.Equals, "ACT001")
+	c.Assert(q.Limit(), check.DeepEquals, carton.Resources{Memory: 8192, Boxes: 4})
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/megamsys/vertice/carton"
+)
+
+//shows a request along with its status, when it started and ended
+//and the error if it failed.
+func requests(w http.ResponseWriter, r *http.Request) error {
+	re, err := carton.GetRequest(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(re)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+)
+
+//lists the schedules of an assembly.
+func schedules(w http.ResponseWriter, r *http.Request) error {
+	ss, err := carton.ListSchedules(r.URL.Query().Get(":catid"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(ss)
+}
+
+//adds a schedule to an assembly, eg:
+//{"category":"control","action":"stop","cron":"0 20 * * 1-5","missed":"skip"}
+func addSchedule(w http.ResponseWriter, r *http.Request) error {
+	var in carton.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	sc, err := carton.NewSchedule(r.URL.Query().Get(":catid"), in.Category, in.Action, in.Cron, in.Missed)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err := carton.AddSchedule(sc); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(sc)
+}
+
+//removes a schedule of an assembly.
+func removeSchedule(w http.ResponseWriter, r *http.Request) error {
+	err := carton.RemoveSchedule(r.URL.Query().Get(":catid"), r.URL.Query().Get(":id"))
+	if err == carton.ErrScheduleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
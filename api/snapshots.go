@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+)
+
+//lists the snapshots of the boxes of an assembly.
+func snapshots(w http.ResponseWriter, r *http.Request) error {
+	ss, err := carton.ListSnapshots(r.URL.Query().Get(":assemblyid"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(ss)
+}
+
+//deletes a snapshot of an assembly.
+func deleteSnapshot(w http.ResponseWriter, r *http.Request) error {
+	err := carton.DeleteSnapshot(r.URL.Query().Get(":assemblyid"), r.URL.Query().Get(":id"))
+	if err == carton.ErrSnapshotNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
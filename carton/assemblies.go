@@ -18,13 +18,12 @@ package carton
 import (
 	"fmt"
 	log "github.com/Sirupsen/logrus"
-	ldb "github.com/megamsys/libgo/db"
-	"github.com/megamsys/vertice/meta"
 	"gopkg.in/yaml.v2"
-	"reflect"
 	"strings"
 )
 
+const ASSEMBLIESBUCKET = "assemblies"
+
 //bunch Assemblys
 type Cartons []*Carton
 
@@ -51,19 +50,8 @@ func (a *Assemblies) String() string {
 /** A public function which pulls the assemblies for deployment.
 and any others we do. **/
 func Get(id string) (*Assemblies, error) {
-
-	a := &Assemblies{}
-	//ops := vdb.ScyllaOptions("assemblies", []string{"Id"}, []string{"org_id"}, map[string]string{"Id": id, "org_id":"ORG123"})
-	ops := ldb.Options{
-		TableName:   "assemblies",
-		Pks:         []string{"Id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"Id": id},
-		CcmsClauses: make(map[string]interface{}),
-	}
-	if err := ldb.Fetchdb(ops, a); err != nil {
+	a, err := store.GetAssemblies(id)
+	if err != nil {
 		return nil, err
 	}
 	log.Debugf("Assemblies %v", a)
@@ -87,28 +75,43 @@ func (a *Assemblies) MkCartons() (Cartons, error) {
 	return newCs, nil
 }
 
-func (a *Assemblies) Delete(asmid string, removedAssemblys []string) {
-	existingAssemblys := make([]string, len(a.AssemblysId))
-
-	for i := 0; i < len(a.AssemblysId); i++ {
-		if len(strings.TrimSpace(a.AssemblysId[i])) > 1 {
-			existingAssemblys[i] = a.AssemblysId[i]
+// Provider returns the provider the assemblies are launched on, as told by
+// their first assembly (or else its first component).
+func (a *Assemblies) Provider() (string, error) {
+	for _, ay := range a.AssemblysId {
+		if len(strings.TrimSpace(ay)) <= 1 {
+			continue
 		}
-	}
-	if reflect.DeepEqual(existingAssemblys, removedAssemblys) {
-		ops := ldb.Options{
-			TableName:   "assemblies",
-			Pks:         []string{"id"},
-			Ccms:        []string{"org_id"},
-			Hosts:       meta.MC.Scylla,
-			Keyspace:    meta.MC.ScyllaKeyspace,
-			PksClauses:  map[string]interface{}{"id": asmid},
-			CcmsClauses: map[string]interface{}{"org_id": a.AccountsId},
+		asm, err := NewAssembly(ay)
+		if err != nil {
+			return "", err
 		}
-		if err := ldb.Deletedb(ops, Assemblies{}); err != nil {
-			return
+		if p := asm.provider(); len(strings.TrimSpace(p)) > 0 {
+			return p, nil
 		}
+		for _, comp := range asm.Components {
+			if p := comp.provider(); len(strings.TrimSpace(p)) > 0 {
+				return p, nil
+			}
+		}
+		return "", fmt.Errorf("no provider for the assembly (%s)", ay)
+	}
+	return "", ErrNotFound
+}
+
+//drops the assembly ay, the assemblies are deleted along with the last of them.
+func (a *Assemblies) Delete(ay string) error {
+	left := make([]string, 0, len(a.AssemblysId))
+	for _, id := range a.AssemblysId {
+		if len(strings.TrimSpace(id)) > 1 && id != ay {
+			left = append(left, id)
+		}
+	}
+	if len(left) > 0 {
+		a.AssemblysId = left
+		return store.StoreAssemblies(a)
 	}
+	return store.DeleteAssemblies(a.Id, a.AccountsId)
 }
 
 //a hash in json representing {name: "", value: ""}
@@ -18,7 +18,6 @@ package carton
 import (
 	"encoding/json"
 	log "github.com/Sirupsen/logrus"
-	ldb "github.com/megamsys/libgo/db"
 	"github.com/megamsys/libgo/events"
 	"github.com/megamsys/libgo/events/alerts"
 	"github.com/megamsys/libgo/pairs"
@@ -27,22 +26,28 @@ import (
 	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/provision"
 	"gopkg.in/yaml.v2"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	ASSEMBLYBUCKET = "assembly"
 	SSHKEY         = "sshkey"
-	VNCPORT       = "vncport"
-	VNCHOST       = "vnchost"
-	VMID         =  "vmid"
+	VNCPORT        = "vncport"
+	VNCHOST        = "vnchost"
+	VMID           = "vmid"
+	VMNODE         = "vmnode" //the node (one frontend) which owns the vm
+	REGION         = "region" //the zone the boxes are placed in, any when blank
+	LABELS         = "labels" //the labels of the nodes the boxes are placed on, as k=v pairs separated by commas
 )
 
 type Policy struct {
-	Name    string   `json:"name" cql:"name"`
-	Type    string   `json:"type" cql:"type"`
-	Members []string `json:"members" cql:"members"`
+	Name    string          `json:"name" cql:"name"`
+	Type    string          `json:"type" cql:"type"`
+	Members []string        `json:"members" cql:"members"`
+	Rules   pairs.JsonPairs `json:"rules" cql:"rules"`
 }
 
 //An assembly comprises of various components.
@@ -92,7 +97,7 @@ func mkCarton(aies string, ay string) (*Carton, error) {
 		return nil, err
 	}
 
-	b, err := a.mkBoxes(aies)
+	b, levels, err := a.mkBoxes(aies)
 	if err != nil {
 		return nil, err
 	}
@@ -100,18 +105,27 @@ func mkCarton(aies string, ay string) (*Carton, error) {
 	c := &Carton{
 		Id:           ay,   //assembly id
 		CartonsId:    aies, //assemblies id
+		AccountsId:   a.AccountId,
 		Name:         a.Name,
 		Tosca:        a.Tosca,
 		ImageVersion: a.imageVersion(),
 		DomainName:   a.domain(),
 		Compute:      a.newCompute(),
+		ResizeTo:     a.resizeTo(),
+		SnapshotName: a.snapshotName(),
+		RestoreName:  a.restoreName(),
 		SSH:          a.newSSH(),
 		Provider:     a.provider(),
 		PublicIp:     a.publicIp(),
+		Region:       a.region(),
+		Labels:       a.labels(),
 		//VncHost:      a.vncHost(),
 		//VncPort:      a.vncPort(),
-		Boxes:        &b,
-		Status:       utils.Status(a.Status),
+		Boxes:    &b,
+		Status:   utils.Status(a.Status),
+		Policies: a.Policies,
+		TTL:      a.ttl(),
+		levels:   levels,
 	}
 	return c, nil
 }
@@ -119,15 +133,19 @@ func mkCarton(aies string, ay string) (*Carton, error) {
 //lets make boxes with components to be mutated later or, and the required
 //information for a launch.
 //A "colored component" externalized with what we need.
-func (a *Assembly) mkBoxes(aies string) ([]provision.Box, error) {
+//The boxes are grouped into levels using the related components, a cycle
+//among them is an error.
+func (a *Assembly) mkBoxes(aies string) ([]provision.Box, [][]int, error) {
 	newBoxs := make([]provision.Box, 0, len(a.Components))
-	for _, comp := range a.Components {
+	related := make([][]string, 0, len(a.Components))
+	for _, comp := range a.sortedComponents() {
 		if len(strings.TrimSpace(comp.Id)) > 1 {
 			if b, err := comp.mkBox(); err != nil {
-				return nil, err
+				return nil, nil, err
 			} else {
 				b.CartonId = a.Id
 				b.CartonsId = aies
+				b.AccountsId = a.AccountId
 				b.CartonName = a.Name
 				if len(strings.TrimSpace(b.Provider)) <= 0 {
 					b.Provider = a.provider()
@@ -140,30 +158,48 @@ func (a *Assembly) mkBoxes(aies string) ([]provision.Box, error) {
 					b.Repo.Hook.BoxId = comp.Id
 				}
 				b.Compute = a.newCompute()
+				b.Region = a.region()
+				b.Labels = a.labels()
 				b.SSH = a.newSSH()
-				b.Status = utils.Status(a.Status)
-				newBoxs = append(newBoxs, b)
+				b.Related = comp.RelatedComponents
+				for unit := 0; unit < comp.boxUnits(); unit++ {
+					b.Unit = unit
+					b.Status = utils.Status(a.Status)
+					if unit == 0 && len(strings.TrimSpace(comp.Status)) > 0 {
+						b.Status = utils.Status(comp.Status)
+					}
+					if st := comp.unitStatus(unit); unit > 0 && len(st) > 0 {
+						b.Status = st
+					}
+					newBoxs = append(newBoxs, b)
+					related = append(related, comp.RelatedComponents)
+				}
 			}
 		}
 	}
-	return newBoxs, nil
+	levels, err := depLevels(newBoxs, related)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newBoxs, levels, nil
 }
 
-func getBig(id string) (*Ambly, error) {
-	a := &Ambly{}
-	ops := ldb.Options{
-		TableName:   ASSEMBLYBUCKET,
-		Pks:         []string{"Id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"Id": id},
-		CcmsClauses: make(map[string]interface{}),
+//the components sorted by id, so that the boxes come out the same every time.
+func (a *Assembly) sortedComponents() []*Component {
+	ids := make([]string, 0, len(a.Components))
+	for id := range a.Components {
+		ids = append(ids, id)
 	}
-	if err := ldb.Fetchdb(ops, a); err != nil {
-		return nil, err
+	sort.Strings(ids)
+	comps := make([]*Component, 0, len(ids))
+	for _, id := range ids {
+		comps = append(comps, a.Components[id])
 	}
-	return a, nil
+	return comps
+}
+
+func getBig(id string) (*Ambly, error) {
+	return store.GetAssembly(id)
 }
 
 //Temporary hack to create an assembly from its id.
@@ -193,16 +229,7 @@ func (a *Ambly) SetStatus(status utils.Status) error {
 	update_fields := make(map[string]interface{})
 	update_fields["Inputs"] = js.ToString()
 	update_fields["Status"] = status.String()
-	ops := ldb.Options{
-		TableName:   ASSEMBLYBUCKET,
-		Pks:         []string{"id"},
-		Ccms:        []string{"org_id"},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"id": a.Id},
-		CcmsClauses: map[string]interface{}{"org_id": a.OrgId},
-	}
-	if err := ldb.Updatedb(ops, update_fields); err != nil {
+	if err := store.UpdateAssembly(a.Id, a.OrgId, update_fields); err != nil {
 		return err
 	}
 	return a.trigger_event(status)
@@ -235,24 +262,34 @@ func (a *Ambly) trigger_event(status utils.Status) error {
 	return newEvent.Write()
 }
 
+//guards the read, change and save of the outputs of an assembly (or a
+//component), the units of a component change theirs at once.
+var outputsMu sync.Mutex
+
+//the outputs of the assembly as saved, the ones read earlier may be stale.
+func (a *Ambly) savedOutputs() (pairs.JsonPairs, error) {
+	saved, err := getBig(a.Id)
+	if err != nil {
+		return nil, err
+	}
+	a.Outputs = saved.Outputs
+	return a.getOutputs(), nil
+}
+
 //update outputs in scylla, nuke the matching keys available
 func (a *Ambly) NukeAndSetOutputs(m map[string][]string) error {
 	if len(m) > 0 {
 		log.Debugf("nuke and set outputs in scylla [%s]", m)
-		js := a.getOutputs()
+		outputsMu.Lock()
+		defer outputsMu.Unlock()
+		js, err := a.savedOutputs()
+		if err != nil {
+			return err
+		}
 		js.NukeAndSet(m) //just nuke the matching output key:
 		update_fields := make(map[string]interface{})
 		update_fields["Outputs"] = js.ToString()
-		ops := ldb.Options{
-			TableName:   ASSEMBLYBUCKET,
-			Pks:         []string{"id"},
-			Ccms:        []string{"org_id"},
-			Hosts:       meta.MC.Scylla,
-			Keyspace:    meta.MC.ScyllaKeyspace,
-			PksClauses:  map[string]interface{}{"id": a.Id},
-			CcmsClauses: map[string]interface{}{"org_id": a.OrgId},
-		}
-		if err := ldb.Updatedb(ops, update_fields); err != nil {
+		if err := store.UpdateAssembly(a.Id, a.OrgId, update_fields); err != nil {
 			return err
 		}
 	} else {
@@ -261,35 +298,63 @@ func (a *Ambly) NukeAndSetOutputs(m map[string][]string) error {
 	return nil
 }
 
-func (c *Assembly) Delete(asmid string) {
-	ops := ldb.Options{
-		TableName:   ASSEMBLYBUCKET,
-		Pks:         []string{"id"},
-		Ccms:        []string{"org_id"},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"id": asmid},
-		CcmsClauses: map[string]interface{}{"org_id": c.OrgId},
+//update inputs in scylla, nuke the matching keys available
+func (a *Ambly) NukeAndSetInputs(m map[string][]string) error {
+	if len(m) > 0 {
+		log.Debugf("nuke and set inputs in scylla [%s]", m)
+		js := a.getInputs()
+		js.NukeAndSet(m) //just nuke the matching input key:
+		update_fields := make(map[string]interface{})
+		update_fields["Inputs"] = js.ToString()
+		if err := store.UpdateAssembly(a.Id, a.OrgId, update_fields); err != nil {
+			return err
+		}
 	}
-	if err := ldb.Deletedb(ops, Ambly{}); err != nil {
-		return
+	return nil
+}
+
+//nukes the outputs with the keys in scylla, the ones not there are ignored.
+func (a *Ambly) NukeOutputs(keys ...string) error {
+	nuke := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		nuke[k] = true
+	}
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	js, err := a.savedOutputs()
+	if err != nil {
+		return err
+	}
+	left := make(pairs.JsonPairs, 0, len(js))
+	for _, o := range js {
+		if !nuke[o.K] {
+			left = append(left, o)
+		}
+	}
+	if len(left) == len(js) {
+		return nil
+	}
+	update_fields := make(map[string]interface{})
+	update_fields["Outputs"] = left.ToString()
+	return store.UpdateAssembly(a.Id, a.OrgId, update_fields)
+}
+
+//the components of the assembly left once compid is gone.
+func (a *Ambly) componentsBut(compid string) []string {
+	left := make([]string, 0, len(a.Components))
+	for _, id := range a.Components {
+		if len(strings.TrimSpace(id)) > 1 && id != compid {
+			left = append(left, id)
+		}
 	}
+	return left
 }
 
 //get the assembly and its children (component). we only store the
 //componentid, hence you see that we have a components map to cater to that need.
 func get(id string) (*Assembly, error) {
-	a := &Ambly{}
-	ops := ldb.Options{
-		TableName:   ASSEMBLYBUCKET,
-		Pks:         []string{"Id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"Id": id},
-		CcmsClauses: make(map[string]interface{}),
-	}
-	if err := ldb.Fetchdb(ops, a); err != nil {
+	a, err := store.GetAssembly(id)
+	if err != nil {
 		return nil, err
 	}
 	asm, _ := a.dig()
@@ -299,6 +364,7 @@ func get(id string) (*Assembly, error) {
 func (a *Ambly) dig() (Assembly, error) {
 	asm := Assembly{}
 	asm.Id = a.Id
+	asm.OrgId = a.OrgId
 	asm.AccountId = a.AccountId
 	asm.Name = a.Name
 	asm.Tosca = a.Tosca
@@ -330,10 +396,36 @@ func (a *Assembly) domain() string {
 	return a.Inputs.Match(DOMAIN)
 }
 
+//a ttl which doesn't parse is ignored, the assembly never expires.
+func (a *Assembly) ttl() time.Duration {
+	d, _ := time.ParseDuration(a.Inputs.Match(TTL))
+	return d
+}
+
 func (a *Assembly) provider() string {
 	return a.Inputs.Match(utils.PROVIDER)
 }
 
+func (a *Assembly) region() string {
+	return a.Inputs.Match(REGION)
+}
+
+//the labels a node must have for the boxes to be placed on it, none when blank.
+func (a *Assembly) labels() map[string]string {
+	labels := make(map[string]string)
+	for _, l := range strings.Split(a.Inputs.Match(LABELS), ",") {
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) < 2 {
+			kv = append(kv, "")
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels
+}
+
 func (a *Assembly) publicIp() string {
 	return a.Outputs.Match(PUBLICIPV4)
 }
@@ -361,8 +453,9 @@ func (a *Assembly) newCompute() provision.BoxCompute {
 
 func (a *Assembly) newSSH() provision.BoxSSH {
 	return provision.BoxSSH{
-		User:   meta.MC.User,
-		Prefix: a.sshkey(),
+		User:      meta.MC.User,
+		Prefix:    a.sshkey(),
+		PublicKey: publicKey(a.OrgId, a.sshkey()),
 	}
 }
 
@@ -0,0 +1,184 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/pairs"
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/carton/bind"
+	"github.com/megamsys/vertice/provision"
+)
+
+const (
+	//the connection details of a box, the rest of its outputs are published as is.
+	HOST = "host"
+	PORT = "port"
+)
+
+//the outputs of a component which aren't connection details.
+var unpublished = map[string]bool{
+	PUBLICIPV4:  true,
+	PRIVATEIPV4: true,
+	PORT:        true,
+	UNITS:       true,
+	VNCHOST:     true,
+	VNCPORT:     true,
+	VMID:        true,
+	VMNODE:      true,
+	HOOKID:      true,
+	UNITSTATUS:  true,
+}
+
+//an output kept for a unit (eg: unitstatus-1) is published as its key is.
+func published(key string) bool {
+	if i := strings.LastIndex(key, "-"); i > 0 {
+		if _, err := strconv.Atoi(key[i+1:]); err == nil {
+			key = key[:i]
+		}
+	}
+	return !unpublished[key]
+}
+
+var envNameRegexp = regexp.MustCompile("[^A-Z0-9]+")
+
+//eg: my-db => MY_DB
+func envName(s string) string {
+	return envNameRegexp.ReplaceAllString(strings.ToUpper(strings.TrimSpace(s)), "_")
+}
+
+//the connection details of a box which the boxes related to it get in their envs,
+//eg: DB_HOST, DB_PORT and DB_<OUTPUT> for every published output of db.
+func endpointEnvs(b *provision.Box, outputs pairs.JsonPairs) []bind.EnvVar {
+	prefix := envName(b.Name) + "_"
+	host := outputs.Match(PUBLICIPV4)
+	if len(strings.TrimSpace(host)) == 0 {
+		host = outputs.Match(PRIVATEIPV4)
+	}
+	if len(strings.TrimSpace(host)) == 0 {
+		host = b.GetFullName()
+	}
+	envs := []bind.EnvVar{bind.EnvVar{Name: prefix + envName(HOST), Value: host, Endpoint: b.Name}}
+	if port := outputs.Match(PORT); len(strings.TrimSpace(port)) > 0 {
+		envs = append(envs, bind.EnvVar{Name: prefix + envName(PORT), Value: port, Endpoint: b.Name})
+	}
+	for _, o := range outputs {
+		if !published(o.K) {
+			continue
+		}
+		envs = append(envs, bind.EnvVar{Name: prefix + envName(o.K), Value: o.V, Endpoint: b.Name})
+	}
+	return envs
+}
+
+//a box relates to the other by the id or name of the other.
+func relates(b, to *provision.Box) bool {
+	for _, r := range b.Related {
+		r = strings.TrimSpace(r)
+		if r == to.Id || r == to.Name {
+			return true
+		}
+	}
+	return false
+}
+
+//drops the envs bound from the endpoint. They are known by the endpoint, or by
+//their names once reloaded from the component.
+func unbound(envs []bind.EnvVar, endpoint string, bound []bind.EnvVar) []bind.EnvVar {
+	names := make(map[string]bool, len(bound))
+	for _, e := range bound {
+		names[e.Name] = true
+	}
+	kept := make([]bind.EnvVar, 0, len(envs))
+	for _, e := range envs {
+		if e.Endpoint == endpoint || names[e.Name] {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// Bind gives the connection details of the box to every box of the carton
+// which relates to it, and saves them in the envs of their components.
+func (c *Carton) Bind(b *provision.Box) error {
+	if b.Level != provision.BoxSome {
+		return nil
+	}
+	comp, err := NewComponent(b.Id)
+	if err != nil {
+		return err
+	}
+	envs := endpointEnvs(b, comp.Outputs)
+	return c.rebind(b, func(e []bind.EnvVar) []bind.EnvVar {
+		return append(unbound(e, b.Name, envs), envs...)
+	})
+}
+
+// Unbind takes away the connection details of the box from every box of the
+// carton which relates to it.
+func (c *Carton) Unbind(b *provision.Box) error {
+	if b.Level != provision.BoxSome {
+		return nil
+	}
+	envs := endpointEnvs(b, nil)
+	if comp, err := NewComponent(b.Id); err == nil {
+		envs = endpointEnvs(b, comp.Outputs)
+	}
+	return c.rebind(b, func(e []bind.EnvVar) []bind.EnvVar {
+		return unbound(e, b.Name, envs)
+	})
+}
+
+//changes the envs of the boxes related to b, the boxes being torn down are left alone.
+func (c *Carton) rebind(b *provision.Box, fn func([]bind.EnvVar) []bind.EnvVar) error {
+	c.bindMu.Lock()
+	defer c.bindMu.Unlock()
+	boxes := *c.Boxes
+	saved := make(map[string]bool)
+	for i := range boxes {
+		if boxes[i].Id == b.Id || !relates(&boxes[i], b) || boxes[i].Status == constants.StatusDestroying {
+			continue
+		}
+		boxes[i].Envs = fn(boxes[i].Envs)
+		if saved[boxes[i].Id] {
+			continue
+		}
+		saved[boxes[i].Id] = true
+		log.Debugf("  bind (%s) into (%s)", unitName(b), boxes[i].Name)
+		if err := (&Component{Id: boxes[i].Id}).UpdateEnvs(boxes[i].Envs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateEnvs saves the envs of the component.
+func (c *Component) UpdateEnvs(envs []bind.EnvVar) error {
+	js := make(pairs.JsonPairs, 0, len(envs))
+	for _, e := range envs {
+		js = append(js, &pairs.JsonPair{K: e.Name, V: e.Value})
+	}
+	c.Envs = js
+
+	update_fields := make(map[string]interface{})
+	update_fields["Envs"] = js.ToString()
+	return store.UpdateComponent(c.Id, update_fields)
+}
@@ -0,0 +1,59 @@
+package carton
+
+import (
+	"github.com/megamsys/libgo/pairs"
+	"github.com/megamsys/vertice/carton/bind"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type BindingSuite struct{}
+
+var _ = check.Suite(&BindingSuite{})
+
+func (s *BindingSuite) TestEndpointEnvs(c *check.C) {
+	outputs := make(pairs.JsonPairs, 0)
+	outputs.NukeAndSet(map[string][]string{
+		PUBLICIPV4: []string{"192.168.1.10"},
+		PORT:       []string{"5432"},
+		"db.user":  []string{"admin"},
+		UNITS:      []string{"1"},
+	})
+	envs := endpointEnvs(&provision.Box{Name: "my-db"}, outputs)
+	byName := make(map[string]string)
+	for _, e := range envs {
+		c.Assert(e.Endpoint, check.Equals, "my-db")
+		byName[e.Name] = e.Value
+	}
+	c.Assert(byName, check.DeepEquals, map[string]string{
+		"MY_DB_HOST":    "192.168.1.10",
+		"MY_DB_PORT":    "5432",
+		"MY_DB_DB_USER": "admin",
+	})
+}
+
+func (s *BindingSuite) TestEndpointEnvsWithoutOutputs(c *check.C) {
+	envs := endpointEnvs(&provision.Box{Name: "db", CartonName: "myapp", DomainName: "megam.co"}, nil)
+	c.Assert(envs, check.DeepEquals, []bind.EnvVar{
+		bind.EnvVar{Name: "DB_HOST", Value: "myapp.megam.co", Endpoint: "db"},
+	})
+}
+
+func (s *BindingSuite) TestUnbound(c *check.C) {
+	envs := []bind.EnvVar{
+		bind.EnvVar{Name: "RAILS_ENV", Value: "production"},
+		bind.EnvVar{Name: "DB_HOST", Value: "10.0.0.1"},
+		bind.EnvVar{Name: "DB_PORT", Value: "5432", Endpoint: "db"},
+	}
+	bound := []bind.EnvVar{bind.EnvVar{Name: "DB_HOST", Value: "10.0.0.2", Endpoint: "db"}}
+	c.Assert(unbound(envs, "db", bound), check.DeepEquals, []bind.EnvVar{
+		bind.EnvVar{Name: "RAILS_ENV", Value: "production"},
+	})
+}
+
+func (s *BindingSuite) TestRelates(c *check.C) {
+	db := &provision.Box{Id: "CMP01", Name: "db"}
+	c.Assert(relates(&provision.Box{Related: []string{"db"}}, db), check.Equals, true)
+	c.Assert(relates(&provision.Box{Related: []string{" CMP01"}}, db), check.Equals, true)
+	c.Assert(relates(&provision.Box{Related: []string{"cache"}}, db), check.Equals, false)
+}
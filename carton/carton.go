@@ -1,9 +1,14 @@
 package carton
 
 import (
+	"io/ioutil"
+	"sync"
+	"time"
+
 	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/provision"
+	"golang.org/x/net/context"
 	"gopkg.in/yaml.v2"
 )
 
@@ -17,12 +22,22 @@ type Carton struct {
 	Tosca        string
 	ImageVersion string
 	Compute      provision.BoxCompute
+	ResizeTo     provision.BoxCompute //the compute asked for by a resize, the same as Compute when none is.
+	SnapshotName string               //the name of the snapshot to take.
+	RestoreName  string               //the name of the snapshot to restore, the latest one when blank.
 	SSH          provision.BoxSSH
 	DomainName   string
 	Provider     string
 	PublicIp     string
+	Region       string
+	Labels       map[string]string //the labels of the nodes the boxes are placed on.
 	Boxes        *[]provision.Box
 	Status       utils.Status
+	Policies     []*Policy
+	TTL          time.Duration   //the assembly expires this long after launch, when set.
+	bindMu       sync.Mutex      //guards the envs of the boxes while binding
+	levels       [][]int         //the boxes (by index) grouped in the order of their dependencies.
+	ctx          context.Context //the context of the operation on the carton, see begin.
 }
 
 //Global provisioners set by the subd daemons.
@@ -62,6 +77,8 @@ func (c *Carton) toBox() error { //assemblies id.
 			Compute:      c.Compute,
 			Provider:     c.Provider,
 			PublicIp:     c.PublicIp,
+			Region:       c.Region,
+			Labels:       c.Labels,
 			Tosca:        c.Tosca,
 			Status:       c.Status,
 		},
@@ -70,38 +87,138 @@ func (c *Carton) toBox() error { //assemblies id.
 	return nil
 }
 
-// Deploy carton, which basically deploys the boxes.
-func (c *Carton) Deploy() error {
-	for _, box := range *c.Boxes {
-		err := Deploy(&DeployOpts{B: &box})
-		if err != nil {
-			return err
+// eachBox runs fn on the boxes level by level in the order of their dependencies,
+// the boxes of a level run concurrently. It reports on every one of them.
+func (c *Carton) eachBox(fn func(b *provision.Box) error) Report {
+	return c.eachLevel(c.order(), fn)
+}
+
+// eachBoxReverse is eachBox with the dependents run before their dependencies.
+func (c *Carton) eachBoxReverse(fn func(b *provision.Box) error) Report {
+	return c.eachLevel(reverse(c.order()), fn)
+}
+
+//when there aren't any dependencies, all the boxes make up a single level.
+func (c *Carton) order() [][]int {
+	if len(c.levels) > 0 {
+		return c.levels
+	}
+	all := make([]int, len(*c.Boxes))
+	for i := range all {
+		all[i] = i
+	}
+	return [][]int{all}
+}
+
+// eachLevel runs fn on the boxes of every level, the boxes are passed by their
+// position so any change made by fn sticks. Once a level fails, the boxes in the levels after it are skipped.
+// So are they once the operation is cancelled.
+func (c *Carton) eachLevel(levels [][]int, fn func(b *provision.Box) error) Report {
+	boxes := *c.Boxes
+	r := make(Report, 0, len(boxes))
+	failed := false
+	for _, level := range levels {
+		if err := c.skipped(failed); err != nil {
+			for _, i := range level {
+				r = append(r, &Result{Name: unitName(&boxes[i]), Err: err})
+			}
+			continue
+		}
+		in := make([]*provision.Box, len(level))
+		for k, i := range level {
+			in[k] = &boxes[i]
 		}
+		errs := eachSlot(in, fn)
+		for k, i := range level {
+			r = append(r, &Result{Name: unitName(&boxes[i]), Err: errs[k]})
+			failed = failed || errs[k] != nil
+		}
+	}
+	return r
+}
+
+//why the boxes of the next level are skipped, when they are.
+func (c *Carton) skipped(failed bool) error {
+	if failed {
+		return ErrDependencyFailed
+	}
+	if c.ctx != nil {
+		return c.ctx.Err()
 	}
 	return nil
 }
 
-// Destroys a carton, which deletes its boxes.
-func (c *Carton) Destroy() error {
-	for _, box := range *c.Boxes {
-		err := Destroy(&DestroyOpts{B: &box})
-		if err != nil {
+// Deploy carton, which basically deploys the boxes. A box launched by an
+// earlier attempt (which failed on another box) isn't deployed again, as
+// the create is retried as a whole.
+func (c *Carton) Deploy() error {
+	return c.enforce(POLICY_DEPLOY, func() error {
+		if err := c.eachBox(func(b *provision.Box) error {
+			if launched(b) {
+				log.Debugf("  box (%s) is %s already, skipping its deploy", unitName(b), b.Status.String())
+			} else if err := Deploy(&DeployOpts{B: b}); err != nil {
+				return err
+			}
+			return c.Bind(b)
+		}).Err(); err != nil {
 			return err
 		}
-	}
-	return nil
+		return c.recordUnits()
+	})
+}
+
+// Destroys a carton, which deletes its boxes. A box which was destroyed
+// doesn't hold back the rest, though what it left behind is reported. A box
+// is removed once the last of its units is destroyed, so a unit which
+// wasn't keeps its component (and the assembly) for the destroy to be run again.
+func (c *Carton) Destroy() error {
+	return c.enforce(POLICY_DESTROY, func() error {
+		var mu sync.Mutex
+		left := make(map[string]error)
+		units := make(map[string]int)
+		for _, b := range *c.Boxes {
+			units[b.Id]++
+		}
+		r := c.eachBoxReverse(func(b *provision.Box) error {
+			if err := c.Unbind(b); err != nil {
+				return err
+			}
+			err := Destroy(&DestroyOpts{B: b})
+			if _, ok := err.(*CleanupError); err != nil && !ok {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if units[b.Id]--; units[b.Id] == 0 {
+				err = joinCleanups(err, RemoveBox(b))
+			}
+			if err != nil {
+				left[unitName(b)] = err
+			}
+			return nil
+		})
+		for _, res := range r {
+			if err, ok := left[res.Name]; ok {
+				res.Err = err
+			}
+		}
+		return r.Err()
+	})
 }
 
 // moves the state to the desired state
 // changing the boxes state to StatusStateup.
 func (c *Carton) Stateup() error {
-	for _, box := range *c.Boxes {
-		err := ChangeState(&StateChangeOpts{B: &box, Changed: utils.StatusStateup})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.eachBox(func(b *provision.Box) error {
+		return ChangeState(&StateChangeOpts{B: b, Changed: utils.StatusStateup})
+	}).Err()
+}
+
+// Statedown brings down the state of all the boxes, the reverse of Stateup.
+func (c *Carton) Statedown() error {
+	return c.eachBoxReverse(func(b *provision.Box) error {
+		return setBoxStatus(b, ioutil.Discard, provision.StatusStatedown)
+	}).Err()
 }
 
 // Available returns true if at least one of N boxes which is started
@@ -116,48 +233,69 @@ func (c *Carton) Available() bool {
 
 //upgrade run thru all the ops.
 func (c *Carton) Upgrade() error {
-	for _, box := range *c.Boxes {
-		err := NewUpgradeable(&box).Upgrade()
-		if err != nil {
-			log.Errorf("Unable to upgrade box : %s", err)
+	if err := c.enforce(POLICY_UPGRADE, func() error {
+		return c.eachBox(func(b *provision.Box) error {
+			if err := NewUpgradeable(b).Upgrade(); err != nil {
+				return err
+			}
+			return c.Bind(b)
+		}).Err()
+	}); err != nil {
+		log.Errorf("Unable to upgrade box : %s", err)
+		return err
+	}
+	return nil
+}
+
+//rollback the boxes to their earlier successful deploy.
+func (c *Carton) Rollback() error {
+	if err := c.eachBox(func(b *provision.Box) error {
+		if err := Rollback(&RollbackOpts{B: b}); err != nil {
 			return err
 		}
+		return c.Bind(b)
+	}).Err(); err != nil {
+		log.Errorf("Unable to rollback box : %s", err)
+		return err
 	}
 	return nil
 }
 
 // starts box
 func (c *Carton) Start() error {
-	for _, box := range *c.Boxes {
-		err := Start(&LifecycleOpts{B: &box})
-		if err != nil {
-			log.Errorf("Unable to start the box  %s", err)
-			return err
-		}
+	if err := c.enforce(POLICY_START, func() error {
+		return c.eachBox(func(b *provision.Box) error {
+			return Start(&LifecycleOpts{B: b})
+		}).Err()
+	}); err != nil {
+		log.Errorf("Unable to start the box  %s", err)
+		return err
 	}
 	return nil
 }
 
 // stops the box
 func (c *Carton) Stop() error {
-	for _, box := range *c.Boxes {
-		err := Stop(&LifecycleOpts{B: &box})
-		if err != nil {
-			log.Errorf("Unable to stop the box %s", err)
-			return err
-		}
+	if err := c.enforce(POLICY_STOP, func() error {
+		return c.eachBox(func(b *provision.Box) error {
+			return Stop(&LifecycleOpts{B: b})
+		}).Err()
+	}); err != nil {
+		log.Errorf("Unable to stop the box %s", err)
+		return err
 	}
 	return nil
 }
 
 // restarts the box
 func (c *Carton) Restart() error {
-	for _, box := range *c.Boxes {
-		err := Restart(&LifecycleOpts{B: &box})
-		if err != nil {
-			log.Errorf("Unable to restart the box %s", err)
-			return err
-		}
+	if err := c.enforce(POLICY_RESTART, func() error {
+		return c.eachBox(func(b *provision.Box) error {
+			return Restart(&LifecycleOpts{B: b})
+		}).Err()
+	}); err != nil {
+		log.Errorf("Unable to restart the box %s", err)
+		return err
 	}
 	return nil
 }
@@ -16,14 +16,14 @@
 package carton
 
 import (
-	ldb "github.com/megamsys/libgo/db"
+	"encoding/json"
 	"github.com/megamsys/libgo/pairs"
 	"github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton/bind"
-	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/repository"
 	"gopkg.in/yaml.v2"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -36,6 +36,12 @@ const (
 	IMAGE_VERSION = "version"
 	ONECLICK      = "oneclick"
 	HOSTIP        = "hostip"
+	UNITS         = "units"
+	HOOKID        = "hookid"
+
+	//the output which tells the status of a unit after the first one,
+	//indexed by the unit (unitstatus-1).
+	UNITSTATUS = "unitstatus"
 )
 
 type Artifacts struct {
@@ -94,17 +100,8 @@ func (a *Component) String() string {
 **fetch the component json from riak and parse the json to struct
 **/
 func NewComponent(id string) (*Component, error) {
-	c := &ComponentTable{Id: id}
-	ops := ldb.Options{
-		TableName:   COMPBUCKET,
-		Pks:         []string{"Id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"Id": id},
-		CcmsClauses: make(map[string]interface{}),
-	}
-	if err := ldb.Fetchdb(ops, c); err != nil {
+	c, err := store.GetComponent(id)
+	if err != nil {
 		return nil, err
 	}
 	com, _ := c.dig()
@@ -133,6 +130,9 @@ func (c *Component) mkBox() (provision.Box, error) {
 			URL:      c.Repo.Rurl,
 		}
 		bt.Repo.Hook = BuildHook(c.Operations, repository.CIHOOK)
+		if bt.Repo.Hook != nil {
+			bt.Repo.Hook.Id = c.Outputs.Match(HOOKID)
+		}
 	}
 	return bt, nil
 }
@@ -147,59 +147,43 @@ func (c *Component) SetStatus(status utils.Status) error {
 	update_fields := make(map[string]interface{})
 	update_fields["Inputs"] = c.Inputs.ToString()
 	update_fields["Status"] = status.String()
-	ops := ldb.Options{
-		TableName:   COMPBUCKET,
-		Pks:         []string{"Id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"Id": c.Id},
-		CcmsClauses: make(map[string]interface{}),
-	}
-	if err := ldb.Updatedb(ops, update_fields); err != nil {
+	if err := store.UpdateComponent(c.Id, update_fields); err != nil {
 		return err
 	}
 	return nil
 }
 
-/*func (c *Component) UpdateOpsRun(opsRan upgrade.OperationsRan) error {
-	mutatedOps := make([]*upgrade.Operation, 0, len(opsRan))
-
+//records the operations ran during an upgrade, along with their status.
+func (c *Component) UpdateOpsRun(opsRan []*Operations) error {
+	ops := make([]string, 0, len(opsRan))
 	for _, o := range opsRan {
-		mutatedOps = append(mutatedOps, o.Raw)
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, string(b))
 	}
-	c.Operations = mutatedOps
+	c.Operations = opsRan
 
-	if err := db.Store(COMPBUCKET, c.Id, c); err != nil {
+	update_fields := make(map[string]interface{})
+	update_fields["Operations"] = ops
+	if err := store.UpdateComponent(c.Id, update_fields); err != nil {
 		return err
 	}
 	return nil
-}*/
-
-func (c *Component) Delete(compid string) {
-	ops := ldb.Options{
-		TableName:   COMPBUCKET,
-		Pks:         []string{"id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"id": compid},
-		CcmsClauses: make(map[string]interface{}),
-	}
-	if err := ldb.Deletedb(ops, ComponentTable{}); err != nil {
-		return
-	}
 }
 
-func (c *Component) setDeployData(dd DeployData) error {
-	/*c.Inputs = append(c.Inputs, utils.NewJsonPair("lastsuccessstatusupdate", ""))
-	c.Inputs = append(c.Inputs, utils.NewJsonPair("status", ""))
-
-	if err := db.Store(COMPBUCKET, c.Id, c); err != nil {
-		return err
-	}*/
-	return nil
+func (c *Component) Delete(compid string) error {
+	return store.DeleteComponent(compid)
+}
 
+//the id of the CI hook is kept in the outputs, so that it can be removed
+//when the box is destroyed.
+func (c *Component) setDeployData(dd DeployData) error {
+	if len(strings.TrimSpace(dd.HookId)) == 0 {
+		return nil
+	}
+	return c.NukeAndSetOutputs(map[string][]string{HOOKID: []string{dd.HookId}})
 }
 
 func (a *ComponentTable) dig() (Component, error) {
@@ -231,6 +215,113 @@ func (c *Component) publicIp() string {
 	return c.Outputs.Match(PUBLICIPV4)
 }
 
+//the units wanted for the component as per its inputs, the default is one.
+func (c *Component) units() int {
+	return atoiOrOne(c.Inputs.Match(UNITS))
+}
+
+//the units deployed for the component as per its outputs, the default is one.
+func (c *Component) runningUnits() int {
+	return atoiOrOne(c.Outputs.Match(UNITS))
+}
+
+func (c *Component) setRunningUnits(units int) error {
+	return c.NukeAndSetOutputs(map[string][]string{UNITS: []string{strconv.Itoa(units)}})
+}
+
+//sets the units wanted for the component in its inputs.
+func (c *Component) setUnits(units int) error {
+	c.Inputs.NukeAndSet(map[string][]string{UNITS: []string{strconv.Itoa(units)}})
+	update_fields := make(map[string]interface{})
+	update_fields["Inputs"] = c.Inputs.ToString()
+	return store.UpdateComponent(c.Id, update_fields)
+}
+
+//the units made into boxes, the ones deployed once the component is or else
+//the ones wanted.
+func (c *Component) boxUnits() int {
+	if len(strings.TrimSpace(c.Outputs.Match(UNITS))) > 0 {
+		return c.runningUnits()
+	}
+	return c.units()
+}
+
+//the status of a unit after the first one, blank when it has none yet.
+func (c *Component) unitStatus(unit int) utils.Status {
+	return utils.Status(c.Outputs.Match(provision.UnitKey(UNITSTATUS, unit)))
+}
+
+// SetUnitStatus sets the status of a unit of the component, the first unit
+// is the component itself.
+func (c *Component) SetUnitStatus(unit int, status utils.Status) error {
+	if unit == 0 {
+		return c.SetStatus(status)
+	}
+	return c.NukeAndSetOutputs(map[string][]string{provision.UnitKey(UNITSTATUS, unit): []string{status.String()}})
+}
+
+func atoiOrOne(s string) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+//reads the outputs of the component as saved, the ones read earlier may be stale.
+func (c *Component) savedOutputs() error {
+	saved, err := NewComponent(c.Id)
+	if err != nil {
+		return err
+	}
+	c.Outputs = saved.Outputs
+	return nil
+}
+
+//update outputs in scylla, nuke the matching keys available
+func (c *Component) NukeAndSetOutputs(m map[string][]string) error {
+	if len(m) <= 0 {
+		return provision.ErrNoOutputsFound
+	}
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	if err := c.savedOutputs(); err != nil {
+		return err
+	}
+	c.Outputs.NukeAndSet(m) //just nuke the matching output key:
+	update_fields := make(map[string]interface{})
+	update_fields["Outputs"] = c.Outputs.ToString()
+	if err := store.UpdateComponent(c.Id, update_fields); err != nil {
+		return err
+	}
+	return nil
+}
+
+//nukes the outputs with the keys in scylla, the ones not there are ignored.
+func (c *Component) nukeOutputs(keys ...string) error {
+	nuke := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		nuke[k] = true
+	}
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	if err := c.savedOutputs(); err != nil {
+		return err
+	}
+	left := make(pairs.JsonPairs, 0, len(c.Outputs))
+	for _, o := range c.Outputs {
+		if !nuke[o.K] {
+			left = append(left, o)
+		}
+	}
+	if len(left) == len(c.Outputs) {
+		return nil
+	}
+	c.Outputs = left
+	update_fields := make(map[string]interface{})
+	update_fields["Outputs"] = c.Outputs.ToString()
+	return store.UpdateComponent(c.Id, update_fields)
+}
+
 func (c *Component) withOneClick() bool {
 	return (len(strings.TrimSpace(c.Envs.Match(ONECLICK))) > 0)
 }
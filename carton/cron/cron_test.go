@@ -0,0 +1,52 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+const layout = "2006-01-02 15:04"
+
+func at(s string) time.Time {
+	t, _ := time.Parse(layout, s)
+	return t
+}
+
+func (s *S) next(c *check.C, spec, from, want string) {
+	sc, err := Parse(spec)
+	c.Assert(err, check.IsNil)
+	c.Assert(sc.Next(at(from)).Format(layout), check.Equals, want, check.Commentf("%s from %s", spec, from))
+}
+
+func (s *S) TestNext(c *check.C) {
+	s.next(c, "* * * * *", "2016-03-01 10:00", "2016-03-01 10:01")
+	s.next(c, "*/15 * * * *", "2016-03-01 10:07", "2016-03-01 10:15")
+	s.next(c, "0 20 * * 1-5", "2016-03-04 20:00", "2016-03-07 20:00") //friday to monday
+	s.next(c, "30 7 * * 1,3", "2016-03-01 08:00", "2016-03-02 07:30")
+	s.next(c, "0 0 1 * *", "2016-02-15 00:00", "2016-03-01 00:00")
+	s.next(c, "0 0 29 2 *", "2016-03-01 00:00", "2020-02-29 00:00")
+	s.next(c, "@daily", "2016-12-31 23:59", "2017-01-01 00:00")
+	s.next(c, "0 12 * * 7", "2016-03-01 00:00", "2016-03-06 12:00") //7 is sunday
+	s.next(c, "0 0 13 * 5", "2016-05-02 00:00", "2016-05-06 00:00") //the 13th or a friday
+}
+
+func (s *S) TestNeverComesUp(c *check.C) {
+	sc, err := Parse("0 0 31 2 *")
+	c.Assert(err, check.IsNil)
+	c.Assert(sc.Next(at("2016-01-01 00:00")).IsZero(), check.Equals, true)
+}
+
+func (s *S) TestParseErrors(c *check.C) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(spec)
+		c.Assert(err, check.NotNil, check.Commentf(spec))
+	}
+}
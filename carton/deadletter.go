@@ -0,0 +1,120 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	DEADLETTERSBUCKET = "deadletters"
+	PARKEDBUCKET      = "parked"
+)
+
+var ErrNotParked = errors.New("request isn't parked")
+
+// DeadLetter is a request parked after it ran out of attempts, along with
+// its error and the topic it is replayed on.
+type DeadLetter struct {
+	Id       string `json:"id" cql:"id"` //the id of the request
+	CatId    string `json:"cat_id" cql:"cat_id"`
+	Category string `json:"category" cql:"category"`
+	Action   string `json:"action" cql:"action"`
+	Topic    string `json:"topic" cql:"topic"`
+	Error    string `json:"error" cql:"error"`
+	Attempts int    `json:"attempts" cql:"attempts"`
+	ParkedAt string `json:"parked_at" cql:"parked_at"`
+}
+
+// Park parks the request which ran out of attempts with err, to be replayed
+// on the topic. A request pushed as a value is saved first, so that it can be.
+func (r *Requests) Park(topic string, err error) (*DeadLetter, error) {
+	if !r.tracked() {
+		r.Id = "RIP" + strings.Replace(uuid.NewV1().String(), "-", "", -1)
+		if serr := store.StoreRequest(r); serr != nil {
+			return nil, serr
+		}
+	}
+	r.Status = REQ_PARKED
+	r.Error = err.Error()
+	if uerr := store.UpdateRequest(r.Id, map[string]interface{}{"Status": r.Status, "Error": r.Error}); uerr != nil {
+		return nil, uerr
+	}
+	d := &DeadLetter{
+		Id:       r.Id,
+		CatId:    r.CatId,
+		Category: r.Category,
+		Action:   r.Action,
+		Topic:    topic,
+		Error:    r.Error,
+		Attempts: r.Attempts,
+		ParkedAt: time.Now().Local().Format(time.RFC3339),
+	}
+	log.Warningf("  request (%s, %s %s) parked after %d attempts", r.Id, r.Category, r.Action, r.Attempts)
+	return d, store.StoreDeadLetter(d)
+}
+
+// DeadLetters returns the parked requests.
+func DeadLetters() ([]*DeadLetter, error) {
+	ids, err := store.DeadLetterIds()
+	if err != nil {
+		return nil, err
+	}
+	ds := make([]*DeadLetter, 0, len(ids))
+	for _, id := range ids {
+		d, err := store.GetDeadLetter(id)
+		if err != nil {
+			log.Debugf("no dead letter found for (%s)", id)
+			continue
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// Replay unparks the request and queues it again with publish, on the topic
+// of its dead letter, its attempts start over. The request is queued before
+// it is published for the daemon to run it, the dead letter is removed once
+// it is published and the request is parked again when it can't be.
+func Replay(id string, publish func(d *DeadLetter) error) (*DeadLetter, error) {
+	d, err := store.GetDeadLetter(id)
+	if err != nil {
+		return nil, ErrNotParked
+	}
+	r, err := GetRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != REQ_PARKED {
+		return nil, ErrNotParked
+	}
+	if err := store.UpdateRequest(id, map[string]interface{}{"Status": REQ_QUEUED, "Attempts": 0, "Error": ""}); err != nil {
+		return nil, err
+	}
+	if err := publish(d); err != nil {
+		parked := map[string]interface{}{"Status": REQ_PARKED, "Attempts": r.Attempts, "Error": r.Error}
+		if perr := store.UpdateRequest(id, parked); perr != nil {
+			log.Errorf("  unable to park request (%s) again %s", id, perr)
+		}
+		return nil, err
+	}
+	return d, store.DeleteDeadLetter(id)
+}
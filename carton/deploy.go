@@ -21,6 +21,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/cmd"
+	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/repository"
 	"io"
@@ -28,35 +29,53 @@ import (
 	"time"
 )
 
-const DOCKER_TYPE = "dockercontainer"
+const (
+	DOCKER_TYPE = "dockercontainer"
+
+	//the origins of a deploy.
+	ORIGIN_GIT      = "git"
+	ORIGIN_IMAGE    = "image"
+	ORIGIN_ROLLBACK = "rollback"
+)
 
 type DeployData struct {
-	BoxName     string
-	HookId      string
-	PrivateIp   string
-	PublicIp    string
-	Timestamp   time.Time
-	Duration    time.Duration
-	Commit      string
-	Image       string
-	Origin      string
-	CanRollback bool
+	BoxName     string        `json:"box_name"`
+	HookId      string        `json:"hook_id"`
+	PrivateIp   string        `json:"private_ip"`
+	PublicIp    string        `json:"public_ip"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Duration    time.Duration `json:"duration"`
+	Commit      string        `json:"commit"`
+	Image       string        `json:"image"`
+	Origin      string        `json:"origin"`
+	CanRollback bool          `json:"can_rollback"`
+	Log         string        `json:"log"`
+	Error       string        `json:"error"`
 }
 
 type DeployOpts struct {
-	B *provision.Box
+	B       *provision.Box
+	Image   string //when set, the box is deployed from this image (rollback)
+	InPlace bool   //the box is replaced, not launched next to the one running
 }
 
 // Deploy runs a deployment of an application. It will first try to run an
 // image based deploy, and then fallback to the Git based deployment.
 func Deploy(opts *DeployOpts) error {
+	if err := opts.B.CanMove(constants.StatusLaunching); err != nil {
+		return err
+	}
 	var outBuffer bytes.Buffer
 	start := time.Now()
 	logWriter := LogWriter{Box: opts.B}
 	logWriter.Async()
 	defer logWriter.Close()
 	writer := io.MultiWriter(&outBuffer, &logWriter)
-	imageId, err := deployToProvisioner(opts, writer)
+	var imageId string
+	err := opts.B.Move(constants.StatusLaunching, func() (err error) {
+		imageId, err = deployToProvisioner(opts, writer)
+		return err
+	})
 	elapsed := time.Since(start)
 	saveErr := saveDeployData(opts, imageId, outBuffer.String(), elapsed, err)
 	if saveErr != nil {
@@ -69,6 +88,23 @@ func Deploy(opts *DeployOpts) error {
 }
 
 func deployToProvisioner(opts *DeployOpts, writer io.Writer) (string, error) {
+	if opts.InPlace {
+		replacer, ok := ProvisionerMap[opts.B.Provider].(provision.Replacer)
+		if !ok {
+			return "", ErrNotReplaceable
+		}
+		img := opts.Image
+		if img == "" && (opts.B.Repo == nil || opts.B.Repo.Type == repository.IMAGE || opts.B.Repo.OneClick) {
+			img = image(opts.B)
+		}
+		return replacer.Replace(opts.B, img, writer)
+	}
+	if opts.Image != "" {
+		if deployer, ok := ProvisionerMap[opts.B.Provider].(provision.ImageDeployer); ok {
+			return deployer.ImageDeploy(opts.B, opts.Image, writer)
+		}
+		return "", ErrRollbackNotSupported
+	}
 	if opts.B.Repo == nil || opts.B.Repo.Type == repository.IMAGE || opts.B.Repo.OneClick {
 		if deployer, ok := ProvisionerMap[opts.B.Provider].(provision.ImageDeployer); ok {
 			return deployer.ImageDeploy(opts.B, image(opts.B), writer)
@@ -99,31 +135,26 @@ func saveDeployData(opts *DeployOpts, imageId, dlog string, duration time.Durati
 		cmd.Colorfy(opts.B.GetFullName(), "cyan", "", "bold"),
 		cmd.Colorfy(duration.String(), "green", "", "bold"),
 		cmd.Colorfy(dlog, "yellow", "", ""))
-	//if there are deployments to track as follows in outputs: {} then do it here.
-	//Scylla: code to save the status of a deploy (created.)
-	// deploy :
-	//     name:
-	//     status:
-
-	/*deploy := DeployData {
-		App:       opts.App.Name,
+	deploy := DeployData{
+		BoxName:   opts.B.GetFullName(),
+		PublicIp:  opts.B.PublicIp,
 		Timestamp: time.Now(),
 		Duration:  duration,
-		Commit:    opts.Commit,
+		Commit:    opts.B.Commit,
 		Image:     imageId,
-		Log:       log,
+		Log:       dlog,
 	}
-	if opts.Commit != "" {
-		deploy.Origin = "git"
-	} else if opts.Image != "" {
-		deploy.Origin = "rollback"
+	if opts.Image != "" {
+		deploy.Origin = ORIGIN_ROLLBACK
+	} else if opts.B.Repo != nil && opts.B.Repo.Type == repository.GIT {
+		deploy.Origin = ORIGIN_GIT
 	} else {
-		deploy.Origin = "app-deploy"
+		deploy.Origin = ORIGIN_IMAGE
 	}
 	if deployError != nil {
 		deploy.Error = deployError.Error()
+	} else {
+		deploy.CanRollback = len(strings.TrimSpace(imageId)) > 0
 	}
-	return db.Store(compid or assmid, &struct)
-	*/
-	return nil
+	return StoreDeploy(opts.B.CartonId, opts.B.UnitId(), &deploy)
 }
@@ -0,0 +1,91 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+)
+
+const DEPLOYSBUCKET = "deploys"
+
+//The deploy history of a box, keyed by the assembly and the component.
+//Every deploy is stored as a json string, the latest one being the last.
+type Deploys struct {
+	AssemblyId  string   `json:"assembly_id" cql:"assembly_id"`
+	ComponentId string   `json:"component_id" cql:"component_id"`
+	Deploys     []string `json:"deploys" cql:"deploys"`
+}
+
+//fetch the deploy history of a component in an assembly.
+func getDeploys(asmid, compid string) (*Deploys, error) {
+	return store.GetDeploys(asmid, compid)
+}
+
+//StoreDeploy appends a deploy to the history of a component in an assembly.
+//The history is started only when there is none.
+func StoreDeploy(asmid, compid string, dd *DeployData) error {
+	b, err := json.Marshal(dd)
+	if err != nil {
+		return err
+	}
+
+	d, err := getDeploys(asmid, compid)
+	switch {
+	case err == ErrNotFound:
+		log.Debugf("no deploys found for (%s, %s), starting afresh.", asmid, compid)
+		d = &Deploys{AssemblyId: asmid, ComponentId: compid}
+	case err != nil:
+		//a failed read isn't an empty history, storing over it would lose it.
+		return err
+	}
+	d.Deploys = append(d.Deploys, string(b))
+
+	if err := store.StoreDeploys(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+//ListDeploys returns the deploy history of a component in an assembly,
+//oldest first.
+func ListDeploys(asmid, compid string) ([]*DeployData, error) {
+	d, err := getDeploys(asmid, compid)
+	if err != nil {
+		return nil, err
+	}
+	return d.list(), nil
+}
+
+//DeployHistory returns the deploy history of a box, every unit has its own.
+func DeployHistory(b *provision.Box) ([]*DeployData, error) {
+	return ListDeploys(b.CartonId, b.UnitId())
+}
+
+func (d *Deploys) list() []*DeployData {
+	keys := make([]*DeployData, 0, len(d.Deploys))
+	for _, in := range d.Deploys {
+		dd := DeployData{}
+		if err := parseStringToStruct(in, &dd); err != nil {
+			log.Errorf("Unparsable deploy, ignoring: %s", in)
+			continue
+		}
+		keys = append(keys, &dd)
+	}
+	return keys
+}
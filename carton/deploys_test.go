@@ -0,0 +1,53 @@
+package carton
+
+import (
+	"errors"
+
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type DeploysSuite struct {
+	m *MemRepository
+}
+
+var _ = check.Suite(&DeploysSuite{})
+
+func (s *DeploysSuite) SetUpTest(c *check.C) {
+	s.m = NewMemRepository()
+	SetRepository(s.m)
+}
+
+func (s *DeploysSuite) TestStoreDeployAppends(c *check.C) {
+	c.Assert(StoreDeploy("ASM001", "CMP001", &DeployData{Image: "web:1", CanRollback: true}), check.IsNil)
+	c.Assert(StoreDeploy("ASM001", "CMP001", &DeployData{Image: "web:2", Error: "boom"}), check.IsNil)
+	c.Assert(StoreDeploy("ASM001", "CMP002", &DeployData{Image: "db:1"}), check.IsNil)
+	ds, err := DeployHistory(&provision.Box{CartonId: "ASM001", Id: "CMP001"})
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 2)
+	c.Assert(ds[0].Image, check.Equals, "web:1")
+	c.Assert(ds[0].CanRollback, check.Equals, true)
+	c.Assert(ds[1].Image, check.Equals, "web:2")
+	c.Assert(ds[1].Error, check.Equals, "boom")
+}
+
+func (s *DeploysSuite) TestStoreDeployKeepsTheHistoryOnAFailedRead(c *check.C) {
+	c.Assert(StoreDeploy("ASM001", "CMP001", &DeployData{Image: "web:1"}), check.IsNil)
+	s.m.FailReads(errors.New("no hosts available"))
+	c.Assert(StoreDeploy("ASM001", "CMP001", &DeployData{Image: "web:2"}), check.ErrorMatches, "no hosts available")
+	s.m.FailReads(nil)
+	ds, err := ListDeploys("ASM001", "CMP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+	c.Assert(ds[0].Image, check.Equals, "web:1")
+}
+
+func (s *DeploysSuite) TestListDeploysSkipsTheUnparsable(c *check.C) {
+	c.Assert(s.m.StoreDeploys(&Deploys{AssemblyId: "ASM001", ComponentId: "CMP001", Deploys: []string{"{", `{"image":"web:1"}`}}), check.IsNil)
+	ds, err := ListDeploys("ASM001", "CMP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+	c.Assert(ds[0].Image, check.Equals, "web:1")
+	_, err = ListDeploys("ASM001", "CMP009")
+	c.Assert(err, check.Equals, ErrNotFound)
+}
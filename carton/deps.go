@@ -0,0 +1,200 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+)
+
+var ErrDependencyFailed = errors.New("skipped, a related component failed")
+
+// CycleError is returned when the related components of an assembly, or the
+// assemblies, depend on each other.
+type CycleError struct {
+	Members []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle among components [%s]", strings.Join(e.Members, ", "))
+}
+
+//depLevels groups the boxes (by index) into levels using their related components.
+//A box is placed in a level after every box it relates to, so the boxes of a
+//level can be operated upon together. A related component is matched by its
+//id or name, with all of its units. The ones which aren't in the boxes are
+//left to cartonLevels, which orders the assemblies they are in.
+func depLevels(boxes []provision.Box, related [][]string) ([][]int, error) {
+	index := boxIndex(boxes)
+	deps := make([][]int, len(boxes))
+	for i, rels := range related {
+		seen := make(map[int]bool)
+		for _, r := range rels {
+			js, ok := index[strings.TrimSpace(r)]
+			if !ok {
+				log.Debugf("  related component %s of %s isn't in the assembly, left to the assemblies order", r, boxes[i].Name)
+				continue
+			}
+			for _, j := range js {
+				if boxes[j].Id == boxes[i].Id {
+					return nil, &CycleError{Members: []string{boxes[i].Name}}
+				}
+				if !seen[j] {
+					seen[j] = true
+					deps[i] = append(deps[i], j)
+				}
+			}
+		}
+	}
+	levels, cycle := sortLevels(deps)
+	if len(cycle) > 0 {
+		members := make([]string, 0, len(cycle))
+		for _, i := range cycle {
+			members = append(members, boxes[i].Name)
+		}
+		return nil, &CycleError{Members: members}
+	}
+	return levels, nil
+}
+
+//the boxes (by index) by their component id and name.
+func boxIndex(boxes []provision.Box) map[string][]int {
+	index := make(map[string][]int, 2*len(boxes))
+	for i, b := range boxes {
+		index[b.Id] = append(index[b.Id], i)
+		if b.Name != b.Id {
+			index[b.Name] = append(index[b.Name], i)
+		}
+	}
+	return index
+}
+
+//cartonLevels groups the cartons (by index) into levels like depLevels does
+//the boxes. A carton is placed after every other carton holding a component
+//its boxes relate to, a related component in its own boxes is left to
+//depLevels. The cartons each one depends on are returned with the levels.
+func cartonLevels(ca Cartons) ([][]int, [][]int, error) {
+	index := make([]map[string][]int, len(ca))
+	for i, c := range ca {
+		if c.Boxes != nil {
+			index[i] = boxIndex(*c.Boxes)
+		}
+	}
+	deps := make([][]int, len(ca))
+	for i, c := range ca {
+		if c.Boxes == nil {
+			continue
+		}
+		seen := make(map[int]bool)
+		for _, b := range *c.Boxes {
+			for _, r := range b.Related {
+				r = strings.TrimSpace(r)
+				if _, ok := index[i][r]; ok {
+					continue
+				}
+				for j := range ca {
+					if _, ok := index[j][r]; ok && j != i && !seen[j] {
+						seen[j] = true
+						deps[i] = append(deps[i], j)
+					}
+				}
+			}
+		}
+	}
+	levels, cycle := sortLevels(deps)
+	if len(cycle) > 0 {
+		members := make([]string, 0, len(cycle))
+		for _, i := range cycle {
+			members = append(members, ca[i].Name)
+		}
+		return nil, nil, &CycleError{Members: members}
+	}
+	return levels, deps, nil
+}
+
+//sorts the nodes (by index) into levels, a node after every node it depends
+//on. The nodes left out by a cycle are returned when there is one.
+func sortLevels(deps [][]int) ([][]int, []int) {
+	indegree := make([]int, len(deps))
+	dependents := make([][]int, len(deps))
+	for i, js := range deps {
+		indegree[i] = len(js)
+		for _, j := range js {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	levels := make([][]int, 0)
+	current := make([]int, 0)
+	for i := range deps {
+		if indegree[i] == 0 {
+			current = append(current, i)
+		}
+	}
+	placed := 0
+	for len(current) > 0 {
+		levels = append(levels, current)
+		placed += len(current)
+		next := make([]int, 0)
+		for _, j := range current {
+			for _, i := range dependents[j] {
+				indegree[i]--
+				if indegree[i] == 0 {
+					next = append(next, i)
+				}
+			}
+		}
+		sort.Ints(next)
+		current = next
+	}
+
+	cycle := make([]int, 0)
+	if placed < len(deps) {
+		for i := range deps {
+			if indegree[i] > 0 {
+				cycle = append(cycle, i)
+			}
+		}
+	}
+	return levels, cycle
+}
+
+//dependents turns the dependencies of every node around, into the nodes
+//which depend on it.
+func dependents(deps [][]int) [][]int {
+	d := make([][]int, len(deps))
+	for i, js := range deps {
+		for _, j := range js {
+			d[j] = append(d[j], i)
+		}
+	}
+	return d
+}
+
+//reverse returns the levels last first, used to tear down the dependents
+//before what they depend on.
+func reverse(levels [][]int) [][]int {
+	r := make([][]int, len(levels))
+	for i, l := range levels {
+		r[len(levels)-1-i] = l
+	}
+	return r
+}
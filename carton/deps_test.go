@@ -0,0 +1,90 @@
+package carton
+
+import (
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type DepsSuite struct{}
+
+var _ = check.Suite(&DepsSuite{})
+
+func (s *DepsSuite) boxes(names ...string) []provision.Box {
+	b := make([]provision.Box, len(names))
+	for i, n := range names {
+		b[i] = provision.Box{Id: "CMP" + n, Name: n}
+	}
+	return b
+}
+
+func (s *DepsSuite) TestDepLevels(c *check.C) {
+	boxes := s.boxes("web", "db", "cache", "worker")
+	related := [][]string{
+		[]string{"db", "CMPcache"},
+		[]string{},
+		[]string{"db"},
+		[]string{"web", "elsewhere"},
+	}
+	levels, err := depLevels(boxes, related)
+	c.Assert(err, check.IsNil)
+	c.Assert(levels, check.DeepEquals, [][]int{[]int{1}, []int{2}, []int{0}, []int{3}})
+	c.Assert(reverse(levels), check.DeepEquals, [][]int{[]int{3}, []int{0}, []int{2}, []int{1}})
+}
+
+func (s *DepsSuite) TestDepLevelsWithoutRelations(c *check.C) {
+	levels, err := depLevels(s.boxes("web", "db"), [][]string{nil, nil})
+	c.Assert(err, check.IsNil)
+	c.Assert(levels, check.DeepEquals, [][]int{[]int{0, 1}})
+}
+
+func (s *DepsSuite) TestDepLevelsCycle(c *check.C) {
+	boxes := s.boxes("web", "db", "cache")
+	related := [][]string{
+		[]string{"db"},
+		[]string{"web"},
+		nil,
+	}
+	_, err := depLevels(boxes, related)
+	c.Assert(err, check.FitsTypeOf, &CycleError{})
+	c.Assert(err.Error(), check.Equals, "dependency cycle among components [web, db]")
+}
+
+func (s *DepsSuite) TestDepLevelsWithUnits(c *check.C) {
+	boxes := s.boxes("web", "web", "db")
+	boxes[1].Unit = 1
+	related := [][]string{
+		[]string{"db"},
+		[]string{"db"},
+		nil,
+	}
+	levels, err := depLevels(boxes, related)
+	c.Assert(err, check.IsNil)
+	c.Assert(levels, check.DeepEquals, [][]int{[]int{2}, []int{0, 1}})
+	c.Assert(unitName(&boxes[0]), check.Equals, "web")
+	c.Assert(unitName(&boxes[1]), check.Equals, "web-1")
+}
+
+func (s *DepsSuite) carton(name string, boxes []provision.Box) *Carton {
+	return &Carton{Id: "ASM" + name, Name: name, Boxes: &boxes}
+}
+
+func (s *DepsSuite) TestCartonLevels(c *check.C) {
+	app := s.boxes("web", "cache")
+	app[0].Related = []string{"CMPdb", "cache"}
+	data := s.boxes("db")
+	ca := Cartons{s.carton("app", app), s.carton("data", data), s.carton("tools", s.boxes("cron"))}
+	levels, deps, err := cartonLevels(ca)
+	c.Assert(err, check.IsNil)
+	c.Assert(levels, check.DeepEquals, [][]int{[]int{1, 2}, []int{0}})
+	c.Assert(deps, check.DeepEquals, [][]int{[]int{1}, nil, nil})
+	c.Assert(dependents(deps), check.DeepEquals, [][]int{nil, []int{0}, nil})
+}
+
+func (s *DepsSuite) TestCartonLevelsCycle(c *check.C) {
+	app, data := s.boxes("web"), s.boxes("db")
+	app[0].Related = []string{"db"}
+	data[0].Related = []string{"web"}
+	_, _, err := cartonLevels(Cartons{s.carton("app", app), s.carton("data", data)})
+	c.Assert(err, check.FitsTypeOf, &CycleError{})
+	c.Assert(err.Error(), check.Equals, "dependency cycle among components [app, data]")
+}
@@ -2,10 +2,15 @@ package carton
 
 import (
 	"bytes"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/cmd"
+	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/repository"
 	"io"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,54 +18,187 @@ type DestroyOpts struct {
 	B *provision.Box
 }
 
-// ChangeState runs a state increment of a machine or a container.
+// CleanupError is returned when a box was destroyed, but some of the
+// artifacts it left behind couldn't be removed.
+type CleanupError struct {
+	Box    string
+	Report Report
+}
+
+func (e *CleanupError) Error() string {
+	failed := e.Report.Failures()
+	msgs := make([]string, 0, len(failed))
+	for _, res := range failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", res.Name, res.Err))
+	}
+	return fmt.Sprintf("box (%s) destroyed, unable to remove %d of %d artifacts [%s]", e.Box, len(failed), len(e.Report), strings.Join(msgs, "; "))
+}
+
+type cleanup struct {
+	name string
+	fn   func(b *provision.Box) error
+}
+
+//the artifacts a destroyed unit leaves behind, removed in this order. The
+//snapshots go once the states are, as the vm of the unit is forgotten then.
+var cleanups = []cleanup{
+	{"deploys", removeDeploys},
+	{"states", markStatesAsRemoved},
+	{"status", removeUnitStatus},
+	{"snapshots", removeSnapshots},
+	{"quota", func(b *provision.Box) error { return releaseHeld(b.AccountsId, b) }},
+}
+
+//the artifacts a box leaves behind once the last of its units is destroyed,
+//removed in this order.
+var boxCleanups = []cleanup{
+	{"hook", removeHook},
+	{"component", removeComponent},
+	{"assembly", markDeploysAsRemoved},
+	{"expiry", func(b *provision.Box) error { return RemoveExpiry(b.CartonsId) }},
+}
+
+//the boxes of a level are destroyed at once, and share their assembly.
+var removeMu sync.Mutex
+
+// Destroy destroys a machine or a container (a unit of a box), along with
+// what it left behind. The box itself is removed by RemoveBox once the last
+// of its units is destroyed.
 func Destroy(opts *DestroyOpts) error {
+	if err := opts.B.CanMove(constants.StatusDestroying); err != nil {
+		return err
+	}
 	var outBuffer bytes.Buffer
 	start := time.Now()
 	logWriter := LogWriter{Box: opts.B}
 	logWriter.Async()
 	defer logWriter.Close()
 	writer := io.MultiWriter(&outBuffer, &logWriter)
-	err := ProvisionerMap[opts.B.Provider].Destroy(opts.B, writer)
+	err := opts.B.Move(constants.StatusDestroying, func() error {
+		return ProvisionerMap[opts.B.Provider].Destroy(opts.B, writer)
+	})
 	elapsed := time.Since(start)
 	saveErr := saveDestroyedData(opts, outBuffer.String(), elapsed, err)
-	if saveErr != nil {
-		log.Errorf("WARNING: couldn't save destroyed data, destroy opts: %#v", opts)
-	}
 	if err != nil {
 		return err
 	}
-	return nil
+	return saveErr
 }
 
+//once destroyed, removes every artifact of the box and reports the ones which
+//couldn't be.
 func saveDestroyedData(opts *DestroyOpts, slog string, duration time.Duration, destroyError error) error {
 	log.Debugf("%s in (%s)\n%s",
 		cmd.Colorfy(opts.B.GetFullName(), "cyan", "", "bold"),
 		cmd.Colorfy(duration.String(), "green", "", "bold"),
 		cmd.Colorfy(slog, "yellow", "", ""))
-	if destroyError == nil {
-		markDeploysAsRemoved(opts)
+	if destroyError != nil {
+		return nil
 	}
-	return nil
+	return cleanUp(opts.B, cleanups)
 }
 
-func markDeploysAsRemoved(opts *DestroyOpts) {
-	removedAssemblys := make([]string, 1)
+// RemoveBox removes what a box leaves behind once the last of its units is
+// destroyed: its component, and along with the last component the assembly.
+func RemoveBox(b *provision.Box) error {
+	return cleanUp(b, boxCleanups)
+}
+
+//runs the cleanups and reports the ones which failed.
+func cleanUp(b *provision.Box, cs []cleanup) error {
+	r := make(Report, 0, len(cs))
+	for _, c := range cs {
+		err := c.fn(b)
+		if err != nil {
+			log.Errorf("  unable to remove the %s of box (%s) %s", c.name, b.GetFullName(), err)
+		}
+		r = append(r, &Result{Name: c.name, Err: err})
+	}
+	if len(r.Failures()) > 0 {
+		return &CleanupError{Box: b.GetFullName(), Report: r}
+	}
+	return nil
+}
 
-	if _, err := NewAssembly(opts.B.CartonId); err == nil {
-		//if asm, err := NewAssembly(opts.B.CartonId); err == nil {
-		removedAssemblys[0] = opts.B.CartonId
-		//asm.Delete(opts.B.CartonId)
+//the reports of the cleanups of a unit and of its box as one.
+func joinCleanups(errs ...error) error {
+	var joined *CleanupError
+	for _, err := range errs {
+		e, ok := err.(*CleanupError)
+		if !ok {
+			continue
+		}
+		if joined == nil {
+			joined = &CleanupError{Box: e.Box}
+		}
+		joined.Report = append(joined.Report, e.Report...)
 	}
+	if joined == nil {
+		return nil
+	}
+	return joined
+}
 
-	if opts.B.Level == provision.BoxSome {
-		if comp, err := NewComponent(opts.B.Id); err == nil {
-			comp.Delete(opts.B.Id)
+//the snapshots of the unit, along with what its provisioner kept of them.
+func removeSnapshots(b *provision.Box) error {
+	ss, err := ListSnapshots(b.CartonId)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, sn := range ss {
+		if sn.BoxId != b.Id || sn.Unit != b.Unit {
+			continue
+		}
+		if err := DeleteSnapshot(b.CartonId, sn.Id); err != nil && err != ErrSnapshotNotFound {
+			return err
 		}
 	}
+	return nil
+}
 
-	if asms, err := Get(opts.B.CartonsId); err == nil {
-		asms.Delete(opts.B.CartonsId, removedAssemblys)
+//the CI hook of a box pointing to its trigger.
+func removeHook(b *provision.Box) error {
+	if b.Level != provision.BoxSome || b.Repo == nil || !b.Repo.IsEnabled() {
+		return nil
 	}
+	return repository.Manager(b.Repo.GetSource()).RemoveHook(b.Repo)
+}
 
+func removeDeploys(b *provision.Box) error {
+	return store.DeleteDeploys(b.CartonId, b.UnitId())
+}
+
+func removeComponent(b *provision.Box) error {
+	if b.Level != provision.BoxSome {
+		return nil
+	}
+	return store.DeleteComponent(b.Id)
+}
+
+//the assembly goes along with the last of its boxes, and the assemblies
+//along with the last of its assembly.
+func markDeploysAsRemoved(b *provision.Box) error {
+	removeMu.Lock()
+	defer removeMu.Unlock()
+	asm, err := NewAmbly(b.CartonId)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if left := asm.componentsBut(b.Id); len(left) > 0 {
+		return store.UpdateAssembly(asm.Id, asm.OrgId, map[string]interface{}{"Components": left})
+	}
+	if err := store.DeleteAssembly(asm.Id, asm.OrgId); err != nil {
+		return err
+	}
+	asms, err := Get(b.CartonsId)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return asms.Delete(b.CartonId)
 }
@@ -0,0 +1,99 @@
+package carton
+
+import (
+	"encoding/json"
+	"errors"
+
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/repository"
+	"gopkg.in/check.v1"
+)
+
+//a repository manager which records the hooks created and removed, a hook
+//created gets the id.
+type fakeHooks struct {
+	id      string
+	created int
+	removed []string
+	err     error
+}
+
+func (m *fakeHooks) CreateHook(r repository.Repository) (string, error) {
+	m.created++
+	return m.id, nil
+}
+
+func (m *fakeHooks) RemoveHook(r repository.Repository) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.removed = append(m.removed, r.GetHookId())
+	return nil
+}
+
+//web gets a CI hook which is removed by the fake manager.
+func (s *FlowSuite) hook(c *check.C, m *fakeHooks) {
+	repository.Register("fakehook", m)
+	op, _ := json.Marshal(Operations{
+		Type:       repository.CIHOOK,
+		Properties: JsonPairs{NewJsonPair(repository.TOKEN, "secret")},
+	})
+	c.Assert(s.repo.UpdateComponent("CMPweb", map[string]interface{}{
+		"Repo":       `{"rtype":"image","source":"fakehook"}`,
+		"Operations": []string{string(op)},
+		"Outputs":    []string{pair(HOOKID, "42")},
+	}), check.IsNil)
+}
+
+func (s *FlowSuite) TestDestroyRemovesEveryArtifact(c *check.C) {
+	m := &fakeHooks{}
+	s.hook(c, m)
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	s.run(c, "RIP002", STATE, DESTROY)
+
+	c.Assert(m.removed, check.DeepEquals, []string{"42"})
+	_, err := ListDeploys("ASM001", "CMPdb")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = ListDeploys("ASM001", "CMPweb")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = NewAmbly("ASM001")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = Get("AMS001")
+	c.Assert(err, check.Equals, ErrNotFound)
+}
+
+func (s *FlowSuite) TestDestroyReportsWhatIsLeft(c *check.C) {
+	s.hook(c, &fakeHooks{err: errors.New("bad credentials")})
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+
+	var p MegdProcessor = DestroyProcess{Name: "AMS001"}
+	err := NewReqOperator("AMS001").Accept(&p)
+	c.Assert(err, check.NotNil)
+	c.Assert(Transient(err), check.Equals, false)
+	failed := err.(*ReportError).Report.Failures()
+	c.Assert(failed, check.HasLen, 1)
+	e, ok := failed[0].Err.(*ReportError).Report.Failures()[0].Err.(*CleanupError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Report.Failures(), check.HasLen, 1)
+	c.Assert(e.Report.Failures()[0].Name, check.Equals, "hook")
+
+	_, err = NewComponent("CMPweb")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = NewAmbly("ASM001")
+	c.Assert(err, check.Equals, ErrNotFound)
+}
+
+func (s *FlowSuite) TestMarkStatesAsRemoved(c *check.C) {
+	c.Assert(s.repo.UpdateAssembly("ASM001", "ORG001", map[string]interface{}{
+		"Outputs": []string{pair(VMID, "12"), pair(VNCHOST, "10.0.0.9"), pair(VNCPORT, "5900"), pair(SNAPSHOTS, "[]")},
+	}), check.IsNil)
+	c.Assert(markStatesAsRemoved(&provision.Box{CartonId: "ASM001"}), check.IsNil)
+	asm, err := NewAmbly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(asm.Outputs, check.HasLen, 1)
+	c.Assert(asm.getOutputs().Match(SNAPSHOTS), check.Equals, "[]")
+	c.Assert(markStatesAsRemoved(&provision.Box{CartonId: "ASM404"}), check.IsNil)
+}
@@ -0,0 +1,231 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/events"
+	"github.com/megamsys/libgo/events/alerts"
+	"github.com/megamsys/libgo/pairs"
+	constants "github.com/megamsys/libgo/utils"
+)
+
+const (
+	EXPIRIESBUCKET = "expiries"
+	EXPIRINGBUCKET = "expiring"
+
+	//the input of an assembly which expires after launch, eg: 72h
+	TTL = "ttl"
+
+	//the status in the event of an assembly about to expire, and expired.
+	EXPIRING = "expiring"
+	EXPIRED  = "expired"
+)
+
+//guards the read, change and save of the expiry of an assembly.
+var expiryMu sync.Mutex
+
+// Expiry is when an assembly is destroyed, along with the warnings (as the
+// lead before the expiry, eg: 24h0m0s) its owner was sent. It is keyed by
+// the assemblies (CatId) the destroy request is made on. The expiry stays
+// till the destroy (RequestId) succeeds, the destroy removes it.
+type Expiry struct {
+	CatId      string   `json:"cat_id" cql:"cat_id"`
+	AccountsId string   `json:"account_id" cql:"account_id"`
+	Name       string   `json:"name" cql:"name"`
+	ExpiresAt  string   `json:"expires_at" cql:"expires_at"`
+	Warned     []string `json:"warned" cql:"warned"`
+	RequestId  string   `json:"request_id" cql:"request_id"`
+}
+
+// GetExpiry returns the expiry of the assembly.
+func GetExpiry(catId string) (*Expiry, error) {
+	return store.GetExpiry(catId)
+}
+
+// SetExpiry makes the assembly expire at the time, the owner is warned
+// afresh.
+func SetExpiry(catId string, at time.Time) (*Expiry, error) {
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+	a, err := store.GetAssemblies(catId)
+	if err != nil {
+		return nil, err
+	}
+	e := &Expiry{
+		CatId:      catId,
+		AccountsId: a.AccountsId,
+		Name:       a.Name,
+		ExpiresAt:  at.Format(time.RFC3339),
+		Warned:     []string{},
+	}
+	return e, store.StoreExpiry(e)
+}
+
+// ExtendExpiry pushes the expiry of the assembly further by the duration.
+func ExtendExpiry(catId string, by time.Duration) (*Expiry, error) {
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+	e, err := store.GetExpiry(catId)
+	if err != nil {
+		return nil, err
+	}
+	at, err := e.At()
+	if err != nil {
+		return nil, err
+	}
+	e.ExpiresAt = at.Add(by).Format(time.RFC3339)
+	e.Warned = []string{}
+	return e, store.StoreExpiry(e)
+}
+
+// RemoveExpiry makes the assembly never expire.
+func RemoveExpiry(catId string) error {
+	return store.DeleteExpiry(catId)
+}
+
+// ExpiringIds returns the assemblies which expire.
+func ExpiringIds() ([]string, error) {
+	return store.ExpiringIds()
+}
+
+// At returns when the assembly expires.
+func (e *Expiry) At() (time.Time, error) {
+	return time.Parse(time.RFC3339, e.ExpiresAt)
+}
+
+// Due returns the leads which have come up at now and the owner wasn't
+// warned of yet.
+func (e *Expiry) Due(leads []time.Duration, now time.Time) []time.Duration {
+	at, err := e.At()
+	if err != nil {
+		return nil
+	}
+	due := make([]time.Duration, 0)
+	for _, l := range leads {
+		if !now.Add(l).Before(at) && !e.warned(l) {
+			due = append(due, l)
+		}
+	}
+	return due
+}
+
+func (e *Expiry) warned(lead time.Duration) bool {
+	for _, w := range e.Warned {
+		if w == lead.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkWarned marks the leads as warned of, unless the expiry was changed
+// meanwhile.
+func (e *Expiry) MarkWarned(leads []time.Duration) error {
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+	cur, err := store.GetExpiry(e.CatId)
+	if err != nil {
+		return err
+	}
+	if cur.ExpiresAt != e.ExpiresAt {
+		return nil
+	}
+	for _, l := range leads {
+		cur.Warned = append(cur.Warned, l.String())
+	}
+	e.Warned = cur.Warned
+	return store.StoreExpiry(cur)
+}
+
+// Expire saves a state/destroy request for the expired assembly, which is
+// queued for the daemons the usual way.
+func (e *Expiry) Expire() (*Requests, error) {
+	return enqueue(EXPIRED, e.CatId, STATE, DESTROY)
+}
+
+// Destroying returns the status of the destroy request of the expired
+// assembly, blank when none was queued (or it is gone).
+func (e *Expiry) Destroying() (string, error) {
+	if len(e.RequestId) == 0 {
+		return "", nil
+	}
+	r, err := GetRequest(e.RequestId)
+	if err == ErrNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if len(r.Status) == 0 {
+		return REQ_QUEUED, nil
+	}
+	return r.Status, nil
+}
+
+// MarkExpired saves the destroy request queued for the expired assembly,
+// unless the expiry was changed meanwhile.
+func (e *Expiry) MarkExpired(reqId string) error {
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+	cur, err := store.GetExpiry(e.CatId)
+	if err != nil {
+		return err
+	}
+	if cur.ExpiresAt != e.ExpiresAt {
+		return nil
+	}
+	cur.RequestId = reqId
+	e.RequestId = reqId
+	return store.StoreExpiry(cur)
+}
+
+// Event tells the owner of the assembly about its expiry.
+func (e *Expiry) Event(status, description string) error {
+	mi := make(map[string]string)
+	js := make(pairs.JsonPairs, 0)
+	m := make(map[string][]string, 2)
+	m["status"] = []string{status}
+	m["description"] = []string{description}
+	js.NukeAndSet(m) //just nuke the matching output key:
+
+	mi[constants.ASSEMBLY_ID] = e.CatId
+	mi[constants.ACCOUNT_ID] = e.AccountsId
+
+	newEvent := events.NewMulti(
+		[]*events.Event{
+			&events.Event{
+				AccountsId:  e.AccountsId,
+				EventAction: alerts.STATUS,
+				EventType:   constants.EventUser,
+				EventData:   alerts.EventData{M: mi, D: js.ToString()},
+				Timestamp:   time.Now().Local(),
+			},
+		})
+	return newEvent.Write()
+}
+
+//the assembly launched with a ttl expires after it.
+func (c *Carton) expire() {
+	if c.TTL <= 0 {
+		return
+	}
+	if _, err := SetExpiry(c.CartonsId, time.Now().Add(c.TTL)); err != nil {
+		log.Errorf("  unable to set the expiry of (%s) %s", c.Name, err)
+	}
+}
@@ -0,0 +1,70 @@
+package carton
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type ExpirySuite struct{}
+
+var _ = check.Suite(&ExpirySuite{})
+
+func (s *ExpirySuite) SetUpTest(c *check.C) {
+	SetRepository(NewMemRepository())
+	c.Assert(store.StoreAssemblies(&Assemblies{Id: "AMS001", AccountsId: "ACT001", Name: "trial"}), check.IsNil)
+}
+
+func at(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+func (s *ExpirySuite) TestSetAndExtend(c *check.C) {
+	e, err := SetExpiry("AMS001", at("2016-03-03T12:00:00Z"))
+	c.Assert(err, check.IsNil)
+	c.Assert(e.AccountsId, check.Equals, "ACT001")
+	c.Assert(e.MarkWarned([]time.Duration{time.Hour}), check.IsNil)
+
+	e, err = ExtendExpiry("AMS001", 24*time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(e.ExpiresAt, check.Equals, "2016-03-04T12:00:00Z")
+	c.Assert(e.Warned, check.HasLen, 0)
+	ids, _ := ExpiringIds()
+	c.Assert(ids, check.DeepEquals, []string{"AMS001"})
+
+	c.Assert(RemoveExpiry("AMS001"), check.IsNil)
+	_, err = ExtendExpiry("AMS001", time.Hour)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *ExpirySuite) TestDue(c *check.C) {
+	e, _ := SetExpiry("AMS001", at("2016-03-03T12:00:00Z"))
+	leads := []time.Duration{24 * time.Hour, time.Hour}
+	c.Assert(e.Due(leads, at("2016-03-02T11:59:00Z")), check.HasLen, 0)
+	c.Assert(e.Due(leads, at("2016-03-03T11:00:00Z")), check.DeepEquals, leads)
+
+	c.Assert(e.MarkWarned(leads[:1]), check.IsNil)
+	c.Assert(e.Due(leads, at("2016-03-03T11:00:00Z")), check.DeepEquals, leads[1:])
+}
+
+func (s *ExpirySuite) TestMarkWarnedAfterAnExtend(c *check.C) {
+	e, _ := SetExpiry("AMS001", at("2016-03-03T12:00:00Z"))
+	_, err := ExtendExpiry("AMS001", time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(e.MarkWarned([]time.Duration{time.Hour}), check.IsNil)
+	cur, _ := GetExpiry("AMS001")
+	c.Assert(cur.Warned, check.HasLen, 0)
+}
+
+func (s *ExpirySuite) TestCartonWithTTLExpires(c *check.C) {
+	(&Carton{Id: "ASM001", CartonsId: "AMS001", TTL: 0}).expire()
+	_, err := GetExpiry("AMS001")
+	c.Assert(err, check.NotNil)
+
+	(&Carton{Id: "ASM001", CartonsId: "AMS001", TTL: 72 * time.Hour}).expire()
+	e, err := GetExpiry("AMS001")
+	c.Assert(err, check.IsNil)
+	t, _ := e.At()
+	c.Assert(t.After(time.Now().Add(71*time.Hour)), check.Equals, true)
+}
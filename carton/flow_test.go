@@ -0,0 +1,165 @@
+package carton
+
+import (
+	"encoding/json"
+
+	"github.com/megamsys/libgo/pairs"
+	"github.com/megamsys/libgo/utils"
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+//runs the requests of an assembly end to end, against the repository in
+//memory and a fake provisioner.
+type FlowSuite struct {
+	repo *MemRepository
+	prov *provisiontest.FakeProvisioner
+}
+
+var _ = check.Suite(&FlowSuite{})
+
+func pair(k, v string) string {
+	b, _ := json.Marshal(pairs.JsonPair{K: k, V: v})
+	return string(b)
+}
+
+func (s *FlowSuite) SetUpTest(c *check.C) {
+	s.repo = NewMemRepository()
+	s.prov = provisiontest.NewFakeProvisioner()
+	SetRepository(s.repo)
+	ProvisionerMap["fake"] = s.prov
+
+	c.Assert(s.repo.StoreAssemblies(&Assemblies{Id: "AMS001", AccountsId: "ORG001", AssemblysId: []string{"ASM001"}}), check.IsNil)
+	c.Assert(s.repo.StoreAssembly(&Ambly{
+		Id:         "ASM001",
+		OrgId:      "ORG001",
+		Name:       "myapp",
+		Inputs:     []string{pair(utils.PROVIDER, "fake")},
+		Status:     constants.StatusLaunching.String(),
+		Components: []string{"CMPdb", "CMPweb"},
+	}), check.IsNil)
+	c.Assert(s.repo.StoreComponent(&ComponentTable{
+		Id:      "CMPdb",
+		Name:    "db",
+		Tosca:   "tosca.service.postgresql",
+		Outputs: []string{pair(PUBLICIPV4, "10.0.0.2")},
+		Repo:    `{"rtype":"image"}`,
+	}), check.IsNil)
+	c.Assert(s.repo.StoreComponent(&ComponentTable{
+		Id:                "CMPweb",
+		Name:              "web",
+		Tosca:             "tosca.app.java",
+		RelatedComponents: []string{"CMPdb"},
+		Repo:              `{"rtype":"image"}`,
+	}), check.IsNil)
+}
+
+func (s *FlowSuite) TearDownTest(c *check.C) {
+	delete(ProvisionerMap, "fake")
+}
+
+//runs a request the way the deployd does, and checks that it succeeded.
+func (s *FlowSuite) run(c *check.C, id, category, action string) {
+	c.Assert(s.runRequest(c, &Requests{Id: id, CatId: "AMS001", Category: category, Action: action}), check.IsNil)
+	r, err := GetRequest(id)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, REQ_SUCCEEDED)
+}
+
+//queues the request and runs it the way the deployd does.
+func (s *FlowSuite) runRequest(c *check.C, r *Requests) error {
+	r.Status = REQ_QUEUED
+	c.Assert(s.repo.StoreRequest(r), check.IsNil)
+	r, err := GetRequest(r.Id)
+	c.Assert(err, check.IsNil)
+	return r.Run(func() error {
+		p, err := r.Parse()
+		if err != nil {
+			return err
+		}
+		return NewReqOperator(r.CatId).Accept(&p)
+	})
+}
+
+//the status the provisioner reports for the assembly once it is done.
+func (s *FlowSuite) settle(c *check.C, status utils.Status) {
+	c.Assert(s.repo.UpdateAssembly("ASM001", "ORG001", map[string]interface{}{"Status": status.String()}), check.IsNil)
+	for _, id := range []string{"CMPdb", "CMPweb"} {
+		c.Assert(s.repo.UpdateComponent(id, map[string]interface{}{"Status": status.String()}), check.IsNil)
+	}
+}
+
+func (s *FlowSuite) TestCreate(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy"})
+
+	ds, err := ListDeploys("ASM001", "CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+	c.Assert(ds[0].Origin, check.Equals, ORIGIN_IMAGE)
+	c.Assert(ds[0].Error, check.Equals, "")
+
+	web, err := NewComponent("CMPweb")
+	c.Assert(err, check.IsNil)
+	c.Assert(web.Envs.Match("DB_HOST"), check.Equals, "10.0.0.2")
+	db, err := NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(db.Outputs.Match(UNITS), check.Equals, "1")
+}
+
+func (s *FlowSuite) TestBoxesGetTheLabelsOfTheAssembly(c *check.C) {
+	a, err := NewAmbly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.NukeAndSetInputs(map[string][]string{LABELS: []string{"storage=ssd, gpu=true"}}), check.IsNil)
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	want := map[string]string{"storage": "ssd", "gpu": "true"}
+	for _, b := range *cs.Boxes {
+		c.Assert(b.Labels, check.DeepEquals, want)
+	}
+}
+
+func (s *FlowSuite) TestLifecycle(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	s.run(c, "RIP002", CONTROL, STOP)
+	s.settle(c, constants.StatusStopped)
+	s.run(c, "RIP003", CONTROL, START)
+	s.settle(c, constants.StatusRunning)
+	s.run(c, "RIP004", CONTROL, RESTART)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "stop", "start", "restart"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy", "stop", "start", "restart"})
+}
+
+func (s *FlowSuite) TestDestroy(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	s.run(c, "RIP002", STATE, DESTROY)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "destroy"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy", "destroy"})
+
+	_, err := NewComponent("CMPweb")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = NewComponent("CMPdb")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = Get("AMS001")
+	c.Assert(err, check.Equals, ErrNotFound)
+}
+
+func (s *FlowSuite) TestFailedRequest(c *check.C) {
+	s.prov.PrepareFailure("deploy", ErrNotFound)
+	c.Assert(s.repo.StoreRequest(&Requests{Id: "RIP001", CatId: "AMS001", Category: STATE, Action: CREATE, Status: REQ_QUEUED}), check.IsNil)
+	r, err := GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	var p MegdProcessor = CreateProcess{Name: r.CatId}
+	err = r.Run(func() error {
+		return NewReqOperator(r.CatId).Accept(&p)
+	})
+	c.Assert(err, check.NotNil)
+	r, err = GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, REQ_FAILED)
+	c.Assert(s.prov.Ops("CMPweb"), check.HasLen, 0)
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/provision"
 )
@@ -39,24 +40,16 @@ func (cy *LifecycleOpts) setLogger() {
 	cy.writer = io.MultiWriter(&cy.logWriter)
 }
 
-//if the state is in running, started, stopped, restarted then allow it to be lcycled.
-// to-do: allow states that ends with "*ing or *ed" that should fix this generically.
-func (cy *LifecycleOpts) canCycle() bool {
-	return cy.B.Status == constants.StatusRunning ||
-		cy.B.Status == constants.StatusStarted ||
-		cy.B.Status == constants.StatusStopped ||
-		cy.B.Status == constants.StatusUpgraded
-}
-
-// Starts  the box.
+// Starts  the box. The box is moved through the transition table, which rejects
+// the moves that race with an earlier one, eg: stop while launching.
 func Start(cy *LifecycleOpts) error {
 	log.Debugf("  start cycle for box (%s, %s)", cy.B.Id, cy.B.GetFullName())
 	cy.setLogger()
 	defer cy.logWriter.Close()
-	if cy.canCycle() {
-		if err := ProvisionerMap[cy.B.Provider].Start(cy.B, "", cy.writer); err != nil {
-			return err
-		}
+	if err := cy.B.Move(constants.StatusStarting, func() error {
+		return ProvisionerMap[cy.B.Provider].Start(cy.B, "", cy.writer)
+	}); err != nil {
+		return err
 	}
 	fmt.Fprintf(cy.writer, "    start (%s, %s, %s) OK\n", cy.B.GetFullName(), cy.B.Status.String(), time.Since(cy.start))
 	return nil
@@ -67,10 +60,10 @@ func Stop(cy *LifecycleOpts) error {
 	log.Debugf("  stop cycle for box (%s, %s)", cy.B.Id, cy.B.GetFullName())
 	cy.setLogger()
 	defer cy.logWriter.Close()
-	if cy.canCycle() {
-		if err := ProvisionerMap[cy.B.Provider].Stop(cy.B, "", cy.writer); err != nil {
-			return err
-		}
+	if err := cy.B.Move(constants.StatusStopping, func() error {
+		return ProvisionerMap[cy.B.Provider].Stop(cy.B, "", cy.writer)
+	}); err != nil {
+		return err
 	}
 	fmt.Fprintf(cy.writer, "    stop (%s, %s, %s) OK\n", cy.B.GetFullName(), cy.B.Status.String(), time.Since(cy.start))
 	return nil
@@ -81,11 +74,18 @@ func Restart(cy *LifecycleOpts) error {
 	log.Debugf("  restart cycle for box (%s, %s)", cy.B.Id, cy.B.GetFullName())
 	cy.setLogger()
 	defer cy.logWriter.Close()
-	if cy.canCycle() {
-		if err := ProvisionerMap[cy.B.Provider].Restart(cy.B, "", cy.writer); err != nil {
-			return err
-		}
+	if err := cy.B.Move(constants.StatusStarting, func() error {
+		return ProvisionerMap[cy.B.Provider].Restart(cy.B, "", cy.writer)
+	}); err != nil {
+		return err
 	}
 	fmt.Fprintf(cy.writer, "    restart (%s, %s, %s) OK\n", cy.B.GetFullName(), cy.B.Status.String(), time.Since(cy.start))
 	return nil
 }
+
+//sets the status of the box through the transition table.
+func setBoxStatus(b *provision.Box, w io.Writer, status utils.Status) error {
+	return b.Move(status, func() error {
+		return ProvisionerMap[b.Provider].SetBoxStatus(b, w, status)
+	})
+}
@@ -33,12 +33,24 @@ func (s CreateProcess) String() string {
 }
 
 func (s CreateProcess) Process(ca Cartons) error {
-	for _, c := range ca {
+	return ca.each(func(c *Carton) error {
+		reserved, err := c.reserve()
+		if err != nil {
+			return err
+		}
 		if err := c.Deploy(); err != nil {
+			c.releaseUnlaunched(reserved)
 			return err
 		}
-	}
-	return nil
+		c.expire()
+		return nil
+	}).Err()
+}
+
+func (s CreateProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planAdmitted(c.planBoxes(c.order(), deploySteps), POLICY_DEPLOY, "", 0, boxResources(c.unlaunched()...))
+	}), nil
 }
 
 // DeleteProcs represents a command for delete cartons.
@@ -54,12 +66,15 @@ func (s DestroyProcess) String() string {
 }
 
 func (s DestroyProcess) Process(ca Cartons) error {
-	for _, c := range ca {
-		if err := c.Destroy(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ca.eachReverse(func(c *Carton) error {
+		return c.Destroy()
+	}).Err()
+}
+
+func (s DestroyProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planAdmitted(c.planBoxes(reverse(c.order()), destroySteps), POLICY_DESTROY, "", 0, Resources{})
+	}), nil
 }
 
 // StartProcs represents a command for starting  cartons.
@@ -75,12 +90,15 @@ func (s StartProcess) String() string {
 }
 
 func (s StartProcess) Process(ca Cartons) error {
-	for _, c := range ca {
-		if err := c.Start(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ca.each(func(c *Carton) error {
+		return c.Start()
+	}).Err()
+}
+
+func (s StartProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planAdmitted(c.planBoxes(c.order(), startSteps), POLICY_START, "", 0, Resources{})
+	}), nil
 }
 
 // StopProcs represents a command for stoping  cartons.
@@ -96,12 +114,15 @@ func (s StopProcess) String() string {
 }
 
 func (s StopProcess) Process(ca Cartons) error {
-	for _, c := range ca {
-		if err := c.Stop(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ca.each(func(c *Carton) error {
+		return c.Stop()
+	}).Err()
+}
+
+func (s StopProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planAdmitted(c.planBoxes(c.order(), stopSteps), POLICY_STOP, "", 0, Resources{})
+	}), nil
 }
 
 // RestartProcs represents a command for restarting  cartons.
@@ -117,12 +138,91 @@ func (s RestartProcess) String() string {
 }
 
 func (s RestartProcess) Process(ca Cartons) error {
-	for _, c := range ca {
-		if err := c.Restart(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ca.each(func(c *Carton) error {
+		return c.Restart()
+	}).Err()
+}
+
+func (s RestartProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planAdmitted(c.planBoxes(c.order(), restartSteps), POLICY_RESTART, "", 0, Resources{})
+	}), nil
+}
+
+// StatedownProcess represents a command for bringing down the state of cartons.
+type StatedownProcess struct {
+	Name string
+}
+
+func (s StatedownProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("STATEDOWN CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s StatedownProcess) Process(ca Cartons) error {
+	return ca.eachReverse(func(c *Carton) error {
+		return c.Statedown()
+	}).Err()
+}
+
+func (s StatedownProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planBoxes(reverse(c.order()), statedownSteps)
+	}), nil
+}
+
+// ScaleProcess represents a command for scaling the units of cartons, the
+// component to the units asked for or else every component to the ones in
+// its inputs.
+type ScaleProcess struct {
+	Name      string
+	Component string
+	Units     int
+}
+
+func (s ScaleProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SCALE CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s ScaleProcess) Process(ca Cartons) error {
+	return ca.each(func(c *Carton) error {
+		return c.Scale(s.Component, s.Units)
+	}).Err()
+}
+
+func (s ScaleProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planScale(s.Component, s.Units)
+	}), nil
+}
+
+// CancelProcess represents a command for cancelling the operations in flight
+// on the cartons, the name is the assemblies id they run on.
+type CancelProcess struct {
+	Name string
+}
+
+func (s CancelProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("CANCEL CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s CancelProcess) Process(ca Cartons) error {
+	return Cancel(s.Name)
+}
+
+//the boxes aren't operated upon, the operations in flight roll them back.
+func (s CancelProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return &CartonPlan{Id: c.Id, Name: c.Name, Boxes: make([]*BoxPlan, 0)}
+	}), nil
 }
 
 // UpgradeProcs represents a command for starting  cartons.
@@ -138,12 +238,112 @@ func (s UpgradeProcess) String() string {
 }
 
 func (s UpgradeProcess) Process(ca Cartons) error {
-	for _, c := range ca {
-		if err := c.Upgrade(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ca.each(func(c *Carton) error {
+		return c.Upgrade()
+	}).Err()
+}
+
+func (s UpgradeProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planAdmitted(c.planBoxes(c.order(), upgradeSteps), POLICY_UPGRADE, "", 0, Resources{})
+	}), nil
+}
+
+// RollbackProcess represents a command for rolling back cartons.
+type RollbackProcess struct {
+	Name string
+}
+
+func (s RollbackProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("ROLLBACK CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s RollbackProcess) Process(ca Cartons) error {
+	return ca.each(func(c *Carton) error {
+		return c.Rollback()
+	}).Err()
+}
+
+func (s RollbackProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planBoxes(c.order(), replaceSteps)
+	}), nil
+}
+
+// ResizeProcess represents a command for resizing the boxes of cartons.
+type ResizeProcess struct {
+	Name string
+}
+
+func (s ResizeProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("RESIZE CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s ResizeProcess) Process(ca Cartons) error {
+	return ca.each(func(c *Carton) error {
+		return c.Resize()
+	}).Err()
+}
+
+func (s ResizeProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planResize()
+	}), nil
+}
+
+// SnapshotProcess represents a command for snapshotting the boxes of cartons.
+type SnapshotProcess struct {
+	Name string
+}
+
+func (s SnapshotProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SNAPSHOT CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s SnapshotProcess) Process(ca Cartons) error {
+	return ca.each(func(c *Carton) error {
+		return c.Snapshot()
+	}).Err()
+}
+
+func (s SnapshotProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planBoxes(c.order(), snapshotSteps)
+	}), nil
+}
+
+// RestoreProcess represents a command for restoring the boxes of cartons
+// from their snapshots.
+type RestoreProcess struct {
+	Name string
+}
+
+func (s RestoreProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("RESTORE CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s RestoreProcess) Process(ca Cartons) error {
+	return ca.each(func(c *Carton) error {
+		return c.Restore()
+	}).Err()
+}
+
+func (s RestoreProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planBoxes(c.order(), restoreSteps)
+	}), nil
 }
 
 // StateupProcess represents a command for restarting  cartons.
@@ -159,10 +359,13 @@ func (s StateupProcess) String() string {
 }
 
 func (s StateupProcess) Process(ca Cartons) error {
-	for _, c := range ca {
-		if err := c.Stateup(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ca.each(func(c *Carton) error {
+		return c.Stateup()
+	}).Err()
+}
+
+func (s StateupProcess) Plan(ca Cartons) (*Plan, error) {
+	return ca.plan(s.String(), func(c *Carton) *CartonPlan {
+		return c.planBoxes(c.order(), stateupSteps)
+	}), nil
 }
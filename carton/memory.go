@@ -0,0 +1,494 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// MemRepository is a Repository in memory, it hands out copies so
+// that the rows change only when updated.
+type MemRepository struct {
+	mu         sync.Mutex
+	assemblies map[string]*Assemblies
+	assembly   map[string]*Ambly
+	components map[string]*ComponentTable
+	requests   map[string]*Requests
+	deploys    map[string]*Deploys
+	quotas     map[string]*Quota
+	sshKeys    map[string]*SSHKey
+	schedules  map[string]*Schedules
+	expiries   map[string]*Expiry
+	dead       map[string]*DeadLetter
+	readErr    error
+}
+
+// NewMemRepository returns an empty repository in memory.
+func NewMemRepository() *MemRepository {
+	return &MemRepository{
+		assemblies: make(map[string]*Assemblies),
+		assembly:   make(map[string]*Ambly),
+		components: make(map[string]*ComponentTable),
+		requests:   make(map[string]*Requests),
+		deploys:    make(map[string]*Deploys),
+		quotas:     make(map[string]*Quota),
+		sshKeys:    make(map[string]*SSHKey),
+		schedules:  make(map[string]*Schedules),
+		expiries:   make(map[string]*Expiry),
+		dead:       make(map[string]*DeadLetter),
+	}
+}
+
+// FailReads makes the reads fail with err till it is called with nil, as a
+// scylla which is down would.
+func (m *MemRepository) FailReads(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readErr = err
+}
+
+//deep copies from into to, the rows are plain json.
+func clone(from, to interface{}) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, to)
+}
+
+//sets the fields of the row by their names, as scylla does with the columns.
+func update(row interface{}, fields map[string]interface{}) error {
+	v := reflect.ValueOf(row).Elem()
+	for name, value := range fields {
+		f := v.FieldByName(name)
+		if !f.IsValid() || !f.CanSet() {
+			return fmt.Errorf("unknown field %s in %s", name, v.Type().Name())
+		}
+		val := reflect.ValueOf(value)
+		if !val.Type().ConvertibleTo(f.Type()) {
+			return fmt.Errorf("field %s in %s can't be set to a %s", name, v.Type().Name(), val.Type())
+		}
+		f.Set(val.Convert(f.Type()))
+	}
+	return nil
+}
+
+//sets the fields of the row to to when they are still from, empty lists are
+//alike as scylla keeps them as null.
+func swap(row interface{}, from, to map[string]interface{}) (bool, error) {
+	v := reflect.ValueOf(row).Elem()
+	for name, value := range from {
+		f := v.FieldByName(name)
+		want := reflect.ValueOf(value)
+		if !f.IsValid() || !want.IsValid() || !want.Type().ConvertibleTo(f.Type()) {
+			return false, fmt.Errorf("unknown field %s in %s", name, v.Type().Name())
+		}
+		want = want.Convert(f.Type())
+		if f.Kind() == reflect.Slice && f.Len() == 0 && want.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(f.Interface(), want.Interface()) {
+			return false, nil
+		}
+	}
+	return true, update(row, to)
+}
+
+func (m *MemRepository) GetAssemblies(id string) (*Assemblies, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.assemblies[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Assemblies{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreAssemblies(a *Assemblies) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Assemblies{}
+	m.assemblies[a.Id] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) DeleteAssemblies(id, orgId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.assemblies, id)
+	return nil
+}
+
+func (m *MemRepository) GetAssembly(id string) (*Ambly, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.assembly[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Ambly{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreAssembly(a *Ambly) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Ambly{}
+	m.assembly[a.Id] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) UpdateAssembly(id, orgId string, fields map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.assembly[id]
+	if !ok {
+		return ErrNotFound
+	}
+	return update(a, fields)
+}
+
+func (m *MemRepository) SwapAssembly(id, orgId string, from, to map[string]interface{}) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.assembly[id]
+	if !ok {
+		return false, nil
+	}
+	return swap(a, from, to)
+}
+
+func (m *MemRepository) DeleteAssembly(id, orgId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.assembly, id)
+	return nil
+}
+
+func (m *MemRepository) GetComponent(id string) (*ComponentTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.components[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &ComponentTable{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreComponent(a *ComponentTable) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &ComponentTable{}
+	m.components[a.Id] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) UpdateComponent(id string, fields map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.components[id]
+	if !ok {
+		return ErrNotFound
+	}
+	return update(a, fields)
+}
+
+func (m *MemRepository) SwapComponent(id string, from, to map[string]interface{}) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.components[id]
+	if !ok {
+		return false, nil
+	}
+	return swap(c, from, to)
+}
+
+func (m *MemRepository) DeleteComponent(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.components, id)
+	return nil
+}
+
+func (m *MemRepository) GetRequest(id string) (*Requests, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.requests[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Requests{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreRequest(a *Requests) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Requests{}
+	m.requests[a.Id] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) UpdateRequest(id string, fields map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.requests[id]
+	if !ok {
+		return ErrNotFound
+	}
+	return update(a, fields)
+}
+
+func (m *MemRepository) SwapRequest(id string, from, to map[string]interface{}) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.requests[id]
+	if !ok {
+		return false, nil
+	}
+	return swap(r, from, to)
+}
+
+func (m *MemRepository) RunningIds() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	ids := make([]string, 0)
+	for id, r := range m.requests {
+		if r.Status == REQ_RUNNING {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (m *MemRepository) GetDeploys(asmid, compid string) (*Deploys, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.deploys[asmid+"/"+compid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Deploys{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreDeploys(a *Deploys) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Deploys{}
+	m.deploys[a.AssemblyId+"/"+a.ComponentId] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) DeleteDeploys(asmid, compid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.deploys, asmid+"/"+compid)
+	return nil
+}
+
+func (m *MemRepository) GetQuota(accountsId string) (*Quota, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.quotas[accountsId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Quota{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreQuota(a *Quota) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Quota{}
+	m.quotas[a.AccountsId] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) GetSSHKey(orgId, name string) (*SSHKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.sshKeys[orgId+"/"+name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &SSHKey{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreSSHKey(a *SSHKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &SSHKey{}
+	m.sshKeys[a.OrgId+"/"+a.Name] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) GetSchedules(catId string) (*Schedules, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.schedules[catId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Schedules{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreSchedules(a *Schedules) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Schedules{}
+	m.schedules[a.CatId] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) ScheduledIds() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	ids := make([]string, 0, len(m.schedules))
+	for id, s := range m.schedules {
+		if len(s.Schedules) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (m *MemRepository) GetExpiry(catId string) (*Expiry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.expiries[catId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &Expiry{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreExpiry(a *Expiry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &Expiry{}
+	m.expiries[a.CatId] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) DeleteExpiry(catId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.expiries, catId)
+	return nil
+}
+
+func (m *MemRepository) ExpiringIds() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	ids := make([]string, 0, len(m.expiries))
+	for id := range m.expiries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (m *MemRepository) GetDeadLetter(id string) (*DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	a, ok := m.dead[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := &DeadLetter{}
+	return c, clone(a, c)
+}
+
+func (m *MemRepository) StoreDeadLetter(a *DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &DeadLetter{}
+	m.dead[a.Id] = c
+	return clone(a, c)
+}
+
+func (m *MemRepository) DeleteDeadLetter(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.dead, id)
+	return nil
+}
+
+func (m *MemRepository) DeadLetterIds() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	ids := make([]string, 0, len(m.dead))
+	for id := range m.dead {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,88 @@
+package carton
+
+import (
+	"errors"
+
+	"github.com/megamsys/gocql"
+	"gopkg.in/check.v1"
+)
+
+type MemorySuite struct{}
+
+var _ = check.Suite(&MemorySuite{})
+
+func (s *MemorySuite) TestGetReturnsACopy(c *check.C) {
+	m := NewMemRepository()
+	c.Assert(m.StoreComponent(&ComponentTable{Id: "CMP001", Envs: []string{"a"}}), check.IsNil)
+	got, err := m.GetComponent("CMP001")
+	c.Assert(err, check.IsNil)
+	got.Envs[0] = "b"
+	again, err := m.GetComponent("CMP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(again.Envs, check.DeepEquals, []string{"a"})
+}
+
+func (s *MemorySuite) TestUpdateByFieldName(c *check.C) {
+	m := NewMemRepository()
+	c.Assert(m.StoreRequest(&Requests{Id: "RIP001", Status: REQ_QUEUED}), check.IsNil)
+	c.Assert(m.UpdateRequest("RIP001", map[string]interface{}{"Status": REQ_RUNNING, "DryRun": true}), check.IsNil)
+	r, err := m.GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, REQ_RUNNING)
+	c.Assert(r.DryRun, check.Equals, true)
+}
+
+func (s *MemorySuite) TestUpdateUnknownField(c *check.C) {
+	m := NewMemRepository()
+	c.Assert(m.StoreRequest(&Requests{Id: "RIP001"}), check.IsNil)
+	err := m.UpdateRequest("RIP001", map[string]interface{}{"Nope": "x"})
+	c.Assert(err, check.ErrorMatches, "unknown field Nope in Requests")
+}
+
+func (s *MemorySuite) TestSwapWhenStillTheSame(c *check.C) {
+	m := NewMemRepository()
+	c.Assert(m.StoreComponent(&ComponentTable{Id: "CMP001", Status: "running"}), check.IsNil)
+	ok, err := m.SwapComponent("CMP001", map[string]interface{}{"Status": "stopped"}, map[string]interface{}{"Status": "starting"})
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	ok, err = m.SwapComponent("CMP001", map[string]interface{}{"Status": "running"}, map[string]interface{}{"Status": "stopping"})
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	ok, err = m.SwapComponent("CMP001", map[string]interface{}{"Outputs": []string{}}, map[string]interface{}{"Outputs": []string{"units=1"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	cmp, err := m.GetComponent("CMP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(cmp.Status, check.Equals, "stopping")
+	c.Assert(cmp.Outputs, check.DeepEquals, []string{"units=1"})
+	ok, err = m.SwapComponent("CMP002", map[string]interface{}{"Status": ""}, map[string]interface{}{"Status": "launching"})
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *MemorySuite) TestNotFound(c *check.C) {
+	m := NewMemRepository()
+	_, err := m.GetAssembly("ASM001")
+	c.Assert(err, check.Equals, ErrNotFound)
+	c.Assert(m.UpdateComponent("CMP001", map[string]interface{}{"Status": "x"}), check.Equals, ErrNotFound)
+}
+
+func (s *MemorySuite) TestFailReads(c *check.C) {
+	m := NewMemRepository()
+	c.Assert(m.StoreQuota(&Quota{AccountsId: "ORG001"}), check.IsNil)
+	m.FailReads(errors.New("no hosts available"))
+	_, err := m.GetQuota("ORG001")
+	c.Assert(err, check.ErrorMatches, "no hosts available")
+	_, err = m.ExpiringIds()
+	c.Assert(err, check.ErrorMatches, "no hosts available")
+	m.FailReads(nil)
+	_, err = m.GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *MemorySuite) TestIsNotFound(c *check.C) {
+	c.Assert(isNotFound(gocql.ErrNotFound), check.Equals, true)
+	c.Assert(isNotFound(errors.New("scylla.go:91: No rows returned")), check.Equals, false)
+	c.Assert(isNotFound(errors.New("keyspace vertice not found")), check.Equals, false)
+	c.Assert(isNotFound(errors.New("gocql: no hosts available in the pool")), check.Equals, false)
+}
@@ -0,0 +1,139 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultOperationTimeout is the default deadline of an operation on an assembly.
+const DefaultOperationTimeout = 30 * time.Minute
+
+//The deadline of an operation on an assembly, a zero is none. Set by the subd daemons.
+//A provider can be given a deadline of its own, see SetOperationTimeout.
+var OperationTimeout = DefaultOperationTimeout
+
+//the deadlines of the operations by provider.
+var timeouts = struct {
+	sync.Mutex
+	by map[string]time.Duration
+}{by: make(map[string]time.Duration)}
+
+// SetOperationTimeout sets the deadline of the operations on the boxes of the
+// provider, a zero is OperationTimeout.
+func SetOperationTimeout(provider string, d time.Duration) {
+	timeouts.Lock()
+	defer timeouts.Unlock()
+	timeouts.by[provider] = d
+}
+
+//the deadline of an operation on the cartons, the longest one of their
+//providers. A zero is none.
+func (ca Cartons) timeout() time.Duration {
+	timeouts.Lock()
+	defer timeouts.Unlock()
+	var longest time.Duration
+	for _, c := range ca {
+		d, ok := timeouts.by[c.Provider]
+		if !ok || d == 0 {
+			d = OperationTimeout
+		}
+		if d <= 0 {
+			return 0
+		}
+		if d > longest {
+			longest = d
+		}
+	}
+	if len(ca) == 0 {
+		return OperationTimeout
+	}
+	return longest
+}
+
+var ErrNoOperation = errors.New("no operation in flight")
+
+//the cancels of the operations running in this daemon, by the assemblies id
+//they run on and then by the order they began in.
+var operations = struct {
+	sync.Mutex
+	seq     int
+	cancels map[string]map[int]context.CancelFunc
+}{cancels: make(map[string]map[int]context.CancelFunc)}
+
+// begin returns the context of an operation on the assemblies, which is done
+// once the operation is past the timeout (a zero is none) or cancelled. end
+// must be called once the operation is over.
+func begin(catId string, timeout time.Duration) (ctx context.Context, end func()) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	operations.Lock()
+	operations.seq++
+	seq := operations.seq
+	if operations.cancels[catId] == nil {
+		operations.cancels[catId] = make(map[int]context.CancelFunc)
+	}
+	operations.cancels[catId][seq] = cancel
+	operations.Unlock()
+
+	return ctx, func() {
+		operations.Lock()
+		delete(operations.cancels[catId], seq)
+		if len(operations.cancels[catId]) == 0 {
+			delete(operations.cancels, catId)
+		}
+		operations.Unlock()
+		cancel()
+	}
+}
+
+// Cancel cancels the operations in flight on the assemblies. The pipelines
+// of their boxes stop at the next step and roll back, the boxes not yet
+// operated upon are skipped.
+func Cancel(catId string) error {
+	operations.Lock()
+	defer operations.Unlock()
+	cancels, ok := operations.cancels[catId]
+	if !ok {
+		return ErrNoOperation
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil
+}
+
+//hands the context of the operation to the cartons and their boxes, which
+//pass it on to the provisioners.
+func (ca Cartons) withContext(ctx context.Context) {
+	for _, c := range ca {
+		c.ctx = ctx
+		if c.Boxes == nil {
+			continue
+		}
+		for i := range *c.Boxes {
+			(*c.Boxes)[i].Ctx = ctx
+		}
+	}
+}
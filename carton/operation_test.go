@@ -0,0 +1,100 @@
+package carton
+
+import (
+	"strings"
+	"time"
+
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+	"golang.org/x/net/context"
+	"gopkg.in/check.v1"
+)
+
+type OperationSuite struct{}
+
+var _ = check.Suite(&OperationSuite{})
+
+func (s *OperationSuite) TestCancel(c *check.C) {
+	ctx, end := begin("AMS001", OperationTimeout)
+	other, endOther := begin("AMS002", OperationTimeout)
+	defer endOther()
+	c.Assert(Cancel("AMS001"), check.IsNil)
+	c.Assert(ctx.Err(), check.Equals, context.Canceled)
+	c.Assert(other.Err(), check.IsNil)
+	end()
+	c.Assert(Cancel("AMS001"), check.Equals, ErrNoOperation)
+}
+
+func (s *OperationSuite) TestTimeoutIsTheLongestOfTheProviders(c *check.C) {
+	SetOperationTimeout("short", time.Minute)
+	SetOperationTimeout("long", time.Hour)
+	defer SetOperationTimeout("short", 0)
+	defer SetOperationTimeout("long", 0)
+	ca := Cartons{&Carton{Provider: "short"}, &Carton{Provider: "long"}}
+	c.Assert(ca.timeout(), check.Equals, time.Hour)
+	c.Assert(Cartons{&Carton{Provider: "short"}}.timeout(), check.Equals, time.Minute)
+	c.Assert(Cartons{&Carton{Provider: "other"}}.timeout(), check.Equals, OperationTimeout)
+}
+
+func (s *OperationSuite) TestOperationTimeout(c *check.C) {
+	OperationTimeout = 10 * time.Millisecond
+	defer func() { OperationTimeout = DefaultOperationTimeout }()
+	ctx, end := begin("AMS001", OperationTimeout)
+	defer end()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		c.Fatal("the operation wasn't past its deadline")
+	}
+	c.Assert(ctx.Err(), check.Equals, context.DeadlineExceeded)
+}
+
+func (s *OperationSuite) TestCancelSkipsTheLevelsLeft(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ca := &Carton{Boxes: &[]provision.Box{{Id: "CMPdb", Name: "db"}, {Id: "CMPweb", Name: "web"}}, levels: [][]int{{0}, {1}}}
+	Cartons{ca}.withContext(ctx)
+	c.Assert((*ca.Boxes)[1].Context(), check.Equals, ctx)
+	r := ca.eachBox(func(b *provision.Box) error {
+		cancel()
+		return nil
+	})
+	c.Assert(r[0].Err, check.IsNil)
+	c.Assert(r[1].Err, check.Equals, context.Canceled)
+}
+
+func (s *FlowSuite) TestCancelRequest(c *check.C) {
+	hung := s.prov.PrepareHang(provision.OP_DEPLOY)
+	done := make(chan error, 1)
+	go func() {
+		var p MegdProcessor = CreateProcess{Name: "AMS001"}
+		done <- NewReqOperator("AMS001").Accept(&p)
+	}()
+	<-hung
+	s.run(c, "RIP002", CONTROL, CANCEL)
+	err := <-done
+	c.Assert(err, check.NotNil)
+	c.Assert(strings.Contains(err.Error(), "db: "+context.Canceled.Error()), check.Equals, true)
+	c.Assert(s.prov.Ops("CMPweb"), check.HasLen, 0)
+	c.Assert(Cancel("AMS001"), check.Equals, ErrNoOperation)
+}
+
+func (s *FlowSuite) TestOperationPastItsDeadline(c *check.C) {
+	OperationTimeout = 10 * time.Millisecond
+	defer func() { OperationTimeout = DefaultOperationTimeout }()
+	s.prov.PrepareHang(provision.OP_DEPLOY)
+	var p MegdProcessor = CreateProcess{Name: "AMS001"}
+	err := NewReqOperator("AMS001").Accept(&p)
+	c.Assert(err, check.NotNil)
+	c.Assert(strings.Contains(err.Error(), "db: "+context.DeadlineExceeded.Error()), check.Equals, true)
+}
+
+func (s *FlowSuite) TestDestroyRunsPastTheDeadline(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	OperationTimeout = time.Nanosecond
+	defer func() { OperationTimeout = DefaultOperationTimeout }()
+	var p MegdProcessor = DestroyProcess{Name: "AMS001"}
+	c.Assert(NewReqOperator("AMS001").Accept(&p), check.IsNil)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "destroy"})
+	c.Assert(Cancel("AMS001"), check.Equals, ErrNoOperation)
+}
@@ -0,0 +1,204 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/megamsys/vertice/provision"
+)
+
+// DefaultConcurrency is the default number of boxes operated upon at once.
+const DefaultConcurrency = 10
+
+//The number of boxes operated upon at once, set by the subd daemons. A
+//provider can be given a number of its own, see SetConcurrency.
+var Concurrency = DefaultConcurrency
+
+//the slots of the boxes operated upon at once in this daemon by provider, so
+//that the limit holds across the cartons (and the requests) run at once.
+var slots = struct {
+	sync.Mutex
+	limits map[string]int
+	free   map[string]chan struct{}
+}{limits: make(map[string]int), free: make(map[string]chan struct{})}
+
+// SetConcurrency sets the number of boxes of the provider operated upon at
+// once, a zero is Concurrency.
+func SetConcurrency(provider string, n int) {
+	slots.Lock()
+	defer slots.Unlock()
+	slots.limits[provider] = n
+	delete(slots.free, provider)
+}
+
+//takes a slot for an operation on a box of the provider, it waits till one
+//is free. The func returned gives it back.
+func acquire(provider string) func() {
+	slots.Lock()
+	free, ok := slots.free[provider]
+	if !ok {
+		n := slots.limits[provider]
+		if n <= 0 {
+			n = Concurrency
+		}
+		if n <= 0 {
+			n = DefaultConcurrency
+		}
+		free = make(chan struct{}, n)
+		slots.free[provider] = free
+	}
+	slots.Unlock()
+	free <- struct{}{}
+	return func() { <-free }
+}
+
+//runs fn on the boxes at once, each one holds a slot of its provider while
+//it runs. It returns the error of every box.
+func eachSlot(boxes []*provision.Box, fn func(b *provision.Box) error) []error {
+	return parallel(len(boxes), len(boxes), func(i int) error {
+		defer acquire(boxes[i].Provider)()
+		return fn(boxes[i])
+	})
+}
+
+// Result is the outcome of an operation on a box or a carton.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Report carries the result of every box or carton operated upon.
+type Report []*Result
+
+// Failures returns the results that errored.
+func (r Report) Failures() Report {
+	failed := make(Report, 0, len(r))
+	for _, res := range r {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns a ReportError when there are failures, or else nil.
+func (r Report) Err() error {
+	if len(r.Failures()) == 0 {
+		return nil
+	}
+	return &ReportError{Report: r}
+}
+
+// ReportError is returned when one or more of the boxes (or cartons) failed.
+// It carries every failure and not just the first.
+type ReportError struct {
+	Report Report
+}
+
+func (e *ReportError) Error() string {
+	failed := e.Report.Failures()
+	msgs := make([]string, 0, len(failed))
+	for _, res := range failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", res.Name, res.Err))
+	}
+	return fmt.Sprintf("%d of %d failed [%s]", len(failed), len(e.Report), strings.Join(msgs, "; "))
+}
+
+//runs fn for the indexes [0, size) with atmost limit running at once, and
+//returns the error of every index.
+func parallel(limit, size int, fn func(i int) error) []error {
+	if limit <= 0 {
+		limit = DefaultConcurrency
+	}
+	errs := make([]error, size)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// each runs fn on the cartons level by level, a carton after the cartons
+// holding the components it relates to (see cartonLevels), and reports on
+// every one of them. The cartons of a level run at once, the limit is on the
+// boxes they operate upon, see eachSlot. A carton is skipped when one it
+// depends on failed.
+func (ca Cartons) each(fn func(c *Carton) error) Report {
+	return ca.eachOrdered(false, fn)
+}
+
+// eachReverse is each with the dependent cartons run before their
+// dependencies, used to tear them down.
+func (ca Cartons) eachReverse(fn func(c *Carton) error) Report {
+	return ca.eachOrdered(true, fn)
+}
+
+func (ca Cartons) eachOrdered(rev bool, fn func(c *Carton) error) Report {
+	r := make(Report, len(ca))
+	for i, c := range ca {
+		r[i] = &Result{Name: c.Name}
+	}
+	levels, deps, err := cartonLevels(ca)
+	if err != nil {
+		for _, res := range r {
+			res.Err = err
+		}
+		return r
+	}
+	if rev {
+		levels, deps = reverse(levels), dependents(deps)
+	}
+	for _, level := range levels {
+		run := make([]int, 0, len(level))
+		for _, i := range level {
+			if failedAny(r, deps[i]) {
+				r[i].Err = ErrDependencyFailed
+				continue
+			}
+			run = append(run, i)
+		}
+		errs := parallel(len(run), len(run), func(k int) error {
+			return fn(ca[run[k]])
+		})
+		for k, i := range run {
+			r[i].Err = errs[k]
+		}
+	}
+	return r
+}
+
+//whether any of the results (by index) failed or was skipped.
+func failedAny(r Report, idx []int) bool {
+	for _, i := range idx {
+		if r[i].Err != nil {
+			return true
+		}
+	}
+	return false
+}
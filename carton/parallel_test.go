@@ -0,0 +1,123 @@
+package carton
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type ParallelSuite struct{}
+
+var _ = check.Suite(&ParallelSuite{})
+
+func (s *ParallelSuite) TestParallelHonorsLimit(c *check.C) {
+	var running, peak int32
+	errs := parallel(2, 6, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	c.Assert(errs, check.HasLen, 6)
+	c.Assert(atomic.LoadInt32(&peak) <= 2, check.Equals, true)
+}
+
+func (s *ParallelSuite) TestEachSlotLimitsTheBoxesAcrossCartons(c *check.C) {
+	SetConcurrency("slotted", 2)
+	defer SetConcurrency("slotted", 0)
+	var running, peak int32
+	fn := func(b *provision.Box) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	var wg sync.WaitGroup
+	for k := 0; k < 3; k++ {
+		boxes := []*provision.Box{{Provider: "slotted"}, {Provider: "slotted"}, {Provider: "slotted"}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Check(eachSlot(boxes, fn), check.HasLen, 3)
+		}()
+	}
+	wg.Wait()
+	c.Assert(atomic.LoadInt32(&peak), check.Equals, int32(2))
+}
+
+func (s *ParallelSuite) TestParallelKeepsEveryError(c *check.C) {
+	errs := parallel(3, 4, func(i int) error {
+		if i%2 == 0 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	c.Assert(errs[0], check.NotNil)
+	c.Assert(errs[1], check.IsNil)
+	c.Assert(errs[2], check.NotNil)
+	c.Assert(errs[3], check.IsNil)
+}
+
+func (s *ParallelSuite) TestEachRunsTheCartonsInTheirOrder(c *check.C) {
+	web := []provision.Box{{Id: "CMPweb", Name: "web", Related: []string{"db"}}}
+	db := []provision.Box{{Id: "CMPdb", Name: "db"}}
+	ca := Cartons{&Carton{Name: "app", Boxes: &web}, &Carton{Name: "data", Boxes: &db}}
+	var mu sync.Mutex
+	ran := make([]string, 0)
+	fn := func(cs *Carton) error {
+		mu.Lock()
+		ran = append(ran, cs.Name)
+		mu.Unlock()
+		return nil
+	}
+	c.Assert(ca.each(fn).Err(), check.IsNil)
+	c.Assert(ran, check.DeepEquals, []string{"data", "app"})
+	ran = ran[:0]
+	c.Assert(ca.eachReverse(fn).Err(), check.IsNil)
+	c.Assert(ran, check.DeepEquals, []string{"app", "data"})
+}
+
+func (s *ParallelSuite) TestEachSkipsTheDependentsOfAFailedCarton(c *check.C) {
+	web := []provision.Box{{Id: "CMPweb", Name: "web", Related: []string{"db"}}}
+	db := []provision.Box{{Id: "CMPdb", Name: "db"}}
+	cron := []provision.Box{{Id: "CMPcron", Name: "cron"}}
+	ca := Cartons{&Carton{Name: "app", Boxes: &web}, &Carton{Name: "data", Boxes: &db}, &Carton{Name: "tools", Boxes: &cron}}
+	r := ca.each(func(cs *Carton) error {
+		if cs.Name == "data" {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	c.Assert(r[0].Err, check.Equals, ErrDependencyFailed)
+	c.Assert(r[1].Err, check.ErrorMatches, "disk full")
+	c.Assert(r[2].Err, check.IsNil)
+}
+
+func (s *ParallelSuite) TestReportErr(c *check.C) {
+	r := Report{
+		&Result{Name: "web"},
+		&Result{Name: "db", Err: errors.New("unreachable")},
+		&Result{Name: "cache", Err: errors.New("timeout")},
+	}
+	err := r.Err()
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*ReportError).Report.Failures(), check.HasLen, 2)
+	c.Assert(err.Error(), check.Equals, "2 of 3 failed [db: unreachable; cache: timeout]")
+	c.Assert(Report{&Result{Name: "web"}}.Err(), check.IsNil)
+}
@@ -18,18 +18,19 @@ package carton
 import (
 	"encoding/json"
 	log "github.com/Sirupsen/logrus"
-	ldb "github.com/megamsys/libgo/db"
-	"github.com/megamsys/vertice/meta"
 	"strings"
 )
 
 type Payload struct {
-	Id        string `json:"id" cql:"id"`
-	Action    string `json:"action" cql:"action"`
-	CatId     string `json:"cat_id" cql:"cat_id"`
-	CatType   string `json:"cattype" cql:"cattype"`
-	Category  string `json:"category" cql:"category"`
-	CreatedAt string `json:"created_at" cql:"created_at"`
+	Id          string `json:"id" cql:"id"`
+	Action      string `json:"action" cql:"action"`
+	CatId       string `json:"cat_id" cql:"cat_id"`
+	CatType     string `json:"cattype" cql:"cattype"`
+	Category    string `json:"category" cql:"category"`
+	CreatedAt   string `json:"created_at" cql:"created_at"`
+	DryRun      bool   `json:"dry_run" cql:"dry_run"`
+	Units       int    `json:"units" cql:"units"`
+	ComponentId string `json:"component_id" cql:"component_id"`
 }
 
 type PayloadConvertor interface {
@@ -53,10 +54,14 @@ func (p *Payload) Convert() (*Requests, error) {
 		return listReqsById(p.Id)
 	} else {
 		return &Requests{
-			Action:    p.Action,
-			Category:  p.Category,
-			CatId:     p.CatId,
-			CreatedAt: p.CreatedAt,
+			Action:      p.Action,
+			Category:    p.Category,
+			CatId:       p.CatId,
+			CreatedAt:   p.CreatedAt,
+			DryRun:      p.DryRun,
+			Units:       p.Units,
+			ComponentId: p.ComponentId,
+			Status:      REQ_QUEUED,
 		}, nil
 	}
 
@@ -67,18 +72,8 @@ func (p *Payload) Convert() (*Requests, error) {
 //value means the id is blank and others are available.
 func listReqsById(id string) (*Requests, error) {
 	log.Debugf("list requests %s", id)
-	r := &Requests{}
-
-	ops := ldb.Options{
-		TableName:   "requests",
-		Pks:         []string{"Id"},
-		Ccms:        []string{},
-		Hosts:       meta.MC.Scylla,
-		Keyspace:    meta.MC.ScyllaKeyspace,
-		PksClauses:  map[string]interface{}{"Id": id},
-		CcmsClauses: make(map[string]interface{}),
-	}
-	if err := ldb.Fetchdb(ops, r); err != nil {
+	r, err := store.GetRequest(id)
+	if err != nil {
 		return nil, err
 	}
 
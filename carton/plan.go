@@ -0,0 +1,218 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/megamsys/libgo/utils"
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+)
+
+// Plan is what a request would do to the cartons, when it isn't run.
+type Plan struct {
+	Request string        `json:"request"`
+	Cartons []*CartonPlan `json:"cartons"`
+}
+
+// CartonPlan is what would happen to the boxes of a carton, in the order
+// they would be operated upon.
+type CartonPlan struct {
+	Id    string     `json:"id"`
+	Name  string     `json:"name"`
+	Boxes []*BoxPlan `json:"boxes"`
+	Error string     `json:"error,omitempty"` //why nothing of the carton would run
+}
+
+// BoxPlan is what would happen to a box. The boxes of a level are operated
+// upon together, and the error says why the box would fail.
+type BoxPlan struct {
+	Name        string   `json:"name"`
+	Level       int      `json:"level"`
+	Provisioner string   `json:"provisioner"`
+	Actions     []string `json:"actions"`
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Error       string   `json:"error,omitempty"`
+}
+
+//a step of an operation on a box, the provisioner operation and the status it moves the box to.
+type planStep struct {
+	op string
+	to utils.Status
+}
+
+var (
+	deploySteps    = []planStep{{provision.OP_DEPLOY, constants.StatusLaunching}}
+	replaceSteps   = []planStep{{provision.OP_REPLACE, constants.StatusLaunching}}
+	destroySteps   = []planStep{{provision.OP_DESTROY, constants.StatusDestroying}}
+	startSteps     = []planStep{{provision.OP_START, constants.StatusStarting}}
+	stopSteps      = []planStep{{provision.OP_STOP, constants.StatusStopping}}
+	restartSteps   = []planStep{{provision.OP_RESTART, constants.StatusStarting}}
+	stateupSteps   = []planStep{{provision.OP_STATE, utils.StatusStateup}}
+	statedownSteps = []planStep{{provision.OP_STATUS, provision.StatusStatedown}}
+	resizeSteps    = []planStep{{provision.OP_RESIZE, provision.StatusResizing}}
+	snapshotSteps  = []planStep{{provision.OP_SNAPSHOT, provision.StatusSnapshotting}}
+	restoreSteps   = []planStep{{provision.OP_RESTORE, provision.StatusRestoring}}
+	upgradeSteps   = []planStep{
+		{provision.OP_REPLACE, constants.StatusLaunching},
+		{provision.OP_RESTART, constants.StatusStarting},
+		{provision.OP_STATUS, constants.StatusUpgraded},
+	}
+)
+
+// plan plans every carton with fn.
+func (ca Cartons) plan(req string, fn func(c *Carton) *CartonPlan) *Plan {
+	p := &Plan{Request: req, Cartons: make([]*CartonPlan, 0, len(ca))}
+	for _, c := range ca {
+		p.Cartons = append(p.Cartons, fn(c))
+	}
+	return p
+}
+
+// planBoxes plans the steps on the boxes level by level, like eachLevel the
+// boxes in the levels after a failing one are skipped.
+func (c *Carton) planBoxes(levels [][]int, steps []planStep) *CartonPlan {
+	boxes := *c.Boxes
+	cp := &CartonPlan{Id: c.Id, Name: c.Name, Boxes: make([]*BoxPlan, 0, len(boxes))}
+	failed := false
+	for l, level := range levels {
+		levelFailed := false
+		for _, i := range level {
+			bp := planBox(boxes[i], l, steps)
+			if failed {
+				bp.Error = ErrDependencyFailed.Error()
+			}
+			levelFailed = levelFailed || len(bp.Error) > 0
+			cp.Boxes = append(cp.Boxes, bp)
+		}
+		failed = failed || levelFailed
+	}
+	return cp
+}
+
+// planScale plans the units deployed or destroyed to bring the component (or
+// else every component) to the units, see Scale.
+func (c *Carton) planScale(compId string, units int) *CartonPlan {
+	cp := &CartonPlan{Id: c.Id, Name: c.Name, Boxes: make([]*BoxPlan, 0)}
+	grow := Resources{}
+	byComp := c.unitsByComponent()
+	for _, id := range scaledIds(byComp, compId) {
+		comp, err := NewComponent(id)
+		if err != nil {
+			cp.Boxes = append(cp.Boxes, &BoxPlan{Name: id, Error: err.Error()})
+			continue
+		}
+		ops, up := c.scaleOps(byComp[id], wantedUnits(comp, units))
+		steps := destroySteps
+		if up {
+			steps = deploySteps
+			grow = grow.plus(boxResources(ops...))
+		}
+		for _, b := range ops {
+			cp.Boxes = append(cp.Boxes, planBox(*b, 0, steps))
+		}
+	}
+	return c.planAdmitted(cp, POLICY_SCALE, compId, units, grow)
+}
+
+// planAdmitted marks the plan vetoed when the account can't take the
+// resources wanted or a policy vetoes the operation, as Process would.
+// Nothing of the carton would run then.
+func (c *Carton) planAdmitted(cp *CartonPlan, op, compId string, units int, want Resources) *CartonPlan {
+	err := c.planQuota(want)
+	if err == nil {
+		err = c.planPolicies(op, compId, units)
+	}
+	if err == nil {
+		return cp
+	}
+	cp.Error = err.Error()
+	for _, bp := range cp.Boxes {
+		if len(bp.Error) == 0 {
+			bp.Error = cp.Error
+		}
+	}
+	return cp
+}
+
+//the quota error of the resources wanted, nothing is reserved.
+func (c *Carton) planQuota(want Resources) error {
+	if len(strings.TrimSpace(c.AccountsId)) == 0 || want == (Resources{}) {
+		return nil
+	}
+	q, err := GetQuota(c.AccountsId)
+	if err != nil {
+		return err
+	}
+	return q.admit(want)
+}
+
+//the veto of a policy of the carton on the operation. The policies are run
+//on a copy of the boxes, the ones which set up the boxes leave them as is.
+func (c *Carton) planPolicies(op, compId string, units int) error {
+	boxes := make([]provision.Box, len(*c.Boxes))
+	copy(boxes, *c.Boxes)
+	for i := range boxes {
+		boxes[i].Envs = append(boxes[i].Envs[:0:0], boxes[i].Envs...)
+	}
+	cc := *c
+	cc.Boxes = &boxes
+	for _, o := range cc.policyOpts(op) {
+		o.Component = compId
+		o.Units = units
+		if err := enforcers[o.Policy.Type].Before(o); err != nil {
+			return &PolicyError{Policy: o.Policy.Name, Type: o.Policy.Type, Op: op, Err: err}
+		}
+	}
+	return nil
+}
+
+//plans the steps on a copy of the box, the status moves as it would without
+//the provisioner being called. Like Move, the box moves from its status saved
+//when there is one to read.
+func planBox(b provision.Box, level int, steps []planStep) *BoxPlan {
+	if provision.Statuses != nil {
+		if saved, err := provision.Statuses.Saved(&b); err == nil {
+			b.Status = saved
+		}
+	}
+	bp := &BoxPlan{
+		Name:        unitName(&b),
+		Level:       level,
+		Provisioner: b.Provider,
+		Actions:     make([]string, 0),
+		From:        b.Status.String(),
+	}
+	p, ok := ProvisionerMap[b.Provider]
+	if !ok {
+		bp.Error = fmt.Sprintf("unknown provisioner %s", b.Provider)
+		return bp
+	}
+	for _, s := range steps {
+		if err := b.DryMove(s.to); err != nil {
+			bp.Error = err.Error()
+			break
+		}
+		if planner, ok := p.(provision.Planner); ok {
+			bp.Actions = append(bp.Actions, planner.PlanActions(s.op, &b)...)
+		}
+	}
+	bp.To = b.Status.String()
+	return bp
+}
@@ -0,0 +1,61 @@
+package carton
+
+import (
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type PlanSuite struct{}
+
+var _ = check.Suite(&PlanSuite{})
+
+//a provisioner which can only plan.
+type planOnly struct {
+	provision.Provisioner
+}
+
+func (planOnly) PlanActions(op string, b *provision.Box) []string {
+	return []string{op + "-box"}
+}
+
+func (s *PlanSuite) SetUpTest(c *check.C) {
+	SetRepository(NewMemRepository())
+	ProvisionerMap["plan"] = planOnly{}
+}
+
+func (s *PlanSuite) TearDownTest(c *check.C) {
+	delete(ProvisionerMap, "plan")
+}
+
+func (s *PlanSuite) carton(status constants.Status) *Carton {
+	boxes := []provision.Box{
+		provision.Box{Id: "CMPweb", Name: "web", CartonName: "myapp", Provider: "plan", Status: status},
+		provision.Box{Id: "CMPdb", Name: "db", CartonName: "myapp", Provider: "plan", Status: constants.StatusRunning},
+	}
+	return &Carton{Id: "ASM001", Name: "myapp", Boxes: &boxes, levels: [][]int{[]int{1}, []int{0}}}
+}
+
+func (s *PlanSuite) TestPlanDestroy(c *check.C) {
+	ca := s.carton(constants.StatusRunning)
+	p, err := DestroyProcess{Name: "myapp"}.Plan(Cartons{ca})
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Request, check.Equals, "DESTROY CARTON myapp")
+	c.Assert(p.Cartons, check.HasLen, 1)
+	c.Assert(p.Cartons[0].Boxes, check.DeepEquals, []*BoxPlan{
+		&BoxPlan{Name: "web", Level: 0, Provisioner: "plan", Actions: []string{"destroy-box"}, From: "running", To: "destroying"},
+		&BoxPlan{Name: "db", Level: 1, Provisioner: "plan", Actions: []string{"destroy-box"}, From: "running", To: "destroying"},
+	})
+	c.Assert((*ca.Boxes)[0].Status, check.Equals, constants.StatusRunning)
+}
+
+func (s *PlanSuite) TestPlanStopSkipsAfterIllegalMove(c *check.C) {
+	ca := s.carton(constants.StatusLaunching)
+	ca.levels = [][]int{[]int{0}, []int{1}}
+	p, err := StopProcess{Name: "myapp"}.Plan(Cartons{ca})
+	c.Assert(err, check.IsNil)
+	boxes := p.Cartons[0].Boxes
+	c.Assert(boxes[0].Error, check.Equals, "box myapp can't move from launching to stopping")
+	c.Assert(boxes[0].To, check.Equals, "launching")
+	c.Assert(boxes[1].Error, check.Equals, ErrDependencyFailed.Error())
+}
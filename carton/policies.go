@@ -0,0 +1,152 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/megamsys/vertice/provision"
+)
+
+const (
+	//the policy types available are.
+	ANTI_AFFINITY = "anti-affinity"
+	HA            = "ha"
+	BIND          = "bind"
+
+	//the rule of a ha policy, the number of units to keep.
+	HA_UNITS         = "units"
+	DEFAULT_HA_UNITS = 2
+)
+
+func init() {
+	RegisterPolicy(ANTI_AFFINITY, antiAffinity{})
+	RegisterPolicy(HA, ha{})
+	RegisterPolicy(BIND, binder{})
+}
+
+//the boxes of the members are kept apart, the provisioners which place the
+//boxes on nodes don't put two boxes of the same group together. A member
+//whose provisioner doesn't is vetoed, rather than placed anywhere.
+type antiAffinity struct{}
+
+func (antiAffinity) Before(opts *PolicyOpts) error {
+	if opts.Op != POLICY_DEPLOY && opts.Op != POLICY_SCALE {
+		return nil
+	}
+	for _, b := range opts.Members {
+		if _, ok := ProvisionerMap[b.Provider].(provision.Separator); !ok {
+			return fmt.Errorf("%s can't be kept apart, its provisioner %q doesn't", b.Name, b.Provider)
+		}
+	}
+	for _, b := range opts.Members {
+		b.AntiAffinity = opts.Policy.Name
+	}
+	return nil
+}
+
+func (antiAffinity) After(opts *PolicyOpts) error { return nil }
+
+//every member keeps atleast the units in the rules of the policy. A member
+//deployed with fewer is scaled up to them, and a scale to fewer is vetoed.
+type ha struct{}
+
+func (ha) Before(opts *PolicyOpts) error {
+	if opts.Op != POLICY_SCALE {
+		return nil
+	}
+	want := haUnits(opts.Policy)
+	for _, b := range firstUnits(opts.Members) {
+		if len(opts.Component) > 0 && b.Id != opts.Component {
+			continue
+		}
+		units := opts.Units
+		if units <= 0 {
+			comp, err := NewComponent(b.Id)
+			if err != nil {
+				return err
+			}
+			units = comp.units()
+		}
+		if units < want {
+			return fmt.Errorf("%s needs %d units, the scale is to %d", b.Name, want, units)
+		}
+	}
+	return nil
+}
+
+func (ha) After(opts *PolicyOpts) error {
+	if opts.Op != POLICY_DEPLOY {
+		return nil
+	}
+	want := haUnits(opts.Policy)
+	byComp := opts.Carton.unitsByComponent()
+	for _, b := range firstUnits(opts.Members) {
+		if len(byComp[b.Id]) >= want {
+			continue
+		}
+		comp, err := NewComponent(b.Id)
+		if err != nil {
+			return err
+		}
+		if err := opts.Carton.scaleComponent(comp, byComp[b.Id], want).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//the units the rules of the policy keep.
+func haUnits(p *Policy) int {
+	if u := p.Rules.Match(HA_UNITS); len(strings.TrimSpace(u)) > 0 {
+		return atoiOrOne(u)
+	}
+	return DEFAULT_HA_UNITS
+}
+
+//the first unit of every component among the boxes.
+func firstUnits(boxes []*provision.Box) []*provision.Box {
+	first := make([]*provision.Box, 0, len(boxes))
+	for _, b := range boxes {
+		if b.Level == provision.BoxSome && b.Unit == 0 {
+			first = append(first, b)
+		}
+	}
+	return first
+}
+
+//the members are linked, every member gets the connection details of the
+//others in its envs. A member with many units is reached at its first unit.
+type binder struct{}
+
+func (binder) Before(opts *PolicyOpts) error {
+	if opts.Op != POLICY_DEPLOY && opts.Op != POLICY_SCALE && opts.Op != POLICY_UPGRADE {
+		return nil
+	}
+	for _, b := range opts.Members {
+		for _, o := range opts.Members {
+			if o.Id == b.Id || o.Unit > 0 {
+				continue
+			}
+			envs := endpointEnvs(o, nil)
+			b.Envs = append(unbound(b.Envs, o.Name, envs), envs...)
+		}
+	}
+	return nil
+}
+
+func (binder) After(opts *PolicyOpts) error { return nil }
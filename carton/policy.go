@@ -0,0 +1,124 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+)
+
+const (
+	//the operations on which the policies are enforced.
+	POLICY_DEPLOY  = "deploy"
+	POLICY_DESTROY = "destroy"
+	POLICY_START   = "start"
+	POLICY_STOP    = "stop"
+	POLICY_RESTART = "restart"
+	POLICY_UPGRADE = "upgrade"
+	POLICY_SCALE   = "scale"
+	POLICY_RESIZE  = "resize"
+)
+
+// PolicyOpts carries a policy of an assembly, the operation on hand and the
+// boxes of the member components.
+type PolicyOpts struct {
+	Policy    *Policy
+	Op        string
+	Carton    *Carton
+	Members   []*provision.Box
+	Component string //the component a scale is of, every one when blank
+	Units     int    //the units a scale is to, none when they are the ones in the inputs
+}
+
+// Enforcer enforces a type of policy. Before runs ahead of an operation and can
+// veto it by returning an error, After runs once the operation succeeded.
+type Enforcer interface {
+	Before(opts *PolicyOpts) error
+	After(opts *PolicyOpts) error
+}
+
+var enforcers = make(map[string]Enforcer)
+
+// RegisterPolicy registers the enforcer of a policy type.
+func RegisterPolicy(policyType string, e Enforcer) {
+	enforcers[policyType] = e
+}
+
+// PolicyError is returned when a policy vetoes an operation or fails after it.
+type PolicyError struct {
+	Policy string
+	Type   string
+	Op     string
+	Err    error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy %s (%s) on %s: %s", e.Policy, e.Type, e.Op, e.Err)
+}
+
+//the boxes of the member components, a member is matched by its id or name.
+func (c *Carton) members(p *Policy) []*provision.Box {
+	boxes := *c.Boxes
+	mb := make([]*provision.Box, 0, len(boxes))
+	for i := range boxes {
+		for _, m := range p.Members {
+			m = strings.TrimSpace(m)
+			if m == boxes[i].Id || m == boxes[i].Name {
+				mb = append(mb, &boxes[i])
+				break
+			}
+		}
+	}
+	return mb
+}
+
+//the policies of the carton which have an enforcer, the ones without are ignored.
+func (c *Carton) policyOpts(op string) []*PolicyOpts {
+	po := make([]*PolicyOpts, 0, len(c.Policies))
+	for _, p := range c.Policies {
+		if _, ok := enforcers[p.Type]; !ok {
+			log.Debugf("  no enforcer for policy %s (%s), ignoring", p.Name, p.Type)
+			continue
+		}
+		po = append(po, &PolicyOpts{Policy: p, Op: op, Carton: c, Members: c.members(p)})
+	}
+	return po
+}
+
+// enforce runs fn for the operation with the policies of the carton enforced.
+func (c *Carton) enforce(op string, fn func() error) error {
+	return c.enforceOpts(op, c.policyOpts(op), fn)
+}
+
+func (c *Carton) enforceOpts(op string, po []*PolicyOpts, fn func() error) error {
+	for _, o := range po {
+		if err := enforcers[o.Policy.Type].Before(o); err != nil {
+			return &PolicyError{Policy: o.Policy.Name, Type: o.Policy.Type, Op: op, Err: err}
+		}
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	for _, o := range po {
+		if err := enforcers[o.Policy.Type].After(o); err != nil {
+			return &PolicyError{Policy: o.Policy.Name, Type: o.Policy.Type, Op: op, Err: err}
+		}
+	}
+	return nil
+}
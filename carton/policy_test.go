@@ -0,0 +1,142 @@
+package carton
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/megamsys/libgo/pairs"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type PolicySuite struct{}
+
+var _ = check.Suite(&PolicySuite{})
+
+type vetoer struct {
+	ops []string
+}
+
+func (v *vetoer) Before(opts *PolicyOpts) error {
+	v.ops = append(v.ops, "before "+opts.Op)
+	if opts.Op == POLICY_STOP {
+		return errors.New("not now")
+	}
+	return nil
+}
+
+func (v *vetoer) After(opts *PolicyOpts) error {
+	v.ops = append(v.ops, "after "+opts.Op)
+	return nil
+}
+
+//a provisioner which keeps the boxes of an anti-affinity group apart.
+type separator struct {
+	provision.Provisioner
+}
+
+func (separator) KeepsApart() {}
+
+func (s *PolicySuite) carton(p *Policy) *Carton {
+	boxes := []provision.Box{
+		provision.Box{Id: "CMPweb", Name: "web", Provider: "apart"},
+		provision.Box{Id: "CMPweb", Name: "web", Unit: 1, Provider: "apart"},
+		provision.Box{Id: "CMPdb", Name: "db", Provider: "apart"},
+	}
+	return &Carton{Name: "myapp", Boxes: &boxes, Policies: []*Policy{p}}
+}
+
+func (s *PolicySuite) TestEnforce(c *check.C) {
+	v := &vetoer{}
+	RegisterPolicy("vetoer", v)
+	defer delete(enforcers, "vetoer")
+	ca := s.carton(&Policy{Name: "careful", Type: "vetoer", Members: []string{"web"}})
+
+	ran := false
+	c.Assert(ca.enforce(POLICY_START, func() error {
+		ran = true
+		return nil
+	}), check.IsNil)
+	c.Assert(ran, check.Equals, true)
+
+	err := ca.enforce(POLICY_STOP, func() error {
+		c.Fatal("vetoed operation ran")
+		return nil
+	})
+	c.Assert(err, check.FitsTypeOf, &PolicyError{})
+	c.Assert(err.Error(), check.Equals, "policy careful (vetoer) on stop: not now")
+	c.Assert(v.ops, check.DeepEquals, []string{"before start", "after start", "before stop"})
+}
+
+func (s *PolicySuite) TestEnforceUnknownPolicy(c *check.C) {
+	ca := s.carton(&Policy{Name: "odd", Type: "unknown"})
+	c.Assert(ca.enforce(POLICY_DEPLOY, func() error { return nil }), check.IsNil)
+}
+
+func (s *PolicySuite) TestHAVetoesAScaleToFewerUnits(c *check.C) {
+	ca := s.carton(&Policy{Name: "keep", Type: HA, Members: []string{"web", "CMPdb"}})
+	po := ca.policyOpts(POLICY_SCALE)
+	po[0].Units = 1
+	err := ca.enforceOpts(POLICY_SCALE, po, func() error {
+		c.Fatal("vetoed scale ran")
+		return nil
+	})
+	c.Assert(err, check.ErrorMatches, ".*web needs 2 units, the scale is to 1")
+	po[0].Units = 2
+	c.Assert(ca.enforceOpts(POLICY_SCALE, po, func() error { return nil }), check.IsNil)
+
+	rules := make(pairs.JsonPairs, 0)
+	rules.NukeAndSet(map[string][]string{HA_UNITS: []string{"3"}})
+	ca = s.carton(&Policy{Name: "keep", Type: HA, Members: []string{"db"}, Rules: rules})
+	po = ca.policyOpts(POLICY_SCALE)
+	po[0].Units = 2
+	c.Assert(ca.enforceOpts(POLICY_SCALE, po, func() error { return nil }), check.ErrorMatches, ".*db needs 3 units, the scale is to 2")
+}
+
+func (s *PolicySuite) TestBindAndAntiAffinity(c *check.C) {
+	ca := s.carton(&Policy{Name: "link", Type: BIND, Members: []string{"web", "db"}})
+	c.Assert(ca.enforce(POLICY_DEPLOY, func() error { return nil }), check.IsNil)
+	boxes := *ca.Boxes
+	c.Assert(boxes[2].Envs, check.HasLen, 1)
+	c.Assert(boxes[2].Envs[0].Name, check.Equals, "WEB_HOST")
+	c.Assert(boxes[0].Envs[0].Name, check.Equals, "DB_HOST")
+
+	ProvisionerMap["apart"] = separator{}
+	defer delete(ProvisionerMap, "apart")
+	ca = s.carton(&Policy{Name: "apart", Type: ANTI_AFFINITY, Members: []string{"web"}})
+	c.Assert(ca.enforce(POLICY_DEPLOY, func() error { return nil }), check.IsNil)
+	c.Assert((*ca.Boxes)[1].AntiAffinity, check.Equals, "apart")
+	c.Assert((*ca.Boxes)[2].AntiAffinity, check.Equals, "")
+}
+
+func (s *PolicySuite) TestAntiAffinityVetoesAProvisionerWhichCantKeepApart(c *check.C) {
+	ca := s.carton(&Policy{Name: "apart", Type: ANTI_AFFINITY, Members: []string{"web"}})
+	(*ca.Boxes)[0].Provider = "docker"
+	ran := false
+	err := ca.enforce(POLICY_DEPLOY, func() error { ran = true; return nil })
+	c.Assert(err, check.ErrorMatches, `.*web can't be kept apart, its provisioner "docker" doesn't`)
+	c.Assert(ran, check.Equals, false)
+	c.Assert((*ca.Boxes)[1].AntiAffinity, check.Equals, "")
+}
+
+func (s *FlowSuite) TestHAScalesUpTheMembersDeployed(c *check.C) {
+	p, err := json.Marshal(Policy{Name: "keep", Type: HA, Members: []string{"db"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.repo.UpdateAssembly("ASM001", "ORG001", map[string]interface{}{"Policies": []string{string(p)}}), check.IsNil)
+
+	s.run(c, "RIP001", STATE, CREATE)
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy"})
+	wanted, running := s.units(c, "CMPdb")
+	c.Assert(wanted, check.Equals, "2")
+	c.Assert(running, check.Equals, "2")
+
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(cs.planScale("CMPdb", 1).Error, check.Matches, ".*db needs 2 units, the scale is to 1")
+	c.Assert(cs.planScale("CMPdb", 3).Error, check.Equals, "")
+	c.Assert(cs.planScale("CMPweb", 1).Error, check.Equals, "")
+	err = s.scaleTo(c, "RIP002", "CMPdb", 1)
+	c.Assert(err, check.ErrorMatches, ".*db needs 2 units, the scale is to 1")
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy"})
+}
@@ -0,0 +1,392 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/events"
+	"github.com/megamsys/libgo/events/alerts"
+	"github.com/megamsys/libgo/pairs"
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+)
+
+const (
+	QUOTASBUCKET = "quotas"
+
+	//the status in the event of a request rejected by the quota.
+	QUOTA_EXCEEDED = "quotaexceeded"
+
+	//the output which tells a unit holds its resources in the quota of its
+	//account, indexed by the unit (reserved-1).
+	RESERVED = "reserved"
+)
+
+// Resources are the cpushare, memory (MB), hdd (MB) and the count of
+// boxes held by an account.
+type Resources struct {
+	Cpushare uint64
+	Memory   uint64
+	HDD      uint64
+	Boxes    uint64
+}
+
+// DefaultQuota is the limit of an account which has none of its own, set by
+// the daemons from their config. A zero is unlimited.
+var DefaultQuota = Resources{}
+
+//guards the read, check and save of the usage of an account.
+var quotaMu sync.Mutex
+
+// Quota is the limits of an account along with its usage. A limit which
+// isn't set falls back to DefaultQuota.
+type Quota struct {
+	AccountsId   string `json:"account_id" cql:"account_id"`
+	Cpushare     uint64 `json:"cpushare" cql:"cpushare"`
+	Memory       uint64 `json:"memory" cql:"memory"`
+	HDD          uint64 `json:"hdd" cql:"hdd"`
+	Boxes        uint64 `json:"boxes" cql:"boxes"`
+	UsedCpushare uint64 `json:"used_cpushare" cql:"used_cpushare"`
+	UsedMemory   uint64 `json:"used_memory" cql:"used_memory"`
+	UsedHDD      uint64 `json:"used_hdd" cql:"used_hdd"`
+	UsedBoxes    uint64 `json:"used_boxes" cql:"used_boxes"`
+}
+
+// QuotaError is returned when an account would go beyond its quota.
+type QuotaError struct {
+	AccountsId string
+	Exceeded   []string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota of account %s exceeded: %s", e.AccountsId, strings.Join(e.Exceeded, ", "))
+}
+
+//the resources the boxes take.
+func boxResources(boxes ...*provision.Box) Resources {
+	r := Resources{}
+	for _, b := range boxes {
+		r.Cpushare += b.GetCpushare()
+		r.Memory += b.GetMemory()
+		r.HDD += b.GetHDD()
+		r.Boxes++
+	}
+	return r
+}
+
+func (r Resources) plus(o Resources) Resources {
+	return Resources{
+		Cpushare: r.Cpushare + o.Cpushare,
+		Memory:   r.Memory + o.Memory,
+		HDD:      r.HDD + o.HDD,
+		Boxes:    r.Boxes + o.Boxes,
+	}
+}
+
+//the usage never drops below zero, the boxes launched before the quotas
+//were tracked are released too.
+func (r Resources) minus(o Resources) Resources {
+	sub := func(a, b uint64) uint64 {
+		if b > a {
+			return 0
+		}
+		return a - b
+	}
+	return Resources{
+		Cpushare: sub(r.Cpushare, o.Cpushare),
+		Memory:   sub(r.Memory, o.Memory),
+		HDD:      sub(r.HDD, o.HDD),
+		Boxes:    sub(r.Boxes, o.Boxes),
+	}
+}
+
+// GetQuota returns the quota of the account, an account which has none
+// gets the default. A failed read is an error, so that the limits and the
+// usage of the account aren't overwritten after it.
+func GetQuota(accountsId string) (*Quota, error) {
+	q, err := store.GetQuota(accountsId)
+	if err == ErrNotFound {
+		log.Debugf("no quota found for (%s), using the default.", accountsId)
+		return &Quota{AccountsId: accountsId}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// SetQuota sets the limits of the account, its usage is left as is.
+func SetQuota(accountsId string, limit Resources) error {
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	q, err := GetQuota(accountsId)
+	if err != nil {
+		return err
+	}
+	q.Cpushare, q.Memory, q.HDD, q.Boxes = limit.Cpushare, limit.Memory, limit.HDD, limit.Boxes
+	return store.StoreQuota(q)
+}
+
+// Limit returns the limits of the account, along with the defaults for the
+// ones it hasn't got.
+func (q *Quota) Limit() Resources {
+	or := func(a, b uint64) uint64 {
+		if a > 0 {
+			return a
+		}
+		return b
+	}
+	return Resources{
+		Cpushare: or(q.Cpushare, DefaultQuota.Cpushare),
+		Memory:   or(q.Memory, DefaultQuota.Memory),
+		HDD:      or(q.HDD, DefaultQuota.HDD),
+		Boxes:    or(q.Boxes, DefaultQuota.Boxes),
+	}
+}
+
+// Used returns the resources held by the account.
+func (q *Quota) Used() Resources {
+	return Resources{Cpushare: q.UsedCpushare, Memory: q.UsedMemory, HDD: q.UsedHDD, Boxes: q.UsedBoxes}
+}
+
+func (q *Quota) setUsed(r Resources) {
+	q.UsedCpushare, q.UsedMemory, q.UsedHDD, q.UsedBoxes = r.Cpushare, r.Memory, r.HDD, r.Boxes
+}
+
+//returns a QuotaError when the account can't take the resources wanted.
+func (q *Quota) admit(want Resources) error {
+	limit, used := q.Limit(), q.Used().plus(want)
+	exceeded := make([]string, 0)
+	check := func(name string, u, l uint64) {
+		if l > 0 && u > l {
+			exceeded = append(exceeded, fmt.Sprintf("%s %d of %d", name, u, l))
+		}
+	}
+	check("cpushare", used.Cpushare, limit.Cpushare)
+	check("memory", used.Memory, limit.Memory)
+	check("hdd", used.HDD, limit.HDD)
+	check("boxes", used.Boxes, limit.Boxes)
+	if len(exceeded) > 0 {
+		return &QuotaError{AccountsId: q.AccountsId, Exceeded: exceeded}
+	}
+	return nil
+}
+
+//takes the resources from the quota of the account, or else fails with a
+//QuotaError. The boxes without an account aren't tracked.
+func reserve(accountsId string, want Resources) error {
+	if len(strings.TrimSpace(accountsId)) == 0 {
+		return nil
+	}
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	q, err := GetQuota(accountsId)
+	if err != nil {
+		return err
+	}
+	if err := q.admit(want); err != nil {
+		return err
+	}
+	q.setUsed(q.Used().plus(want))
+	return store.StoreQuota(q)
+}
+
+//gives back the resources to the quota of the account.
+func release(accountsId string, r Resources) error {
+	if len(strings.TrimSpace(accountsId)) == 0 {
+		return nil
+	}
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	q, err := GetQuota(accountsId)
+	if err != nil {
+		return err
+	}
+	q.setUsed(q.Used().minus(r))
+	return store.StoreQuota(q)
+}
+
+//the resources taken by all the boxes of the carton.
+func (c *Carton) resources() Resources {
+	boxes := *c.Boxes
+	ptrs := make([]*provision.Box, len(boxes))
+	for i := range boxes {
+		ptrs[i] = &boxes[i]
+	}
+	return boxResources(ptrs...)
+}
+
+//the boxes of the carton which aren't launched, the ones launched by an
+//earlier attempt of the create hold their resources already.
+func (c *Carton) unlaunched() []*provision.Box {
+	boxes := *c.Boxes
+	ptrs := make([]*provision.Box, 0, len(boxes))
+	for i := range boxes {
+		if !launched(&boxes[i]) {
+			ptrs = append(ptrs, &boxes[i])
+		}
+	}
+	return ptrs
+}
+
+//a box which made it through its launch.
+func launched(b *provision.Box) bool {
+	switch b.Status {
+	case "", constants.StatusLaunching, constants.StatusError, constants.StatusDestroying:
+		return false
+	}
+	return true
+}
+
+//takes the resources of the boxes of the carton which aren't launched (nor
+//hold them since an earlier attempt) from the quota of its account, the user
+//is told when it is exceeded.
+func (c *Carton) reserve() ([]*provision.Box, error) {
+	boxes := make([]*provision.Box, 0)
+	for _, b := range c.unlaunched() {
+		held, err := holds(b)
+		if err != nil {
+			return nil, err
+		}
+		if !held {
+			boxes = append(boxes, b)
+		}
+	}
+	if err := c.reserveResources(boxResources(boxes...)); err != nil {
+		return nil, err
+	}
+	return boxes, setHeld(boxes, true)
+}
+
+//gives back the resources of the boxes reserved for, which didn't launch.
+func (c *Carton) releaseUnlaunched(reserved []*provision.Box) {
+	failed := make([]*provision.Box, 0, len(reserved))
+	for _, b := range reserved {
+		if !launched(b) {
+			failed = append(failed, b)
+		}
+	}
+	if err := releaseHeld(c.AccountsId, failed...); err != nil {
+		log.Errorf("  unable to release the quota of (%s) %s", c.Name, err)
+	}
+}
+
+//takes the resources of the boxes from the quota of the account, and records
+//that they hold them.
+func hold(accountsId string, boxes ...*provision.Box) error {
+	if err := reserve(accountsId, boxResources(boxes...)); err != nil {
+		return err
+	}
+	return setHeld(boxes, true)
+}
+
+//gives back the resources of the boxes which hold them to the quota of the
+//account, a box holds its resources once. A box which gave them back (eg: a
+//failed create) or never took them (eg: launched before the quota) gives
+//nothing back on a destroy.
+func releaseHeld(accountsId string, boxes ...*provision.Box) error {
+	held := make([]*provision.Box, 0, len(boxes))
+	for _, b := range boxes {
+		ok, err := holds(b)
+		if err != nil {
+			return err
+		}
+		if ok {
+			held = append(held, b)
+		}
+	}
+	if len(held) == 0 {
+		return nil
+	}
+	if err := setHeld(held, false); err != nil {
+		return err
+	}
+	return release(accountsId, boxResources(held...))
+}
+
+//whether the unit of the box holds its resources, as recorded in the outputs
+//of its component. A component which is gone holds nothing.
+func holds(b *provision.Box) (bool, error) {
+	if b.Level != provision.BoxSome {
+		return false, nil
+	}
+	comp, err := NewComponent(b.Id)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(comp.Outputs.Match(provision.UnitKey(RESERVED, b.Unit))) > 0, nil
+}
+
+//records (or forgets) that the units of the boxes hold their resources.
+func setHeld(boxes []*provision.Box, held bool) error {
+	for _, b := range boxes {
+		if b.Level != provision.BoxSome {
+			continue
+		}
+		comp := &Component{Id: b.Id}
+		key := provision.UnitKey(RESERVED, b.Unit)
+		var err error
+		if held {
+			err = comp.NukeAndSetOutputs(map[string][]string{key: []string{"true"}})
+		} else {
+			err = comp.nukeOutputs(key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Carton) reserveResources(want Resources) error {
+	err := reserve(c.AccountsId, want)
+	if qe, ok := err.(*QuotaError); ok {
+		if everr := c.quotaEvent(qe); everr != nil {
+			log.Errorf("  unable to notify the quota exceeded for (%s) %s", c.Name, everr)
+		}
+	}
+	return err
+}
+
+func (c *Carton) quotaEvent(qe *QuotaError) error {
+	mi := make(map[string]string)
+	js := make(pairs.JsonPairs, 0)
+	m := make(map[string][]string, 2)
+	m["status"] = []string{QUOTA_EXCEEDED}
+	m["description"] = []string{qe.Error()}
+	js.NukeAndSet(m) //just nuke the matching output key:
+
+	mi[constants.ASSEMBLY_ID] = c.Id
+	mi[constants.ACCOUNT_ID] = c.AccountsId
+
+	newEvent := events.NewMulti(
+		[]*events.Event{
+			&events.Event{
+				AccountsId:  c.AccountsId,
+				EventAction: alerts.STATUS,
+				EventType:   constants.EventUser,
+				EventData:   alerts.EventData{M: mi, D: js.ToString()},
+				Timestamp:   time.Now().Local(),
+			},
+		})
+	return newEvent.Write()
+}
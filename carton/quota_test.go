@@ -0,0 +1,150 @@
+package carton
+
+import (
+	"errors"
+
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+type QuotaSuite struct{}
+
+var _ = check.Suite(&QuotaSuite{})
+
+func (s *QuotaSuite) SetUpTest(c *check.C) {
+	SetRepository(NewMemRepository())
+}
+
+func (s *QuotaSuite) TearDownTest(c *check.C) {
+	DefaultQuota = Resources{}
+}
+
+func (s *QuotaSuite) TestBoxResources(c *check.C) {
+	b := &provision.Box{Compute: provision.BoxCompute{Cpushare: "2", Memory: "1G", HDD: "10G SSD"}}
+	r := boxResources(b, b)
+	c.Assert(r, check.DeepEquals, Resources{Cpushare: 4, Memory: 2048, HDD: 20480, Boxes: 2})
+}
+
+func (s *QuotaSuite) TestAdmit(c *check.C) {
+	q := &Quota{AccountsId: "ACT001", Boxes: 2, UsedBoxes: 1}
+	c.Assert(q.admit(Resources{Boxes: 1, Memory: 4096}), check.IsNil)
+	err := q.admit(Resources{Boxes: 2})
+	c.Assert(err, check.ErrorMatches, "quota of account ACT001 exceeded: boxes 3 of 2")
+}
+
+func (s *QuotaSuite) TestLimitFallsBackToTheDefault(c *check.C) {
+	DefaultQuota = Resources{Memory: 1024, Boxes: 3}
+	q := &Quota{AccountsId: "ACT001", Boxes: 5}
+	c.Assert(q.Limit(), check.DeepEquals, Resources{Memory: 1024, Boxes: 5})
+	_, ok := q.admit(Resources{Memory: 2048}).(*QuotaError)
+	c.Assert(ok, check.Equals, true)
+}
+
+func (s *QuotaSuite) TestReserveAndRelease(c *check.C) {
+	c.Assert(SetQuota("ACT001", Resources{Boxes: 2}), check.IsNil)
+	c.Assert(reserve("ACT001", Resources{Cpushare: 1, Boxes: 2}), check.IsNil)
+	_, ok := reserve("ACT001", Resources{Boxes: 1}).(*QuotaError)
+	c.Assert(ok, check.Equals, true)
+
+	c.Assert(release("ACT001", Resources{Cpushare: 2, Boxes: 1}), check.IsNil)
+	q, err := GetQuota("ACT001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Boxes: 1})
+	c.Assert(reserve("ACT001", Resources{Boxes: 1}), check.IsNil)
+}
+
+func (s *QuotaSuite) TestAFailedReadKeepsTheQuota(c *check.C) {
+	m := NewMemRepository()
+	SetRepository(m)
+	c.Assert(SetQuota("ACT001", Resources{Boxes: 2}), check.IsNil)
+	c.Assert(reserve("ACT001", Resources{Boxes: 1}), check.IsNil)
+
+	m.FailReads(errors.New("timed out"))
+	_, err := GetQuota("ACT001")
+	c.Assert(err, check.ErrorMatches, "timed out")
+	c.Assert(reserve("ACT001", Resources{Boxes: 1}), check.ErrorMatches, "timed out")
+	c.Assert(release("ACT001", Resources{Boxes: 1}), check.ErrorMatches, "timed out")
+	c.Assert(SetQuota("ACT001", Resources{Boxes: 9}), check.ErrorMatches, "timed out")
+
+	m.FailReads(nil)
+	q, err := GetQuota("ACT001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Limit(), check.DeepEquals, Resources{Boxes: 2})
+	c.Assert(q.Used(), check.DeepEquals, Resources{Boxes: 1})
+}
+
+func (s *FlowSuite) TestFailedCreateReleasesTheQuota(c *check.C) {
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 2}), check.IsNil)
+	s.prov.PrepareFailure("deploy", errors.New("no room on the host"))
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(CreateProcess{}.Process(Cartons{cs}), check.ErrorMatches, ".*no room on the host.*")
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{})
+
+	cs, err = mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(CreateProcess{}.Process(Cartons{cs}), check.IsNil)
+	q, err = GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Boxes: 2})
+}
+
+func (s *FlowSuite) TestCreatePlanShowsTheQuotaExceeded(c *check.C) {
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 1}), check.IsNil)
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	p, err := CreateProcess{}.Plan(Cartons{cs})
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Cartons, check.HasLen, 1)
+	c.Assert(p.Cartons[0].Error, check.Matches, "quota of account ORG001 exceeded.*")
+	for _, bp := range p.Cartons[0].Boxes {
+		c.Assert(bp.Error, check.Equals, p.Cartons[0].Error)
+	}
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{})
+
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 2}), check.IsNil)
+	p, err = CreateProcess{}.Plan(Cartons{cs})
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Cartons[0].Error, check.Equals, "")
+}
+
+func (s *FlowSuite) TestDestroyReleasesOnlyTheQuotaHeld(c *check.C) {
+	c.Assert(s.repo.UpdateAssembly("ASM001", "ORG001", map[string]interface{}{"AccountId": "ORG001"}), check.IsNil)
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 3}), check.IsNil)
+	c.Assert(reserve("ORG001", Resources{Boxes: 1}), check.IsNil) //held by the other boxes of the account.
+	s.prov.PrepareFailure("deploy", errors.New("no room on the host"))
+	c.Assert(s.runRequest(c, &Requests{Id: "RIP001", CatId: "AMS001", Category: STATE, Action: CREATE}), check.NotNil)
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Boxes: 1})
+
+	s.run(c, "RIP002", STATE, DESTROY)
+	q, err = GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Boxes: 1})
+}
+
+func (s *FlowSuite) TestDestroyReleasesTheQuotaOfTheCreate(c *check.C) {
+	c.Assert(s.repo.UpdateAssembly("ASM001", "ORG001", map[string]interface{}{"AccountId": "ORG001"}), check.IsNil)
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 3}), check.IsNil)
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Boxes: 2})
+
+	s.run(c, "RIP002", STATE, DESTROY)
+	q, err = GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{})
+}
+
+func (s *QuotaSuite) TestReserveWithoutAccount(c *check.C) {
+	DefaultQuota = Resources{Boxes: 1}
+	c.Assert(reserve("", Resources{Boxes: 5}), check.IsNil)
+}
@@ -0,0 +1,524 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/megamsys/gocql"
+	ldb "github.com/megamsys/libgo/db"
+	"github.com/megamsys/vertice/meta"
+)
+
+// ErrNotFound is the error of a Get of a row which isn't there.
+var ErrNotFound = errors.New("not found")
+
+// Repository stores the assemblies, components, requests and deploys
+// a carton is made of, along with the quotas of the accounts, the
+// schedules and expiries of the assemblies and the parked requests. The fields of an update are keyed by the field
+// names of the stored struct. A Get of a row which isn't there is
+// ErrNotFound, any other error is a failed read. A Swap sets the fields to
+// when the ones saved are still from, and tells if it did, so that two
+// daemons don't both act upon the same values.
+type Repository interface {
+	GetAssemblies(id string) (*Assemblies, error)
+	StoreAssemblies(a *Assemblies) error
+	DeleteAssemblies(id, orgId string) error
+
+	GetAssembly(id string) (*Ambly, error)
+	StoreAssembly(a *Ambly) error
+	UpdateAssembly(id, orgId string, fields map[string]interface{}) error
+	SwapAssembly(id, orgId string, from, to map[string]interface{}) (bool, error)
+	DeleteAssembly(id, orgId string) error
+
+	GetComponent(id string) (*ComponentTable, error)
+	StoreComponent(c *ComponentTable) error
+	UpdateComponent(id string, fields map[string]interface{}) error
+	SwapComponent(id string, from, to map[string]interface{}) (bool, error)
+	DeleteComponent(id string) error
+
+	GetRequest(id string) (*Requests, error)
+	StoreRequest(r *Requests) error
+	UpdateRequest(id string, fields map[string]interface{}) error
+	SwapRequest(id string, from, to map[string]interface{}) (bool, error)
+	RunningIds() ([]string, error)
+
+	GetDeploys(asmid, compid string) (*Deploys, error)
+	StoreDeploys(d *Deploys) error
+	DeleteDeploys(asmid, compid string) error
+
+	GetQuota(accountsId string) (*Quota, error)
+	StoreQuota(q *Quota) error
+
+	GetSSHKey(orgId, name string) (*SSHKey, error)
+	StoreSSHKey(k *SSHKey) error
+
+	GetSchedules(catId string) (*Schedules, error)
+	StoreSchedules(s *Schedules) error
+	ScheduledIds() ([]string, error)
+
+	GetExpiry(catId string) (*Expiry, error)
+	StoreExpiry(e *Expiry) error
+	DeleteExpiry(catId string) error
+	ExpiringIds() ([]string, error)
+
+	GetDeadLetter(id string) (*DeadLetter, error)
+	StoreDeadLetter(d *DeadLetter) error
+	DeleteDeadLetter(id string) error
+	DeadLetterIds() ([]string, error)
+}
+
+//the repository used by carton, scylla unless set otherwise.
+var store Repository = &scyllaRepository{}
+
+// SetRepository makes carton use the repository r, eg: the one in memory
+// for tests.
+func SetRepository(r Repository) {
+	store = r
+}
+
+//the repository in scylla, the hosts are read when used as the config
+//is loaded after the package.
+type scyllaRepository struct{}
+
+func (s *scyllaRepository) options(table string, pks, ccms map[string]interface{}) ldb.Options {
+	ops := ldb.Options{
+		TableName:   table,
+		Pks:         []string{},
+		Ccms:        []string{},
+		Hosts:       meta.MC.Scylla,
+		Keyspace:    meta.MC.ScyllaKeyspace,
+		PksClauses:  pks,
+		CcmsClauses: ccms,
+	}
+	for k := range pks {
+		ops.Pks = append(ops.Pks, k)
+	}
+	for k := range ccms {
+		ops.Ccms = append(ops.Ccms, k)
+	}
+	return ops
+}
+
+//fetch and update by Id, delete by id along with the clustering org_id.
+func (s *scyllaRepository) byId(table, id string) ldb.Options {
+	return s.options(table, map[string]interface{}{"Id": id}, make(map[string]interface{}))
+}
+
+//fetches the row into data, a row which isn't there is ErrNotFound as it is
+//in memory, so that it isn't told apart from a failed read by the message.
+func (s *scyllaRepository) fetch(ops ldb.Options, data interface{}) error {
+	err := ldb.Fetchdb(ops, data)
+	if err != nil && isNotFound(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+//gocql tells a missing row by its ErrNotFound, and gocassa (which the ldb
+//helpers read with) by its RowNotFoundError. Any other error, whatever its
+//message, is a failed read.
+func isNotFound(err error) bool {
+	if err == gocql.ErrNotFound {
+		return true
+	}
+	t := reflect.TypeOf(err)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name() == "RowNotFoundError" && strings.HasSuffix(t.PkgPath(), "gocassa")
+}
+
+//the session of the conditional writes, which the ldb helpers don't do.
+var (
+	sessionMu sync.Mutex
+	session   *gocql.Session
+)
+
+func (s *scyllaRepository) session() (*gocql.Session, error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if session != nil {
+		return session, nil
+	}
+	cluster := gocql.NewCluster(meta.MC.Scylla...)
+	cluster.Keyspace = meta.MC.ScyllaKeyspace
+	cluster.Consistency = gocql.Quorum
+	ses, err := cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	session = ses
+	return session, nil
+}
+
+//the column of the field of the row, as tagged.
+func column(row interface{}, field string) (string, error) {
+	f, ok := reflect.TypeOf(row).Elem().FieldByName(field)
+	if !ok || len(f.Tag.Get("cql")) == 0 {
+		return "", fmt.Errorf("unknown field %s in %T", field, row)
+	}
+	return f.Tag.Get("cql"), nil
+}
+
+//sets the columns of the fields to to when they are still from, with a
+//lightweight transaction. A row which isn't there isn't swapped.
+func (s *scyllaRepository) swap(table string, row interface{}, keys, from, to map[string]interface{}) (bool, error) {
+	ses, err := s.session()
+	if err != nil {
+		return false, err
+	}
+	sets, where, ifs := make([]string, 0, len(to)), make([]string, 0, len(keys)), make([]string, 0, len(from))
+	args := make([]interface{}, 0, len(to)+len(keys)+len(from))
+	for field, v := range to {
+		col, err := column(row, field)
+		if err != nil {
+			return false, err
+		}
+		sets = append(sets, col+" = ?")
+		args = append(args, v)
+	}
+	for k, v := range keys {
+		where = append(where, k+" = ?")
+		args = append(args, v)
+	}
+	for field, v := range from {
+		col, err := column(row, field)
+		if err != nil {
+			return false, err
+		}
+		//a blank text which was never set is null, which = '' doesn't match.
+		if str, ok := v.(string); ok && len(str) == 0 {
+			ifs = append(ifs, col+" IN (null, '')")
+			continue
+		}
+		ifs = append(ifs, col+" = ?")
+		args = append(args, v)
+	}
+	cql := fmt.Sprintf("UPDATE %s SET %s WHERE %s IF %s", table, strings.Join(sets, ", "), strings.Join(where, " AND "), strings.Join(ifs, " AND "))
+	return ses.Query(cql, args...).MapScanCAS(make(map[string]interface{}))
+}
+
+func (s *scyllaRepository) byOrg(table, id, orgId string) ldb.Options {
+	return s.options(table, map[string]interface{}{"id": id}, map[string]interface{}{"org_id": orgId})
+}
+
+func (s *scyllaRepository) GetAssemblies(id string) (*Assemblies, error) {
+	a := &Assemblies{}
+	if err := s.fetch(s.byId(ASSEMBLIESBUCKET, id), a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *scyllaRepository) StoreAssemblies(a *Assemblies) error {
+	return ldb.Storedb(s.byOrg(ASSEMBLIESBUCKET, a.Id, a.AccountsId), a)
+}
+
+func (s *scyllaRepository) DeleteAssemblies(id, orgId string) error {
+	return ldb.Deletedb(s.byOrg(ASSEMBLIESBUCKET, id, orgId), Assemblies{})
+}
+
+func (s *scyllaRepository) GetAssembly(id string) (*Ambly, error) {
+	a := &Ambly{}
+	if err := s.fetch(s.byId(ASSEMBLYBUCKET, id), a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *scyllaRepository) StoreAssembly(a *Ambly) error {
+	return ldb.Storedb(s.byOrg(ASSEMBLYBUCKET, a.Id, a.OrgId), a)
+}
+
+func (s *scyllaRepository) UpdateAssembly(id, orgId string, fields map[string]interface{}) error {
+	return ldb.Updatedb(s.byOrg(ASSEMBLYBUCKET, id, orgId), fields)
+}
+
+func (s *scyllaRepository) SwapAssembly(id, orgId string, from, to map[string]interface{}) (bool, error) {
+	return s.swap(ASSEMBLYBUCKET, &Ambly{}, map[string]interface{}{"id": id, "org_id": orgId}, from, to)
+}
+
+func (s *scyllaRepository) DeleteAssembly(id, orgId string) error {
+	return ldb.Deletedb(s.byOrg(ASSEMBLYBUCKET, id, orgId), Ambly{})
+}
+
+func (s *scyllaRepository) GetComponent(id string) (*ComponentTable, error) {
+	c := &ComponentTable{Id: id}
+	if err := s.fetch(s.byId(COMPBUCKET, id), c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *scyllaRepository) StoreComponent(c *ComponentTable) error {
+	return ldb.Storedb(s.byId(COMPBUCKET, c.Id), c)
+}
+
+func (s *scyllaRepository) UpdateComponent(id string, fields map[string]interface{}) error {
+	return ldb.Updatedb(s.byId(COMPBUCKET, id), fields)
+}
+
+func (s *scyllaRepository) SwapComponent(id string, from, to map[string]interface{}) (bool, error) {
+	return s.swap(COMPBUCKET, &ComponentTable{}, map[string]interface{}{"id": id}, from, to)
+}
+
+func (s *scyllaRepository) DeleteComponent(id string) error {
+	return ldb.Deletedb(s.options(COMPBUCKET, map[string]interface{}{"id": id}, make(map[string]interface{})), ComponentTable{})
+}
+
+func (s *scyllaRepository) GetRequest(id string) (*Requests, error) {
+	r := &Requests{}
+	if err := s.fetch(s.byId(REQUESTSBUCKET, id), r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *scyllaRepository) StoreRequest(r *Requests) error {
+	return ldb.Storedb(s.byId(REQUESTSBUCKET, r.Id), r)
+}
+
+func (s *scyllaRepository) UpdateRequest(id string, fields map[string]interface{}) error {
+	if err := ldb.Updatedb(s.byId(REQUESTSBUCKET, id), fields); err != nil {
+		return err
+	}
+	return s.reindexRunning(id, fields)
+}
+
+func (s *scyllaRepository) SwapRequest(id string, from, to map[string]interface{}) (bool, error) {
+	ok, err := s.swap(REQUESTSBUCKET, &Requests{}, map[string]interface{}{"id": id}, from, to)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.reindexRunning(id, to)
+}
+
+//the running requests are indexed a row each, added as one is running and
+//deleted once it isn't, so that the ones whose daemon is gone are found
+//without a scan of the requests. The daemons running requests at once each
+//write their own rows, and don't undo one another.
+func (s *scyllaRepository) reindexRunning(id string, fields map[string]interface{}) error {
+	status, ok := fields["Status"]
+	if !ok {
+		return nil
+	}
+	ses, err := s.session()
+	if err != nil {
+		return err
+	}
+	if status == REQ_RUNNING {
+		return ses.Query(fmt.Sprintf("INSERT INTO %s (id) VALUES (?)", RUNNINGBUCKET), id).Exec()
+	}
+	return ses.Query(fmt.Sprintf("DELETE FROM %s WHERE id = ?", RUNNINGBUCKET), id).Exec()
+}
+
+func (s *scyllaRepository) RunningIds() ([]string, error) {
+	ses, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	iter := ses.Query(fmt.Sprintf("SELECT id FROM %s", RUNNINGBUCKET)).Iter()
+	ids := make([]string, 0)
+	var id string
+	for iter.Scan(&id) {
+		ids = append(ids, id)
+	}
+	return ids, iter.Close()
+}
+
+func (s *scyllaRepository) deploys(asmid, compid string) ldb.Options {
+	return s.options(DEPLOYSBUCKET, map[string]interface{}{"assembly_id": asmid}, map[string]interface{}{"component_id": compid})
+}
+
+func (s *scyllaRepository) GetDeploys(asmid, compid string) (*Deploys, error) {
+	d := &Deploys{}
+	if err := s.fetch(s.deploys(asmid, compid), d); err != nil {
+		return nil, err
+	}
+	d.AssemblyId = asmid
+	d.ComponentId = compid
+	return d, nil
+}
+
+func (s *scyllaRepository) StoreDeploys(d *Deploys) error {
+	return ldb.Storedb(s.deploys(d.AssemblyId, d.ComponentId), d)
+}
+
+func (s *scyllaRepository) DeleteDeploys(asmid, compid string) error {
+	return ldb.Deletedb(s.deploys(asmid, compid), Deploys{})
+}
+
+func (s *scyllaRepository) quotas(accountsId string) ldb.Options {
+	return s.options(QUOTASBUCKET, map[string]interface{}{"account_id": accountsId}, make(map[string]interface{}))
+}
+
+func (s *scyllaRepository) GetQuota(accountsId string) (*Quota, error) {
+	q := &Quota{}
+	if err := s.fetch(s.quotas(accountsId), q); err != nil {
+		return nil, err
+	}
+	q.AccountsId = accountsId
+	return q, nil
+}
+
+func (s *scyllaRepository) StoreQuota(q *Quota) error {
+	return ldb.Storedb(s.quotas(q.AccountsId), q)
+}
+
+func (s *scyllaRepository) sshKeys(orgId, name string) ldb.Options {
+	return s.options(SSHKEYSBUCKET, map[string]interface{}{"org_id": orgId}, map[string]interface{}{"name": name})
+}
+
+func (s *scyllaRepository) GetSSHKey(orgId, name string) (*SSHKey, error) {
+	k := &SSHKey{}
+	if err := s.fetch(s.sshKeys(orgId, name), k); err != nil {
+		return nil, err
+	}
+	k.OrgId, k.Name = orgId, name
+	return k, nil
+}
+
+func (s *scyllaRepository) StoreSSHKey(k *SSHKey) error {
+	return ldb.Storedb(s.sshKeys(k.OrgId, k.Name), k)
+}
+
+func (s *scyllaRepository) schedules(catId string) ldb.Options {
+	return s.options(SCHEDULESBUCKET, map[string]interface{}{"cat_id": catId}, make(map[string]interface{}))
+}
+
+func (s *scyllaRepository) GetSchedules(catId string) (*Schedules, error) {
+	d := &Schedules{}
+	if err := s.fetch(s.schedules(catId), d); err != nil {
+		return nil, err
+	}
+	d.CatId = catId
+	return d, nil
+}
+
+//the assemblies which have schedules (or expire), and the parked requests
+//are kept in a set in a single row of the index table, so that the daemons
+//needn't scan the rows.
+type index struct {
+	Id     string   `cql:"id"`
+	CatIds []string `cql:"cat_ids"`
+}
+
+func (s *scyllaRepository) index(table string) ldb.Options {
+	return s.options(table, map[string]interface{}{"id": "all"}, make(map[string]interface{}))
+}
+
+//an index which isn't there yet is empty, a failed read is an error.
+func (s *scyllaRepository) indexed(table string) ([]string, error) {
+	all := &index{}
+	if err := s.fetch(s.index(table), all); err == ErrNotFound {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return all.CatIds, nil
+}
+
+//adds or removes the id in the set of the index table in place, so that the
+//daemons changing the index at once don't undo one another.
+func (s *scyllaRepository) reindex(table, id string, present bool) error {
+	ses, err := s.session()
+	if err != nil {
+		return err
+	}
+	op := "+"
+	if !present {
+		op = "-"
+	}
+	return ses.Query(fmt.Sprintf("UPDATE %s SET cat_ids = cat_ids %s ? WHERE id = ?", table, op), []string{id}, "all").Exec()
+}
+
+func (s *scyllaRepository) StoreSchedules(d *Schedules) error {
+	if err := ldb.Storedb(s.schedules(d.CatId), d); err != nil {
+		return err
+	}
+	return s.reindex(SCHEDULEDBUCKET, d.CatId, len(d.Schedules) > 0)
+}
+
+func (s *scyllaRepository) ScheduledIds() ([]string, error) {
+	return s.indexed(SCHEDULEDBUCKET)
+}
+
+func (s *scyllaRepository) expiries(catId string) ldb.Options {
+	return s.options(EXPIRIESBUCKET, map[string]interface{}{"cat_id": catId}, make(map[string]interface{}))
+}
+
+func (s *scyllaRepository) GetExpiry(catId string) (*Expiry, error) {
+	e := &Expiry{}
+	if err := s.fetch(s.expiries(catId), e); err != nil {
+		return nil, err
+	}
+	e.CatId = catId
+	return e, nil
+}
+
+func (s *scyllaRepository) StoreExpiry(e *Expiry) error {
+	if err := ldb.Storedb(s.expiries(e.CatId), e); err != nil {
+		return err
+	}
+	return s.reindex(EXPIRINGBUCKET, e.CatId, true)
+}
+
+func (s *scyllaRepository) DeleteExpiry(catId string) error {
+	if err := ldb.Deletedb(s.expiries(catId), Expiry{}); err != nil {
+		return err
+	}
+	return s.reindex(EXPIRINGBUCKET, catId, false)
+}
+
+func (s *scyllaRepository) ExpiringIds() ([]string, error) {
+	return s.indexed(EXPIRINGBUCKET)
+}
+
+func (s *scyllaRepository) deadLetters(id string) ldb.Options {
+	return s.options(DEADLETTERSBUCKET, map[string]interface{}{"id": id}, make(map[string]interface{}))
+}
+
+func (s *scyllaRepository) GetDeadLetter(id string) (*DeadLetter, error) {
+	d := &DeadLetter{}
+	if err := s.fetch(s.deadLetters(id), d); err != nil {
+		return nil, err
+	}
+	d.Id = id
+	return d, nil
+}
+
+func (s *scyllaRepository) StoreDeadLetter(d *DeadLetter) error {
+	if err := ldb.Storedb(s.deadLetters(d.Id), d); err != nil {
+		return err
+	}
+	return s.reindex(PARKEDBUCKET, d.Id, true)
+}
+
+func (s *scyllaRepository) DeleteDeadLetter(id string) error {
+	if err := ldb.Deletedb(s.deadLetters(id), DeadLetter{}); err != nil {
+		return err
+	}
+	return s.reindex(PARKEDBUCKET, id, false)
+}
+
+func (s *scyllaRepository) DeadLetterIds() ([]string, error) {
+	return s.indexed(PARKEDBUCKET)
+}
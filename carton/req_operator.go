@@ -38,9 +38,29 @@ func (p *ReqOperator) Accept(r *MegdProcessor) error {
 	}
 	md := *r
 	log.Debugf(cmd.Colorfy(md.String(), "cyan", "", "bold"))
+	switch md.(type) {
+	case CancelProcess: //a cancel isn't an operation of its own.
+		return md.Process(c)
+	case DestroyProcess: //a destroy stopped half way leaves the boxes stuck, it runs to its end.
+		return md.Process(c)
+	}
+	ctx, end := begin(p.Id, c.timeout())
+	defer end()
+	c.withContext(ctx)
 	return md.Process(c)
 }
 
+// Plan is Accept in the plan mode, nothing is run.
+func (p *ReqOperator) Plan(r *MegdProcessor) (*Plan, error) {
+	c, err := p.Get(p.Id)
+	if err != nil {
+		return nil, err
+	}
+	md := *r
+	log.Debugf(cmd.Colorfy("PLAN "+md.String(), "cyan", "", "bold"))
+	return md.Plan(c)
+}
+
 func (p *ReqOperator) Get(cat_id string) (Cartons, error) {
 	a, err := Get(cat_id)
 	if err != nil {
@@ -57,5 +77,7 @@ func (p *ReqOperator) Get(cat_id string) (Cartons, error) {
 // MegdProcessor represents a single operation in vertice.
 type MegdProcessor interface {
 	Process(c Cartons) error
+	// Plan reports what Process would do, without calling the provisioners.
+	Plan(c Cartons) (*Plan, error)
 	String() string
 }
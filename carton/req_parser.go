@@ -36,10 +36,16 @@ var (
 	STOP    = "stop"
 	START   = "start"
 	RESTART = "restart"
+	SCALE   = "scale"  //to the units in the component inputs
+	CANCEL  = "cancel" //the operations in flight
 
-	//the operation actions is just one called upgrade
+	//the operation actions available are.
 	OPERATIONS = "operations"
 	UPGRADE    = "upgrade"
+	ROLLBACK   = "rollback"
+	RESIZE     = "resize" //to the compute in the resize inputs
+	SNAPSHOT   = "snapshot"
+	RESTORE    = "restore"
 )
 
 type ReqParser struct {
@@ -81,12 +87,12 @@ func (p *ReqParser) parseState(action string) (MegdProcessor, error) {
 		return DestroyProcess{
 			Name: p.name,
 		}, nil
-	case BOOTSTRAPPED:
+	case BOOTSTRAPPED, STATEUP:
 		return StateupProcess{
 			Name: p.name,
 		}, nil
 	case STATEDOWN:
-		return StateupProcess{
+		return StatedownProcess{
 			Name: p.name,
 		}, nil
 	default:
@@ -109,8 +115,16 @@ func (p *ReqParser) parseControl(action string) (MegdProcessor, error) {
 		return RestartProcess{
 			Name: p.name,
 		}, nil
+	case SCALE:
+		return ScaleProcess{
+			Name: p.name,
+		}, nil
+	case CANCEL:
+		return CancelProcess{
+			Name: p.name,
+		}, nil
 	default:
-		return nil, newParseError([]string{CONTROL, action}, []string{START, STOP, RESTART})
+		return nil, newParseError([]string{CONTROL, action}, []string{START, STOP, RESTART, SCALE, CANCEL})
 	}
 }
 
@@ -120,9 +134,43 @@ func (p *ReqParser) parseOperations(action string) (MegdProcessor, error) {
 		return UpgradeProcess{
 			Name: p.name,
 		}, nil
+	case ROLLBACK:
+		return RollbackProcess{
+			Name: p.name,
+		}, nil
+	case RESIZE:
+		return ResizeProcess{
+			Name: p.name,
+		}, nil
+	case SNAPSHOT:
+		return SnapshotProcess{
+			Name: p.name,
+		}, nil
+	case RESTORE:
+		return RestoreProcess{
+			Name: p.name,
+		}, nil
 	default:
-		return nil, newParseError([]string{OPERATIONS, action}, []string{UPGRADE})
+		return nil, newParseError([]string{OPERATIONS, action}, []string{UPGRADE, ROLLBACK, RESIZE, SNAPSHOT, RESTORE})
+	}
+}
+
+// Parse parses the request and returns its MegdProcess representation, a
+// scale is of the component to the units the request asks for.
+func (r *Requests) Parse() (MegdProcessor, error) {
+	p, err := ParseRequest(r.CatId, r.Category, r.Action)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := p.(ScaleProcess); ok {
+		if r.Units > 0 && len(strings.TrimSpace(r.ComponentId)) == 0 {
+			return nil, fmt.Errorf("a scale to %d units needs the component_id of the component scaled", r.Units)
+		}
+		s.Component = strings.TrimSpace(r.ComponentId)
+		s.Units = r.Units
+		return s, nil
 	}
+	return p, nil
 }
 
 // ParseError represents an error that occurred during parsing.
@@ -142,12 +190,22 @@ func (e *ParseError) Error() string {
 }
 
 type Requests struct {
-	Id        string `json:"id" cql:"id"`
-	Name      string `json:"name" cql:"name"`
-	CatId     string `json:"cat_id" cql:"cat_id"`
-	Action    string `json:"action" cql:"action"`
-	Category  string `json:"category" cql:"category"`
-	CreatedAt string `json:"created_at" cql:"created_at"`
+	Id          string `json:"id" cql:"id"`
+	Name        string `json:"name" cql:"name"`
+	CatId       string `json:"cat_id" cql:"cat_id"`
+	Action      string `json:"action" cql:"action"`
+	Category    string `json:"category" cql:"category"`
+	CreatedAt   string `json:"created_at" cql:"created_at"`
+	Status      string `json:"status" cql:"status"`
+	StartedAt   string `json:"started_at" cql:"started_at"`
+	EndedAt     string `json:"ended_at" cql:"ended_at"`
+	Error       string `json:"error" cql:"error"`
+	DryRun      bool   `json:"dry_run" cql:"dry_run"` //when set, the request is planned and not run
+	Plan        string `json:"plan" cql:"plan"`       //the plan of a dry run request
+	Attempts    int    `json:"attempts" cql:"attempts"`
+	Units       int    `json:"units" cql:"units"`               //the units a scale request brings the component to, the inputs are used when zero
+	ComponentId string `json:"component_id" cql:"component_id"` //the component a scale request is of, every one (to its inputs) when blank
+	LeasedUntil string `json:"leased_until" cql:"leased_until"` //the claim of the daemon running the request lapses at, unless renewed
 }
 
 func (r *Requests) String() string {
@@ -0,0 +1,334 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	REQUESTSBUCKET = "requests"
+	RUNNINGBUCKET  = "running" //the index of the running requests, a row each.
+
+	//the lifecycle of a request.
+	REQ_QUEUED    = "queued"
+	REQ_RUNNING   = "running"
+	REQ_SUCCEEDED = "succeeded"
+	REQ_FAILED    = "failed"
+	REQ_PARKED    = "parked" //failed after running out of attempts, see Park.
+)
+
+// Lease is how long the claim of a daemon on a request holds, the daemon
+// renews it while the request runs. A request still running once its lease
+// lapsed was left by a daemon which went away, and is claimed again.
+var Lease = 2 * time.Minute
+
+// DefaultSweepInterval is how often a daemon looks for the requests left
+// running by a daemon which went away.
+const DefaultSweepInterval = 1 * time.Minute
+
+// ErrLeaseLost is returned when a request ends after its lease was taken
+// over by another daemon, which saves how it went instead.
+var ErrLeaseLost = errors.New("the lease of the request was taken over")
+
+//the requests running in this daemon, guards against a redelivery which
+//arrives before the status of the first delivery is saved.
+var inflight = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: make(map[string]bool)}
+
+// GetRequest returns the request along with its status.
+func GetRequest(id string) (*Requests, error) {
+	return listReqsById(id)
+}
+
+//a request which was pushed as a value has no id, and isn't tracked.
+func (r *Requests) tracked() bool {
+	return len(strings.TrimSpace(r.Id)) > 0
+}
+
+//only a queued request can run, the one which is running or has
+//ended is a redelivery.
+func (r *Requests) runnable() bool {
+	switch r.Status {
+	case "", REQ_QUEUED:
+		return true
+	}
+	return false
+}
+
+//a running request whose lease lapsed was left by a daemon which went away,
+//as was one which has no lease.
+func (r *Requests) stale(now time.Time) bool {
+	if r.Status != REQ_RUNNING {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, r.LeasedUntil)
+	return err != nil || !now.Before(until)
+}
+
+//the request as claimed, the conditions of the writes under the lease.
+func (r *Requests) lease() map[string]interface{} {
+	return map[string]interface{}{"Status": r.Status, "LeasedUntil": r.LeasedUntil}
+}
+
+// Claim marks the request as running under a lease, and returns false when
+// the request is running under the lease of another or has ended, so the
+// same request doesn't run twice. The request is claimed as saved with a
+// compare-and-set, so that two daemons don't both claim it.
+func (r *Requests) Claim() (bool, error) {
+	if !r.tracked() {
+		return true, nil
+	}
+	inflight.Lock()
+	defer inflight.Unlock()
+	if inflight.ids[r.Id] {
+		log.Debugf("  request (%s) is running, skipping", r.Id)
+		return false, nil
+	}
+	saved, err := store.GetRequest(r.Id)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	left := saved.stale(now)
+	switch {
+	case saved.runnable():
+	case left:
+		log.Warningf("  request (%s) was left running, its lease lapsed at %s", r.Id, saved.LeasedUntil)
+	default:
+		log.Debugf("  request (%s) is %s, skipping", r.Id, saved.Status)
+		return false, nil
+	}
+	started := now.Local().Format(time.RFC3339)
+	leased := now.Add(Lease).Local().Format(time.RFC3339)
+	ok, err := store.SwapRequest(r.Id, saved.lease(), map[string]interface{}{
+		"Status":      REQ_RUNNING,
+		"StartedAt":   started,
+		"LeasedUntil": leased,
+	})
+	if err != nil {
+		return false, err
+	} else if !ok {
+		log.Debugf("  request (%s) was claimed by another, skipping", r.Id)
+		return false, nil
+	}
+	r.Status, r.StartedAt, r.LeasedUntil = REQ_RUNNING, started, leased
+	inflight.ids[r.Id] = true
+	if left {
+		if err := r.recoverBoxes(now); err != nil {
+			log.Errorf("  unable to recover the boxes left by request (%s) %s", r.Id, err)
+		}
+	}
+	return true, nil
+}
+
+//moves the boxes of the assemblies which the request left in the middle of
+//a move to error, so that it can move them once more (see Box.Recover). The
+//boxes are left as they are while another request runs on the assemblies,
+//they may be in its move.
+func (r *Requests) recoverBoxes(now time.Time) error {
+	if busy, err := r.busy(now); err != nil || busy {
+		return err
+	}
+	a, err := Get(r.CatId)
+	if err != nil {
+		return err
+	}
+	ca, err := a.MkCartons()
+	if err != nil {
+		return err
+	}
+	for _, c := range ca {
+		for i := range *c.Boxes {
+			b := &(*c.Boxes)[i]
+			if ok, err := b.Recover(); err != nil {
+				return err
+			} else if ok {
+				log.Warningf("  box %s was left in the middle of a move, it is in error", b.GetFullName())
+			}
+		}
+	}
+	return nil
+}
+
+//whether another request runs on the assemblies of the request, under a
+//lease which holds.
+func (r *Requests) busy(now time.Time) (bool, error) {
+	ids, err := store.RunningIds()
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == r.Id {
+			continue
+		}
+		o, err := store.GetRequest(id)
+		if err != nil {
+			continue
+		}
+		if o.CatId == r.CatId && o.Status == REQ_RUNNING && !o.stale(now) {
+			log.Debugf("  request (%s) runs on (%s), the boxes are left as they are", o.Id, r.CatId)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//renews the lease from leased till stop is closed, and hands the last one
+//over. A lease taken over isn't renewed any more.
+func (r *Requests) renew(leased string, stop <-chan struct{}, last chan<- string) {
+	defer func() { last <- leased }()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(Lease / 3):
+		}
+		until := time.Now().Add(Lease).Local().Format(time.RFC3339)
+		ok, err := store.SwapRequest(r.Id, map[string]interface{}{"Status": REQ_RUNNING, "LeasedUntil": leased},
+			map[string]interface{}{"LeasedUntil": until})
+		switch {
+		case err != nil:
+			log.Errorf("  unable to renew the lease of request (%s) %s", r.Id, err)
+		case !ok:
+			log.Warningf("  request (%s) lost its lease", r.Id)
+			<-stop
+			return
+		default:
+			leased = until
+		}
+	}
+}
+
+// Done marks the request as succeeded or failed with the error, unless its
+// lease was taken over.
+func (r *Requests) Done(err error) error {
+	if !r.tracked() {
+		return nil
+	}
+	defer func() {
+		inflight.Lock()
+		delete(inflight.ids, r.Id)
+		inflight.Unlock()
+	}()
+	claimed := r.lease()
+	r.Status = REQ_SUCCEEDED
+	if err != nil {
+		r.Status = REQ_FAILED
+		r.Error = err.Error()
+	}
+	r.EndedAt = time.Now().Local().Format(time.RFC3339)
+	ok, serr := store.SwapRequest(r.Id, claimed, map[string]interface{}{
+		"Status":  r.Status,
+		"EndedAt": r.EndedAt,
+		"Error":   r.Error,
+	})
+	if serr == nil && !ok {
+		return ErrLeaseLost
+	}
+	return serr
+}
+
+// Run runs fn once for the request, and records how it went. A redelivered
+// request is skipped, the lease is renewed while fn runs.
+func (r *Requests) Run(fn func() error) error {
+	ok, err := r.Claim()
+	if err != nil || !ok {
+		return err
+	}
+	stop, last := make(chan struct{}), make(chan string, 1)
+	if r.tracked() {
+		go r.renew(r.LeasedUntil, stop, last)
+	}
+	err = fn()
+	if r.tracked() {
+		close(stop)
+		r.LeasedUntil = <-last
+	}
+	if derr := r.Done(err); derr != nil {
+		log.Errorf("  unable to save the status of request (%s) %s", r.Id, derr)
+	}
+	return err
+}
+
+// StaleRequests returns the requests left running by a daemon which went
+// away, their lease lapsed at now. They are to be queued again.
+func StaleRequests(now time.Time) ([]*Requests, error) {
+	ids, err := store.RunningIds()
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]*Requests, 0)
+	for _, id := range ids {
+		r, err := store.GetRequest(id)
+		if err != nil {
+			log.Debugf("no request found for (%s)", id)
+			continue
+		}
+		if r.stale(now) {
+			rs = append(rs, r)
+		}
+	}
+	return rs, nil
+}
+
+// StaleRequestsOn returns the stale requests on the assemblies of the
+// providers on says it runs, the daemon of the providers runs them again.
+func StaleRequestsOn(now time.Time, on func(provider string) bool) ([]*Requests, error) {
+	stale, err := StaleRequests(now)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]*Requests, 0, len(stale))
+	for _, r := range stale {
+		a, err := Get(r.CatId)
+		if err != nil {
+			log.Debugf("no assemblies found for request (%s) %s", r.Id, err)
+			continue
+		}
+		provider, err := a.Provider()
+		if err != nil {
+			log.Debugf("no provider found for request (%s) %s", r.Id, err)
+			continue
+		}
+		if on(provider) {
+			rs = append(rs, r)
+		}
+	}
+	return rs, nil
+}
+
+// SavePlan saves the plan of a dry run request.
+func (r *Requests) SavePlan(p *Plan) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	r.Plan = string(b)
+	if !r.tracked() {
+		log.Debugf("  plan %s", r.Plan)
+		return nil
+	}
+	return store.UpdateRequest(r.Id, map[string]interface{}{"Plan": r.Plan})
+}
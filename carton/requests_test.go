@@ -0,0 +1,147 @@
+package carton
+
+import (
+	"errors"
+	"time"
+
+	constants "github.com/megamsys/libgo/utils"
+	"gopkg.in/check.v1"
+)
+
+type RequestsSuite struct{}
+
+var _ = check.Suite(&RequestsSuite{})
+
+func (s *RequestsSuite) SetUpTest(c *check.C) {
+	SetRepository(NewMemRepository())
+}
+
+//the request as another daemon reads it, which doesn't share the inflight ones.
+func (s *RequestsSuite) elsewhere(c *check.C, id string) *Requests {
+	inflight.Lock()
+	delete(inflight.ids, id)
+	inflight.Unlock()
+	r, err := GetRequest(id)
+	c.Assert(err, check.IsNil)
+	return r
+}
+
+func (s *RequestsSuite) TestRunnable(c *check.C) {
+	c.Assert((&Requests{}).runnable(), check.Equals, true)
+	c.Assert((&Requests{Status: REQ_QUEUED}).runnable(), check.Equals, true)
+	c.Assert((&Requests{Status: REQ_RUNNING}).runnable(), check.Equals, false)
+	c.Assert((&Requests{Status: REQ_SUCCEEDED}).runnable(), check.Equals, false)
+	c.Assert((&Requests{Status: REQ_FAILED}).runnable(), check.Equals, false)
+}
+
+func (s *RequestsSuite) TestRunUntracked(c *check.C) {
+	ran := 0
+	r := &Requests{CatId: "ASM001", Category: STATE, Action: CREATE}
+	err := r.Run(func() error {
+		ran++
+		return errors.New("boom")
+	})
+	c.Assert(err, check.ErrorMatches, "boom")
+	c.Assert(ran, check.Equals, 1)
+}
+
+func (s *RequestsSuite) TestClaimOnce(c *check.C) {
+	c.Assert(store.StoreRequest(&Requests{Id: "RIP001", Status: REQ_QUEUED}), check.IsNil)
+	r := &Requests{Id: "RIP001", Status: REQ_QUEUED}
+	ok, err := r.Claim()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(r.LeasedUntil, check.Not(check.Equals), "")
+
+	ok, err = (&Requests{Id: "RIP001", Status: REQ_QUEUED}).Claim()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	ok, err = s.elsewhere(c, "RIP001").Claim()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+
+	c.Assert(r.Done(nil), check.IsNil)
+	saved, err := GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(saved.Status, check.Equals, REQ_SUCCEEDED)
+}
+
+func (s *RequestsSuite) TestClaimWhatADaemonLeftRunning(c *check.C) {
+	lapsed := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	c.Assert(store.StoreRequest(&Requests{Id: "RIP001", Status: REQ_RUNNING, LeasedUntil: lapsed}), check.IsNil)
+	c.Assert(store.StoreRequest(&Requests{Id: "RIP002", Status: REQ_QUEUED}), check.IsNil)
+	stale, err := StaleRequests(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(stale, check.HasLen, 1)
+	c.Assert(stale[0].Id, check.Equals, "RIP001")
+
+	r := s.elsewhere(c, "RIP001")
+	ok, err := r.Claim()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	stale, err = StaleRequests(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(stale, check.HasLen, 0)
+	c.Assert(r.Done(nil), check.IsNil)
+}
+
+func (s *RequestsSuite) TestDoneAfterTheLeaseWasTakenOver(c *check.C) {
+	c.Assert(store.StoreRequest(&Requests{Id: "RIP001", Status: REQ_QUEUED}), check.IsNil)
+	r := &Requests{Id: "RIP001"}
+	ok, err := r.Claim()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	taken := time.Now().Add(time.Hour).Format(time.RFC3339)
+	c.Assert(store.UpdateRequest("RIP001", map[string]interface{}{"LeasedUntil": taken}), check.IsNil)
+
+	c.Assert(r.Done(errors.New("boom")), check.Equals, ErrLeaseLost)
+	saved, err := GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(saved.Status, check.Equals, REQ_RUNNING)
+	c.Assert(saved.LeasedUntil, check.Equals, taken)
+}
+
+func (s *FlowSuite) TestDestroyLeftRunningIsRecovered(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	c.Assert(s.repo.UpdateComponent("CMPdb", map[string]interface{}{"Status": constants.StatusDestroying.String()}), check.IsNil)
+
+	lapsed := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	c.Assert(s.repo.StoreRequest(&Requests{Id: "RIP002", CatId: "AMS001", Category: STATE, Action: DESTROY, Status: REQ_RUNNING, LeasedUntil: lapsed}), check.IsNil)
+	r, err := GetRequest("RIP002")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Run(func() error {
+		p, err := r.Parse()
+		if err != nil {
+			return err
+		}
+		return NewReqOperator(r.CatId).Accept(&p)
+	}), check.IsNil)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "destroy"})
+}
+
+func (s *FlowSuite) TestBoxesOfABusyAssemblyAreLeftAsTheyAre(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	c.Assert(s.repo.UpdateComponent("CMPdb", map[string]interface{}{"Status": constants.StatusDestroying.String()}), check.IsNil)
+	leased := time.Now().Add(time.Minute).Format(time.RFC3339)
+	c.Assert(s.repo.StoreRequest(&Requests{Id: "RIP002", CatId: "AMS001", Status: REQ_RUNNING, LeasedUntil: leased}), check.IsNil)
+
+	r := &Requests{Id: "RIP003", CatId: "AMS001"}
+	c.Assert(r.recoverBoxes(time.Now()), check.IsNil)
+	cmp, err := NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(cmp.Status, check.Equals, constants.StatusDestroying.String())
+}
+
+func (s *FlowSuite) TestStaleRequestsOnTheProvider(c *check.C) {
+	lapsed := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	c.Assert(s.repo.StoreRequest(&Requests{Id: "RIP001", CatId: "AMS001", Status: REQ_RUNNING, LeasedUntil: lapsed}), check.IsNil)
+	rs, err := StaleRequestsOn(time.Now(), func(provider string) bool { return provider == "fake" })
+	c.Assert(err, check.IsNil)
+	c.Assert(rs, check.HasLen, 1)
+	c.Assert(rs[0].Id, check.Equals, "RIP001")
+	rs, err = StaleRequestsOn(time.Now(), func(provider string) bool { return provider == "docker" })
+	c.Assert(err, check.IsNil)
+	c.Assert(rs, check.HasLen, 0)
+}
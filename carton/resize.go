@@ -0,0 +1,247 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+)
+
+const (
+	//the compute an assembly is resized to, staged in its inputs ahead of
+	//an operations/resize request. Once resized they become its cpu, ram and hdd.
+	RESIZE_CPU = "resize_cpu"
+	RESIZE_RAM = "resize_ram"
+	RESIZE_HDD = "resize_hdd"
+
+	//the output listing the boxes a resize which failed part way went
+	//through, see partialResize.
+	RESIZED = "resized"
+)
+
+//the units of the boxes a resize which failed part way resized, they are
+//billed and hold the quota of the compute they were resized to. A resize to
+//the same compute skips them.
+type partialResize struct {
+	To    provision.BoxCompute `json:"to"`
+	Units []string             `json:"units"`
+}
+
+type ResizeOpts struct {
+	B         *provision.Box
+	To        provision.BoxCompute
+	start     time.Time
+	logWriter LogWriter
+	writer    io.Writer
+}
+
+func (r *ResizeOpts) setLogger() {
+	r.start = time.Now()
+	r.logWriter = NewLogWriter(r.B)
+	r.writer = io.MultiWriter(&r.logWriter)
+}
+
+// Resize changes the compute of the box in place, when its provisioner
+// can do so.
+func Resize(opts *ResizeOpts) error {
+	log.Debugf("  resize cycle for box (%s, %s) to %s", opts.B.Id, opts.B.GetFullName(), opts.To.String())
+	r, ok := ProvisionerMap[opts.B.Provider].(provision.Resizer)
+	if !ok {
+		return fmt.Errorf("provisioner %q can't resize the box %s", opts.B.Provider, opts.B.GetFullName())
+	}
+	opts.setLogger()
+	defer opts.logWriter.Close()
+	if err := opts.B.Move(provision.StatusResizing, func() error {
+		return r.Resize(opts.B, opts.To, opts.writer)
+	}); err != nil {
+		return err
+	}
+	opts.B.Compute = opts.To
+	fmt.Fprintf(opts.writer, "    resize (%s, %s, %s) OK\n", opts.B.GetFullName(), opts.B.Status.String(), time.Since(opts.start))
+	return nil
+}
+
+//the compute staged for a resize over the one in use, a value which isn't
+//staged stays as is.
+func (a *Assembly) resizeTo() provision.BoxCompute {
+	to := a.newCompute()
+	staged := func(key string, v *string) {
+		if s := strings.TrimSpace(a.Inputs.Match(key)); len(s) > 0 {
+			*v = s
+		}
+	}
+	staged(RESIZE_CPU, &to.Cpushare)
+	staged(RESIZE_RAM, &to.Memory)
+	staged(RESIZE_HDD, &to.HDD)
+	return to
+}
+
+//the units resized to the compute by a resize which failed part way.
+func (c *Carton) partlyResized(to provision.BoxCompute) (map[string]bool, error) {
+	a, err := NewAssembly(c.Id)
+	if err != nil {
+		return nil, err
+	}
+	units := make(map[string]bool)
+	pr := partialResize{}
+	if in := a.Outputs.Match(RESIZED); len(strings.TrimSpace(in)) > 0 {
+		if err := json.Unmarshal([]byte(in), &pr); err != nil {
+			log.Errorf("Unparsable partial resize of (%s), ignoring: %s", c.Name, err)
+		}
+	}
+	if pr.To == to {
+		for _, u := range pr.Units {
+			units[u] = true
+		}
+	}
+	return units, nil
+}
+
+//saves the units resized so far, none once the resize went through.
+func (c *Carton) saveResized(units map[string]bool) error {
+	a, err := NewAmbly(c.Id)
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return a.NukeOutputs(RESIZED)
+	}
+	pr := partialResize{To: c.ResizeTo}
+	for u := range units {
+		pr.Units = append(pr.Units, u)
+	}
+	sort.Strings(pr.Units)
+	b, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+	return a.NukeAndSetOutputs(map[string][]string{RESIZED: []string{string(b)}})
+}
+
+//the resources a box takes at the compute.
+func resourcesAt(b provision.Box, compute provision.BoxCompute) Resources {
+	b.Compute = compute
+	return boxResources(&b)
+}
+
+//the resources the boxes which aren't done grow and shrink by, from the
+//compute they are at to the one resized to.
+func (c *Carton) resizeBy(done map[string]bool) (grow, shrink Resources) {
+	for _, b := range *c.Boxes {
+		if done[unitName(&b)] {
+			continue
+		}
+		was, to := resourcesAt(b, c.Compute), resourcesAt(b, c.ResizeTo)
+		grow, shrink = grow.plus(to.minus(was)), shrink.plus(was.minus(to))
+	}
+	return grow, shrink
+}
+
+// planResize plans the resize of the boxes, the growth is checked against
+// the quota as Resize would take it.
+func (c *Carton) planResize() *CartonPlan {
+	cp := c.planBoxes(c.order(), resizeSteps)
+	if c.ResizeTo == c.Compute {
+		return cp
+	}
+	done, err := c.partlyResized(c.ResizeTo)
+	if err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	grow, _ := c.resizeBy(done)
+	return c.planAdmitted(cp, POLICY_RESIZE, "", 0, grow)
+}
+
+// Resize resizes the boxes of the carton to the compute staged in its
+// inputs. The growth is taken from the quota upfront, and the shrink given
+// back once the boxes are resized. When the resize fails part way, the boxes
+// which were resized keep the growth (they are billed at the new compute) and
+// the ones which weren't give it back. A next resize to the same compute
+// picks up the boxes left.
+func (c *Carton) Resize() error {
+	if c.ResizeTo == c.Compute {
+		log.Debugf("  nothing to resize for (%s)", c.Name)
+		return nil
+	}
+	done, err := c.partlyResized(c.ResizeTo)
+	if err != nil {
+		return err
+	}
+	grow, shrink := c.resizeBy(done)
+	if err := c.reserveResources(grow); err != nil {
+		return err
+	}
+	if err := c.enforce(POLICY_RESIZE, func() error {
+		return c.eachBox(func(b *provision.Box) error {
+			if done[unitName(b)] {
+				b.Compute = c.ResizeTo
+				return nil
+			}
+			return Resize(&ResizeOpts{B: b, To: c.ResizeTo})
+		}).Err()
+	}); err != nil {
+		back := Resources{}
+		for _, b := range *c.Boxes {
+			if done[unitName(&b)] {
+				continue
+			}
+			was, to := resourcesAt(b, c.Compute), resourcesAt(b, c.ResizeTo)
+			if b.Compute == c.ResizeTo {
+				done[unitName(&b)] = true
+				back = back.plus(was.minus(to))
+			} else {
+				back = back.plus(to.minus(was))
+			}
+		}
+		if rerr := release(c.AccountsId, back); rerr != nil {
+			log.Errorf("  unable to release the quota of (%s) %s", c.Name, rerr)
+		}
+		if serr := c.saveResized(done); serr != nil {
+			log.Errorf("  unable to save the boxes resized of (%s) %s", c.Name, serr)
+		}
+		log.Errorf("Unable to resize the box %s", err)
+		return err
+	}
+	if err := release(c.AccountsId, shrink); err != nil {
+		log.Errorf("  unable to release the quota of (%s) %s", c.Name, err)
+	}
+	c.Compute = c.ResizeTo
+	if err := c.saveCompute(); err != nil {
+		return err
+	}
+	return c.saveResized(nil)
+}
+
+//the compute in use is saved as the cpu, ram and hdd inputs of the assembly.
+func (c *Carton) saveCompute() error {
+	a, err := NewAmbly(c.Id)
+	if err != nil {
+		return err
+	}
+	return a.NukeAndSetInputs(map[string][]string{
+		provision.CPU: []string{c.Compute.Cpushare},
+		provision.RAM: []string{c.Compute.Memory},
+		provision.HDD: []string{c.Compute.HDD},
+	})
+}
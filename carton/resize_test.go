@@ -0,0 +1,88 @@
+package carton
+
+import (
+	"errors"
+
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+//stages a resize in the inputs of the assembly, the way the gateway does.
+func (s *FlowSuite) stageResize(c *check.C, cpu, ram string) {
+	a, err := NewAmbly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.NukeAndSetInputs(map[string][]string{
+		provision.CPU: []string{"1"},
+		provision.RAM: []string{"1G"},
+		RESIZE_CPU:    []string{cpu},
+		RESIZE_RAM:    []string{ram},
+	}), check.IsNil)
+}
+
+func (s *FlowSuite) TestResize(c *check.C) {
+	s.stageResize(c, "2", "2G")
+	s.settle(c, constants.StatusRunning)
+	c.Assert(SetQuota("ORG001", Resources{Memory: 8192}), check.IsNil)
+	s.run(c, "RIP001", OPERATIONS, RESIZE)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"resize"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"resize"})
+
+	a, err := NewAssembly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.getCpushare(), check.Equals, "2")
+	c.Assert(a.getMemory(), check.Equals, "2G")
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Cpushare: 2, Memory: 2048})
+
+	s.run(c, "RIP002", OPERATIONS, RESIZE)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"resize"})
+}
+
+func (s *FlowSuite) TestResizeFailureReleasesTheGrowth(c *check.C) {
+	s.stageResize(c, "1", "2G")
+	s.settle(c, constants.StatusRunning)
+	s.prov.PrepareFailure("resize", errors.New("no room on the host"))
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(cs.Resize(), check.ErrorMatches, ".*no room on the host.*")
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{})
+
+	a, err := NewAssembly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.getMemory(), check.Equals, "1G")
+}
+
+func (s *FlowSuite) TestPartialResizeBillsTheBoxesResized(c *check.C) {
+	s.stageResize(c, "1", "2G")
+	s.settle(c, constants.StatusRunning)
+	c.Assert(SetQuota("ORG001", Resources{Memory: 8192}), check.IsNil)
+	s.prov.PrepareFailureOn("resize", "CMPweb", errors.New("no room on the host"))
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(cs.Resize(), check.ErrorMatches, ".*no room on the host.*")
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"resize"})
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Memory: 1024})
+	a, err := NewAssembly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.getMemory(), check.Equals, "1G")
+
+	s.settle(c, constants.StatusRunning)
+	cs, err = mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(cs.Resize(), check.IsNil)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"resize"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"resize"})
+	q, err = GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used(), check.DeepEquals, Resources{Memory: 2048})
+	a, err = NewAssembly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.getMemory(), check.Equals, "2G")
+	c.Assert(a.Outputs.Match(RESIZED), check.Equals, "")
+}
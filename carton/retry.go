@@ -0,0 +1,137 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	DefaultAttempts   = 3
+	DefaultBackoff    = 5 * time.Second
+	DefaultMaxBackoff = 2 * time.Minute
+)
+
+// RetryPolicy is how a request failing with a transient error is retried,
+// the backoff doubles after every attempt up to the max.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+//The retry policy of the requests, set by the subd daemons.
+var Retry = RetryPolicy{Attempts: DefaultAttempts, Backoff: DefaultBackoff, MaxBackoff: DefaultMaxBackoff}
+
+//waits out the backoff, the tests needn't.
+var sleep = time.Sleep
+
+//the backoff after the attempt (from 1).
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+//the messages of the errors worth a retry, as some clients (eg: xmlrpc to
+//one) hand out just the message of the error they got.
+var transientMessages = []string{
+	"connection refused",
+	"connection reset",
+	"no route to host",
+	"network is unreachable",
+	"i/o timeout",
+	"Unavailable nodes",
+}
+
+// Transient returns true when err is worth a retry, eg: a node which is
+// unreachable or refused the connection. A cancel or a deadline isn't.
+// A report is when every failure in it is, leaving out the boxes skipped
+// for them.
+func Transient(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	switch e := err.(type) {
+	case *CleanupError:
+		return false //the box is gone, a retry can't destroy it again.
+	case *ReportError:
+		transient := false
+		for _, res := range e.Report.Failures() {
+			if res.Err == ErrDependencyFailed {
+				continue
+			}
+			if !Transient(res.Err) {
+				return false
+			}
+			transient = true
+		}
+		return transient
+	case *url.Error:
+		return Transient(e.Err)
+	case *net.OpError:
+		return true
+	case interface {
+		BaseError() error
+	}:
+		return Transient(e.BaseError())
+	case interface {
+		Temporary() bool
+	}:
+		if e.Temporary() {
+			return true
+		}
+	}
+	msg := err.Error()
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retried runs fn for the request till it goes through, fails with an error
+// which isn't transient or else runs out of the attempts of the policy. The
+// attempts are saved along with the request.
+func (r *Requests) Retried(p RetryPolicy, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		r.Attempts = attempt
+		if r.tracked() {
+			if err := store.UpdateRequest(r.Id, map[string]interface{}{"Attempts": attempt}); err != nil {
+				log.Errorf("  unable to save the attempts of request (%s) %s", r.Id, err)
+			}
+		}
+		err := fn()
+		if err == nil || !Transient(err) || attempt >= p.Attempts {
+			return err
+		}
+		d := p.delay(attempt)
+		log.Warningf("  request (%s) failed on attempt %d of %d, retrying in %s\n --> %s", r.Id, attempt, p.Attempts, d, err)
+		sleep(d)
+	}
+}
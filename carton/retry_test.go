@@ -0,0 +1,168 @@
+package carton
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+	"gopkg.in/check.v1"
+)
+
+type RetrySuite struct{}
+
+var _ = check.Suite(&RetrySuite{})
+
+var errRefused = &url.Error{Op: "Post", URL: "http://localhost:2633/RPC2", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+
+func (s *RetrySuite) TestDelay(c *check.C) {
+	p := RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	c.Assert(p.delay(1), check.Equals, time.Second)
+	c.Assert(p.delay(2), check.Equals, 2*time.Second)
+	c.Assert(p.delay(3), check.Equals, 3*time.Second)
+	c.Assert(p.delay(4), check.Equals, 3*time.Second)
+}
+
+func (s *RetrySuite) TestTransient(c *check.C) {
+	c.Assert(Transient(nil), check.Equals, false)
+	c.Assert(Transient(errRefused), check.Equals, true)
+	c.Assert(Transient(errors.New("Unavailable nodes (hint: start or beat it).")), check.Equals, true)
+	c.Assert(Transient(ErrNotFound), check.Equals, false)
+	c.Assert(Transient(context.Canceled), check.Equals, false)
+	c.Assert(Transient(context.DeadlineExceeded), check.Equals, false)
+	c.Assert(Transient(Report{
+		&Result{Name: "db", Err: errRefused},
+		&Result{Name: "web", Err: ErrDependencyFailed},
+	}.Err()), check.Equals, true)
+	c.Assert(Transient(Report{
+		&Result{Name: "db", Err: errRefused},
+		&Result{Name: "web", Err: ErrNotFound},
+	}.Err()), check.Equals, false)
+}
+
+func (s *RetrySuite) TestRetried(c *check.C) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+	p := RetryPolicy{Attempts: 3, Backoff: time.Second, MaxBackoff: time.Minute}
+
+	r := &Requests{}
+	ran := 0
+	err := r.Retried(p, func() error {
+		ran++
+		if ran < 2 {
+			return errRefused
+		}
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Attempts, check.Equals, 2)
+	c.Assert(slept, check.DeepEquals, []time.Duration{time.Second})
+
+	slept, ran = nil, 0
+	err = r.Retried(p, func() error {
+		ran++
+		return errRefused
+	})
+	c.Assert(err, check.Equals, errRefused)
+	c.Assert(ran, check.Equals, 3)
+	c.Assert(slept, check.DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+
+	ran = 0
+	err = r.Retried(p, func() error {
+		ran++
+		return ErrNotFound
+	})
+	c.Assert(err, check.Equals, ErrNotFound)
+	c.Assert(ran, check.Equals, 1)
+}
+
+func (s *FlowSuite) TestRetriedCreateDeploysTheBoxesLeft(c *check.C) {
+	sleep = func(d time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 10}), check.IsNil)
+	s.prov.PrepareFailureOn("deploy", "CMPweb", errRefused)
+	c.Assert(s.repo.StoreRequest(&Requests{Id: "RIP001", CatId: "AMS001", Category: STATE, Action: CREATE, Status: REQ_QUEUED}), check.IsNil)
+	r, err := GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	err = r.Run(func() error {
+		return r.Retried(RetryPolicy{Attempts: 2}, func() error {
+			p, err := r.Parse()
+			if err != nil {
+				return err
+			}
+			return NewReqOperator(r.CatId).Accept(&p)
+		})
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Attempts, check.Equals, 2)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy"})
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used().Boxes, check.Equals, 2)
+}
+
+func (s *FlowSuite) TestParkAndReplay(c *check.C) {
+	c.Assert(s.repo.StoreRequest(&Requests{Id: "RIP001", CatId: "AMS001", Category: STATE, Action: CREATE, Status: REQ_QUEUED}), check.IsNil)
+	r, err := GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	err = r.Run(func() error {
+		return r.Retried(RetryPolicy{Attempts: 1}, func() error {
+			return errRefused
+		})
+	})
+	c.Assert(Transient(err), check.Equals, true)
+	d, err := r.Park("vms", err)
+	c.Assert(err, check.IsNil)
+	c.Assert(d.Topic, check.Equals, "vms")
+	c.Assert(d.Attempts, check.Equals, 1)
+
+	ds, err := DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+	c.Assert(ds[0].Error, check.Equals, errRefused.Error())
+	r, err = GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, REQ_PARKED)
+
+	var published []string
+	publish := func(d *DeadLetter) error {
+		published = append(published, d.Topic)
+		return nil
+	}
+	_, err = Replay("RIP001", func(d *DeadLetter) error { return errRefused })
+	c.Assert(err, check.Equals, errRefused)
+	r, err = GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, REQ_PARKED)
+	c.Assert(r.Attempts, check.Equals, 1)
+	ds, err = DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+
+	_, err = Replay("RIP001", publish)
+	c.Assert(err, check.IsNil)
+	c.Assert(published, check.DeepEquals, []string{"vms"})
+	r, err = GetRequest("RIP001")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, REQ_QUEUED)
+	c.Assert(r.Attempts, check.Equals, 0)
+	ds, err = DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 0)
+	_, err = Replay("RIP001", publish)
+	c.Assert(err, check.Equals, ErrNotParked)
+}
+
+func (s *FlowSuite) TestParkUntracked(c *check.C) {
+	r := &Requests{CatId: "AMS001", Category: CONTROL, Action: STOP, Attempts: 3}
+	d, err := r.Park("vms", errRefused)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Id, check.Not(check.Equals), "")
+	c.Assert(d.Id, check.Equals, r.Id)
+	stored, err := GetRequest(r.Id)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Status, check.Equals, REQ_PARKED)
+}
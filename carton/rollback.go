@@ -0,0 +1,93 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"errors"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+)
+
+var (
+	ErrNoRollback           = errors.New("no earlier successful deploy to rollback to")
+	ErrRollbackNotSupported = errors.New("provisioner can't deploy from an image, rollback isn't supported")
+	ErrNotReplaceable       = errors.New("provisioner can't replace a box in place")
+)
+
+type RollbackOpts struct {
+	B *provision.Box
+}
+
+// Rollback replaces what runs the box by the image of its successful deploy
+// before the one running, each rollback steps back one more.
+func Rollback(opts *RollbackOpts) error {
+	ds, err := DeployHistory(opts.B)
+	if err != nil {
+		return err
+	}
+
+	dd, err := rollbackTarget(ds)
+	if err != nil {
+		return err
+	}
+	log.Debugf("  rollback box (%s) to (image:%s, commit:%s)", opts.B.GetFullName(), dd.Image, dd.Commit)
+	opts.B.Commit = dd.Commit
+	return Deploy(&DeployOpts{B: opts.B, Image: dd.Image, InPlace: true})
+}
+
+//the successful deploys make a line, the last one is what runs now. A
+//rollback steps back on the line to the deploy it went to, so the next one
+//goes further back rather than to the deploy just left. We pick the deploy
+//before the one running which has a different image or commit.
+func rollbackTarget(ds []*DeployData) (*DeployData, error) {
+	line := make([]*DeployData, 0, len(ds))
+	for _, d := range ds {
+		if !d.succeeded() {
+			continue
+		}
+		if d.Origin != ORIGIN_ROLLBACK {
+			line = append(line, d)
+			continue
+		}
+		for len(line) > 0 && !line[len(line)-1].same(d) {
+			line = line[:len(line)-1]
+		}
+		if len(line) == 0 {
+			line = append(line, d)
+		}
+	}
+	if len(line) == 0 {
+		return nil, ErrNoRollback
+	}
+	current := line[len(line)-1]
+	for i := len(line) - 2; i >= 0; i-- {
+		if line[i].CanRollback && !line[i].same(current) {
+			return line[i], nil
+		}
+	}
+	return nil, ErrNoRollback
+}
+
+func (d *DeployData) succeeded() bool {
+	return len(strings.TrimSpace(d.Error)) == 0
+}
+
+//both deploys run the same image of the same commit.
+func (d *DeployData) same(o *DeployData) bool {
+	return d.Image == o.Image && d.Commit == o.Commit
+}
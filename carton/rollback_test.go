@@ -0,0 +1,95 @@
+package carton
+
+import (
+	"errors"
+
+	constants "github.com/megamsys/libgo/utils"
+	"gopkg.in/check.v1"
+)
+
+type RollbackSuite struct{}
+
+var _ = check.Suite(&RollbackSuite{})
+
+func (s *RollbackSuite) TestRollbackTargetPicksEarlierSuccessfulDeploy(c *check.C) {
+	ds := []*DeployData{
+		&DeployData{Image: "img1", CanRollback: true},
+		&DeployData{Image: "img2", CanRollback: true},
+		&DeployData{Image: "img3", Error: "create-machine failed"},
+		&DeployData{Image: "img2", CanRollback: true},
+	}
+	dd, err := rollbackTarget(ds)
+	c.Assert(err, check.IsNil)
+	c.Assert(dd.Image, check.Equals, "img1")
+}
+
+func (s *RollbackSuite) TestRollbackTargetWithoutEarlierDeploy(c *check.C) {
+	ds := []*DeployData{
+		&DeployData{Image: "img1", Error: "create-machine failed"},
+		&DeployData{Image: "img2", CanRollback: true},
+	}
+	_, err := rollbackTarget(ds)
+	c.Assert(err, check.Equals, ErrNoRollback)
+}
+
+func (s *RollbackSuite) TestRollbackTargetStepsBackOnEveryRollback(c *check.C) {
+	ds := []*DeployData{
+		&DeployData{Image: "img1", CanRollback: true},
+		&DeployData{Image: "img2", CanRollback: true},
+		&DeployData{Image: "img3", CanRollback: true},
+		&DeployData{Image: "img2", Origin: ORIGIN_ROLLBACK, CanRollback: true},
+	}
+	dd, err := rollbackTarget(ds)
+	c.Assert(err, check.IsNil)
+	c.Assert(dd.Image, check.Equals, "img1")
+
+	ds = append(ds, &DeployData{Image: "img1", Origin: ORIGIN_ROLLBACK, CanRollback: true})
+	_, err = rollbackTarget(ds)
+	c.Assert(err, check.Equals, ErrNoRollback)
+
+	ds = append(ds, &DeployData{Image: "img4", CanRollback: true})
+	dd, err = rollbackTarget(ds)
+	c.Assert(err, check.IsNil)
+	c.Assert(dd.Image, check.Equals, "img1")
+}
+
+func (s *FlowSuite) TestRollbackReplacesTheBoxInPlace(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	for _, id := range []string{"CMPdb", "CMPweb"} {
+		for _, img := range []string{"img1", "img2", "img3"} {
+			c.Assert(StoreDeploy("ASM001", id, &DeployData{Image: img, Origin: ORIGIN_IMAGE, CanRollback: true}), check.IsNil)
+		}
+	}
+
+	s.run(c, "RIP002", OPERATIONS, ROLLBACK)
+	s.settle(c, constants.StatusRunning)
+	s.run(c, "RIP003", OPERATIONS, ROLLBACK)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "replace", "replace"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy", "replace", "replace"})
+	ds, err := ListDeploys("ASM001", "CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 6)
+	c.Assert(ds[4].Image, check.Equals, "img2")
+	c.Assert(ds[4].Origin, check.Equals, ORIGIN_ROLLBACK)
+	c.Assert(ds[5].Image, check.Equals, "img1")
+}
+
+func (s *FlowSuite) TestFailedRollbackKeepsWhatRuns(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	for _, img := range []string{"img1", "img2"} {
+		c.Assert(StoreDeploy("ASM001", "CMPdb", &DeployData{Image: img, Origin: ORIGIN_IMAGE, CanRollback: true}), check.IsNil)
+	}
+
+	s.prov.PrepareFailureOn("replace", "CMPdb", errors.New("image gone"))
+	c.Assert(s.runRequest(c, &Requests{Id: "RIP002", CatId: "AMS001", Category: OPERATIONS, Action: ROLLBACK}), check.ErrorMatches, ".*image gone.*")
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy"})
+	ds, err := ListDeploys("ASM001", "CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 4)
+	c.Assert(ds[3].Error, check.Matches, ".*image gone.*")
+	dd, err := rollbackTarget(ds)
+	c.Assert(err, check.IsNil)
+	c.Assert(dd.Image, check.Equals, "img1")
+}
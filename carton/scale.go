@@ -0,0 +1,294 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/cmd"
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+)
+
+//the name of a box along with its unit, eg: web, web-1, web-2
+func unitName(b *provision.Box) string {
+	if b.Unit > 0 {
+		return b.Name + "-" + strconv.Itoa(b.Unit)
+	}
+	return b.Name
+}
+
+//the boxes (by index) of every component.
+func (c *Carton) unitsByComponent() map[string][]int {
+	units := make(map[string][]int)
+	for i, b := range *c.Boxes {
+		if b.Level == provision.BoxSome {
+			units[b.Id] = append(units[b.Id], i)
+		}
+	}
+	return units
+}
+
+//the component ids sorted, so that they are scaled in the same order every time.
+//A scale of a component is of that one alone, when the carton has it.
+func scaledIds(byComp map[string][]int, compId string) []string {
+	if len(compId) > 0 {
+		if _, ok := byComp[compId]; ok {
+			return []string{compId}
+		}
+		return []string{}
+	}
+	return sortedIds(byComp)
+}
+
+//the component ids sorted.
+func sortedIds(byComp map[string][]int) []string {
+	ids := make([]string, 0, len(byComp))
+	for id := range byComp {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+//records the units deployed for every component.
+func (c *Carton) recordUnits() error {
+	for id, idxs := range c.unitsByComponent() {
+		comp, err := NewComponent(id)
+		if err != nil {
+			return err
+		}
+		if err = comp.setRunningUnits(len(idxs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scale brings the units of the component to the count asked for, or the
+// units of every component to the count in its inputs when no component is
+// asked for. The boxes are the units running, the ones wanted beyond them
+// are deployed or else the surplus ones destroyed. The provisioner removes
+// the routes of a unit it destroys, its outputs are removed here.
+func (c *Carton) Scale(compId string, units int) error {
+	po := c.policyOpts(POLICY_SCALE)
+	for _, o := range po {
+		o.Component = compId
+		o.Units = units
+	}
+	return c.enforceOpts(POLICY_SCALE, po, func() error {
+		return c.scale(compId, units)
+	})
+}
+
+func (c *Carton) scale(compId string, units int) error {
+	byComp := c.unitsByComponent()
+	r := make(Report, 0)
+	for _, id := range scaledIds(byComp, compId) {
+		comp, err := NewComponent(id)
+		if err != nil {
+			r = append(r, &Result{Name: id, Err: err})
+			continue
+		}
+		r = append(r, c.scaleComponent(comp, byComp[id], wantedUnits(comp, units))...)
+	}
+	if err := r.Err(); err != nil {
+		log.Errorf("Unable to scale the boxes %s", err)
+		return err
+	}
+	return nil
+}
+
+//the units wanted for the component, the ones asked for or else the ones in
+//its inputs.
+func wantedUnits(comp *Component, units int) int {
+	if units > 0 {
+		return units
+	}
+	return comp.units()
+}
+
+//the boxes to operate upon to bring the component to the units wanted. The
+//units beyond the running ones are made from the first unit and deployed,
+//or else the surplus units are destroyed (the last unit first).
+func (c *Carton) scaleOps(idxs []int, wanted int) (ops []*provision.Box, up bool) {
+	boxes := *c.Boxes
+	running := len(idxs)
+	switch {
+	case wanted > running:
+		for unit := running; unit < wanted; unit++ {
+			b := boxes[idxs[0]]
+			b.Unit = unit
+			b.Status = "" //a unit not deployed yet has no status.
+			ops = append(ops, &b)
+		}
+		return ops, true
+	case wanted < running:
+		for k := len(idxs) - 1; k >= 0; k-- {
+			if boxes[idxs[k]].Unit >= wanted {
+				ops = append(ops, &boxes[idxs[k]])
+			}
+		}
+	}
+	return ops, false
+}
+
+func (c *Carton) scaleComponent(comp *Component, idxs []int, wanted int) Report {
+	log.Debugf("  scale component (%s) from %d to %d units", comp.Name, len(idxs), wanted)
+	ops, up := c.scaleOps(idxs, wanted)
+	var r Report
+	if up {
+		r = c.scaleUp(comp, ops)
+	} else if len(ops) > 0 {
+		r = c.scaleDown(comp, ops)
+	}
+	if r.Err() == nil && wanted != comp.units() {
+		if err := comp.setUnits(wanted); err != nil {
+			r = append(r, &Result{Name: comp.Name, Err: err})
+		}
+	}
+	return r
+}
+
+//deploys the units at once. When one of them fails, the ones which went up
+//are destroyed so that the running units stay as they were.
+func (c *Carton) scaleUp(comp *Component, ops []*provision.Box) Report {
+	if err := hold(c.AccountsId, ops...); err != nil {
+		return Report{&Result{Name: comp.Name, Err: err}}
+	}
+	errs := eachSlot(ops, func(b *provision.Box) error {
+		return Deploy(&DeployOpts{B: b})
+	})
+	r := make(Report, len(ops))
+	up := make([]*provision.Box, 0, len(ops))
+	for i, b := range ops {
+		r[i] = &Result{Name: unitName(b), Err: errs[i]}
+		if errs[i] == nil {
+			up = append(up, b)
+		}
+	}
+	if r.Err() == nil {
+		if err := comp.setRunningUnits(ops[len(ops)-1].Unit + 1); err != nil {
+			r = append(r, &Result{Name: comp.Name, Err: err})
+		}
+		return r
+	}
+	released := ops
+	for k, err := range eachSlot(up, destroyUnit) {
+		if err != nil {
+			log.Errorf("  unable to undo the unit %s of the failed scale %s", unitName(up[k]), err)
+			released = without(released, up[k])
+		}
+	}
+	if err := releaseHeld(c.AccountsId, released...); err != nil {
+		r = append(r, &Result{Name: comp.Name, Err: err})
+	}
+	return r
+}
+
+//destroys the surplus units one after the other, the last unit first. The
+//running units are the ones up to the unit which failed, so that the units
+//running stay in a row.
+func (c *Carton) scaleDown(comp *Component, ops []*provision.Box) Report {
+	r := make(Report, 0, len(ops))
+	running := ops[0].Unit + 1
+	destroyed := make([]*provision.Box, 0, len(ops))
+	for _, b := range ops {
+		err := eachSlot([]*provision.Box{b}, destroyUnit)[0]
+		r = append(r, &Result{Name: unitName(b), Err: err})
+		if err != nil {
+			break
+		}
+		destroyed = append(destroyed, b)
+		running = b.Unit
+	}
+	if len(destroyed) == 0 {
+		return r
+	}
+	if err := releaseHeld(c.AccountsId, destroyed...); err != nil {
+		r = append(r, &Result{Name: comp.Name, Err: err})
+	}
+	if err := comp.setRunningUnits(running); err != nil {
+		r = append(r, &Result{Name: comp.Name, Err: err})
+	}
+	return r
+}
+
+//the boxes but the one.
+func without(boxes []*provision.Box, b *provision.Box) []*provision.Box {
+	left := make([]*provision.Box, 0, len(boxes))
+	for _, o := range boxes {
+		if o != b {
+			left = append(left, o)
+		}
+	}
+	return left
+}
+
+//the outputs a surplus unit leaves behind, removed once it is destroyed. Its
+//routes are removed by the destroy of its provisioner.
+var unitCleanups = []cleanup{
+	{"deploys", removeDeploys},
+	{"states", markStatesAsRemoved},
+	{"status", removeUnitStatus},
+	{"snapshots", removeSnapshots},
+}
+
+//the status of a unit after the first one, the first is the component's.
+func removeUnitStatus(b *provision.Box) error {
+	if b.Level != provision.BoxSome || b.Unit == 0 {
+		return nil
+	}
+	comp, err := NewComponent(b.Id)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return comp.nukeOutputs(provision.UnitKey(UNITSTATUS, b.Unit))
+}
+
+//destroys a surplus unit of a component along with its outputs, unlike
+//Destroy the component and the assembly are left intact.
+func destroyUnit(b *provision.Box) error {
+	var outBuffer bytes.Buffer
+	start := time.Now()
+	logWriter := LogWriter{Box: b}
+	logWriter.Async()
+	defer logWriter.Close()
+	writer := io.MultiWriter(&outBuffer, &logWriter)
+	err := b.Move(constants.StatusDestroying, func() error {
+		return ProvisionerMap[b.Provider].Destroy(b, writer)
+	})
+	log.Debugf("%s in (%s)\n%s",
+		cmd.Colorfy(b.GetFullName(), "cyan", "", "bold"),
+		cmd.Colorfy(time.Since(start).String(), "green", "", "bold"),
+		cmd.Colorfy(outBuffer.String(), "yellow", "", ""))
+	if err != nil {
+		return err
+	}
+	for _, c := range unitCleanups {
+		if cerr := c.fn(b); cerr != nil {
+			log.Errorf("  unable to remove the %s of unit (%s) %s", c.name, unitName(b), cerr)
+		}
+	}
+	return nil
+}
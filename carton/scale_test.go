@@ -0,0 +1,150 @@
+package carton
+
+import (
+	"errors"
+
+	constants "github.com/megamsys/libgo/utils"
+	"gopkg.in/check.v1"
+)
+
+//scales the component of the assembly to the units.
+func (s *FlowSuite) scaleTo(c *check.C, id, compId string, units int) error {
+	return s.runRequest(c, &Requests{Id: id, CatId: "AMS001", Category: CONTROL, Action: SCALE, ComponentId: compId, Units: units})
+}
+
+func (s *FlowSuite) units(c *check.C, id string) (wanted, running string) {
+	comp, err := NewComponent(id)
+	c.Assert(err, check.IsNil)
+	return comp.Inputs.Match(UNITS), comp.Outputs.Match(UNITS)
+}
+
+func (s *FlowSuite) TestScaleToTheUnitsAsked(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+
+	c.Assert(s.scaleTo(c, "RIP002", "CMPdb", 3), check.IsNil)
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy"})
+	c.Assert(s.prov.Ops("CMPdb-2"), check.DeepEquals, []string{"deploy"})
+	wanted, running := s.units(c, "CMPdb")
+	c.Assert(wanted, check.Equals, "3")
+	c.Assert(running, check.Equals, "3")
+	ds, err := ListDeploys("ASM001", "CMPdb-2")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+	c.Assert(ds[0].BoxName, check.Equals, "myapp-2")
+
+	c.Assert(s.scaleTo(c, "RIP003", "CMPdb", 1), check.IsNil)
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy", "destroy"})
+	c.Assert(s.prov.Ops("CMPdb-2"), check.DeepEquals, []string{"deploy", "destroy"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy"})
+	c.Assert(s.prov.Ops("CMPweb-1"), check.HasLen, 0)
+	wanted, _ = s.units(c, "CMPweb")
+	c.Assert(wanted, check.Equals, "")
+	wanted, running = s.units(c, "CMPdb")
+	c.Assert(wanted, check.Equals, "1")
+	c.Assert(running, check.Equals, "1")
+	_, err = ListDeploys("ASM001", "CMPdb-2")
+	c.Assert(err, check.Equals, ErrNotFound)
+	ds, err = ListDeploys("ASM001", "CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 1)
+}
+
+func (s *FlowSuite) TestAScaleToTheUnitsNeedsTheComponent(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	c.Assert(s.scaleTo(c, "RIP002", "", 2), check.ErrorMatches, ".*needs the component_id of the component scaled")
+	c.Assert(s.prov.Ops("CMPdb-1"), check.HasLen, 0)
+	c.Assert(s.prov.Ops("CMPweb-1"), check.HasLen, 0)
+}
+
+func (s *FlowSuite) TestUnitsKeepTheirOwnStatus(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	c.Assert(s.scaleTo(c, "RIP002", "CMPdb", 2), check.IsNil)
+	comp, err := NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(comp.SetUnitStatus(1, constants.StatusStopped), check.IsNil)
+
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	for _, b := range *cs.Boxes {
+		if b.Id == "CMPdb" && b.Unit == 1 {
+			c.Check(b.Status, check.Equals, constants.StatusStopped)
+		} else {
+			c.Check(b.Status, check.Equals, constants.StatusRunning)
+		}
+	}
+	c.Assert(*cs.Boxes, check.HasLen, 3)
+}
+
+func (s *FlowSuite) TestDestroyTearsDownTheRunningUnits(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	c.Assert(s.scaleTo(c, "RIP002", "CMPdb", 3), check.IsNil)
+	comp, err := NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(comp.setUnits(1), check.IsNil)
+
+	s.run(c, "RIP003", STATE, DESTROY)
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy", "destroy"})
+	c.Assert(s.prov.Ops("CMPdb-2"), check.DeepEquals, []string{"deploy", "destroy"})
+}
+
+func (s *FlowSuite) TestFailedScaleUpUndoesTheUnitsDeployed(c *check.C) {
+	c.Assert(SetQuota("ORG001", Resources{Boxes: 10}), check.IsNil)
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	q, err := GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	used := q.Used()
+
+	s.prov.PrepareFailureOn("deploy", "CMPdb-2", errors.New("no room on the host"))
+	c.Assert(s.scaleTo(c, "RIP002", "CMPdb", 3), check.ErrorMatches, ".*no room on the host.*")
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy", "destroy"})
+	c.Assert(s.prov.Ops("CMPdb-2"), check.HasLen, 0)
+	wanted, running := s.units(c, "CMPdb")
+	c.Assert(wanted, check.Equals, "")
+	c.Assert(running, check.Equals, "1")
+	q, err = GetQuota("ORG001")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Used().Boxes, check.Equals, used.Boxes)
+}
+
+func (s *FlowSuite) TestPlanScaleToTheUnitsAsked(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	cp := cs.planScale("CMPdb", 2)
+	c.Assert(cp.Boxes, check.HasLen, 1)
+	c.Assert(cp.Boxes[0].Name, check.Equals, "db-1")
+	cp = cs.planScale("", 0)
+	c.Assert(cp.Boxes, check.HasLen, 0)
+	c.Assert(s.prov.Ops("CMPdb-1"), check.HasLen, 0)
+}
+
+func (s *FlowSuite) TestDestroyRemovesTheBoxWithItsLastUnit(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+	c.Assert(s.scaleTo(c, "RIP002", "CMPdb", 2), check.IsNil)
+	c.Assert(s.scaleTo(c, "RIP005", "CMPweb", 2), check.IsNil)
+	s.run(c, "RIP003", OPERATIONS, SNAPSHOT)
+	ss, err := ListSnapshots("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ss, check.HasLen, 4)
+
+	s.prov.PrepareFailureOn("destroy", "CMPdb-1", errors.New("host unreachable"))
+	c.Assert(s.runRequest(c, &Requests{Id: "RIP004", CatId: "AMS001", Category: STATE, Action: DESTROY}), check.ErrorMatches, ".*host unreachable.*")
+	_, err = NewComponent("CMPweb")
+	c.Assert(err, check.Equals, ErrNotFound)
+	_, err = NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	ss, err = ListSnapshots("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ss, check.HasLen, 1)
+	c.Assert(ss[0].BoxId, check.Equals, "CMPdb")
+	c.Assert(ss[0].Unit, check.Equals, 1)
+	c.Assert(s.prov.Ops("CMPweb-1"), check.DeepEquals, []string{"deploy", "snapshot", "destroy", "deletesnapshot"})
+	c.Assert(s.prov.Ops("CMPdb-1"), check.DeepEquals, []string{"deploy", "snapshot"})
+}
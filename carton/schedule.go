@@ -0,0 +1,214 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/carton/cron"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	SCHEDULESBUCKET = "schedules"
+	SCHEDULEDBUCKET = "scheduled"
+
+	//what is done with the runs of a schedule which were missed, eg: when
+	//the engine was down.
+	MISSED_ONCE = "once" //they are made up with a single run.
+	MISSED_SKIP = "skip" //they are skipped, the schedule waits for its next run.
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+//guards the read, change and save of the schedules of an assembly.
+var scheduleMu sync.Mutex
+
+// Schedule runs a request on an assembly as per a cron spec,
+// eg: control/stop at "0 20 * * 1-5".
+type Schedule struct {
+	Id        string `json:"id"`
+	CatId     string `json:"cat_id"`
+	Category  string `json:"category"`
+	Action    string `json:"action"`
+	Cron      string `json:"cron"`
+	Missed    string `json:"missed"`
+	LastRun   string `json:"last_run"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Schedules are the schedules of an assembly, each one stored as a json string.
+type Schedules struct {
+	CatId     string   `json:"cat_id" cql:"cat_id"`
+	Schedules []string `json:"schedules" cql:"schedules"`
+}
+
+// NewSchedule returns a schedule of the request on the assembly, the
+// request and the cron spec are checked upfront.
+func NewSchedule(catId, category, action, spec, missed string) (*Schedule, error) {
+	if _, err := ParseRequest(catId, category, action); err != nil {
+		return nil, err
+	}
+	if _, err := cron.Parse(spec); err != nil {
+		return nil, err
+	}
+	switch missed {
+	case "", MISSED_ONCE, MISSED_SKIP:
+	default:
+		return nil, fmt.Errorf("unknown missed run policy %q, use %s or %s", missed, MISSED_ONCE, MISSED_SKIP)
+	}
+	return &Schedule{
+		Id:        "SCH" + strings.Replace(uuid.NewV1().String(), "-", "", -1),
+		CatId:     catId,
+		Category:  category,
+		Action:    action,
+		Cron:      spec,
+		Missed:    missed,
+		CreatedAt: time.Now().Local().Format(time.RFC3339),
+	}, nil
+}
+
+// Next returns when the schedule is due after it last ran, or else after
+// it was created.
+func (s *Schedule) Next() (time.Time, error) {
+	c, err := cron.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	last := s.CreatedAt
+	if len(strings.TrimSpace(s.LastRun)) > 0 {
+		last = s.LastRun
+	}
+	t, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.Next(t), nil
+}
+
+// Enqueue saves a request for the schedule, which is queued for the
+// daemons the usual way.
+func (s *Schedule) Enqueue() (*Requests, error) {
+	return enqueue(s.Id, s.CatId, s.Category, s.Action)
+}
+
+//saves a queued request on the assembly, named after what asked for it.
+func enqueue(name, catId, category, action string) (*Requests, error) {
+	r := &Requests{
+		Id:        "RIP" + strings.Replace(uuid.NewV1().String(), "-", "", -1),
+		Name:      name,
+		CatId:     catId,
+		Category:  category,
+		Action:    action,
+		CreatedAt: time.Now().Local().Format(time.RFC3339),
+		Status:    REQ_QUEUED,
+	}
+	if err := store.StoreRequest(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Ran saves when the schedule last ran.
+func (s *Schedule) Ran(t time.Time) error {
+	s.LastRun = t.Format(time.RFC3339)
+	return changeSchedules(s.CatId, func(ss []*Schedule) ([]*Schedule, error) {
+		for i := range ss {
+			if ss[i].Id == s.Id {
+				ss[i].LastRun = s.LastRun
+				return ss, nil
+			}
+		}
+		return nil, ErrScheduleNotFound
+	})
+}
+
+// AddSchedule saves the schedule along with the others of its assembly.
+func AddSchedule(s *Schedule) error {
+	return changeSchedules(s.CatId, func(ss []*Schedule) ([]*Schedule, error) {
+		return append(ss, s), nil
+	})
+}
+
+// RemoveSchedule removes a schedule of an assembly.
+func RemoveSchedule(catId, id string) error {
+	return changeSchedules(catId, func(ss []*Schedule) ([]*Schedule, error) {
+		for i := range ss {
+			if ss[i].Id == id {
+				return append(ss[:i], ss[i+1:]...), nil
+			}
+		}
+		return nil, ErrScheduleNotFound
+	})
+}
+
+// ListSchedules returns the schedules of an assembly, none when it has
+// none. A failed read is an error, so that the schedules aren't overwritten
+// after it.
+func ListSchedules(catId string) ([]*Schedule, error) {
+	d, err := store.GetSchedules(catId)
+	if err == ErrNotFound {
+		return []*Schedule{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return d.list(), nil
+}
+
+// ScheduledIds returns the assemblies which have schedules.
+func ScheduledIds() ([]string, error) {
+	return store.ScheduledIds()
+}
+
+func changeSchedules(catId string, fn func([]*Schedule) ([]*Schedule, error)) error {
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+	ss, err := ListSchedules(catId)
+	if err != nil {
+		return err
+	}
+	if ss, err = fn(ss); err != nil {
+		return err
+	}
+	d := &Schedules{CatId: catId, Schedules: make([]string, 0, len(ss))}
+	for _, s := range ss {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		d.Schedules = append(d.Schedules, string(b))
+	}
+	return store.StoreSchedules(d)
+}
+
+func (d *Schedules) list() []*Schedule {
+	ss := make([]*Schedule, 0, len(d.Schedules))
+	for _, in := range d.Schedules {
+		s := Schedule{}
+		if err := parseStringToStruct(in, &s); err != nil {
+			log.Errorf("Unparsable schedule, ignoring: %s", in)
+			continue
+		}
+		ss = append(ss, &s)
+	}
+	return ss
+}
@@ -0,0 +1,95 @@
+package carton
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type ScheduleSuite struct{}
+
+var _ = check.Suite(&ScheduleSuite{})
+
+func (s *ScheduleSuite) SetUpTest(c *check.C) {
+	SetRepository(NewMemRepository())
+}
+
+func (s *ScheduleSuite) TestNewScheduleChecks(c *check.C) {
+	_, err := NewSchedule("ASM001", CONTROL, STOP, "0 20 * * 1-5", "")
+	c.Assert(err, check.IsNil)
+	_, err = NewSchedule("ASM001", CONTROL, "nap", "0 20 * * 1-5", "")
+	c.Assert(err, check.NotNil)
+	_, err = NewSchedule("ASM001", CONTROL, STOP, "0 25 * * *", "")
+	c.Assert(err, check.NotNil)
+	_, err = NewSchedule("ASM001", CONTROL, STOP, "@daily", "twice")
+	c.Assert(err, check.ErrorMatches, "unknown missed run policy.*")
+}
+
+func (s *ScheduleSuite) TestAddListRemove(c *check.C) {
+	stop, _ := NewSchedule("ASM001", CONTROL, STOP, "0 20 * * *", MISSED_SKIP)
+	start, _ := NewSchedule("ASM001", CONTROL, START, "0 8 * * *", "")
+	c.Assert(AddSchedule(stop), check.IsNil)
+	c.Assert(AddSchedule(start), check.IsNil)
+
+	ss, err := ListSchedules("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ss, check.DeepEquals, []*Schedule{stop, start})
+	ids, _ := ScheduledIds()
+	c.Assert(ids, check.DeepEquals, []string{"ASM001"})
+
+	c.Assert(RemoveSchedule("ASM001", stop.Id), check.IsNil)
+	c.Assert(RemoveSchedule("ASM001", stop.Id), check.Equals, ErrScheduleNotFound)
+	c.Assert(RemoveSchedule("ASM001", start.Id), check.IsNil)
+	ss, _ = ListSchedules("ASM001")
+	c.Assert(ss, check.HasLen, 0)
+	ids, _ = ScheduledIds()
+	c.Assert(ids, check.HasLen, 0)
+}
+
+func (s *ScheduleSuite) TestAFailedReadKeepsTheSchedules(c *check.C) {
+	m := NewMemRepository()
+	SetRepository(m)
+	stop, _ := NewSchedule("ASM001", CONTROL, STOP, "0 20 * * *", "")
+	start, _ := NewSchedule("ASM001", CONTROL, START, "0 8 * * *", "")
+	c.Assert(AddSchedule(stop), check.IsNil)
+
+	m.FailReads(errors.New("timed out"))
+	_, err := ListSchedules("ASM001")
+	c.Assert(err, check.ErrorMatches, "timed out")
+	c.Assert(AddSchedule(start), check.ErrorMatches, "timed out")
+	c.Assert(stop.Ran(time.Now()), check.ErrorMatches, "timed out")
+
+	m.FailReads(nil)
+	ss, err := ListSchedules("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ss, check.HasLen, 1)
+	c.Assert(ss[0].Id, check.Equals, stop.Id)
+	c.Assert(ss[0].LastRun, check.Equals, "")
+}
+
+func (s *ScheduleSuite) TestNextAfterLastRun(c *check.C) {
+	sc, _ := NewSchedule("ASM001", CONTROL, STOP, "0 20 * * *", "")
+	sc.CreatedAt = "2016-03-01T09:00:00Z"
+	c.Assert(AddSchedule(sc), check.IsNil)
+	next, err := sc.Next()
+	c.Assert(err, check.IsNil)
+	c.Assert(next.Format(time.RFC3339), check.Equals, "2016-03-01T20:00:00Z")
+
+	ran, _ := time.Parse(time.RFC3339, "2016-03-01T20:00:10Z")
+	c.Assert(sc.Ran(ran), check.IsNil)
+	ss, _ := ListSchedules("ASM001")
+	next, _ = ss[0].Next()
+	c.Assert(next.Format(time.RFC3339), check.Equals, "2016-03-02T20:00:00Z")
+}
+
+func (s *ScheduleSuite) TestEnqueue(c *check.C) {
+	sc, _ := NewSchedule("ASM001", CONTROL, START, "@daily", "")
+	r, err := sc.Enqueue()
+	c.Assert(err, check.IsNil)
+	got, err := store.GetRequest(r.Id)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Name, check.Equals, sc.Id)
+	c.Assert(got.CatId, check.Equals, "ASM001")
+	c.Assert(got.Status, check.Equals, REQ_QUEUED)
+}
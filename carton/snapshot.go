@@ -0,0 +1,254 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	//the output of an assembly which lists its snapshots.
+	SNAPSHOTS = "snapshots"
+
+	//the inputs staged ahead of an operations/snapshot (or restore) request,
+	//the name of the snapshot to take (or restore). A snapshot without a
+	//name is named after when it was taken, the latest snapshot is restored.
+	SNAPSHOT_NAME    = "snapshot_name"
+	RESTORE_SNAPSHOT = "restore_snapshot"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+//guards the read, change and save of the snapshots of an assembly.
+var snapshotMu sync.Mutex
+
+// Snapshot is a snapshot of a box, the ref is what its provisioner knows
+// it by, eg: a disk snapshot id or an image.
+type Snapshot struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	BoxId     string `json:"box_id"`
+	Unit      int    `json:"unit"`
+	BoxName   string `json:"box_name"`
+	Provider  string `json:"provider"`
+	Ref       string `json:"ref"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SnapshotOpts struct {
+	B         *provision.Box
+	Name      string
+	Ref       string
+	start     time.Time
+	logWriter LogWriter
+	writer    io.Writer
+}
+
+func (s *SnapshotOpts) setLogger() {
+	s.start = time.Now()
+	s.logWriter = NewLogWriter(s.B)
+	s.writer = io.MultiWriter(&s.logWriter)
+}
+
+func snapshotter(b *provision.Box) (provision.Snapshotter, error) {
+	s, ok := ProvisionerMap[b.Provider].(provision.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("provisioner %q can't snapshot the box %s", b.Provider, b.GetFullName())
+	}
+	return s, nil
+}
+
+// TakeSnapshot snapshots the box and records the snapshot in the outputs of
+// its assembly.
+func TakeSnapshot(opts *SnapshotOpts) (*Snapshot, error) {
+	log.Debugf("  snapshot cycle for box (%s, %s)", opts.B.Id, opts.B.GetFullName())
+	p, err := snapshotter(opts.B)
+	if err != nil {
+		return nil, err
+	}
+	opts.setLogger()
+	defer opts.logWriter.Close()
+	var ref string
+	if err := opts.B.Move(provision.StatusSnapshotting, func() (serr error) {
+		ref, serr = p.Snapshot(opts.B, opts.Name, opts.writer)
+		return serr
+	}); err != nil {
+		return nil, err
+	}
+	s := &Snapshot{
+		Id:        "SNP" + strings.Replace(uuid.NewV1().String(), "-", "", -1),
+		Name:      opts.Name,
+		BoxId:     opts.B.Id,
+		Unit:      opts.B.Unit,
+		BoxName:   opts.B.GetFullName(),
+		Provider:  opts.B.Provider,
+		Ref:       ref,
+		CreatedAt: time.Now().Local().Format(time.RFC3339),
+	}
+	if err := changeSnapshots(opts.B.CartonId, func(ss []*Snapshot) ([]*Snapshot, error) {
+		return append(ss, s), nil
+	}); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(opts.writer, "    snapshot (%s, %s, %s) OK\n", opts.B.GetFullName(), s.Name, time.Since(opts.start))
+	return s, nil
+}
+
+// RestoreSnapshot restores the box from the snapshot of the ref.
+func RestoreSnapshot(opts *SnapshotOpts) error {
+	log.Debugf("  restore cycle for box (%s, %s) from %s", opts.B.Id, opts.B.GetFullName(), opts.Ref)
+	p, err := snapshotter(opts.B)
+	if err != nil {
+		return err
+	}
+	opts.setLogger()
+	defer opts.logWriter.Close()
+	if err := opts.B.Move(provision.StatusRestoring, func() error {
+		return p.Restore(opts.B, opts.Ref, opts.writer)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.writer, "    restore (%s, %s, %s) OK\n", opts.B.GetFullName(), opts.Name, time.Since(opts.start))
+	return nil
+}
+
+// ListSnapshots returns the snapshots of an assembly, in the order taken.
+func ListSnapshots(asmId string) ([]*Snapshot, error) {
+	a, err := get(asmId)
+	if err != nil {
+		return nil, err
+	}
+	return a.snapshots(), nil
+}
+
+// DeleteSnapshot deletes a snapshot of an assembly, along with what its
+// provisioner kept of it.
+func DeleteSnapshot(asmId, id string) error {
+	return changeSnapshots(asmId, func(ss []*Snapshot) ([]*Snapshot, error) {
+		for i, s := range ss {
+			if s.Id != id {
+				continue
+			}
+			b := &provision.Box{Id: s.BoxId, Unit: s.Unit, CartonId: asmId, Name: s.BoxName, Provider: s.Provider}
+			p, err := snapshotter(b)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.DeleteSnapshot(b, s.Ref, ioutil.Discard); err != nil {
+				return nil, err
+			}
+			return append(ss[:i], ss[i+1:]...), nil
+		}
+		return nil, ErrSnapshotNotFound
+	})
+}
+
+//a snapshot which doesn't parse is ignored.
+func (a *Assembly) snapshots() []*Snapshot {
+	ss := make([]*Snapshot, 0)
+	if in := a.Outputs.Match(SNAPSHOTS); len(strings.TrimSpace(in)) > 0 {
+		if err := parseStringToStruct(in, &ss); err != nil {
+			log.Errorf("Unparsable snapshots of (%s), ignoring: %s", a.Name, err)
+		}
+	}
+	return ss
+}
+
+func changeSnapshots(asmId string, fn func([]*Snapshot) ([]*Snapshot, error)) error {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	ss, err := ListSnapshots(asmId)
+	if err != nil {
+		return err
+	}
+	if ss, err = fn(ss); err != nil {
+		return err
+	}
+	b, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	a, err := NewAmbly(asmId)
+	if err != nil {
+		return err
+	}
+	return a.NukeAndSetOutputs(map[string][]string{SNAPSHOTS: []string{string(b)}})
+}
+
+func (a *Assembly) snapshotName() string {
+	return strings.TrimSpace(a.Inputs.Match(SNAPSHOT_NAME))
+}
+
+func (a *Assembly) restoreName() string {
+	return strings.TrimSpace(a.Inputs.Match(RESTORE_SNAPSHOT))
+}
+
+//the latest snapshot of the unit of the box with the name, or else the
+//latest one of the unit when there's no name.
+func (c *Carton) snapshotOf(ss []*Snapshot, b *provision.Box) (*Snapshot, error) {
+	for i := len(ss) - 1; i >= 0; i-- {
+		if ss[i].BoxId == b.Id && ss[i].Unit == b.Unit && (len(c.RestoreName) == 0 || ss[i].Name == c.RestoreName) {
+			return ss[i], nil
+		}
+	}
+	return nil, ErrSnapshotNotFound
+}
+
+// Snapshot snapshots the boxes of the carton, under the same name.
+func (c *Carton) Snapshot() error {
+	name := c.SnapshotName
+	if len(name) == 0 {
+		name = "snap" + time.Now().Local().Format("20060102150405")
+	}
+	if err := c.eachBox(func(b *provision.Box) error {
+		_, err := TakeSnapshot(&SnapshotOpts{B: b, Name: name})
+		return err
+	}).Err(); err != nil {
+		log.Errorf("Unable to snapshot the box %s", err)
+		return err
+	}
+	return nil
+}
+
+// Restore restores the boxes of the carton from their snapshots.
+func (c *Carton) Restore() error {
+	ss, err := ListSnapshots(c.Id)
+	if err != nil {
+		return err
+	}
+	if err := c.eachBox(func(b *provision.Box) error {
+		s, err := c.snapshotOf(ss, b)
+		if err != nil {
+			return err
+		}
+		return RestoreSnapshot(&SnapshotOpts{B: b, Name: s.Name, Ref: s.Ref})
+	}).Err(); err != nil {
+		log.Errorf("Unable to restore the box %s", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package carton
+
+import (
+	constants "github.com/megamsys/libgo/utils"
+	"gopkg.in/check.v1"
+)
+
+//stages the inputs of a snapshot (or restore) in the assembly.
+func (s *FlowSuite) stageSnapshot(c *check.C, key, name string) {
+	a, err := NewAmbly("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.NukeAndSetInputs(map[string][]string{key: []string{name}}), check.IsNil)
+}
+
+func (s *FlowSuite) TestSnapshotAndRestore(c *check.C) {
+	s.settle(c, constants.StatusRunning)
+	s.stageSnapshot(c, SNAPSHOT_NAME, "before-upgrade")
+	s.run(c, "RIP001", OPERATIONS, SNAPSHOT)
+	s.stageSnapshot(c, SNAPSHOT_NAME, "after-upgrade")
+	s.run(c, "RIP002", OPERATIONS, SNAPSHOT)
+
+	ss, err := ListSnapshots("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ss, check.HasLen, 4)
+	c.Assert(ss[0].Name, check.Equals, "before-upgrade")
+	c.Assert(ss[0].Ref, check.Equals, ss[0].BoxId+"/before-upgrade")
+	c.Assert(ss[3].Name, check.Equals, "after-upgrade")
+
+	s.stageSnapshot(c, RESTORE_SNAPSHOT, "before-upgrade")
+	s.run(c, "RIP003", OPERATIONS, RESTORE)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"snapshot", "snapshot", "restore"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"snapshot", "snapshot", "restore"})
+}
+
+func (s *FlowSuite) TestRestoreWithoutSnapshot(c *check.C) {
+	s.settle(c, constants.StatusRunning)
+	cs, err := mkCarton("AMS001", "ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(cs.Restore(), check.ErrorMatches, ".*snapshot not found.*")
+}
+
+func (s *FlowSuite) TestDeleteSnapshot(c *check.C) {
+	s.settle(c, constants.StatusRunning)
+	s.run(c, "RIP001", OPERATIONS, SNAPSHOT)
+	ss, _ := ListSnapshots("ASM001")
+	c.Assert(ss, check.HasLen, 2)
+
+	c.Assert(DeleteSnapshot("ASM001", ss[0].Id), check.IsNil)
+	c.Assert(DeleteSnapshot("ASM001", ss[0].Id), check.Equals, ErrSnapshotNotFound)
+	left, _ := ListSnapshots("ASM001")
+	c.Assert(left, check.HasLen, 1)
+	c.Assert(left[0].Id, check.Equals, ss[1].Id)
+	c.Assert(s.prov.Ops(ss[0].BoxId), check.DeepEquals, []string{"snapshot", "deletesnapshot"})
+}
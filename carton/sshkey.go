@@ -0,0 +1,45 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const SSHKEYSBUCKET = "sshkeys"
+
+// SSHKey is a key pair of an org kept by its name, the boxes launched with
+// it get its public key.
+type SSHKey struct {
+	OrgId     string `json:"org_id" cql:"org_id"`
+	Name      string `json:"name" cql:"name"`
+	PublicKey string `json:"publickey" cql:"publickey"`
+}
+
+//the public key of the named key of the org, blank when there is none.
+func publicKey(orgId, name string) string {
+	if len(strings.TrimSpace(name)) == 0 {
+		return ""
+	}
+	k, err := store.GetSSHKey(orgId, name)
+	if err != nil {
+		log.Warningf("  unable to read the ssh key %s of org %s: %s", name, orgId, err)
+		return ""
+	}
+	return strings.TrimSpace(k.PublicKey)
+}
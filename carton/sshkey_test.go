@@ -0,0 +1,21 @@
+package carton
+
+import (
+	"gopkg.in/check.v1"
+)
+
+type SSHKeySuite struct{}
+
+var _ = check.Suite(&SSHKeySuite{})
+
+func (s *SSHKeySuite) SetUpTest(c *check.C) {
+	SetRepository(NewMemRepository())
+}
+
+func (s *SSHKeySuite) TestPublicKey(c *check.C) {
+	c.Assert(store.StoreSSHKey(&SSHKey{OrgId: "ORG001", Name: "plano", PublicKey: "ssh-rsa AAAA me@plano\n"}), check.IsNil)
+	c.Assert(publicKey("ORG001", "plano"), check.Equals, "ssh-rsa AAAA me@plano")
+	c.Assert(publicKey("ORG001", "dallas"), check.Equals, "")
+	c.Assert(publicKey("ORG002", "plano"), check.Equals, "")
+	c.Assert(publicKey("ORG001", ""), check.Equals, "")
+}
@@ -42,7 +42,9 @@ func ChangeState(opts *StateChangeOpts) error {
 func moveState(opts *StateChangeOpts, writer io.Writer) error {
 	if &opts.Changed != nil {
 		if changer, ok := ProvisionerMap[opts.B.Provider].(provision.StateChanger); ok {
-			return changer.SetState(opts.B, writer, opts.Changed)
+			return opts.B.Move(opts.Changed, func() error {
+				return changer.SetState(opts.B, writer, opts.Changed)
+			})
 		}
 	}
 	return nil
@@ -76,7 +78,20 @@ func saveStateData(opts *StateChangeOpts, slog string, duration time.Duration, c
 	return nil
 }
 
-func markStatesAsRemoved(boxName string) error {
-	//Scylla: code to nuke state changes out of a box
-	return nil
+//the outputs the states of a box leave in its assembly, of no use once it is
+//gone. Every unit keeps its own, see provision.UnitKey.
+var stateOutputs = []string{VNCHOST, VNCPORT, VMID, VMNODE, HOSTIP}
+
+func markStatesAsRemoved(b *provision.Box) error {
+	asm, err := NewAmbly(b.CartonId)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	keys := make([]string, len(stateOutputs))
+	for i, k := range stateOutputs {
+		keys[i] = provision.UnitKey(k, b.Unit)
+	}
+	return asm.NukeOutputs(keys...)
 }
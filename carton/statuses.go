@@ -0,0 +1,85 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+)
+
+func init() {
+	provision.Statuses = savedStatuses{}
+}
+
+//the statuses of the boxes as saved: the first unit of a component in the
+//component, its other units in the outputs of the component and a box
+//without a component in the assembly. A component which has none yet goes by
+//the status of its assembly, as the gateway sets it.
+type savedStatuses struct{}
+
+func (savedStatuses) Saved(b *provision.Box) (utils.Status, error) {
+	if b.Level != provision.BoxSome {
+		a, err := store.GetAssembly(b.CartonId)
+		if err != nil {
+			return "", err
+		}
+		return utils.Status(a.Status), nil
+	}
+	c, err := store.GetComponent(b.Id)
+	if err != nil {
+		return "", err
+	}
+	if b.Unit > 0 {
+		comp, _ := c.dig()
+		return comp.unitStatus(b.Unit), nil
+	}
+	return utils.Status(c.Status), nil
+}
+
+//the status of a unit is swapped along with the outputs it is in, under the
+//lock of the outputs so that the ones set here don't fail it.
+func (savedStatuses) Swap(b *provision.Box, from, to utils.Status) (bool, error) {
+	if b.Level != provision.BoxSome {
+		a, err := store.GetAssembly(b.CartonId)
+		if err == ErrNotFound {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		return store.SwapAssembly(a.Id, a.OrgId, statusField(from), statusField(to))
+	}
+	if b.Unit == 0 {
+		return store.SwapComponent(b.Id, statusField(from), statusField(to))
+	}
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	c, err := store.GetComponent(b.Id)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	comp, _ := c.dig()
+	if comp.unitStatus(b.Unit) != from {
+		return false, nil
+	}
+	comp.Outputs.NukeAndSet(map[string][]string{provision.UnitKey(UNITSTATUS, b.Unit): []string{to.String()}})
+	return store.SwapComponent(b.Id, map[string]interface{}{"Outputs": c.Outputs}, map[string]interface{}{"Outputs": comp.Outputs.ToString()})
+}
+
+func statusField(s utils.Status) map[string]interface{} {
+	return map[string]interface{}{"Status": s.String()}
+}
@@ -1,5 +1,19 @@
 package carton
 
+import (
+	"testing"
+
+	"github.com/megamsys/vertice/meta"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	meta.MC = meta.NewConfig()
+	meta.MC.NSQd = []string{"127.0.0.1:0"} //the boxes log to nsqd, which fails fast in tests.
+	SetRepository(NewMemRepository())
+	check.TestingT(t)
+}
+
 /*
 import (
 	"encoding/json"
@@ -13,8 +27,6 @@ import (
 	"gopkg.in/check.v1"
 )
 
-func Test(t *testing.T) { check.TestingT(t) }
-
 type S struct {
 	provisioner *onetest.FakeOneProvisioner
 }
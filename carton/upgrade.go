@@ -1,17 +1,82 @@
 package carton
 
 import (
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/repository"
 )
 
+const (
+	//the status of an operation after an upgrade.
+	OPS_RAN    = "ran"
+	OPS_FAILED = "failed"
+
+	//the operation which runs the command in its "command" property in the box.
+	OPS_COMMAND = "command"
+)
+
+// OpsRunner runs an operation of a component on its box.
+type OpsRunner func(b *provision.Box, o *Operations, w io.Writer) error
+
+var opsRunners = make(map[string]OpsRunner)
+
+// RegisterOpsRunner registers the runner for an operation type.
+func RegisterOpsRunner(opsType string, r OpsRunner) {
+	opsRunners[opsType] = r
+}
+
+func init() {
+	RegisterOpsRunner(repository.CIHOOK, runCIOps)
+	RegisterOpsRunner(OPS_COMMAND, runCommandOps)
+}
+
+//the CI hook is what triggers an upgrade, it is created with the repo
+//manager when the box has none (eg: its create failed) and its id saved.
+func runCIOps(b *provision.Box, o *Operations, w io.Writer) error {
+	if b.Repo == nil || !b.Repo.IsEnabled() {
+		return fmt.Errorf("box %s has no repo to hook", b.GetFullName())
+	}
+	if len(strings.TrimSpace(b.Repo.GetHookId())) > 0 {
+		fmt.Fprintf(w, "    ci hook (%s) of %s is bound\n", b.Repo.GetHookId(), b.GetFullName())
+		return nil
+	}
+	hookId, err := repository.Manager(b.Repo.GetSource()).CreateHook(b.Repo)
+	if err != nil {
+		return err
+	}
+	comp, err := NewComponent(b.Id)
+	if err != nil {
+		return err
+	}
+	if err := comp.setDeployData(DeployData{Timestamp: time.Now(), HookId: hookId}); err != nil {
+		return err
+	}
+	b.Repo.Hook.Id = hookId
+	fmt.Fprintf(w, "    ci hook (%s) of %s created\n", hookId, b.GetFullName())
+	return nil
+}
+
+//runs the command of the operation in the box.
+func runCommandOps(b *provision.Box, o *Operations, w io.Writer) error {
+	args := strings.Fields(o.Properties.match(OPS_COMMAND))
+	if len(args) == 0 {
+		return fmt.Errorf("operation %s of %s has no command", o.Type, b.GetFullName())
+	}
+	fmt.Fprintf(w, "    running %q in %s\n", strings.Join(args, " "), b.GetFullName())
+	return ProvisionerMap[b.Provider].ExecuteCommandOnce(w, w, b, args[0], args[1:]...)
+}
+
 type Upgradeable struct {
 	B             *provision.Box
 	w             io.Writer
 	ShouldRestart bool
+	opsRan        []*Operations
 }
 
 func NewUpgradeable(box *provision.Box) *Upgradeable {
@@ -37,18 +102,26 @@ func (u *Upgradeable) Upgrade() error {
 	return nil
 }
 
+//runs the operations of the component, rebuilds the box and replaces what
+//runs it in place, and restarts it if need be.
 func (u *Upgradeable) operateBox(writer io.Writer) error {
 	u.w = writer
 	start := time.Now()
-	/*opsRan, err := upgrade.Run(upgrade.RunArgs{
-		Name:   u.B.GetFullName(),
-		O:      u.B.Operations,
-		Writer: writer,
-		Force:  false,
-	})
-	if err != nil {
+	if err := u.runOps(); err != nil {
+		return err
+	}
+
+	if err := Deploy(&DeployOpts{B: u.B, InPlace: true}); err != nil {
 		return err
-	}*/
+	}
+
+	if u.ShouldRestart {
+		if err := u.B.Move(constants.StatusStarting, func() error {
+			return ProvisionerMap[u.B.Provider].Restart(u.B, "", writer)
+		}); err != nil {
+			return err
+		}
+	}
 
 	elapsed := time.Since(start)
 
@@ -56,14 +129,50 @@ func (u *Upgradeable) operateBox(writer io.Writer) error {
 		log.Errorf("WARNING: couldn't save ops data, ops opts: %#v", u)
 		return err
 	}
+	fmt.Fprintf(writer, "    upgrade (%s, %s) OK\n", u.B.GetFullName(), elapsed)
+	return nil
+}
 
-	if !u.ShouldRestart {
+//runs the operations of the component in the box, a box without a component
+//has none. Every operation is recorded with its status, even when it fails.
+func (u *Upgradeable) runOps() error {
+	if u.B.Level != provision.BoxSome {
 		return nil
 	}
-
+	comp, err := NewComponent(u.B.Id)
+	if err != nil {
+		return err
+	}
+	u.opsRan = comp.Operations
+	for _, o := range u.opsRan {
+		r, ok := opsRunners[o.Type]
+		if !ok {
+			o.Status = OPS_FAILED
+			u.saveOps()
+			return fmt.Errorf("no runner for operation %s of %s", o.Type, u.B.GetFullName())
+		}
+		if err := r(u.B, o, u.w); err != nil {
+			o.Status = OPS_FAILED
+			u.saveOps()
+			return err
+		}
+		o.Status = OPS_RAN
+	}
 	return nil
 }
 
+func (u *Upgradeable) saveOps() error {
+	if len(u.opsRan) == 0 {
+		return nil
+	}
+	comp := &Component{Id: u.B.Id}
+	return comp.UpdateOpsRun(u.opsRan)
+}
+
 func (u *Upgradeable) saveData(duration time.Duration) error {
-	return nil
+	log.Debugf("  upgraded box (%s) in (%s), %d ops ran", u.B.GetFullName(), duration, len(u.opsRan))
+	if err := u.saveOps(); err != nil {
+		return err
+	}
+	return setBoxStatus(u.B, u.w, constants.StatusUpgraded)
 }
@@ -0,0 +1,67 @@
+package carton
+
+import (
+	"encoding/json"
+
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/repository"
+	"gopkg.in/check.v1"
+)
+
+//the operations of the component.
+func (s *FlowSuite) operations(c *check.C, id string, ops ...Operations) {
+	raw := make([]string, 0, len(ops))
+	for _, o := range ops {
+		b, err := json.Marshal(o)
+		c.Assert(err, check.IsNil)
+		raw = append(raw, string(b))
+	}
+	c.Assert(s.repo.UpdateComponent(id, map[string]interface{}{"Operations": raw}), check.IsNil)
+}
+
+func (s *FlowSuite) TestUpgradeRunsTheOperationsAndReplacesTheBox(c *check.C) {
+	m := &fakeHooks{id: "43"}
+	repository.Register("fakehook", m)
+	c.Assert(s.repo.UpdateComponent("CMPweb", map[string]interface{}{"Repo": `{"rtype":"image","source":"fakehook"}`}), check.IsNil)
+	s.operations(c, "CMPweb", Operations{Type: repository.CIHOOK, Properties: JsonPairs{NewJsonPair(repository.TOKEN, "secret")}})
+	s.operations(c, "CMPdb", Operations{Type: OPS_COMMAND, Properties: JsonPairs{NewJsonPair(OPS_COMMAND, "migrate --all")}})
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+
+	s.run(c, "RIP002", OPERATIONS, UPGRADE)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "migrate", "replace", "restart", "status"})
+	c.Assert(s.prov.Ops("CMPweb"), check.DeepEquals, []string{"deploy", "replace", "restart", "status"})
+	c.Assert(m.created, check.Equals, 1)
+	web, err := NewComponent("CMPweb")
+	c.Assert(err, check.IsNil)
+	c.Assert(web.Outputs.Match(HOOKID), check.Equals, "43")
+	c.Assert(web.Operations[0].Status, check.Equals, OPS_RAN)
+	db, err := NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(db.Operations[0].Status, check.Equals, OPS_RAN)
+	ds, err := ListDeploys("ASM001", "CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(ds, check.HasLen, 2)
+
+	s.run(c, "RIP003", OPERATIONS, UPGRADE)
+	c.Assert(m.created, check.Equals, 1)
+}
+
+func (s *FlowSuite) TestUpgradeABoxJustLaunched(c *check.C) {
+	s.run(c, "RIP001", STATE, CREATE)
+	s.run(c, "RIP002", OPERATIONS, UPGRADE)
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy", "replace", "restart", "status"})
+}
+
+func (s *FlowSuite) TestUpgradeFailsOnAnOperationWithoutRunner(c *check.C) {
+	s.operations(c, "CMPdb", Operations{Type: "bogus"})
+	s.run(c, "RIP001", STATE, CREATE)
+	s.settle(c, constants.StatusRunning)
+
+	err := s.runRequest(c, &Requests{Id: "RIP002", CatId: "AMS001", Category: OPERATIONS, Action: UPGRADE})
+	c.Assert(err, check.ErrorMatches, ".*no runner for operation bogus.*")
+	c.Assert(s.prov.Ops("CMPdb"), check.DeepEquals, []string{"deploy"})
+	db, err := NewComponent("CMPdb")
+	c.Assert(err, check.IsNil)
+	c.Assert(db.Operations[0].Status, check.Equals, OPS_FAILED)
+}
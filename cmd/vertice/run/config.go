@@ -10,17 +10,19 @@ import (
 	"github.com/megamsys/vertice/subd/eventsd"
 	"github.com/megamsys/vertice/subd/httpd"
 	"github.com/megamsys/vertice/subd/metricsd"
+	"github.com/megamsys/vertice/subd/scheduled"
 )
 
 type Config struct {
-	Meta    *meta.Config     `toml:"meta"`
-	Deployd *deployd.Config  `toml:"deployd"`
-	HTTPD   *httpd.Config    `toml:"http"`
-	Docker  *docker.Config   `toml:"docker"`
-	Bridges *docker.Bridges  `toml:"bridges"`
-	Metrics *metricsd.Config `toml:"metrics"`
-	DNS     *dns.Config      `toml:"dns"`
-	Events  *eventsd.Config  `toml:"events"`
+	Meta      *meta.Config      `toml:"meta"`
+	Deployd   *deployd.Config   `toml:"deployd"`
+	HTTPD     *httpd.Config     `toml:"http"`
+	Docker    *docker.Config    `toml:"docker"`
+	Bridges   *docker.Bridges   `toml:"bridges"`
+	Metrics   *metricsd.Config  `toml:"metrics"`
+	DNS       *dns.Config       `toml:"dns"`
+	Events    *eventsd.Config   `toml:"events"`
+	Schedules *scheduled.Config `toml:"schedules"`
 }
 
 func (c Config) String() string {
@@ -32,7 +34,8 @@ func (c Config) String() string {
 		c.Bridges.String() + "\n" +
 		c.Metrics.String() + "\n" +
 		c.DNS.String() + "\n" +
-		c.Events.String())
+		c.Events.String() + "\n" +
+		c.Schedules.String())
 
 }
 
@@ -47,6 +50,7 @@ func NewConfig() *Config {
 	c.Metrics = metricsd.NewConfig()
 	c.Events = eventsd.NewConfig()
 	c.DNS = dns.NewConfig()
+	c.Schedules = scheduled.NewConfig()
 	return c
 }
 
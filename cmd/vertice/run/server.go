@@ -15,6 +15,7 @@ import (
 	"github.com/megamsys/vertice/subd/eventsd"
 	"github.com/megamsys/vertice/subd/httpd"
 	"github.com/megamsys/vertice/subd/metricsd"
+	"github.com/megamsys/vertice/subd/scheduled"
 )
 
 // Server represents a container for the metadata and storage data and services.
@@ -45,6 +46,7 @@ func NewServer(c *Config, version string) (*Server, error) {
 	s.appendDockerService(c.Meta, c.Docker, c.Bridges)
 	s.appendMetricsdService(c.Meta, c.Deployd, c.Metrics)
 	s.appendEventsdService(c.Meta, c.Events)
+	s.appendScheduledService(c.Meta, c.Schedules)
 	s.selfieDNS(c.DNS)
 	return s, nil
 }
@@ -91,6 +93,15 @@ func (s *Server) appendEventsdService(c *meta.Config, e *eventsd.Config) {
 	s.Services = append(s.Services, srv)
 }
 
+func (s *Server) appendScheduledService(c *meta.Config, f *scheduled.Config) {
+	if !f.Enabled {
+		log.Warn("skip scheduled service.")
+		return
+	}
+	srv := scheduled.NewService(c, f)
+	s.Services = append(s.Services, srv)
+}
+
 //we are just making the DNS config global
 func (s *Server) selfieDNS(c *dns.Config) {
 	c.MkGlobal()
@@ -23,17 +23,7 @@ const (
 func SendMetricsToScylla(address []string, metrics Sensors, hostname string) (err error) {
 	started := time.Now()
 	for _, m := range metrics {
-		ops := ldb.Options{
-			TableName:   SENSORSBUCKET,
-			Pks:         []string{"sensor_type"},
-			Ccms:        []string{"account_id", "assembly_id"},
-			Hosts:       meta.MC.Scylla,
-			Keyspace:    meta.MC.ScyllaKeyspace,
-			PksClauses:  map[string]interface{}{"sensor_type": m.SensorType},
-			CcmsClauses: map[string]interface{}{"account_id": m.AccountId, "assembly_id": m.AssemblyId},
-		}
-		s := m.ParseScyllaformat()
-		if err = ldb.Storedb(ops, s); err != nil {
+		if err = StoreSensor(m); err != nil {
 			log.Debugf(err.Error())
 			continue
 		}
@@ -44,6 +34,20 @@ func SendMetricsToScylla(address []string, metrics Sensors, hostname string) (er
 	return nil
 }
 
+// StoreSensor stores the sensor in scylla, without billing it.
+func StoreSensor(m *Sensor) error {
+	ops := ldb.Options{
+		TableName:   SENSORSBUCKET,
+		Pks:         []string{"sensor_type"},
+		Ccms:        []string{"account_id", "assembly_id"},
+		Hosts:       meta.MC.Scylla,
+		Keyspace:    meta.MC.ScyllaKeyspace,
+		PksClauses:  map[string]interface{}{"sensor_type": m.SensorType},
+		CcmsClauses: map[string]interface{}{"account_id": m.AccountId, "assembly_id": m.AssemblyId},
+	}
+	return ldb.Storedb(ops, m.ParseScyllaformat())
+}
+
 func mkBalance(s *Sensor) error {
 	mi := make(map[string]string)
 	m := s.Metrics.Totalcost()
@@ -17,12 +17,19 @@ package metrix
 
 import (
 	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/megamsys/vertice/api"
+	"github.com/megamsys/vertice/provision"
 )
 
-const SENSORSBUCKET = "sensors"
+const (
+	SENSORSBUCKET = "sensors"
+
+	//the sensor of a box whose compute was resized, billed from then on.
+	RESIZED = "compute.instance.resized"
+)
 
 type Sensor struct {
 	Id                   string  `json:"id" cql:"id"`
@@ -77,6 +84,31 @@ func NewSensor(senstype string) *Sensor {
 	return s
 }
 
+// NewResizeSensor returns the sensor of the box resized on the system,
+// which carries the compute it was resized to.
+func NewResizeSensor(b *provision.Box, system string) *Sensor {
+	s := NewSensor(RESIZED)
+	s.AccountId = b.AccountsId
+	s.AssemblyId = b.CartonId
+	s.AssemblyName = b.CartonName
+	s.AssembliesId = b.CartonsId
+	s.System = system
+	s.Source = system
+	s.Status = b.Status.String()
+	s.Message = "vm resized"
+	s.AuditPeriodBeginning = time.Now().Local().String()
+	s.addMetric("cpu", strconv.FormatUint(b.GetCpushare(), 10), "cores", "gauge")
+	s.addMetric("memory", strconv.FormatUint(b.GetMemory(), 10), "MB", "gauge")
+	s.addMetric("hdd", strconv.FormatUint(b.GetHDD(), 10), "MB", "gauge")
+	return s
+}
+
+// RecordResize stores the sensor of the box resized on the system, the
+// billing picks up the new compute from it.
+func RecordResize(b *provision.Box, system string) error {
+	return StoreSensor(NewResizeSensor(b, system))
+}
+
 func (s *Sensor) addMetric(key string, value string, unit string, mtype string) {
 	s.newMetric(
 		&Metric{
@@ -0,0 +1,24 @@
+package metrix
+
+import (
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNewResizeSensor(c *check.C) {
+	b := &provision.Box{
+		AccountsId: "ACT001",
+		CartonId:   "ASM001",
+		CartonsId:  "AMS001",
+		CartonName: "myapp",
+		Compute:    provision.BoxCompute{Cpushare: "2", Memory: "2G", HDD: "20G SSD"},
+	}
+	m := NewResizeSensor(b, OPENNEBULA)
+	c.Assert(m.SensorType, check.Equals, RESIZED)
+	c.Assert(m.AssemblyId, check.Equals, "ASM001")
+	c.Assert(m.System, check.Equals, OPENNEBULA)
+	c.Assert(m.Metrics, check.HasLen, 3)
+	c.Assert(m.Metrics[0].MetricValue, check.Equals, "2")
+	c.Assert(m.Metrics[1].MetricValue, check.Equals, "2048")
+	c.Assert(m.Metrics[2].MetricValue, check.Equals, "20480")
+}
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,8 +30,8 @@ import (
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton/bind"
 	"github.com/megamsys/vertice/repository"
+	"golang.org/x/net/context"
 	"gopkg.in/yaml.v2"
-
 )
 
 const (
@@ -59,8 +60,9 @@ type Boxlog struct {
 }
 
 type BoxSSH struct {
-	User   string
-	Prefix string
+	User      string
+	Prefix    string
+	PublicKey string //the public key of the key named by the prefix, placed in the box
 }
 
 func (bs *BoxSSH) Pub() string {
@@ -117,6 +119,9 @@ type Box struct {
 	CartonId     string
 	CartonName   string
 	Name         string
+	Unit         int      //the unit index of the box when a component is scaled
+	AntiAffinity string   //the boxes of the same group aren't placed on a node together
+	Related      []string //the components (by id or name) whose connection details the box gets
 	Level        BoxLevel
 	DomainName   string
 	Tosca        string
@@ -126,10 +131,13 @@ type Box struct {
 	Status       utils.Status
 	Provider     string
 	PublicIp     string
+	Region       string            //the zone the box is placed in, any when blank
+	Labels       map[string]string //the labels of the nodes the box may be placed on
 	SSH          BoxSSH
 	Commit       string
 	Envs         []bind.EnvVar
 	Address      *url.URL
+	Ctx          context.Context `json:"-" yaml:"-"` //the context of the operation the box is in, see Context.
 }
 
 func (b *Box) String() string {
@@ -160,11 +168,35 @@ func (b *Box) GetHDD() uint64 {
 }
 
 // GetName returns the assemblyname.domain(assembly001YeahBoy.megambox.com) of the box.
+// The units after the first are indexed (assembly001YeahBoy-1.megambox.com)
 func (b *Box) GetFullName() string {
+	name := b.CartonName
+	if b.Unit > 0 {
+		name = name + "-" + strconv.Itoa(b.Unit)
+	}
 	if len(strings.TrimSpace(b.DomainName)) > 0 {
-		return strings.Join([]string{b.CartonName, b.DomainName}, ".")
+		return strings.Join([]string{name, b.DomainName}, ".")
+	}
+	return name
+}
+
+// UnitId returns the id of the unit of the box, the first unit is known by
+// the id of the box and the others are indexed (CMP001, CMP001-1). The deploys
+// of a unit are kept under it.
+func (b *Box) UnitId() string {
+	if b.Unit > 0 {
+		return b.Id + "-" + strconv.Itoa(b.Unit)
+	}
+	return b.Id
+}
+
+// UnitKey returns the key of an output kept for a unit, the first unit uses
+// the key as is and the others are indexed (vmid, vmid-1).
+func UnitKey(key string, unit int) string {
+	if unit > 0 {
+		return key + "-" + strconv.Itoa(unit)
 	}
-	return b.CartonName
+	return key
 }
 
 // GetTosca returns the tosca type of the box.
@@ -177,13 +209,12 @@ func (b *Box) GetPublicIp() string {
 	return b.PublicIp
 }
 
-// Available returns true if the unit is available. It will return true
-// whenever the unit itself is available, even when the application process is
-// not.
+// Available returns true if the unit is available, ie it can be started
+// or stopped. It will return true whenever the unit itself is available,
+// even when the application process is not.
 func (b *Box) Available() bool {
-	return b.Status == constants.StatusLaunching ||
-		b.Status == constants.StatusLaunched ||
-		b.Status == constants.StatusError
+	return CanTransition(b.Status, constants.StatusStarting) &&
+		CanTransition(b.Status, constants.StatusStopping)
 }
 
 func (box *Box) GetRouter() (string, error) {
@@ -0,0 +1,20 @@
+package provision
+
+import (
+	"gopkg.in/check.v1"
+)
+
+type BoxSuite struct{}
+
+var _ = check.Suite(&BoxSuite{})
+
+func (s *BoxSuite) TestUnitsHaveTheirOwnIdAndKeys(c *check.C) {
+	b := &Box{Id: "CMP001", CartonName: "myapp", DomainName: "megambox.com"}
+	c.Assert(b.UnitId(), check.Equals, "CMP001")
+	c.Assert(b.GetFullName(), check.Equals, "myapp.megambox.com")
+	c.Assert(UnitKey("vmid", b.Unit), check.Equals, "vmid")
+	b.Unit = 2
+	c.Assert(b.UnitId(), check.Equals, "CMP001-2")
+	c.Assert(b.GetFullName(), check.Equals, "myapp-2.megambox.com")
+	c.Assert(UnitKey("vmid", b.Unit), check.Equals, "vmid-2")
+}
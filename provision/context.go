@@ -0,0 +1,77 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package provision
+
+import (
+	"github.com/megamsys/libgo/action"
+	"golang.org/x/net/context"
+)
+
+// Context returns the context of the operation the box is in, it is done
+// once the operation is past its deadline or cancelled. A box outside of
+// any operation is never done.
+func (b *Box) Context() context.Context {
+	if b == nil || b.Ctx == nil {
+		return context.Background()
+	}
+	return b.Ctx
+}
+
+// Cancellable returns the actions of a pipeline, each one fails upfront with
+// the error of the context of the box once it is done. The pipeline stops
+// there and runs the backward of the actions which went through.
+func Cancellable(box func(ctx action.FWContext) *Box, actions ...*action.Action) []*action.Action {
+	wrapped := make([]*action.Action, 0, len(actions))
+	for _, a := range actions {
+		forward := a.Forward
+		wrapped = append(wrapped, &action.Action{
+			Name: a.Name,
+			Forward: func(ctx action.FWContext) (action.Result, error) {
+				if err := box(ctx).Context().Err(); err != nil {
+					return nil, err
+				}
+				return forward(ctx)
+			},
+			Backward:  a.Backward,
+			OnError:   a.OnError,
+			MinParams: a.MinParams,
+		})
+	}
+	return wrapped
+}
+
+// Interruptible runs fn, a call which can't be interrupted, till it returns or
+// else ctx is done. fn goes on in the background then, and undo is run once
+// it went through, eg: to remove a vm created too late.
+func Interruptible(ctx context.Context, fn func() error, undo func()) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if undo != nil {
+			go func() {
+				if err := <-done; err == nil {
+					undo()
+				}
+			}()
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package provision
+
+import (
+	"github.com/megamsys/libgo/action"
+	"golang.org/x/net/context"
+	"gopkg.in/check.v1"
+)
+
+type ContextSuite struct{}
+
+var _ = check.Suite(&ContextSuite{})
+
+func (s *ContextSuite) TestContextOutsideOfAnOperation(c *check.C) {
+	var b *Box
+	c.Assert(b.Context().Err(), check.IsNil)
+	c.Assert((&Box{}).Context().Err(), check.IsNil)
+}
+
+func (s *ContextSuite) TestCancellableRollsBack(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var ran []string
+	first := action.Action{
+		Name: "first",
+		Forward: func(ctx action.FWContext) (action.Result, error) {
+			ran = append(ran, "first")
+			cancel()
+			return nil, nil
+		},
+		Backward: func(ctx action.BWContext) {
+			ran = append(ran, "undo first")
+		},
+	}
+	second := action.Action{
+		Name: "second",
+		Forward: func(ctx action.FWContext) (action.Result, error) {
+			ran = append(ran, "second")
+			return nil, nil
+		},
+	}
+	actions := Cancellable(func(ctx action.FWContext) *Box {
+		return ctx.Params[0].(*Box)
+	}, &first, &second)
+	c.Assert(actions[1].Name, check.Equals, "second")
+	err := action.NewPipeline(actions...).Execute(&Box{Ctx: ctx})
+	c.Assert(err, check.ErrorMatches, ".*"+context.Canceled.Error())
+	c.Assert(ran, check.DeepEquals, []string{"first", "undo first"})
+}
+
+func (s *ContextSuite) TestInterruptibleUndoesWhatWentThroughLate(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan bool)
+	undone := make(chan bool, 1)
+	cancel()
+	err := Interruptible(ctx, func() error {
+		<-release
+		return nil
+	}, func() { undone <- true })
+	c.Assert(err, check.Equals, context.Canceled)
+	close(release)
+	c.Assert(<-undone, check.Equals, true)
+	c.Assert(Interruptible(context.Background(), func() error { return nil }, nil), check.IsNil)
+}
@@ -27,6 +27,7 @@ type runContainerActionsArgs struct {
 	isDeploy         bool
 	buildingImage    string
 	provisioner      *dockerProvisioner
+	name             string //the name of the container, the full name of the box when empty.
 }
 
 type containersToAdd struct {
@@ -115,6 +116,9 @@ var updateStatusInScylla = action.Action{
 			ncont, _ := args.provisioner.GetContainerByBox(args.box)
 			cont = *ncont
 			cont.Image = args.imageId
+			if args.name != "" {
+				cont.BoxName = args.name
+			}
 		}
 
 		if err := cont.SetStatus(args.containerStatus); err != nil {
@@ -128,7 +132,6 @@ var updateStatusInScylla = action.Action{
 	},
 }
 
-
 var createContainer = action.Action{
 	Name: "create-container",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
@@ -321,7 +324,7 @@ var addNewRoute = action.Action{
 			}
 			c.Routable = true
 			toRollback <- c
-			
+
 			fmt.Fprintf(writer, lb.W(lb.CONTAINER_DEPLOY, lb.INFO, fmt.Sprintf("---> Added route to container (%s,%s)", c.BoxName, c.ShortId())))
 			return nil
 		}, func(c *container.Container) {
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
-		"github.com/megamsys/vertice/carton"
-		//	"github.com/megamsys/vertice/provision"
 
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision"
 )
 
 const (
@@ -26,6 +26,7 @@ type DockerClient struct {
 	IpAddr        string
 	Gateway       string
 	CartonId      string
+	Unit          int `json:"-"` //the unit of the box, its outputs are indexed by it
 	//HostAddr       string
 }
 
@@ -42,7 +43,7 @@ func (d *DockerClient) NetworkRequest(url string, port string) error {
 	var ips = make(map[string][]string)
 	hostip := []string{}
 	hostip = []string{url}
-	ips[carton.HOSTIP] = hostip
+	ips[provision.UnitKey(carton.HOSTIP, d.Unit)] = hostip
 	if asm, err := carton.NewAmbly(d.CartonId); err != nil {
 		return err
 	} else if err = asm.NukeAndSetOutputs(ips); err != nil {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
+	"github.com/megamsys/vertice/provision/nodes"
 )
 
 var (
@@ -76,9 +77,13 @@ type Storage interface {
 // which creates a container in one node of the cluster.
 type Cluster struct {
 	Healer         Healer
+	Clock          nodes.Clock
+	Notify         func(nodes.Event) //told when a probe finds a node went down or came back up
 	stor           Storage
 	bridges        Bridges
 	gulp           Gulp
+	probe          func(Node) error
+	history        *nodes.History
 	monitoringDone chan bool
 }
 
@@ -130,10 +135,13 @@ func New(storage Storage, gulp Gulp, bridges []Bridge, nodes ...Node) (*Cluster,
 	c.bridges = bridges
 	c.gulp = gulp
 	c.Healer = DefaultHealer{}
+	c.Clock = nodes.RealClock{}
+	c.probe = c.ping
+	c.history = nodes.NewHistory()
 
 	if len(nodes) > 0 {
 		for _, n := range nodes {
-			err = c.Register(n)
+			err = c.registerOrMigrate(n)
 			if err != nil {
 				return &c, err
 			}
@@ -142,6 +150,27 @@ func New(storage Storage, gulp Gulp, bridges []Bridge, nodes ...Node) (*Cluster,
 	return &c, err
 }
 
+//the metadata a node gathers as it is used, kept when a node stored before
+//its config keys were told is registered again.
+var healthMetadata = []string{"Failures", "LastSuccess", "LastError", "DisabledUntil"}
+
+//registers the node, or when the storage has it already (eg: after a restart)
+//migrates its metadata to the given ones keeping what it gathered since.
+func (c *Cluster) registerOrMigrate(n Node) error {
+	given := n.Metadata
+	n.Metadata = nodes.MigrateMetadata(nil, given)
+	err := c.Register(n)
+	if err != ErrDuplicatedNodeAddress {
+		return err
+	}
+	dbNode, err := c.storage().RetrieveNode(n.Address)
+	if err != nil {
+		return err
+	}
+	dbNode.Metadata = nodes.MigrateMetadata(dbNode.Metadata, given, healthMetadata...)
+	return c.storage().UpdateNode(dbNode)
+}
+
 // Register adds new nodes to the cluster.
 func (c *Cluster) Register(node Node) error {
 	if node.Address == "" {
@@ -178,7 +207,15 @@ func (c *Cluster) UpdateNode(node Node) (Node, error) {
 
 // Unregister removes nodes from the cluster.
 func (c *Cluster) Unregister(address string) error {
-	return c.storage().RemoveNode(address)
+	if err := c.storage().RemoveNode(address); err != nil {
+		return err
+	}
+	c.history.Forget(address)
+	return nil
+}
+
+func (c *Cluster) GetNode(address string) (Node, error) {
+	return c.storage().RetrieveNode(address)
 }
 
 func (c *Cluster) UnfilteredNodes() ([]Node, error) {
@@ -234,20 +271,26 @@ func (c *Cluster) handleNodeError(addr string, lastErr error, incrementFailures
 	}
 	go func() {
 		defer unlock()
-		node, err := c.storage().RetrieveNode(addr)
-		if err != nil {
-			return
-		}
-		node.updateError(lastErr, incrementFailures)
-		duration := c.Healer.HandleError(&node)
-		if duration > 0 {
-			node.updateDisabled(time.Now().Add(duration))
-		}
-		c.storage().UpdateNode(node)
+		c.updateNodeError(addr, lastErr, incrementFailures)
 	}()
 	return nil
 }
 
+//records the error of the node and disables it for as long as the healer
+//says, the node is to be locked.
+func (c *Cluster) updateNodeError(addr string, lastErr error, incrementFailures bool) error {
+	node, err := c.storage().RetrieveNode(addr)
+	if err != nil {
+		return err
+	}
+	node.updateError(lastErr, incrementFailures)
+	duration := c.Healer.HandleError(&node)
+	if duration > 0 {
+		node.updateDisabled(c.now().Add(duration))
+	}
+	return c.storage().UpdateNode(node)
+}
+
 func (c *Cluster) handleNodeSuccess(addr string) error {
 	unlock, err := c.lockWithTimeout(addr, false)
 	if err != nil {
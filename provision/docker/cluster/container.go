@@ -11,6 +11,8 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
+	"github.com/megamsys/vertice/provision"
+	"golang.org/x/net/context"
 )
 
 type Container struct {
@@ -23,12 +25,12 @@ type Container struct {
 //
 // It returns the container, or an error, in case of failures.
 func (c *Cluster) CreateContainer(opts docker.CreateContainerOptions) (string, *docker.Container, error) {
-	return c.CreateContainerSchedulerOpts(opts)
+	return c.CreateContainerSchedulerOpts(context.Background(), opts)
 }
 
-// Similar to CreateContainer but allows arbritary options to be passed to
-// the scheduler.
-func (c *Cluster) CreateContainerSchedulerOpts(opts docker.CreateContainerOptions) (string, *docker.Container, error) {
+// Similar to CreateContainer but gives up once ctx is done, a container
+// created after that is removed.
+func (c *Cluster) CreateContainerSchedulerOpts(ctx context.Context, opts docker.CreateContainerOptions) (string, *docker.Container, error) {
 	var (
 		addr      string
 		container *docker.Container
@@ -42,7 +44,21 @@ func (c *Cluster) CreateContainerSchedulerOpts(opts docker.CreateContainerOption
 		if addr == "" {
 			return addr, nil, errors.New("CreateContainer needs a non empty node addr")
 		}
-		container, err = c.createContainerInNode(opts, addr)
+		in := addr
+		var created *docker.Container
+		err = provision.Interruptible(ctx, func() (err error) {
+			created, err = c.createContainerInNode(opts, in)
+			return err
+		}, func() {
+			log.Warningf("  removing container %s created after its deadline", opts.Name)
+			if err := c.removeCreated(created.ID, in); err != nil {
+				log.Errorf("  unable to remove container %s: %s", opts.Name, err)
+			}
+		})
+		if err == ctx.Err() && err != nil {
+			return addr, nil, err
+		}
+		container = created
 		if err == nil {
 			c.handleNodeSuccess(addr)
 			break
@@ -66,7 +82,7 @@ func (c *Cluster) CreateContainerSchedulerOpts(opts docker.CreateContainerOption
 	if err != nil {
 		return addr, nil, fmt.Errorf("CreateContainer: maximum number of tries exceeded, last error: %s", err.Error())
 	}
-		err = c.storage().StoreContainer(container.ID, addr)
+	err = c.storage().StoreContainer(container.ID, addr)
 	err = c.storage().StoreContainerByName(container.ID, container.Name)
 	return addr, container, err
 }
@@ -89,6 +105,15 @@ func (c *Cluster) createContainerInNode(opts docker.CreateContainerOptions, node
 	return cont, wrapErrorWithCmd(node, err, "createContainer")
 }
 
+//removes a container created in the node which isn't in the storage yet.
+func (c *Cluster) removeCreated(id, nodeAddress string) error {
+	node, err := c.getNodeByAddr(nodeAddress)
+	if err != nil {
+		return err
+	}
+	return wrapError(node, node.RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true}))
+}
+
 func (c *Cluster) GetIP() (net.IP, string, string, error) {
 	var ip net.IP
 	var gateway string
@@ -182,13 +207,15 @@ func (c *Cluster) removeFromStorage(opts docker.RemoveContainerOptions) error {
 	return c.storage().RemoveContainer(opts.ID)
 }
 
-func (c *Cluster) StartContainer(id string, hostConfig *docker.HostConfig) error {
-
+// StartContainer starts a container, it gives up once ctx is done.
+func (c *Cluster) StartContainer(ctx context.Context, id string, hostConfig *docker.HostConfig) error {
 	node, err := c.getNodeForContainer(id)
 	if err != nil {
 		return err
 	}
-	return wrapError(node, node.StartContainer(id, hostConfig))
+	return provision.Interruptible(ctx, func() error {
+		return wrapError(node, node.StartContainer(id, hostConfig))
+	}, nil)
 }
 
 func (c *Cluster) PreStopAction(name string) (string, error) {
@@ -209,6 +236,30 @@ func (c *Cluster) StopContainer(id string, timeout uint) error {
 	return wrapError(node, node.StopContainer(id, timeout))
 }
 
+// UpdateContainer changes the resource limits of a running container, it
+// gives up once ctx is done.
+func (c *Cluster) UpdateContainer(ctx context.Context, id string, opts docker.UpdateContainerOptions) error {
+	node, err := c.getNodeForContainer(id)
+	if err != nil {
+		return err
+	}
+	return provision.Interruptible(ctx, func() error {
+		return wrapError(node, node.UpdateContainer(id, opts))
+	}, nil)
+}
+
+// RenameContainer renames a container, the storage finds it by its new name.
+func (c *Cluster) RenameContainer(id, name string) error {
+	node, err := c.getNodeForContainer(id)
+	if err != nil {
+		return err
+	}
+	if err := node.RenameContainer(docker.RenameContainerOptions{ID: id, Name: name}); err != nil {
+		return wrapError(node, err)
+	}
+	return c.storage().StoreContainerByName(id, name)
+}
+
 // RestartContainer restarts a container, killing it after the given timeout,
 // if it fails to stop nicely.
 func (c *Cluster) RestartContainer(id string, timeout uint) error {
@@ -266,13 +317,21 @@ func (c *Cluster) Logs(opts docker.LogsOptions) error {
 	return wrapError(node, node.Logs(opts))
 }
 
-// CommitContainer commits a container and returns the image id.
-func (c *Cluster) CommitContainer(opts docker.CommitContainerOptions) (*docker.Image, error) {
+// CommitContainer commits a container and returns the image id, it gives up
+// once ctx is done.
+func (c *Cluster) CommitContainer(ctx context.Context, opts docker.CommitContainerOptions) (*docker.Image, error) {
 	node, err := c.getNodeForContainer(opts.Container)
 	if err != nil {
 		return nil, err
 	}
-	image, err := node.CommitContainer(opts)
+	var image *docker.Image
+	err = provision.Interruptible(ctx, func() (err error) {
+		image, err = node.CommitContainer(opts)
+		return err
+	}, nil)
+	if err == ctx.Err() && err != nil {
+		return nil, err
+	}
 	if err != nil {
 		return nil, wrapError(node, err)
 	}
@@ -313,9 +372,9 @@ func (c *Cluster) getNodeForContainer(container string) (node, error) {
 	})
 }
 
-func (c *Cluster) SetNetworkinNode(containerId string, ip string, gateway string, bridge string, cartonId string) error {
+func (c *Cluster) SetNetworkinNode(containerId string, ip string, gateway string, bridge string, cartonId string, unit int) error {
 	container := c.getContainerObject(containerId)
-	client := DockerClient{Bridge: bridge, ContainerId: containerId, IpAddr: ip, Gateway: gateway, CartonId: cartonId }
+	client := DockerClient{Bridge: bridge, ContainerId: containerId, IpAddr: ip, Gateway: gateway, CartonId: cartonId, Unit: unit}
 	err := client.NetworkRequest(container.Node.IP, c.gulp.Port)
 	if err != nil {
 		return err
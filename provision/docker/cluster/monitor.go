@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/provision/nodes"
+)
+
+// HealthHistory returns the latest probes of a node, the oldest first.
+func (c *Cluster) HealthHistory(address string) []provision.NodeHealth {
+	return c.history.Get(address)
+}
+
+func (c *Cluster) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+// StartActiveMonitoring probes the nodes every updateInterval, a node which
+// fails is disabled as the healer says and one which comes back is enabled.
+func (c *Cluster) StartActiveMonitoring(updateInterval time.Duration) {
+	c.StopActiveMonitoring()
+	c.monitoringDone = make(chan bool)
+	go nodes.Monitor(c.Clock, updateInterval, c.probeNodes, c.monitoringDone)
+}
+
+func (c *Cluster) StopActiveMonitoring() {
+	if c.monitoringDone != nil {
+		close(c.monitoringDone)
+		c.monitoringDone = nil
+	}
+}
+
+//probes the nodes which are enabled, a disabled one is probed once it is
+//past its backoff.
+func (c *Cluster) probeNodes() {
+	all, err := c.UnfilteredNodes()
+	if err != nil {
+		log.Errorf("  unable to list the nodes to probe: %s", err)
+		return
+	}
+	now := c.now()
+	for _, n := range all {
+		if n.isEnabledAt(now) {
+			c.probeNode(n)
+		}
+	}
+}
+
+func (c *Cluster) probeNode(n Node) {
+	err := c.probe(n)
+	if err != nil {
+		if unlock, lerr := c.lockWithTimeout(n.Address, true); lerr == nil {
+			c.updateNodeError(n.Address, err, true)
+			unlock()
+		}
+	} else {
+		c.handleNodeSuccess(n.Address)
+	}
+	if e, changed := c.history.Add(n.Address, c.now(), err); changed {
+		c.notify(e)
+	}
+}
+
+func (c *Cluster) notify(e nodes.Event) {
+	if e.Up {
+		log.Infof("  docker node %s is up", e.Address)
+	} else {
+		log.Warningf("  docker node %s is down: %s", e.Address, e.Err)
+	}
+	if c.Notify != nil {
+		c.Notify(e)
+	}
+}
+
+//the ping of the node.
+func (c *Cluster) ping(n Node) error {
+	client, err := n.Client()
+	if err != nil {
+		return err
+	}
+	return client.Ping()
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/megamsys/vertice/provision/nodes"
+	"github.com/megamsys/vertice/provision/nodes/nodestest"
+)
+
+func TestDefaultHealerBacksOff(t *testing.T) {
+	h := DefaultHealer{Base: time.Minute, Max: 5 * time.Minute}
+	want := []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for failures, w := range want {
+		n := Node{Metadata: map[string]string{}}
+		if failures > 0 {
+			n.Metadata["Failures"] = strconv.Itoa(failures)
+		}
+		if got := h.HandleError(&n); got != w {
+			t.Errorf("HandleError with %d failures: want %s, got %s", failures, w, got)
+		}
+	}
+}
+
+//pings the docker api of a server which is down till it is told to be up.
+func TestProbeNodesPingsWithBackoff(t *testing.T) {
+	var up, pings int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ping" {
+			t.Errorf("expected a ping, got %s", r.URL.Path)
+		}
+		atomic.AddInt32(&pings, 1)
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+	c, err := New(&MapStorage{}, Gulp{}, nil, Node{Address: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := nodestest.NewClock(time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC))
+	c.Clock = clock
+	var events []nodes.Event
+	c.Notify = func(e nodes.Event) { events = append(events, e) }
+
+	c.probeNodes()
+	n, _ := c.GetNode(server.URL)
+	if want := clock.Now().Add(time.Minute); n.isEnabledAt(want.Add(-time.Second)) || !n.isEnabledAt(want.Add(time.Second)) {
+		t.Errorf("expected the node disabled until %s, got %s", want, n.Metadata["DisabledUntil"])
+	}
+
+	clock.Advance(30 * time.Second)
+	c.probeNodes()
+	if got := atomic.LoadInt32(&pings); got != 1 {
+		t.Errorf("expected a disabled node not to be pinged, got %d pings", got)
+	}
+
+	clock.Advance(31 * time.Second)
+	c.probeNodes()
+	n, _ = c.GetNode(server.URL)
+	if want := clock.Now().Add(time.Minute); n.isEnabledAt(want.Add(time.Second)) {
+		t.Errorf("expected the node disabled for longer than %s after the 2nd failure, got %s", want, n.Metadata["DisabledUntil"])
+	}
+
+	clock.Advance(2*time.Minute + time.Second)
+	atomic.StoreInt32(&up, 1)
+	c.probeNodes()
+	n, _ = c.GetNode(server.URL)
+	if !n.isEnabledAt(clock.Now()) || n.FailureCount() != 0 {
+		t.Errorf("expected the node enabled once back, got %v", n.Metadata)
+	}
+
+	if got := atomic.LoadInt32(&pings); got != 3 {
+		t.Errorf("expected 3 pings, got %d", got)
+	}
+	ups := []bool{}
+	for _, e := range events {
+		ups = append(ups, e.Up)
+	}
+	if !reflect.DeepEqual(ups, []bool{false, true}) {
+		t.Errorf("expected a down and an up event, got %v", ups)
+	}
+	history := c.HealthHistory(server.URL)
+	if len(history) != 3 || history[0].Up || history[1].Up || !history[2].Up {
+		t.Errorf("unexpected health history %+v", history)
+	}
+	if err := c.Unregister(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if history := c.HealthHistory(server.URL); len(history) != 0 {
+		t.Errorf("expected the history of an unregistered node forgotten, got %+v", history)
+	}
+}
@@ -139,6 +139,10 @@ func (n *Node) CleanMetadata() map[string]string {
 }
 
 func (n *Node) isEnabled() bool {
+	return n.isEnabledAt(time.Now())
+}
+
+func (n *Node) isEnabledAt(now time.Time) bool {
 	if n.CreationStatus != "" && n.CreationStatus != NodeCreationStatusCreated {
 		return false
 	}
@@ -150,7 +154,7 @@ func (n *Node) isEnabled() bool {
 	}
 	disabledStr, _ := n.Metadata["DisabledUntil"]
 	t, _ := time.Parse(time.RFC3339, disabledStr)
-	return time.Now().After(t)
+	return now.After(t)
 }
 
 func (n *Node) isHealing() bool {
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/megamsys/vertice/provision/nodes"
+)
+
+const (
+	NODESBUCKET      = "docker_nodes"
+	REGISTEREDBUCKET = "docker_registered"
+)
+
+// ScyllaStorage keeps the nodes in scylla, so that they outlive a restart.
+// The containers and images are in memory as the MapStorage ones.
+type ScyllaStorage struct {
+	*MapStorage
+	*nodes.Registry
+}
+
+func NewScyllaStorage(hosts []string, keyspace string) *ScyllaStorage {
+	return &ScyllaStorage{MapStorage: &MapStorage{}, Registry: nodes.NewRegistry(hosts, keyspace, NODESBUCKET, REGISTEREDBUCKET)}
+}
+
+func toRow(n Node) *nodes.Row {
+	return nodes.NewRow(n.Address, n.Metadata, n.CreationStatus)
+}
+
+func (s *ScyllaStorage) toNode(r *nodes.Row) Node {
+	h := s.Healing(r.Address)
+	return Node{
+		Address:        r.Address,
+		Metadata:       r.MetadataMap(),
+		CreationStatus: r.CreationStatus,
+		Healing:        HealingData{LockedUntil: h.LockedUntil, IsFailure: h.IsFailure},
+	}
+}
+
+//the errors of the registry as the cluster tells them.
+func nodeError(err error) error {
+	switch err {
+	case nodes.ErrNotRegistered:
+		return ErrNoSuchNode
+	case nodes.ErrRegistered:
+		return ErrDuplicatedNodeAddress
+	}
+	return err
+}
+
+func (s *ScyllaStorage) StoreNode(node Node) error {
+	return nodeError(s.Store(toRow(node)))
+}
+
+func (s *ScyllaStorage) RetrieveNodes() ([]Node, error) {
+	rows, err := s.All()
+	if err != nil {
+		return nil, nodeError(err)
+	}
+	ns := make([]Node, 0, len(rows))
+	for _, r := range rows {
+		ns = append(ns, s.toNode(r))
+	}
+	return ns, nil
+}
+
+func (s *ScyllaStorage) RetrieveNode(address string) (Node, error) {
+	r, err := s.Get(address)
+	if err != nil {
+		return Node{}, nodeError(err)
+	}
+	return s.toNode(r), nil
+}
+
+func (s *ScyllaStorage) RetrieveNodesByMetadata(metadata map[string]string) ([]Node, error) {
+	all, err := s.RetrieveNodes()
+	if err != nil {
+		return nil, err
+	}
+	filteredNodes := []Node{}
+	for _, node := range all {
+		for key, value := range metadata {
+			nodeVal, ok := node.Metadata[key]
+			if ok && nodeVal == value {
+				filteredNodes = append(filteredNodes, node)
+			}
+		}
+	}
+	return filteredNodes, nil
+}
+
+func (s *ScyllaStorage) UpdateNode(node Node) error {
+	return nodeError(s.Update(toRow(node)))
+}
+
+func (s *ScyllaStorage) RemoveNode(address string) error {
+	return s.RemoveNodes([]string{address})
+}
+
+func (s *ScyllaStorage) RemoveNodes(addresses []string) error {
+	return nodeError(s.Remove(addresses))
+}
+
+func (s *ScyllaStorage) LockNodeForHealing(address string, isFailure bool, timeout time.Duration) (bool, error) {
+	ok, err := s.Lock(address, isFailure, timeout)
+	return ok, nodeError(err)
+}
+
+func (s *ScyllaStorage) ExtendNodeLock(address string, timeout time.Duration) error {
+	return nodeError(s.Extend(address, timeout))
+}
+
+func (s *ScyllaStorage) UnlockNode(address string) error {
+	return nodeError(s.Unlock(address))
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/megamsys/vertice/provision/nodes"
+	"github.com/megamsys/vertice/provision/nodes/nodestest"
+)
+
+func newScyllaStorage() (*ScyllaStorage, *nodestest.Rows) {
+	s := NewScyllaStorage(nil, "vertice")
+	rows := nodestest.NewRows()
+	s.Rows = rows
+	return s, rows
+}
+
+func TestScyllaStorageNodes(t *testing.T) {
+	s, _ := newScyllaStorage()
+	n := Node{
+		Address:        "http://192.168.1.241:2375",
+		Metadata:       map[string]string{"pool": "ssd", "Failures": "2"},
+		CreationStatus: NodeCreationStatusCreated,
+	}
+	if err := s.StoreNode(n); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreNode(Node{Address: "http://192.168.1.242:2375", Metadata: map[string]string{"pool": "hdd"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.RetrieveNode(n.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, n) {
+		t.Errorf("round trip: want %#v, got %#v", n, got)
+	}
+	ssd, err := s.RetrieveNodesByMetadata(map[string]string{"pool": "ssd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ssd) != 1 || ssd[0].Address != n.Address {
+		t.Errorf("nodes of the ssd pool: got %v", ssd)
+	}
+	if err := s.RemoveNode(n.Address); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RetrieveNode(n.Address); err != ErrNoSuchNode {
+		t.Errorf("removed node: want %v, got %v", ErrNoSuchNode, err)
+	}
+}
+
+func TestScyllaStorageKeepsTheNodesOnAFailedRead(t *testing.T) {
+	s, rows := newScyllaStorage()
+	if err := s.StoreNode(Node{Address: "http://192.168.1.241:2375"}); err != nil {
+		t.Fatal(err)
+	}
+	rows.FailReads(errors.New("timed out"))
+	if err := s.StoreNode(Node{Address: "http://192.168.1.242:2375"}); err == nil {
+		t.Error("store on a failed read: want an error")
+	}
+	if err := s.RemoveNode("http://192.168.1.241:2375"); err == nil || err == ErrNoSuchNode {
+		t.Errorf("remove on a failed read: want the read error, got %v", err)
+	}
+	rows.FailReads(nil)
+	all, err := s.RetrieveNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Address != "http://192.168.1.241:2375" {
+		t.Errorf("nodes after a failed read: got %v", all)
+	}
+}
+
+func TestNewMigratesStoredNodes(t *testing.T) {
+	s, _ := newScyllaStorage()
+	if _, err := New(s, Gulp{}, nil, Node{Address: "http://192.168.1.241:2375", Metadata: map[string]string{"pool": "ssd", "region": "plano"}}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.RetrieveNode("http://192.168.1.241:2375")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Metadata["Failures"] = "2"
+	n.Metadata["ip_pool"] = "103.56.93.0/24"
+	if err := s.UpdateNode(n); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(s, Gulp{}, nil, Node{Address: "http://192.168.1.241:2375", Metadata: map[string]string{"pool": "hdd"}}); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.RetrieveNode("http://192.168.1.241:2375")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"pool": "hdd", "Failures": "2", "ip_pool": "103.56.93.0/24", nodes.CONFIG_KEYS: "pool"}
+	if !reflect.DeepEqual(n.Metadata, want) {
+		t.Errorf("migrated metadata: want %v, got %v", want, n.Metadata)
+	}
+}
@@ -31,6 +31,7 @@ type DockerProvisioner interface {
 type Container struct {
 	Id                      string //container id.
 	BoxId                   string
+	Unit                    int //the unit of the component, it keeps its outputs indexed by it
 	CartonId                string
 	Name                    string
 	BoxName                 string
@@ -88,7 +89,7 @@ func (c *Container) Create(args *CreateArgs) error {
 
 	//c.addEnvsToConfig(args, &config)
 	opts := docker.CreateContainerOptions{Name: c.BoxName, Config: &config}
-	addr, cont, err := args.Provisioner.Cluster().CreateContainerSchedulerOpts(opts)
+	addr, cont, err := args.Provisioner.Cluster().CreateContainerSchedulerOpts(args.Box.Context(), opts)
 	if err != nil {
 		log.Errorf("Error on creating container in docker %s - %s", c.BoxName, err)
 		return err
@@ -167,7 +168,7 @@ func (c *Container) Start(args *StartArgs) error {
 		CPUShares:  int64(args.Box.GetCpushare()),
 	}
 
-	err = args.Provisioner.Cluster().StartContainer(c.Id, &hostConfig)
+	err = args.Provisioner.Cluster().StartContainer(args.Box.Context(), c.Id, &hostConfig)
 	if err != nil {
 		return err
 	}
@@ -178,6 +179,18 @@ func (c *Container) Start(args *StartArgs) error {
 	return c.SetStatus(initialStatus)
 }
 
+// Resize changes the memory and cpu limits of the container to the compute
+// of the box, its disk can't be resized. The update of a container is in
+// go-dockerclient as of the rev 9df1f25 in Godeps.
+func (c *Container) Resize(p DockerProvisioner, b *provision.Box) error {
+	opts := docker.UpdateContainerOptions{
+		Memory:     int(b.ConGetMemory()),
+		MemorySwap: int(b.ConGetMemory() + b.GetSwap()),
+		CPUShares:  int(b.GetCpushare()),
+	}
+	return p.Cluster().UpdateContainer(b.Context(), c.Id, opts)
+}
+
 func (c *Container) Stop(p DockerProvisioner) error {
 	if c.Status.String() == constants.StatusStopped.String() {
 		return nil
@@ -227,6 +240,13 @@ func SafeAttachWaitContainer(p DockerProvisioner, opts docker.AttachToContainerO
 
 func (c *Container) SetStatus(status utils.Status) error {
 	log.Debugf("  set status[%s] of container (%s, %s)", c.BoxId, c.Name, status.String())
+	if c.Unit > 0 { //the assembly and the component tell the first unit, the others have their own.
+		comp, err := carton.NewComponent(c.BoxId)
+		if err != nil {
+			return err
+		}
+		return comp.SetUnitStatus(c.Unit, status)
+	}
 	if asm, err := carton.NewAmbly(c.CartonId); err != nil {
 		return err
 	} else if err = asm.SetStatus(status); err != nil {
@@ -257,7 +277,7 @@ func (c *Container) NetworkInfo(p DockerProvisioner) (NetworkInfo, error) {
 		return netInfo, err
 	}
 	netInfo.IP = ip.String()
-	err = p.Cluster().SetNetworkinNode(c.Id, netInfo.IP, gateway, bridge, c.CartonId)
+	err = p.Cluster().SetNetworkinNode(c.Id, netInfo.IP, gateway, bridge, c.CartonId, c.Unit)
 	return netInfo, err
 }
 
@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"time"
+
+	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/provision/docker/cluster"
+)
+
+//the docker hosts, with and without health.
+func (p *dockerProvisioner) Nodes() ([]provision.Node, error) {
+	nodes, err := p.Cluster().UnfilteredNodes()
+	if err != nil {
+		return nil, err
+	}
+	pn := make([]provision.Node, len(nodes))
+	for i, n := range nodes {
+		pn[i] = provision.Node{Address: n.Address, Metadata: n.Metadata, Status: n.Status()}
+	}
+	return pn, nil
+}
+
+func (p *dockerProvisioner) AddNode(address string, metadata map[string]string) error {
+	err := p.Cluster().Register(cluster.Node{Address: address, Metadata: metadata})
+	if err == cluster.ErrDuplicatedNodeAddress {
+		return provision.ErrNodeExists
+	}
+	return err
+}
+
+func (p *dockerProvisioner) RemoveNode(address string) error {
+	err := p.Cluster().Unregister(address)
+	if err == cluster.ErrNoSuchNode {
+		return provision.ErrNodeNotFound
+	}
+	return err
+}
+
+//the latest probes of a node, the oldest first.
+func (p *dockerProvisioner) NodeHealth(address string) ([]provision.NodeHealth, error) {
+	if _, err := p.Cluster().GetNode(address); err != nil {
+		return nil, provision.ErrNodeNotFound
+	}
+	return p.Cluster().HealthHistory(address), nil
+}
+
+//probes the nodes every interval, none when zero.
+func (p *dockerProvisioner) MonitorNodes(interval time.Duration) {
+	if interval <= 0 {
+		p.Cluster().StopActiveMonitoring()
+		return
+	}
+	p.Cluster().StartActiveMonitoring(interval)
+}
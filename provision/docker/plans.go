@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"github.com/megamsys/libgo/action"
+	"github.com/megamsys/vertice/provision"
+)
+
+//the pipelines of the operations on a box, each one but the destroy stops
+//once the operation is cancelled. A destroy stopped half way would leave the
+//box stuck, so it runs to its end.
+var (
+	deployActions = cancellable(
+		&updateStatusInScylla,
+		&createContainer,
+		&startContainer,
+		&updateStatusInScylla,
+		&setNetworkInfo,
+		&followLogsAndCommit,
+	)
+
+	destroyActions = []*action.Action{
+		&destroyOldContainers,
+		&removeOldRoutes,
+	}
+
+	statusActions = cancellable(
+		&updateStatusInScylla,
+	)
+)
+
+// PlanActions returns the names of the actions run for the operation. The
+// containers are started and stopped one by one, without a pipeline.
+func (p *dockerProvisioner) PlanActions(op string, box *provision.Box) []string {
+	var actions []*action.Action
+	switch op {
+	case provision.OP_DEPLOY:
+		actions = deployActions
+	case provision.OP_DESTROY:
+		actions = destroyActions
+	case provision.OP_STATUS:
+		actions = statusActions
+	case provision.OP_START:
+		return []string{"start-containers"}
+	case provision.OP_STOP:
+		return []string{"stop-containers"}
+	case provision.OP_RESIZE:
+		return []string{"resize-containers"}
+	case provision.OP_SNAPSHOT:
+		return []string{"commit-containers"}
+	case provision.OP_RESTORE, provision.OP_REPLACE:
+		names := []string{"put-aside-containers"}
+		for _, a := range deployActions {
+			names = append(names, a.Name)
+		}
+		return append(names, "remove-aside-containers")
+	}
+	names := make([]string, 0, len(actions))
+	for _, a := range actions {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+//the actions of a pipeline on the box in its args.
+func cancellable(actions ...*action.Action) []*action.Action {
+	return provision.Cancellable(func(ctx action.FWContext) *provision.Box {
+		switch args := ctx.Params[0].(type) {
+		case runContainerActionsArgs:
+			return args.box
+		case changeUnitsPipelineArgs:
+			return args.box
+		}
+		return nil
+	}, actions...)
+}
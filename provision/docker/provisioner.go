@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/url"
+	"regexp"
 	"strings"
 	"text/tabwriter"
 
@@ -17,6 +18,8 @@ import (
 	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
 	lb "github.com/megamsys/vertice/logbox"
+	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/docker/cluster"
 	"github.com/megamsys/vertice/provision/docker/container"
@@ -92,8 +95,10 @@ func (p *dockerProvisioner) initDockerCluster(m map[string]string, b map[string]
 	return nil
 }
 
+//the nodes are kept in scylla, so that the ones added while running and
+//their health outlive a restart.
 func buildClusterStorage() (cluster.Storage, error) {
-	return &cluster.MapStorage{}, nil
+	return cluster.NewScyllaStorage(meta.MC.Scylla, meta.MC.ScyllaKeyspace), nil
 }
 
 func getRouterForBox(box *provision.Box) (router.Router, error) {
@@ -139,18 +144,15 @@ func (p *dockerProvisioner) ImageDeploy(box *provision.Box, imageId string, w io
 }
 
 func (p *dockerProvisioner) deployPipeline(box *provision.Box, imageId string, w io.Writer) (string, error) {
+	return p.deployContainer(box, "", imageId, w)
+}
 
-	fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.INFO, fmt.Sprintf("--- deploy box (%s, image:%s)", box.GetFullName(), imageId)))
-	actions := []*action.Action{
-		&updateStatusInScylla,
-		&createContainer,
-		&startContainer,
-		&updateStatusInScylla,
-		&setNetworkInfo,
-		&followLogsAndCommit,
-	}
+//deploys a container of the image for the box, named name or else after the
+//box.
+func (p *dockerProvisioner) deployContainer(box *provision.Box, name, imageId string, w io.Writer) (string, error) {
 
-	pipeline := action.NewPipeline(actions...)
+	fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.INFO, fmt.Sprintf("--- deploy box (%s, image:%s)", box.GetFullName(), imageId)))
+	pipeline := action.NewPipeline(deployActions...)
 
 	args := runContainerActionsArgs{
 		box:             box,
@@ -160,6 +162,7 @@ func (p *dockerProvisioner) deployPipeline(box *provision.Box, imageId string, w
 		buildingImage:   imageId,
 		containerStatus: constants.StatusLaunching,
 		provisioner:     p,
+		name:            name,
 	}
 	err := pipeline.Execute(args)
 	if err != nil {
@@ -186,10 +189,7 @@ func (p *dockerProvisioner) Destroy(box *provision.Box, w io.Writer) error {
 		provisioner: p,
 		boxDestroy:  true,
 	}
-	pipeline := action.NewPipeline(
-		&destroyOldContainers,
-		&removeOldRoutes,
-	)
+	pipeline := action.NewPipeline(destroyActions...)
 	err = pipeline.Execute(args)
 	if err != nil {
 		return err
@@ -234,6 +234,212 @@ func (p *dockerProvisioner) Stop(box *provision.Box, process string, w io.Writer
 	}, nil, true)
 }
 
+// Resize updates the resource limits of the containers of the box in place.
+func (p *dockerProvisioner) Resize(box *provision.Box, to provision.BoxCompute, w io.Writer) error {
+	containers, err := p.listContainersByBox(box)
+	if err != nil {
+		fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.ERROR, fmt.Sprintf("Failed to list box containers (%s) --> %s", box.GetFullName(), err)))
+		return err
+	}
+	b := *box
+	b.Compute = to
+	if err := runInContainers(containers, func(c *container.Container, _ chan *container.Container) error {
+		return c.Resize(p, &b)
+	}, nil, true); err != nil {
+		log.Errorf("Failed to resize %q: %s", box.GetFullName(), err)
+		return err
+	}
+	if err := metrix.RecordResize(&b, constants.PROVIDER_DOCKER); err != nil {
+		log.Errorf("Failed to record the resize of %q: %s", box.GetFullName(), err)
+	}
+	fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.INFO, fmt.Sprintf("---- resized box %s to %s ----", box.GetFullName(), to.String())))
+	return nil
+}
+
+//the characters of a snapshot name which aren't allowed in an image tag.
+var snapshotTag = regexp.MustCompile("[^A-Za-z0-9_.-]")
+
+//the images the containers of the box are committed to by a snapshot, the
+//first one is tagged after the snapshot and every next one with its index.
+func snapshotRefs(box *provision.Box, name string, containers int) []string {
+	repository := strings.ToLower(box.GetFullName())
+	tag := snapshotTag.ReplaceAllString(name, "-")
+	refs := make([]string, containers)
+	for i := range refs {
+		if i == 0 {
+			refs[i] = repository + ":" + tag
+		} else {
+			refs[i] = fmt.Sprintf("%s:%s-%d", repository, tag, i)
+		}
+	}
+	return refs
+}
+
+//the containers of the box, with the ids the storage knows them by.
+func (p *dockerProvisioner) boxContainers(box *provision.Box) ([]container.Container, error) {
+	containers, err := p.listContainersByBox(box)
+	if err != nil {
+		return nil, err
+	}
+	for i := range containers {
+		id, err := p.Cluster().PreStopAction(containers[i].BoxName)
+		if err != nil {
+			return nil, fmt.Errorf("no container %s of box %s: %s", containers[i].BoxName, box.GetFullName(), err)
+		}
+		containers[i].Id = id
+	}
+	return containers, nil
+}
+
+// Snapshot commits every container of the box to an image, the ref is the
+// images comma separated in the order of the containers.
+func (p *dockerProvisioner) Snapshot(box *provision.Box, name string, w io.Writer) (string, error) {
+	containers, err := p.boxContainers(box)
+	if err != nil {
+		fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.ERROR, fmt.Sprintf("Failed to list box containers (%s) --> %s", box.GetFullName(), err)))
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("no containers of box %s to snapshot", box.GetFullName())
+	}
+	refs := snapshotRefs(box, name, len(containers))
+	for i, c := range containers {
+		parts := strings.SplitN(refs[i], ":", 2)
+		opts := docker.CommitContainerOptions{Container: c.Id, Repository: parts[0], Tag: parts[1]}
+		if _, err := p.Cluster().CommitContainer(box.Context(), opts); err != nil {
+			log.Errorf("Failed to snapshot %q: %s", box.GetFullName(), err)
+			for _, ref := range refs[:i] {
+				p.Cluster().RemoveImage(ref)
+			}
+			return "", err
+		}
+	}
+	ref := strings.Join(refs, ",")
+	fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.INFO, fmt.Sprintf("---- snapshot box %s as %s ----", box.GetFullName(), ref)))
+	return ref, nil
+}
+
+//the name a container is put aside under while its replacement is deployed.
+func asideName(name string) string {
+	return name + "-restoring"
+}
+
+// Restore replaces the containers of the box by ones of the snapshot images.
+func (p *dockerProvisioner) Restore(box *provision.Box, ref string, w io.Writer) error {
+	containers, err := p.boxContainers(box)
+	if err != nil {
+		fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.ERROR, fmt.Sprintf("Failed to list box containers (%s) --> %s", box.GetFullName(), err)))
+		return err
+	}
+	refs := strings.Split(ref, ",")
+	if len(refs) != len(containers) {
+		return fmt.Errorf("snapshot %s has %d images, box %s has %d containers", ref, len(refs), box.GetFullName(), len(containers))
+	}
+	return p.swapContainers(box, containers, refs, w)
+}
+
+// Replace replaces the containers of the box by ones of the image, a box
+// without any gets one.
+func (p *dockerProvisioner) Replace(box *provision.Box, imageId string, w io.Writer) (string, error) {
+	if imageId == "" {
+		imageId = p.boxImage(box)
+	}
+	isValid, err := isValidBoxImage(box.GetFullName(), imageId)
+	if err != nil {
+		return "", err
+	}
+	if !isValid {
+		return "", fmt.Errorf("invalid image for box %s: %s", box.GetFullName(), imageId)
+	}
+	containers, err := p.boxContainers(box)
+	if err != nil {
+		fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.ERROR, fmt.Sprintf("Failed to list box containers (%s) --> %s", box.GetFullName(), err)))
+		return "", err
+	}
+	if len(containers) == 0 {
+		return p.deployPipeline(box, imageId, w)
+	}
+	images := make([]string, len(containers))
+	for i := range images {
+		images[i] = imageId
+	}
+	if err := p.swapContainers(box, containers, images, w); err != nil {
+		return "", err
+	}
+	return imageId, nil
+}
+
+//the image the box is deployed from, built from its git repo or else the
+//image its repo names.
+func (p *dockerProvisioner) boxImage(box *provision.Box) string {
+	if box.Repo == nil {
+		return ""
+	}
+	if box.Repo.Type == repository.GIT {
+		return p.getBuildImage(box.Repo, box.ImageVersion)
+	}
+	return box.Repo.URL
+}
+
+//deploys a container of images[i] for every container of the box in its
+//place. The containers are put aside (renamed) while the new ones are
+//deployed, and removed once every new one runs. When a deploy fails the new
+//ones are removed and the containers put back, the box is left as it was.
+func (p *dockerProvisioner) swapContainers(box *provision.Box, containers []container.Container, images []string, w io.Writer) error {
+	aside := 0
+	deployed := 0
+	putBack := func() {
+		for _, c := range containers[:deployed] {
+			if id, err := p.Cluster().PreStopAction(c.BoxName); err == nil {
+				p.removeContainer(id)
+			}
+		}
+		for _, c := range containers[:aside] {
+			if err := p.Cluster().RenameContainer(c.Id, c.BoxName); err != nil {
+				log.Errorf("Failed to put back container %s of %q: %s", c.BoxName, box.GetFullName(), err)
+			}
+		}
+	}
+	for _, c := range containers {
+		if err := p.Cluster().RenameContainer(c.Id, asideName(c.BoxName)); err != nil {
+			putBack()
+			return err
+		}
+		aside++
+	}
+	for i, c := range containers {
+		if _, err := p.deployContainer(box, c.BoxName, images[i], w); err != nil {
+			putBack()
+			return err
+		}
+		deployed++
+	}
+	for _, c := range containers {
+		if err := p.removeContainer(c.Id); err != nil {
+			log.Errorf("Failed to remove the old container %s of %q: %s", c.BoxName, box.GetFullName(), err)
+		}
+	}
+	return nil
+}
+
+//stops and removes a container, without a say on the status of its box.
+func (p *dockerProvisioner) removeContainer(id string) error {
+	if err := p.Cluster().StopContainer(id, 10); err != nil {
+		log.Errorf("error on stop container %s: %s", id, err)
+	}
+	return p.Cluster().RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true})
+}
+
+// DeleteSnapshot removes the images of the snapshot.
+func (p *dockerProvisioner) DeleteSnapshot(box *provision.Box, ref string, w io.Writer) error {
+	for _, r := range strings.Split(ref, ",") {
+		if err := p.Cluster().RemoveImage(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *dockerProvisioner) Restart(box *provision.Box, process string, w io.Writer) error {
 	return nil
 }
@@ -255,10 +461,7 @@ func (*dockerProvisioner) Addr(box *provision.Box) (string, error) {
 func (p *dockerProvisioner) SetBoxStatus(box *provision.Box, w io.Writer, status utils.Status) error {
 
 	fmt.Fprintf(w, lb.W(lb.CONTAINER_DEPLOY, lb.INFO, fmt.Sprintf("---- status %s box %s ----", box.GetFullName(), status.String())))
-	actions := []*action.Action{
-		&updateStatusInScylla,
-	}
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(statusActions...)
 
 	args := runContainerActionsArgs{
 		box:             box,
@@ -11,6 +11,12 @@ func (s *S) TestShouldBeRegistered(c *check.C) {
 	c.Assert(p, check.FitsTypeOf, &dockerProvisioner{})
 }
 
+func (s *S) TestSnapshotRefs(c *check.C) {
+	box := &provision.Box{CartonName: "Tom", DomainName: "megambox.com"}
+	c.Assert(snapshotRefs(box, "before upgrade", 1), check.DeepEquals, []string{"tom.megambox.com:before-upgrade"})
+	c.Assert(snapshotRefs(box, "v1", 2), check.DeepEquals, []string{"tom.megambox.com:v1", "tom.megambox.com:v1-1"})
+}
+
 /*func (s *S) TestProvisionerProvision(c *check.C) {
 	app := provisiontest.NewFakeApp("myapp", "python", 1)
 	err := s.p.Provision(app)
@@ -9,6 +9,7 @@ import (
 func (p *dockerProvisioner) GetContainerByBox(box *provision.Box) (*container.Container, error) {
 	return &container.Container{
 		BoxId:    box.Id,
+		Unit:     box.Unit,
 		CartonId: box.CartonId,
 		Name:     box.Name,
 		BoxName:  box.GetFullName(),
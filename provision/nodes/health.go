@@ -0,0 +1,114 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package nodes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/megamsys/vertice/provision"
+)
+
+//the probes kept for a node.
+const HEALTH_HISTORY = 20
+
+// Clock tells the time to a cluster, a fake one in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type RealClock struct{}
+
+func (RealClock) Now() time.Time                         { return time.Now() }
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Event tells that a probe found a node went down or came back up.
+type Event struct {
+	Address string
+	Up      bool
+	Err     error
+	Time    time.Time
+}
+
+// History keeps the latest probes of the nodes, the oldest first.
+type History struct {
+	mu     sync.Mutex
+	probes map[string][]provision.NodeHealth
+}
+
+func NewHistory() *History {
+	return &History{probes: make(map[string][]provision.NodeHealth)}
+}
+
+// Add records the probe of the node at now, err is what the probe failed with.
+// It tells the event when the node went down or came back up, the first
+// probe of a node tells only a down.
+func (h *History) Add(addr string, now time.Time, err error) (Event, bool) {
+	nh := provision.NodeHealth{Time: now, Up: err == nil}
+	if err != nil {
+		nh.Error = err.Error()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	probes := h.probes[addr]
+	seen := len(probes) > 0
+	changed := !seen && !nh.Up || seen && probes[len(probes)-1].Up != nh.Up
+	probes = append(probes, nh)
+	if len(probes) > HEALTH_HISTORY {
+		probes = probes[len(probes)-HEALTH_HISTORY:]
+	}
+	h.probes[addr] = probes
+	return Event{Address: addr, Up: nh.Up, Err: err, Time: now}, changed
+}
+
+func (h *History) Get(addr string) []provision.NodeHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]provision.NodeHealth{}, h.probes[addr]...)
+}
+
+func (h *History) Forget(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.probes, addr)
+}
+
+// Monitor runs probe every interval on the clock till done is closed.
+func Monitor(clock Clock, interval time.Duration, probe func(), done chan bool) {
+	for {
+		probe()
+		select {
+		case <-done:
+			return
+		case <-clock.After(interval):
+		}
+	}
+}
+
+// Backoff is how long a node which failed failures times in a row is
+// disabled: base for the first failure, twice as long for every next one up
+// to max.
+func Backoff(base, max time.Duration, failures int) time.Duration {
+	d := base
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,52 @@
+package nodes
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type HealthSuite struct{}
+
+var _ = check.Suite(&HealthSuite{})
+
+func (s *HealthSuite) TestHistoryTellsTheChanges(c *check.C) {
+	h := NewHistory()
+	now := time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC)
+	down := errors.New("connection refused")
+	_, changed := h.Add("plano", now, nil)
+	c.Assert(changed, check.Equals, false)
+	e, changed := h.Add("plano", now, down)
+	c.Assert(changed, check.Equals, true)
+	c.Assert(e, check.DeepEquals, Event{Address: "plano", Up: false, Err: down, Time: now})
+	_, changed = h.Add("plano", now, down)
+	c.Assert(changed, check.Equals, false)
+	e, changed = h.Add("plano", now, nil)
+	c.Assert(changed, check.Equals, true)
+	c.Assert(e.Up, check.Equals, true)
+	_, changed = h.Add("dallas", now, down)
+	c.Assert(changed, check.Equals, true)
+	c.Assert(h.Get("plano"), check.HasLen, 4)
+	c.Assert(h.Get("plano")[1].Error, check.Equals, "connection refused")
+	h.Forget("plano")
+	c.Assert(h.Get("plano"), check.HasLen, 0)
+}
+
+func (s *HealthSuite) TestHistoryKeepsTheLatest(c *check.C) {
+	h := NewHistory()
+	start := time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < HEALTH_HISTORY+5; i++ {
+		h.Add("plano", start.Add(time.Duration(i)*time.Minute), nil)
+	}
+	probes := h.Get("plano")
+	c.Assert(probes, check.HasLen, HEALTH_HISTORY)
+	c.Assert(probes[0].Time, check.Equals, start.Add(5*time.Minute))
+}
+
+func (s *HealthSuite) TestBackoff(c *check.C) {
+	want := []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for failures, w := range want {
+		c.Check(Backoff(time.Minute, 5*time.Minute, failures), check.Equals, w, check.Commentf("%d failures", failures))
+	}
+}
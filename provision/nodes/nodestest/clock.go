@@ -0,0 +1,31 @@
+package nodestest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is told the time, its After never fires so that a test drives the
+// probes itself.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) After(d time.Duration) <-chan time.Time { return make(chan time.Time) }
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
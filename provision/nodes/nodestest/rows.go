@@ -0,0 +1,77 @@
+package nodestest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/megamsys/gocql"
+	ldb "github.com/megamsys/libgo/db"
+)
+
+// Rows keeps the rows of a registry in memory, keyed by their table and
+// primary key. The reads fail with the error it is told to.
+type Rows struct {
+	mu      sync.Mutex
+	rows    map[string][]byte
+	readErr error
+}
+
+func NewRows() *Rows {
+	return &Rows{rows: make(map[string][]byte)}
+}
+
+// FailReads makes every read fail with err, a nil err ends it.
+func (r *Rows) FailReads(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readErr = err
+}
+
+// Len returns the count of the rows kept.
+func (r *Rows) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.rows)
+}
+
+func key(ops ldb.Options) string {
+	pks := make([]string, 0, len(ops.PksClauses))
+	for k, v := range ops.PksClauses {
+		pks = append(pks, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pks)
+	return fmt.Sprintf("%s%v", ops.TableName, pks)
+}
+
+func (r *Rows) Fetch(ops ldb.Options, row interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.readErr != nil {
+		return r.readErr
+	}
+	b, ok := r.rows[key(ops)]
+	if !ok {
+		return gocql.ErrNotFound
+	}
+	return json.Unmarshal(b, row)
+}
+
+func (r *Rows) Store(ops ldb.Options, row interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	r.rows[key(ops)] = b
+	return nil
+}
+
+func (r *Rows) Delete(ops ldb.Options, row interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rows, key(ops))
+	return nil
+}
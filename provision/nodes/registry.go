@@ -0,0 +1,364 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+// Package nodes keeps the nodes of a cluster (one frontends or docker hosts)
+// in scylla, and the history of their health.
+package nodes
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/megamsys/gocql"
+	ldb "github.com/megamsys/libgo/db"
+)
+
+//the metadata listing the keys the config gave a node, the other keys are
+//gathered as the node is used (eg: its health) and outlive a new config.
+const CONFIG_KEYS = "ConfigKeys"
+
+var (
+	ErrNotRegistered = errors.New("node isn't registered")
+	ErrRegistered    = errors.New("node is registered already")
+)
+
+// Row is a node as kept, its metadata as k=v pairs.
+type Row struct {
+	Address        string   `cql:"address"`
+	Metadata       []string `cql:"metadata"`
+	CreationStatus string   `cql:"creation_status"`
+}
+
+// NewRow returns the row of a node.
+func NewRow(address string, metadata map[string]string, creationStatus string) *Row {
+	r := &Row{Address: address, Metadata: []string{}, CreationStatus: creationStatus}
+	for k, v := range metadata {
+		r.Metadata = append(r.Metadata, k+"="+v)
+	}
+	sort.Strings(r.Metadata)
+	return r
+}
+
+// MetadataMap returns the metadata of the node.
+func (r *Row) MetadataMap() map[string]string {
+	m := make(map[string]string, len(r.Metadata))
+	for _, kv := range r.Metadata {
+		if i := strings.Index(kv, "="); i > 0 {
+			m[kv[:i]] = kv[i+1:]
+		}
+	}
+	return m
+}
+
+//the addresses of the nodes are kept in a single row of the registered
+//table, so that the nodes needn't be scanned.
+type registeredRow struct {
+	Id        string   `cql:"id"`
+	Addresses []string `cql:"addresses"`
+}
+
+// Healing is the lock a healer holds on a node.
+type Healing struct {
+	LockedUntil time.Time
+	IsFailure   bool
+}
+
+// Rows is where the rows of the registry are read and written.
+type Rows interface {
+	Fetch(ops ldb.Options, row interface{}) error
+	Store(ops ldb.Options, row interface{}) error
+	Delete(ops ldb.Options, row interface{}) error
+}
+
+type scyllaRows struct{}
+
+func (scyllaRows) Fetch(ops ldb.Options, row interface{}) error  { return ldb.Fetchdb(ops, row) }
+func (scyllaRows) Store(ops ldb.Options, row interface{}) error  { return ldb.Storedb(ops, row) }
+func (scyllaRows) Delete(ops ldb.Options, row interface{}) error { return ldb.Deletedb(ops, row) }
+
+// Registry keeps the nodes in the nodes table of scylla, and their addresses
+// in the registered one. The healing locks are held in memory as they only
+// guard the updates of this vertice.
+type Registry struct {
+	Hosts      []string
+	Keyspace   string
+	Rows       Rows //scylla, unless told (eg: a fake in tests).
+	nodes      string
+	registered string
+	mu         sync.Mutex
+	healing    map[string]Healing
+}
+
+// NewRegistry returns the registry of the nodes in the tables, eg: one_nodes
+// and one_registered.
+func NewRegistry(hosts []string, keyspace, nodes, registered string) *Registry {
+	return &Registry{
+		Hosts:      hosts,
+		Keyspace:   keyspace,
+		nodes:      nodes,
+		registered: registered,
+		Rows:       scyllaRows{},
+		healing:    make(map[string]Healing),
+	}
+}
+
+// IsNotFound tells whether the error of a read is about a row which isn't
+// there, and not a failed read.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == gocql.ErrNotFound {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not found") || strings.Contains(msg, "no rows")
+}
+
+func (s *Registry) options(table, pk, id string) ldb.Options {
+	return ldb.Options{
+		TableName:   table,
+		Pks:         []string{pk},
+		Ccms:        []string{},
+		Hosts:       s.Hosts,
+		Keyspace:    s.Keyspace,
+		PksClauses:  map[string]interface{}{pk: id},
+		CcmsClauses: make(map[string]interface{}),
+	}
+}
+
+func (s *Registry) byAddress(address string) ldb.Options {
+	return s.options(s.nodes, "address", address)
+}
+
+//the addresses registered, none when the row isn't there yet. A failed read
+//is an error, so that the row isn't overwritten after it without the others.
+func (s *Registry) addresses() ([]string, error) {
+	all := &registeredRow{}
+	if err := s.Rows.Fetch(s.options(s.registered, "id", "all"), all); IsNotFound(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return all.Addresses, nil
+}
+
+func (s *Registry) register(addresses []string) error {
+	return s.Rows.Store(s.options(s.registered, "id", "all"), &registeredRow{Id: "all", Addresses: addresses})
+}
+
+func (s *Registry) isRegistered(address string) (bool, error) {
+	addrs, err := s.addresses()
+	if err != nil {
+		return false, err
+	}
+	for _, a := range addrs {
+		if a == address {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Registry) get(address string) (*Row, error) {
+	r := &Row{}
+	if err := s.Rows.Fetch(s.byAddress(address), r); IsNotFound(err) {
+		return nil, ErrNotRegistered
+	} else if err != nil {
+		return nil, err
+	}
+	r.Address = address
+	return r, nil
+}
+
+// Store registers the node, ErrRegistered when it is already.
+func (s *Registry) Store(r *Row) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs, err := s.addresses()
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if a == r.Address {
+			return ErrRegistered
+		}
+	}
+	if err := s.Rows.Store(s.byAddress(r.Address), r); err != nil {
+		return err
+	}
+	return s.register(append(addrs, r.Address))
+}
+
+// All returns the nodes registered.
+func (s *Registry) All() ([]*Row, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs, err := s.addresses()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*Row, 0, len(addrs))
+	for _, a := range addrs {
+		r, err := s.get(a)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// Get returns the node, ErrNotRegistered when it isn't.
+func (s *Registry) Get(address string) (*Row, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok, err := s.isRegistered(address); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrNotRegistered
+	}
+	return s.get(address)
+}
+
+// Update saves the node, ErrNotRegistered when it isn't.
+func (s *Registry) Update(r *Row) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok, err := s.isRegistered(r.Address); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotRegistered
+	}
+	return s.Rows.Store(s.byAddress(r.Address), r)
+}
+
+// Remove unregisters the nodes, ErrNotRegistered when none of them is.
+func (s *Registry) Remove(addresses []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs, err := s.addresses()
+	if err != nil {
+		return err
+	}
+	addrMap := map[string]struct{}{}
+	for _, addr := range addresses {
+		addrMap[addr] = struct{}{}
+	}
+	left := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if _, ok := addrMap[a]; !ok {
+			left = append(left, a)
+		}
+	}
+	if len(left) == len(addrs) {
+		return ErrNotRegistered
+	}
+	if err := s.register(left); err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if _, ok := addrMap[a]; ok {
+			delete(s.healing, a)
+			if err := s.Rows.Delete(s.byAddress(a), Row{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Healing returns the healing lock held on the node.
+func (s *Registry) Healing(address string) Healing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.healing[address]
+}
+
+// Lock locks the node for healing till the timeout, false when it is locked
+// already.
+func (s *Registry) Lock(address string, isFailure bool, timeout time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok, err := s.isRegistered(address); err != nil {
+		return false, err
+	} else if !ok {
+		return false, ErrNotRegistered
+	}
+	now := time.Now().UTC()
+	if s.healing[address].LockedUntil.After(now) {
+		return false, nil
+	}
+	s.healing[address] = Healing{LockedUntil: now.Add(timeout), IsFailure: isFailure}
+	return true, nil
+}
+
+// Extend holds the healing lock of the node till the timeout from now.
+func (s *Registry) Extend(address string, timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, present := s.healing[address]
+	if !present {
+		return ErrNotRegistered
+	}
+	h.LockedUntil = time.Now().UTC().Add(timeout)
+	s.healing[address] = h
+	return nil
+}
+
+// Unlock releases the healing lock of the node.
+func (s *Registry) Unlock(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, present := s.healing[address]; !present {
+		return ErrNotRegistered
+	}
+	delete(s.healing, address)
+	return nil
+}
+
+// MigrateMetadata returns the metadata a node registered again (eg: after a
+// restart) is given. The keys the config gave it before (as told by
+// CONFIG_KEYS) are replaced by the given ones, so the keys dropped since are
+// removed; the ones it gathered as it was used are kept. Of a node stored
+// before its config keys were told, only the kept keys are.
+func MigrateMetadata(stored, given map[string]string, kept ...string) map[string]string {
+	m := make(map[string]string, len(stored)+len(given)+1)
+	if configKeys, ok := stored[CONFIG_KEYS]; ok {
+		for k, v := range stored {
+			m[k] = v
+		}
+		for _, k := range strings.Split(configKeys, ",") {
+			delete(m, k)
+		}
+	} else {
+		for _, k := range kept {
+			if v, ok := stored[k]; ok {
+				m[k] = v
+			}
+		}
+	}
+	keys := make([]string, 0, len(given))
+	for k, v := range given {
+		m[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	m[CONFIG_KEYS] = strings.Join(keys, ",")
+	return m
+}
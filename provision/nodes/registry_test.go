@@ -0,0 +1,114 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/megamsys/vertice/provision/nodes/nodestest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type RegistrySuite struct {
+	rows *nodestest.Rows
+	reg  *Registry
+}
+
+var _ = check.Suite(&RegistrySuite{})
+
+func (s *RegistrySuite) SetUpTest(c *check.C) {
+	s.rows = nodestest.NewRows()
+	s.reg = NewRegistry(nil, "vertice", "one_nodes", "one_registered")
+	s.reg.Rows = s.rows
+}
+
+func (s *RegistrySuite) TestRowRoundTrip(c *check.C) {
+	md := map[string]string{"zone": "plano01", "labels": "storage=ssd,gpu=true"}
+	c.Assert(s.reg.Store(NewRow("http://plano:2633/RPC2", md, "created")), check.IsNil)
+	r, err := s.reg.Get("http://plano:2633/RPC2")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.MetadataMap(), check.DeepEquals, md)
+	c.Assert(r.CreationStatus, check.Equals, "created")
+	c.Assert(s.reg.Store(NewRow("http://plano:2633/RPC2", md, "")), check.Equals, ErrRegistered)
+}
+
+func (s *RegistrySuite) TestNothingRegistered(c *check.C) {
+	rows, err := s.reg.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(rows, check.HasLen, 0)
+	_, err = s.reg.Get("http://plano:2633/RPC2")
+	c.Assert(err, check.Equals, ErrNotRegistered)
+}
+
+func (s *RegistrySuite) TestAFailedReadKeepsTheOtherNodes(c *check.C) {
+	c.Assert(s.reg.Store(NewRow("http://plano:2633/RPC2", nil, "")), check.IsNil)
+	c.Assert(s.reg.Store(NewRow("http://dallas:2633/RPC2", nil, "")), check.IsNil)
+
+	s.rows.FailReads(errors.New("timed out"))
+	c.Assert(s.reg.Store(NewRow("http://austin:2633/RPC2", nil, "")), check.ErrorMatches, "timed out")
+	c.Assert(s.reg.Remove([]string{"http://plano:2633/RPC2"}), check.ErrorMatches, "timed out")
+	_, err := s.reg.All()
+	c.Assert(err, check.ErrorMatches, "timed out")
+
+	s.rows.FailReads(nil)
+	rows, err := s.reg.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(rows, check.HasLen, 2)
+}
+
+func (s *RegistrySuite) TestRemove(c *check.C) {
+	c.Assert(s.reg.Store(NewRow("http://plano:2633/RPC2", nil, "")), check.IsNil)
+	c.Assert(s.reg.Store(NewRow("http://dallas:2633/RPC2", nil, "")), check.IsNil)
+	c.Assert(s.reg.Remove([]string{"http://plano:2633/RPC2"}), check.IsNil)
+	c.Assert(s.reg.Remove([]string{"http://plano:2633/RPC2"}), check.Equals, ErrNotRegistered)
+	rows, err := s.reg.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(rows, check.HasLen, 1)
+	c.Assert(rows[0].Address, check.Equals, "http://dallas:2633/RPC2")
+	c.Assert(s.rows.Len(), check.Equals, 2)
+}
+
+func (s *RegistrySuite) TestHealingLock(c *check.C) {
+	c.Assert(s.reg.Store(NewRow("http://plano:2633/RPC2", nil, "")), check.IsNil)
+	ok, err := s.reg.Lock("http://plano:2633/RPC2", true, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	ok, err = s.reg.Lock("http://plano:2633/RPC2", true, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(s.reg.Healing("http://plano:2633/RPC2").IsFailure, check.Equals, true)
+	c.Assert(s.reg.Unlock("http://plano:2633/RPC2"), check.IsNil)
+	c.Assert(s.reg.Unlock("http://plano:2633/RPC2"), check.Equals, ErrNotRegistered)
+	_, err = s.reg.Lock("http://dallas:2633/RPC2", true, time.Minute)
+	c.Assert(err, check.Equals, ErrNotRegistered)
+}
+
+func (s *RegistrySuite) TestMigrateMetadata(c *check.C) {
+	stored := MigrateMetadata(nil, map[string]string{"password": "old", "zone": "plano01"})
+	stored["Failures"] = "2"
+	stored["pool"] = "ssd"
+	got := MigrateMetadata(stored, map[string]string{"password": "new", "labels": "storage=ssd"}, "Failures")
+	c.Assert(got, check.DeepEquals, map[string]string{
+		"password":  "new",
+		"labels":    "storage=ssd",
+		"Failures":  "2",
+		"pool":      "ssd",
+		CONFIG_KEYS: "labels,password",
+	})
+}
+
+func (s *RegistrySuite) TestMigrateMetadataOfAnOlderNode(c *check.C) {
+	stored := map[string]string{"password": "old", "zone": "plano01", "Failures": "2", "pool": "ssd"}
+	got := MigrateMetadata(stored, map[string]string{"password": "new"}, "Failures")
+	c.Assert(got, check.DeepEquals, map[string]string{"password": "new", "Failures": "2", CONFIG_KEYS: "password"})
+}
+
+func (s *RegistrySuite) TestIsNotFound(c *check.C) {
+	c.Assert(IsNotFound(nil), check.Equals, false)
+	c.Assert(IsNotFound(errors.New("not found")), check.Equals, true)
+	c.Assert(IsNotFound(errors.New("timed out")), check.Equals, false)
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
 	lb "github.com/megamsys/vertice/logbox"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/one/machine"
 )
@@ -43,6 +44,10 @@ type runMachineActionsArgs struct {
 	isDeploy      bool
 	machineStatus utils.Status
 	provisioner   *oneProvisioner
+	resizeTo      provision.BoxCompute
+	snapshot      string           //the name of the snapshot to take, or the ref to restore.
+	ref           *string          //the ref of the snapshot taken.
+	replaced      *machine.Machine //the machine whose vm is replaced by the one deployed.
 }
 
 //If there is a previous machine created and it has a status, we use that.
@@ -62,14 +67,16 @@ var updateStatusInScylla = action.Action{
 			mach = ctx.Previous.(machine.Machine)
 		} else {
 			mach = machine.Machine{
-				Id:         args.box.Id,
-				AccountsId: args.box.AccountsId,
-				CartonId:   args.box.CartonId,
-				Level:      args.box.Level,
-				Name:       args.box.GetFullName(),
-				Status:     args.machineStatus,
-				Image:      args.imageId,
+				Id:           args.box.Id,
+				Unit:         args.box.Unit,
+				AccountsId:   args.box.AccountsId,
+				CartonId:     args.box.CartonId,
+				Level:        args.box.Level,
+				Name:         args.box.GetFullName(),
+				Status:       args.machineStatus,
+				Image:        args.imageId,
 				VCPUThrottle: args.provisioner.vcpuThrottle,
+				Region:       args.box.Region,
 			}
 		}
 		if err := mach.SetStatus(mach.Status); err != nil {
@@ -123,8 +130,6 @@ var createMachine = action.Action{
 	},
 }
 
-
-
 var getVmHostIpPort = action.Action{
 	Name: "gethost-port",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
@@ -135,7 +140,9 @@ var getVmHostIpPort = action.Action{
 			writer = ioutil.Discard
 		}
 		err := mach.VmHostIpPort(&machine.CreateArgs{
-    	Provisioner: args.provisioner,
+			Box:         args.box,
+			Provisioner: args.provisioner,
+			Writer:      writer,
 		})
 		if err != nil {
 			return nil, err
@@ -149,7 +156,6 @@ var getVmHostIpPort = action.Action{
 	},
 }
 
-
 var updateVnchostInScylla = action.Action{
 	Name: "updateVnchost",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
@@ -231,6 +237,36 @@ var destroyOldMachine = action.Action{
 	MinParams: 1,
 }
 
+var destroyReplacedMachine = action.Action{
+	Name: "destroy-replaced-machine",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		mach := ctx.Previous.(machine.Machine)
+		args := ctx.Params[0].(runMachineActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		old := args.replaced
+		if old == nil || len(old.VMId) == 0 {
+			return mach, nil
+		}
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  destroying replaced machine (%s, vm:%s)", old.Name, old.VMId)))
+		if err := old.Remove(args.provisioner); err != nil {
+			//the box runs on the new vm, the old one is only left behind.
+			fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.ERROR, fmt.Sprintf("  destroying replaced machine (%s, vm:%s) --> %s", old.Name, old.VMId, err)))
+			return mach, nil
+		}
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  destroyed replaced machine (%s, vm:%s) OK", old.Name, old.VMId)))
+		return mach, nil
+	},
+	Backward: func(ctx action.BWContext) {
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
 var startMachine = action.Action{
 	Name: "start-machine",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
@@ -286,6 +322,91 @@ var stopMachine = action.Action{
 	MinParams: 1,
 }
 
+var resizeMachine = action.Action{
+	Name: "resize-machine",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		mach := ctx.Previous.(machine.Machine)
+		args := ctx.Params[0].(runMachineActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+
+		b := *args.box
+		b.Compute = args.resizeTo
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  resizing  machine %s to %s", mach.Name, b.Compute.String())))
+		if err := mach.Resize(args.provisioner, &b); err != nil {
+			return nil, err
+		}
+		mach.Status = provision.StatusResized
+		if err := metrix.RecordResize(&b, metrix.OPENNEBULA); err != nil {
+			log.Errorf("  unable to record the resize of machine %s: %s", mach.Name, err)
+		}
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  resizing  machine (%s, %s) OK", mach.Id, mach.Name)))
+		return mach, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		//do you want to add it back.
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
+var snapshotMachine = action.Action{
+	Name: "snapshot-machine",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		mach := ctx.Previous.(machine.Machine)
+		args := ctx.Params[0].(runMachineActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  snapshotting  machine %s", mach.Name)))
+		ref, err := mach.Snapshot(args.box.Context(), args.provisioner, args.snapshot)
+		if err != nil {
+			return nil, err
+		}
+		*args.ref = ref
+		mach.Status = provision.StatusSnapshotted
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  snapshotting  machine (%s, %s) OK", mach.Id, mach.Name)))
+		return mach, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		//do you want to add it back.
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
+var restoreMachine = action.Action{
+	Name: "restore-machine",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		mach := ctx.Previous.(machine.Machine)
+		args := ctx.Params[0].(runMachineActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  restoring  machine %s from %s", mach.Name, args.snapshot)))
+		if err := mach.Restore(args.box.Context(), args.provisioner, args.snapshot); err != nil {
+			return nil, err
+		}
+		mach.Status = provision.StatusRestored
+
+		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  restoring  machine (%s, %s) OK", mach.Id, mach.Name)))
+		return mach, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		//do you want to add it back.
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
 var restartMachine = action.Action{
 	Name: "restart-machine",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
@@ -297,7 +418,7 @@ var restartMachine = action.Action{
 		}
 
 		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("restarting  machine %s", mach.Name)))
-		err := mach.LifecycleOps(args.provisioner, RESTART)
+		err := mach.Restart(args.provisioner, args.box)
 		if err != nil {
 			return nil, err
 		}
@@ -325,6 +446,7 @@ var changeStateofMachine = action.Action{
 		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  change state of machine (%s, %s)", args.box.GetFullName(), args.machineStatus.String())))
 		mach := machine.Machine{
 			Id:       args.box.Id,
+			Unit:     args.box.Unit,
 			CartonId: args.box.CartonId,
 			Level:    args.box.Level,
 			Name:     args.box.GetFullName(),
@@ -355,7 +477,6 @@ var addNewRoute = action.Action{
 			writer = ioutil.Discard
 		}
 
-
 		fmt.Fprintf(writer, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("adding route to machine (%s, %s)", mach.Name, args.box.PublicIp)))
 		err = r.SetCName(mach.Name, args.box.PublicIp)
 		if err != nil {
@@ -1,38 +1,34 @@
 package cluster
 
 import (
-	"fmt"
-
 	log "github.com/Sirupsen/logrus"
-	"github.com/megamsys/libgo/cmd"
 	"github.com/megamsys/opennebula-go/metrics"
 )
 
-// Showback returns the metrics of the one cluster
+// Showback returns the metrics of the one cluster, gathered from every node.
 func (c *Cluster) Showback(start int64, end int64) ([]interface{}, error) {
 	log.Debugf("showback (%d, %d)", start, end)
-	var (
-		addr string
-	)
 	nodlist, err := c.Nodes()
 
 	if err != nil || len(nodlist) <= 0 {
-		return nil, fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-	} else {
-		addr = nodlist[0].Address
+		return nil, errUnavailableNodes
 	}
 
-	node, err := c.getNodeByAddr(addr)
-	if err != nil {
-		return nil, err
-	}
-	opts := metrics.Accounting{Api: node.Client, StartTime: start, EndTime: end}
+	all := make([]interface{}, 0)
+	for _, n := range nodlist {
+		node, err := c.getNodeByAddr(n.Address)
+		if err != nil {
+			return nil, err
+		}
+		opts := metrics.Accounting{Api: node.Client, StartTime: start, EndTime: end}
 
-	sb, err := opts.Get()
+		sb, err := opts.Get()
 
-	if err != nil {
-		return nil, wrapError(node, err)
+		if err != nil {
+			return nil, wrapError(node, err)
+		}
+		all = append(all, sb...)
 	}
 	log.Debugf("showback (%d, %d) OK", start, end)
-	return sb, nil
+	return all, nil
 }
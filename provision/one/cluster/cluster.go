@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/vertice/provision/nodes"
 )
 
 var (
@@ -44,9 +45,16 @@ type ClusterHook interface {
 // Cluster is the basic type of the package. It manages internal nodes, and
 // provide methods for interaction with those nodes
 type Cluster struct {
-	Healer Healer
-	Hook   ClusterHook
-	stor   Storage
+	Healer    Healer
+	Hook      ClusterHook
+	Scheduler Scheduler
+	Clock     nodes.Clock
+	Notify    func(nodes.Event) //told when a probe finds a node went down or came back up
+	stor      Storage
+
+	probe          func(Node) error
+	history        *nodes.History
+	monitoringDone chan bool
 }
 
 type OneNodeError struct {
@@ -92,10 +100,14 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	}
 	c.stor = storage
 	c.Healer = DefaultHealer{}
+	c.Scheduler = DefaultScheduler{}
+	c.Clock = nodes.RealClock{}
+	c.probe = c.ping
+	c.history = nodes.NewHistory()
 
 	if len(nodes) > 0 {
 		for _, n := range nodes {
-			err = c.Register(n)
+			err = c.registerOrMigrate(n)
 			if err != nil {
 				return &c, err
 			}
@@ -104,6 +116,27 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	return &c, err
 }
 
+//the metadata a node gathers as it is used, kept when a node stored before
+//its config keys were told is registered again.
+var healthMetadata = []string{"Failures", "LastSuccess", "LastError", "DisabledUntil"}
+
+//registers the node, or when the storage has it already (eg: after a restart)
+//migrates its metadata to the given ones keeping what it gathered since.
+func (c *Cluster) registerOrMigrate(n Node) error {
+	given := n.Metadata
+	n.Metadata = nodes.MigrateMetadata(nil, given)
+	err := c.Register(n)
+	if err != ErrDuplicatedNodeAddress {
+		return err
+	}
+	dbNode, err := c.storage().RetrieveNode(n.Address)
+	if err != nil {
+		return err
+	}
+	dbNode.Metadata = nodes.MigrateMetadata(dbNode.Metadata, given, healthMetadata...)
+	return c.storage().UpdateNode(dbNode)
+}
+
 // Register adds new nodes to the cluster.
 func (c *Cluster) Register(node Node) error {
 	if node.Address == "" {
@@ -141,13 +174,21 @@ func (c *Cluster) UpdateNode(node Node) (Node, error) {
 
 // Unregister removes nodes from the cluster.
 func (c *Cluster) Unregister(address string) error {
-	return c.storage().RemoveNode(address)
+	if err := c.storage().RemoveNode(address); err != nil {
+		return err
+	}
+	c.history.Forget(address)
+	return nil
 }
 
 func (c *Cluster) UnregisterNodes(addresses ...string) error {
 	return c.storage().RemoveNodes(addresses)
 }
 
+func (c *Cluster) GetNode(address string) (Node, error) {
+	return c.storage().RetrieveNode(address)
+}
+
 func (c *Cluster) UnfilteredNodes() ([]Node, error) {
 	return c.storage().RetrieveNodes()
 }
@@ -167,16 +208,7 @@ func (c *Cluster) handleNodeError(addr string, lastErr error, incrementFailures
 	}
 	go func() {
 		defer unlock()
-		node, err := c.storage().RetrieveNode(addr)
-		if err != nil {
-			return
-		}
-		node.updateError(lastErr, incrementFailures)
-		duration := c.Healer.HandleError(&node)
-		if duration > 0 {
-			node.updateDisabled(time.Now().Add(duration))
-		}
-		c.storage().UpdateNode(node)
+		c.updateNodeError(addr, lastErr, incrementFailures)
 		if fn := nodeUpdatedOnError.Val(); fn != nil {
 			fn()
 		}
@@ -184,6 +216,21 @@ func (c *Cluster) handleNodeError(addr string, lastErr error, incrementFailures
 	return nil
 }
 
+//records the error of the node and disables it for as long as the healer
+//says, the node is to be locked.
+func (c *Cluster) updateNodeError(addr string, lastErr error, incrementFailures bool) error {
+	node, err := c.storage().RetrieveNode(addr)
+	if err != nil {
+		return err
+	}
+	node.updateError(lastErr, incrementFailures)
+	duration := c.Healer.HandleError(&node)
+	if duration > 0 {
+		node.updateDisabled(c.now().Add(duration))
+	}
+	return c.storage().UpdateNode(node)
+}
+
 // Modified by tests
 var nodeUpdatedOnError nodeUpdatedHook
 
@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	VM_UPDATECONF = "one.vm.updateconf"
+
+	//the context var which lists the envs of the box put in the context, so
+	//that the ones since removed are dropped on the next update.
+	CONTEXT_ENVS = "VERTICE_ENVS"
+)
+
+//a var of the context of a vm, as one tells it.
+type contextVar struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type vmContext struct {
+	Vars []contextVar `xml:",any"`
+}
+
+func (vc vmContext) toMap() map[string]string {
+	m := make(map[string]string, len(vc.Vars))
+	for _, v := range vc.Vars {
+		m[v.XMLName.Local] = v.Value
+	}
+	return m
+}
+
+//the context the vm has with vars put over it. The envs put before (as told
+//by CONTEXT_ENVS) which aren't in vars any more are dropped.
+func mergeContext(existing, vars map[string]string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(vars))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for _, name := range strings.Fields(existing[CONTEXT_ENVS]) {
+		delete(merged, name)
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return merged
+}
+
+//the CONTEXT section of a vm template, the vars sorted by name.
+func contextTemplate(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	for _, k := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", k, r.Replace(vars[k])))
+	}
+	return "CONTEXT=[" + strings.Join(pairs, ",") + "]"
+}
+
+// RebootVMWithContext puts the vars in the context of a vm in the node addr
+// and boots it again, so that the contextualization in the vm picks them up.
+// A running vm is powered off for it and resumed after, one which was off is
+// left off and picks them up on its next boot.
+func (c *Cluster) RebootVMWithContext(ctx context.Context, addr, vmid string, vars map[string]string) error {
+	n, id, err := c.vmNode(addr, vmid)
+	if err != nil {
+		return err
+	}
+	return c.offline(ctx, n, id, func(vm *vmInfo) error {
+		template := contextTemplate(mergeContext(vm.Context.toMap(), vars))
+		if _, err := n.Client.Call(VM_UPDATECONF, []interface{}{n.Client.Key, id, template}); err != nil {
+			return wrapErrorWithCmd(n, err, "rebootVM")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,28 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestMergeContext(t *testing.T) {
+	body := `<VM><STATE>8</STATE><TEMPLATE><CONTEXT><NETWORK>YES</NETWORK><DB_HOST>10.0.0.9</DB_HOST><DB_PORT>5432</DB_PORT><VERTICE_ENVS>DB_HOST DB_PORT</VERTICE_ENVS></CONTEXT></TEMPLATE></VM>`
+	vm := &vmInfo{}
+	if err := xml.Unmarshal([]byte(body), vm); err != nil {
+		t.Fatal(err)
+	}
+	got := mergeContext(vm.Context.toMap(), map[string]string{"DB_HOST": "10.0.0.10", CONTEXT_ENVS: "DB_HOST"})
+	want := map[string]string{"NETWORK": "YES", "DB_HOST": "10.0.0.10", CONTEXT_ENVS: "DB_HOST"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeContext: want %v, got %v", want, got)
+	}
+}
+
+func TestContextTemplate(t *testing.T) {
+	got := contextTemplate(map[string]string{"SSH_PUBLIC_KEY": "ssh-rsa AAAA me@plano", "MOTD": `say "hi" \o/`})
+	want := `CONTEXT=[MOTD="say \"hi\" \\o/",SSH_PUBLIC_KEY="ssh-rsa AAAA me@plano"]`
+	if got != want {
+		t.Errorf("contextTemplate: want %s, got %s", want, got)
+	}
+}
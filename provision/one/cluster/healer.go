@@ -1,13 +1,35 @@
 package cluster
 
-import "time"
+import (
+	"time"
+
+	"github.com/megamsys/vertice/provision/nodes"
+)
 
 type Healer interface {
 	HandleError(node *Node) time.Duration
 }
 
-type DefaultHealer struct{}
+const (
+	DefaultDisableBase = 1 * time.Minute
+	DefaultDisableMax  = 30 * time.Minute
+)
+
+// DefaultHealer disables a failing node with an exponential backoff, the
+// first failure disables it for Base, every next one for twice as long up
+// to Max. The zeros are the defaults.
+type DefaultHealer struct {
+	Base time.Duration
+	Max  time.Duration
+}
 
-func (DefaultHealer) HandleError(node *Node) time.Duration {
-	return 1 * time.Minute
+func (h DefaultHealer) HandleError(node *Node) time.Duration {
+	base, max := h.Base, h.Max
+	if base <= 0 {
+		base = DefaultDisableBase
+	}
+	if max <= 0 {
+		max = DefaultDisableMax
+	}
+	return nodes.Backoff(base, max, node.FailureCount())
 }
@@ -1,16 +1,18 @@
 package cluster
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/url"
-	"encoding/json"
-	 "strings"
+	"strings"
+
 	log "github.com/Sirupsen/logrus"
-	"github.com/megamsys/libgo/cmd"
 	"github.com/megamsys/opennebula-go/compute"
 	"github.com/megamsys/opennebula-go/virtualmachine"
+	"github.com/megamsys/vertice/provision"
+	"golang.org/x/net/context"
 )
 
 // CreateVM creates a vm in the specified node.
@@ -21,10 +23,12 @@ const (
 	RESTART = "restart"
 )
 
-
 var ErrConnRefused = errors.New("connection refused")
 
-func (c *Cluster) CreateVM(opts compute.VirtualMachine) (string, string, string, error) {
+//CreateVM creates a vm in the node the scheduler picks, another one is tried
+//when it fails. It gives up once ctx is done, a vm which comes up after that
+//is destroyed. It returns the node the vm is in, along with the vm.
+func (c *Cluster) CreateVM(ctx context.Context, opts compute.VirtualMachine, schedulerOpts SchedulerOptions) (string, string, string, error) {
 	var (
 		addr    string
 		machine string
@@ -33,118 +37,124 @@ func (c *Cluster) CreateVM(opts compute.VirtualMachine) (string, string, string,
 	)
 	maxTries := 5
 	for ; maxTries > 0; maxTries-- {
-
-		nodlist, err := c.Nodes()
-
-		if err != nil || len(nodlist) <= 0 {
-			return addr, machine, vmid, fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-		} else {
-			addr = nodlist[0].Address
-		}
-
-		if err == nil {
-			machine, vmid, err = c.createVMInNode(opts, addr)
+		n, serr := c.Scheduler.Schedule(c, opts, schedulerOpts)
+		if serr != nil {
 			if err == nil {
-				c.handleNodeSuccess(addr)
-				break
+				return "", "", "", serr
 			}
-			log.Errorf("  > Trying... %s", addr)
-		}
-		shouldIncrementFailures := false
-		isCreateMachineErr := false
-		baseErr := err
-		if nodeErr, ok := baseErr.(OneNodeError); ok {
-			isCreateMachineErr = nodeErr.cmd == "createVM"
-			baseErr = nodeErr.BaseError()
+			break //no node is left to try
 		}
-		if urlErr, ok := baseErr.(*url.Error); ok {
-			baseErr = urlErr.Err
+		addr = n.Address
+		in := addr
+		err = provision.Interruptible(ctx, func() (err error) {
+			machine, vmid, err = c.createVMInNode(opts, in)
+			return err
+		}, func() {
+			log.Warningf("  removing vm %s created after its deadline", opts.Name)
+			if err := c.DestroyVM(in, compute.VirtualMachine{Name: opts.Name}); err != nil {
+				log.Errorf("  unable to remove vm %s: %s", opts.Name, err)
+			}
+		})
+		if err == ctx.Err() && err != nil {
+			return addr, "", "", err
 		}
-		_, isNetErr := baseErr.(*net.OpError)
-		if isNetErr || isCreateMachineErr || baseErr == ErrConnRefused {
-			shouldIncrementFailures = true
+		if err == nil {
+			c.handleNodeSuccess(addr)
+			return addr, machine, vmid, nil
 		}
-		c.handleNodeError(addr, err, shouldIncrementFailures)
-		return addr, machine, vmid, err
+		log.Errorf("  > Trying another node, vm %s failed in %s: %s", opts.Name, addr, err)
+		c.handleNodeError(addr, err, shouldIncrementFailures(err))
+		schedulerOpts.Exclude = append(schedulerOpts.Exclude, addr)
 	}
-	if err != nil {
-		return addr, machine, vmid,fmt.Errorf("CreateVM: maximum number of tries exceeded, last error: %s", err.Error())
+	return addr, "", "", fmt.Errorf("CreateVM: maximum number of tries exceeded, last error: %s", err.Error())
+}
+
+//a node is failed (and healed) for the errors of reaching it or creating in it.
+func shouldIncrementFailures(err error) bool {
+	isCreateMachineErr := false
+	baseErr := err
+	if nodeErr, ok := baseErr.(OneNodeError); ok {
+		isCreateMachineErr = nodeErr.cmd == "createVM"
+		baseErr = nodeErr.BaseError()
 	}
-	return addr, machine, vmid, err
+	if urlErr, ok := baseErr.(*url.Error); ok {
+		baseErr = urlErr.Err
+	}
+	_, isNetErr := baseErr.(*net.OpError)
+	return isNetErr || isCreateMachineErr || baseErr == ErrConnRefused
 }
 
 //create a vm in a node.
-func (c *Cluster) createVMInNode(opts compute.VirtualMachine, nodeAddress string) ( string, string, error) {
-	 node, err := c.getNodeByAddr(nodeAddress)
+func (c *Cluster) createVMInNode(opts compute.VirtualMachine, nodeAddress string) (string, string, error) {
+	node, err := c.getNodeByAddr(nodeAddress)
 	if err != nil {
-		return "", "",err
+		return "", "", err
 	}
 	opts.TemplateName = node.template
 	opts.T = node.Client
 
 	res, err := opts.Create()
-	 b, err :=json.Marshal(res)
-	 if err != nil {
-		 return "", "", err
-	 }
-	  str :=string(b)
-		 spstr :=strings.Split(str,",")
-		 vmres := spstr[1]
 	if err != nil {
 		return "", "", wrapErrorWithCmd(node, err, "createVM")
 	}
-
-	return opts.Name, vmres, nil
+	b, err := json.Marshal(res)
+	if err != nil {
+		return "", "", err
+	}
+	spstr := strings.Split(string(b), ",")
+	if len(spstr) < 2 {
+		return "", "", wrapErrorWithCmd(node, fmt.Errorf("no vm id in %s", string(b)), "createVM")
+	}
+	return opts.Name, spstr[1], nil
 }
 
+//the node which owns the vm. The first one is used when the owner isn't
+//known, eg: for the vms created before the owner was saved.
+func (c *Cluster) vmOwner(addr string) (node, error) {
+	if len(strings.TrimSpace(addr)) == 0 {
+		nodlist, err := c.Nodes()
+		if err != nil || len(nodlist) <= 0 {
+			return node{}, errUnavailableNodes
+		}
+		addr = nodlist[0].Address
+	}
+	return c.getNodeByAddr(addr)
+}
 
-
-func (c *Cluster) GetIpPort(opts virtualmachine.Vnc) ( string, string, error) {
-
+// GetIpPort returns the vnc host and port of a vm in the node addr.
+func (c *Cluster) GetIpPort(ctx context.Context, addr string, opts virtualmachine.Vnc) (string, string, error) {
 	var (
-		addr string
 		vnchost string
 		vncport string
 	)
-	nodlist, err := c.Nodes()
-
-	if err != nil || len(nodlist) <= 0 {
-		return vnchost, vncport, fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-	} else {
-		addr = nodlist[0].Address
-	}
-
-	node, err := c.getNodeByAddr(addr)
+	node, err := c.vmOwner(addr)
 	if err != nil {
-		return "", "",err
+		return "", "", err
 	}
-	//opts.TemplateName = node.template
 	opts.T = node.Client
 
-	res, err := opts.GetVm()
-	 vnchost = res.GetHostIp()
-	 vncport = res.GetPort()
-	 
+	err = provision.Interruptible(ctx, func() error {
+		res, err := opts.GetVm()
+		if err != nil {
+			return err
+		}
+		vnchost = res.GetHostIp()
+		vncport = res.GetPort()
+		return nil
+	}, nil)
+	if err == ctx.Err() && err != nil {
+		return "", "", err
+	}
 	if err != nil {
-		return "", "", wrapErrorWithCmd(node, err, "createVM")
+		return "", "", wrapErrorWithCmd(node, err, "getVm")
 	}
 
 	return vnchost, vncport, nil
 }
-// DestroyVM kills a vm, returning an error in case of failure.
-func (c *Cluster) DestroyVM(opts compute.VirtualMachine) error {
-	var (
-		addr string
-	)
-	nodlist, err := c.Nodes()
 
-	if err != nil || len(nodlist) <= 0 {
-		return fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-	} else {
-		addr = nodlist[0].Address
-	}
-
-	node, err := c.getNodeByAddr(addr)
+// DestroyVM kills a vm in the node addr, returning an error in case of failure.
+func (c *Cluster) DestroyVM(addr string, opts compute.VirtualMachine) error {
+	node, err := c.vmOwner(addr)
 	if err != nil {
 		return err
 	}
@@ -157,31 +167,21 @@ func (c *Cluster) DestroyVM(opts compute.VirtualMachine) error {
 	return nil
 }
 
-func (c *Cluster) VM(opts compute.VirtualMachine, action string) error {
+func (c *Cluster) VM(addr string, opts compute.VirtualMachine, action string) error {
 	switch action {
 	case START:
-		return c.StartVM(opts)
+		return c.StartVM(addr, opts)
 	case STOP:
-		return c.StopVM(opts)
+		return c.StopVM(addr, opts)
 	case RESTART:
-		return c.RestartVM(opts)
+		return c.RestartVM(addr, opts)
 	default:
 		return nil
 	}
 }
-func (c *Cluster) StartVM(opts compute.VirtualMachine) error {
-	var (
-		addr string
-	)
-	nodlist, err := c.Nodes()
 
-	if err != nil || len(nodlist) <= 0 {
-		return fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-	} else {
-		addr = nodlist[0].Address
-	}
-
-	node, err := c.getNodeByAddr(addr)
+func (c *Cluster) StartVM(addr string, opts compute.VirtualMachine) error {
+	node, err := c.vmOwner(addr)
 	if err != nil {
 		return err
 	}
@@ -194,19 +194,8 @@ func (c *Cluster) StartVM(opts compute.VirtualMachine) error {
 	return nil
 }
 
-func (c *Cluster) RestartVM(opts compute.VirtualMachine) error {
-	var (
-		addr string
-	)
-	nodlist, err := c.Nodes()
-
-	if err != nil || len(nodlist) <= 0 {
-		return fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-	} else {
-		addr = nodlist[0].Address
-	}
-
-	node, err := c.getNodeByAddr(addr)
+func (c *Cluster) RestartVM(addr string, opts compute.VirtualMachine) error {
+	node, err := c.vmOwner(addr)
 	if err != nil {
 		return err
 	}
@@ -219,18 +208,8 @@ func (c *Cluster) RestartVM(opts compute.VirtualMachine) error {
 	return nil
 }
 
-func (c *Cluster) StopVM(opts compute.VirtualMachine) error {
-	var (
-		addr string
-	)
-	nodlist, err := c.Nodes()
-	if err != nil || len(nodlist) <= 0 {
-		return fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
-	} else {
-		addr = nodlist[0].Address
-	}
-
-	node, err := c.getNodeByAddr(addr)
+func (c *Cluster) StopVM(addr string, opts compute.VirtualMachine) error {
+	node, err := c.vmOwner(addr)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/provision/nodes"
+)
+
+const SYSTEM_VERSION = "one.system.version"
+
+// HealthHistory returns the latest probes of a node, the oldest first.
+func (c *Cluster) HealthHistory(address string) []provision.NodeHealth {
+	return c.history.Get(address)
+}
+
+func (c *Cluster) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+// StartActiveMonitoring probes the nodes every updateInterval, a node which
+// fails is disabled as the healer says and one which comes back is enabled.
+func (c *Cluster) StartActiveMonitoring(updateInterval time.Duration) {
+	c.StopActiveMonitoring()
+	c.monitoringDone = make(chan bool)
+	go nodes.Monitor(c.Clock, updateInterval, c.probeNodes, c.monitoringDone)
+}
+
+func (c *Cluster) StopActiveMonitoring() {
+	if c.monitoringDone != nil {
+		close(c.monitoringDone)
+		c.monitoringDone = nil
+	}
+}
+
+//probes the nodes which are enabled, a disabled one is probed once it is
+//past its backoff.
+func (c *Cluster) probeNodes() {
+	all, err := c.UnfilteredNodes()
+	if err != nil {
+		log.Errorf("  unable to list the nodes to probe: %s", err)
+		return
+	}
+	now := c.now()
+	for _, n := range all {
+		if n.isEnabledAt(now) {
+			c.probeNode(n)
+		}
+	}
+}
+
+func (c *Cluster) probeNode(n Node) {
+	err := c.probe(n)
+	if err != nil {
+		if unlock, lerr := c.lockWithTimeout(n.Address, true); lerr == nil {
+			c.updateNodeError(n.Address, err, true)
+			unlock()
+		}
+	} else {
+		c.handleNodeSuccess(n.Address)
+	}
+	if e, changed := c.history.Add(n.Address, c.now(), err); changed {
+		c.notify(e)
+	}
+}
+
+func (c *Cluster) notify(e nodes.Event) {
+	if e.Up {
+		log.Infof("  one node %s is up", e.Address)
+	} else {
+		log.Warningf("  one node %s is down: %s", e.Address, e.Err)
+	}
+	if c.Notify != nil {
+		c.Notify(e)
+	}
+}
+
+//the version call of the node.
+func (c *Cluster) ping(nodeo Node) error {
+	n, err := c.getNodeByObject(nodeo)
+	if err != nil {
+		return err
+	}
+	_, err = n.Client.Call(SYSTEM_VERSION, []interface{}{n.Client.Key})
+	return wrapError(n, err)
+}
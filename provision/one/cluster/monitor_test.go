@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/megamsys/vertice/provision/nodes"
+	"github.com/megamsys/vertice/provision/nodes/nodestest"
+)
+
+func TestDefaultHealerBacksOff(t *testing.T) {
+	h := DefaultHealer{Base: time.Minute, Max: 5 * time.Minute}
+	want := []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for failures, w := range want {
+		n := Node{Metadata: map[string]string{}}
+		if failures > 0 {
+			n.Metadata["Failures"] = strconv.Itoa(failures)
+		}
+		if got := h.HandleError(&n); got != w {
+			t.Errorf("HandleError with %d failures: want %s, got %s", failures, w, got)
+		}
+	}
+}
+
+func TestProbeNodesDisablesWithBackoff(t *testing.T) {
+	addr := "http://plano:2633/RPC2"
+	c, err := New(&MapStorage{}, Node{Address: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := nodestest.NewClock(time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC))
+	c.Clock = clock
+	var probeErr error
+	probes := 0
+	c.probe = func(n Node) error {
+		probes++
+		return probeErr
+	}
+	var events []nodes.Event
+	c.Notify = func(e nodes.Event) { events = append(events, e) }
+
+	probeErr = errors.New("connection refused")
+	c.probeNodes()
+	n, _ := c.GetNode(addr)
+	if want := clock.Now().Add(time.Minute); n.isEnabledAt(want.Add(-time.Second)) || !n.isEnabledAt(want.Add(time.Second)) {
+		t.Errorf("expected the node disabled until %s, got %s", want, n.Metadata["DisabledUntil"])
+	}
+
+	clock.Advance(30 * time.Second)
+	c.probeNodes()
+	if probes != 1 {
+		t.Errorf("expected a disabled node not to be probed, got %d probes", probes)
+	}
+
+	clock.Advance(31 * time.Second)
+	c.probeNodes()
+	n, _ = c.GetNode(addr)
+	if want := clock.Now().Add(time.Minute); n.isEnabledAt(want.Add(time.Second)) {
+		t.Errorf("expected the node disabled for longer than %s after the 2nd failure, got %s", want, n.Metadata["DisabledUntil"])
+	}
+
+	clock.Advance(2*time.Minute + time.Second)
+	probeErr = nil
+	c.probeNodes()
+	n, _ = c.GetNode(addr)
+	if !n.isEnabledAt(clock.Now()) || n.FailureCount() != 0 {
+		t.Errorf("expected the node enabled once back, got %v", n.Metadata)
+	}
+
+	if probes != 3 {
+		t.Errorf("expected 3 probes, got %d", probes)
+	}
+	ups := []bool{}
+	for _, e := range events {
+		ups = append(ups, e.Up)
+	}
+	if !reflect.DeepEqual(ups, []bool{false, true}) {
+		t.Errorf("expected a down and an up event, got %v", ups)
+	}
+	history := c.HealthHistory(addr)
+	if len(history) != 3 || history[0].Up || history[1].Up || !history[2].Up {
+		t.Errorf("unexpected health history %+v", history)
+	}
+}
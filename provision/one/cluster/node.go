@@ -77,6 +77,10 @@ func (n *Node) Status() string {
 }
 
 func (n *Node) isEnabled() bool {
+	return n.isEnabledAt(time.Now())
+}
+
+func (n *Node) isEnabledAt(now time.Time) bool {
 	if n.CreationStatus != "" && n.CreationStatus != NodeCreationStatusCreated {
 		return false
 	}
@@ -88,7 +92,7 @@ func (n *Node) isEnabled() bool {
 	}
 	disabledStr, _ := n.Metadata["DisabledUntil"]
 	t, _ := time.Parse(time.RFC3339, disabledStr)
-	return time.Now().After(t)
+	return now.After(t)
 }
 
 func (n *Node) isHealing() bool {
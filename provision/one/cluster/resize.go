@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	VM_RESIZE     = "one.vm.resize"
+	VM_DISKRESIZE = "one.vm.diskresize"
+)
+
+// ResizeOpts is what a vm is resized to, the memory and hdd in MB. The disk
+// of a vm can only grow, a smaller hdd leaves it as is.
+type ResizeOpts struct {
+	VMId   string
+	Cpu    string
+	VCpu   string
+	Memory string
+	HDD    string
+}
+
+// ResizeVM changes the compute of a vm in the node addr in place. A running
+// vm is powered off to be resized and resumed after, whether the resize went
+// through or not.
+func (c *Cluster) ResizeVM(ctx context.Context, addr string, opts ResizeOpts) error {
+	n, id, err := c.vmNode(addr, opts.VMId)
+	if err != nil {
+		return err
+	}
+	return c.offline(ctx, n, id, func(vm *vmInfo) error {
+		template := fmt.Sprintf("CPU=%s VCPU=%s MEMORY=%s", opts.Cpu, opts.VCpu, opts.Memory)
+		if _, err := n.Client.Call(VM_RESIZE, []interface{}{n.Client.Key, id, template, true}); err != nil {
+			return wrapErrorWithCmd(n, err, "resizeVM")
+		}
+
+		hdd, _ := strconv.ParseUint(opts.HDD, 10, 64)
+		disk, _ := strconv.ParseUint(vm.Disk, 10, 64)
+		if hdd <= disk {
+			if hdd < disk {
+				log.Warningf("  the disk of vm %d can't shrink from %d to %d, left as is", id, disk, hdd)
+			}
+			return nil
+		}
+		if _, err := n.Client.Call(VM_DISKRESIZE, []interface{}{n.Client.Key, id, 0, opts.HDD}); err != nil {
+			return wrapErrorWithCmd(n, err, "resizeVM")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,260 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/opennebula-go/compute"
+)
+
+const (
+	HOSTPOOL_INFO = "one.hostpool.info"
+
+	//the metadata of a node the scheduler matches on.
+	ZONE   = "zone"
+	LABELS = "labels" //eg: storage=ssd,gpu=true
+
+	//the states of a host in one which take vms.
+	HOST_MONITORING_MONITORED = 1
+	HOST_MONITORED            = 2
+)
+
+var (
+	errUnavailableNodes = fmt.Errorf("%s", cmd.Colorfy("Unavailable nodes (hint: start or beat it).\n", "red", "", ""))
+	ErrNoMatchingNodes  = errors.New("no node matches the zone and labels asked for")
+	ErrNoCapacity       = errors.New("no node has the capacity for the vm")
+	ErrNoNodeApart      = errors.New("no node is apart from the vms the vm is kept apart from")
+)
+
+// SchedulerOptions narrows down the nodes a vm can be placed on, the blanks
+// match any node.
+type SchedulerOptions struct {
+	Zone    string
+	Labels  map[string]string
+	Exclude []string //the nodes not to be picked, eg: the ones already tried.
+	Apart   []string //the nodes of the vms the vm is kept apart from (anti-affinity).
+}
+
+// Scheduler picks the node a vm is created on.
+type Scheduler interface {
+	Schedule(c *Cluster, opts compute.VirtualMachine, schedulerOpts SchedulerOptions) (Node, error)
+}
+
+// Capacity is what a host of a node has free, the cpu in hundredths of a
+// cpu and the memory in KB as one counts them.
+type Capacity struct {
+	Cpu    int64
+	Memory int64
+}
+
+func (c Capacity) fits(want Capacity) bool {
+	return c.Cpu >= want.Cpu && c.Memory >= want.Memory
+}
+
+//whether c has more free memory (and then cpu) than o.
+func (c Capacity) more(o Capacity) bool {
+	return c.Memory > o.Memory || (c.Memory == o.Memory && c.Cpu > o.Cpu)
+}
+
+//the host with the most free memory (and then cpu) which fits the want, a vm
+//runs on a single host so what the hosts have free isn't added up.
+func roomFor(hosts []Capacity, want Capacity) (Capacity, bool) {
+	var best Capacity
+	found := false
+	for _, h := range hosts {
+		if h.fits(want) && (!found || h.more(best)) {
+			best, found = h, true
+		}
+	}
+	return best, found
+}
+
+// DefaultScheduler picks the healthy node with a host which has room for the
+// vm among the ones in the zone with the labels, the one whose host has the
+// most free memory. The nodes
+// of the vms it is kept apart from aren't picked. A node whose capacity
+// can't be read is handled as failed.
+type DefaultScheduler struct{}
+
+func (DefaultScheduler) Schedule(c *Cluster, opts compute.VirtualMachine, schedulerOpts SchedulerOptions) (Node, error) {
+	nodes, err := c.Nodes()
+	if err != nil || len(nodes) <= 0 {
+		return Node{}, errUnavailableNodes
+	}
+	matched := NodeList(nodes).matching(schedulerOpts)
+	if len(matched) <= 0 {
+		return Node{}, ErrNoMatchingNodes
+	}
+	if matched = matched.apart(schedulerOpts.Apart); len(matched) <= 0 {
+		return Node{}, ErrNoNodeApart
+	}
+	free := make([][]Capacity, 0, len(matched))
+	healthy := make(NodeList, 0, len(matched))
+	for _, n := range matched {
+		cp, err := c.Capacity(n)
+		if err != nil {
+			log.Errorf("  unable to read the capacity of node %s: %s", n.Address, err)
+			c.handleNodeError(n.Address, err, true)
+			continue
+		}
+		healthy = append(healthy, n)
+		free = append(free, cp)
+	}
+	if len(healthy) <= 0 {
+		return Node{}, errUnavailableNodes
+	}
+	return pick(healthy, free, wants(opts))
+}
+
+//the capacity a vm wants, its cpu is in cpus and memory in MB.
+func wants(opts compute.VirtualMachine) Capacity {
+	cpu, _ := strconv.ParseFloat(strings.TrimSpace(opts.Cpu), 64)
+	mem, _ := strconv.ParseInt(strings.TrimSpace(opts.Memory), 10, 64)
+	return Capacity{Cpu: int64(cpu * 100), Memory: mem * 1024}
+}
+
+//the node with a host which fits the want, the one whose host has the most
+//free memory (and then cpu).
+func pick(nodes NodeList, free [][]Capacity, want Capacity) (Node, error) {
+	best := -1
+	var room Capacity
+	for i := range nodes {
+		host, ok := roomFor(free[i], want)
+		if !ok {
+			continue
+		}
+		if best < 0 || host.more(room) {
+			best, room = i, host
+		}
+	}
+	if best < 0 {
+		return Node{}, ErrNoCapacity
+	}
+	return nodes[best], nil
+}
+
+//the nodes in the zone with all the labels, which aren't excluded. The nodes
+//come out in the order of their address.
+func (nodes NodeList) matching(opts SchedulerOptions) NodeList {
+	excluded := make(map[string]bool, len(opts.Exclude))
+	for _, addr := range opts.Exclude {
+		excluded[addr] = true
+	}
+	matched := make(NodeList, 0, len(nodes))
+	for _, n := range nodes {
+		if excluded[n.Address] {
+			continue
+		}
+		if len(strings.TrimSpace(opts.Zone)) > 0 && n.Metadata[ZONE] != opts.Zone {
+			continue
+		}
+		if !n.hasLabels(opts.Labels) {
+			continue
+		}
+		matched = append(matched, n)
+	}
+	sort.Sort(matched)
+	return matched
+}
+
+//the nodes but the ones in addrs.
+func (nodes NodeList) apart(addrs []string) NodeList {
+	if len(addrs) == 0 {
+		return nodes
+	}
+	taken := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		taken[addr] = true
+	}
+	left := make(NodeList, 0, len(nodes))
+	for _, n := range nodes {
+		if !taken[n.Address] {
+			left = append(left, n)
+		}
+	}
+	return left
+}
+
+// Labels returns the labels of the node, kept in its metadata as k=v pairs
+// separated by commas.
+func (n *Node) Labels() map[string]string {
+	labels := make(map[string]string)
+	for _, l := range strings.Split(n.Metadata[LABELS], ",") {
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) < 2 {
+			kv = append(kv, "")
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels
+}
+
+func (n *Node) hasLabels(want map[string]string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	labels := n.Labels()
+	for k, v := range want {
+		if l, ok := labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
+
+//the parts of the host pool info we read.
+type hostPool struct {
+	Hosts []struct {
+		State    int   `xml:"STATE"`
+		MaxCpu   int64 `xml:"HOST_SHARE>MAX_CPU"`
+		CpuUsage int64 `xml:"HOST_SHARE>CPU_USAGE"`
+		MaxMem   int64 `xml:"HOST_SHARE>MAX_MEM"`
+		MemUsage int64 `xml:"HOST_SHARE>MEM_USAGE"`
+	} `xml:"HOST"`
+}
+
+//what each of the hosts which take vms has free, as allocated to the vms on it.
+func (p *hostPool) free() []Capacity {
+	free := []Capacity{}
+	for _, h := range p.Hosts {
+		if h.State != HOST_MONITORED && h.State != HOST_MONITORING_MONITORED {
+			continue
+		}
+		free = append(free, Capacity{Cpu: h.MaxCpu - h.CpuUsage, Memory: h.MaxMem - h.MemUsage})
+	}
+	return free
+}
+
+// Capacity returns what each of the hosts of the node has free.
+func (c *Cluster) Capacity(nodeo Node) ([]Capacity, error) {
+	n, err := c.getNodeByObject(nodeo)
+	if err != nil {
+		return nil, err
+	}
+	res, err := n.Client.Call(HOSTPOOL_INFO, []interface{}{n.Client.Key})
+	if err != nil {
+		return nil, wrapError(n, err)
+	}
+	value, err := response(res)
+	if err != nil {
+		return nil, fmt.Errorf("no host pool info of node %s: %s", n.addr, err)
+	}
+	body, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unreadable host pool info of node %s", n.addr)
+	}
+	pool := &hostPool{}
+	if err := xml.Unmarshal([]byte(body), pool); err != nil {
+		return nil, err
+	}
+	return pool.free(), nil
+}
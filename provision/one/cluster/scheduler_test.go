@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"github.com/megamsys/opennebula-go/compute"
+)
+
+func TestPickMostFreeMemory(t *testing.T) {
+	nodes := NodeList{{Address: "a"}, {Address: "b"}, {Address: "c"}}
+	free := [][]Capacity{{{Cpu: 800, Memory: 4096}}, {{Cpu: 100, Memory: 8192}}, {{Cpu: 400, Memory: 8192}}}
+	n, err := pick(nodes, free, Capacity{Cpu: 100, Memory: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Address != "c" {
+		t.Errorf("pick: want c, got %s", n.Address)
+	}
+	_, err = pick(nodes, free, Capacity{Cpu: 900, Memory: 1024})
+	if err != ErrNoCapacity {
+		t.Errorf("pick: want %v, got %v", ErrNoCapacity, err)
+	}
+}
+
+func TestPickAHostWhichFits(t *testing.T) {
+	nodes := NodeList{{Address: "a"}, {Address: "b"}}
+	free := [][]Capacity{
+		{{Cpu: 300, Memory: 4096}, {Cpu: 300, Memory: 4096}},
+		{{Cpu: 100, Memory: 1024}, {Cpu: 400, Memory: 2048}},
+	}
+	n, err := pick(nodes, free, Capacity{Cpu: 400, Memory: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Address != "b" {
+		t.Errorf("pick: want b, got %s", n.Address)
+	}
+	_, err = pick(nodes, free, Capacity{Cpu: 500, Memory: 1024})
+	if err != ErrNoCapacity {
+		t.Errorf("pick: want %v, got %v", ErrNoCapacity, err)
+	}
+}
+
+func TestWants(t *testing.T) {
+	got := wants(compute.VirtualMachine{Cpu: "1.5", Memory: "2048"})
+	if want := (Capacity{Cpu: 150, Memory: 2048 * 1024}); got != want {
+		t.Errorf("wants: want %v, got %v", want, got)
+	}
+}
+
+func TestMatching(t *testing.T) {
+	nodes := NodeList{
+		{Address: "c", Metadata: map[string]string{ZONE: "plano01", LABELS: "storage=ssd, gpu=true"}},
+		{Address: "b", Metadata: map[string]string{ZONE: "dallas01", LABELS: "storage=ssd"}},
+		{Address: "a", Metadata: map[string]string{ZONE: "plano01"}},
+	}
+	addrs := func(nl NodeList) []string {
+		a := []string{}
+		for _, n := range nl {
+			a = append(a, n.Address)
+		}
+		return a
+	}
+	tests := []struct {
+		opts SchedulerOptions
+		want []string
+	}{
+		{SchedulerOptions{}, []string{"a", "b", "c"}},
+		{SchedulerOptions{Zone: "plano01"}, []string{"a", "c"}},
+		{SchedulerOptions{Labels: map[string]string{"storage": "ssd"}}, []string{"b", "c"}},
+		{SchedulerOptions{Zone: "plano01", Labels: map[string]string{"gpu": "true"}}, []string{"c"}},
+		{SchedulerOptions{Zone: "plano01", Exclude: []string{"a"}}, []string{"c"}},
+		{SchedulerOptions{Zone: "austin01"}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := addrs(nodes.matching(tt.opts)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matching(%+v): want %v, got %v", tt.opts, tt.want, got)
+		}
+	}
+}
+
+func TestApart(t *testing.T) {
+	nodes := NodeList{{Address: "a"}, {Address: "b"}, {Address: "c"}}
+	got := []string{}
+	for _, n := range nodes.apart([]string{"a", "c", "x"}) {
+		got = append(got, n.Address)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("apart: want %v, got %v", want, got)
+	}
+	if left := nodes.apart(nil); len(left) != 3 {
+		t.Errorf("apart of none: want 3 nodes, got %d", len(left))
+	}
+}
+
+func TestHostPoolFree(t *testing.T) {
+	body := `<HOST_POOL>
+<HOST><STATE>2</STATE><HOST_SHARE><MAX_CPU>800</MAX_CPU><CPU_USAGE>200</CPU_USAGE><MAX_MEM>8192</MAX_MEM><MEM_USAGE>1024</MEM_USAGE></HOST_SHARE></HOST>
+<HOST><STATE>4</STATE><HOST_SHARE><MAX_CPU>800</MAX_CPU><CPU_USAGE>0</CPU_USAGE><MAX_MEM>8192</MAX_MEM><MEM_USAGE>0</MEM_USAGE></HOST_SHARE></HOST>
+</HOST_POOL>`
+	pool := &hostPool{}
+	if err := xml.Unmarshal([]byte(body), pool); err != nil {
+		t.Fatal(err)
+	}
+	if want := []Capacity{{Cpu: 600, Memory: 7168}}; !reflect.DeepEqual(pool.free(), want) {
+		t.Errorf("free: want %v, got %v", want, pool.free())
+	}
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/megamsys/vertice/provision/nodes"
+	"github.com/megamsys/vertice/provision/nodes/nodestest"
+)
+
+func newScyllaStorage() (*ScyllaStorage, *nodestest.Rows) {
+	s := NewScyllaStorage(nil, "vertice")
+	rows := nodestest.NewRows()
+	s.Rows = rows
+	return s, rows
+}
+
+func TestNodeRowRoundTrip(t *testing.T) {
+	s, _ := newScyllaStorage()
+	n := Node{
+		Address:        "http://plano:2633/RPC2",
+		Metadata:       map[string]string{"zone": "plano01", "labels": "storage=ssd,gpu=true", "Failures": "2"},
+		CreationStatus: NodeCreationStatusCreated,
+	}
+	if err := s.StoreNode(n); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.RetrieveNode(n.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, n) {
+		t.Errorf("round trip: want %#v, got %#v", n, got)
+	}
+	if err := s.StoreNode(n); err != ErrDuplicatedNodeAddress {
+		t.Errorf("store again: want %v, got %v", ErrDuplicatedNodeAddress, err)
+	}
+}
+
+func TestScyllaStorageKeepsTheNodesOnAFailedRead(t *testing.T) {
+	s, rows := newScyllaStorage()
+	if err := s.StoreNode(Node{Address: "http://plano:2633/RPC2"}); err != nil {
+		t.Fatal(err)
+	}
+	rows.FailReads(errors.New("timed out"))
+	if err := s.StoreNode(Node{Address: "http://dallas:2633/RPC2"}); err == nil {
+		t.Error("store on a failed read: want an error")
+	}
+	if _, err := s.RetrieveNode("http://plano:2633/RPC2"); err == nil || err == ErrNoSuchNode {
+		t.Errorf("retrieve on a failed read: want the read error, got %v", err)
+	}
+	rows.FailReads(nil)
+	all, err := s.RetrieveNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Address != "http://plano:2633/RPC2" {
+		t.Errorf("nodes after a failed read: got %v", all)
+	}
+}
+
+func TestNewMigratesStoredNodes(t *testing.T) {
+	storage := &MapStorage{}
+	err := storage.StoreNode(Node{
+		Address:  "http://plano:2633/RPC2",
+		Metadata: map[string]string{"password": "old", "zone": "plano01", "Failures": "2", "DisabledUntil": "2016-10-17T10:00:00Z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(storage, Node{
+		Address:  "http://plano:2633/RPC2",
+		Metadata: map[string]string{"password": "new", "labels": "storage=ssd"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := storage.RetrieveNode("http://plano:2633/RPC2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"password": "new", "labels": "storage=ssd", "Failures": "2", "DisabledUntil": "2016-10-17T10:00:00Z", nodes.CONFIG_KEYS: "labels,password"}
+	if !reflect.DeepEqual(n.Metadata, want) {
+		t.Errorf("migrated metadata: want %v, got %v", want, n.Metadata)
+	}
+}
@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	VM_DISKSNAPSHOT_CREATE = "one.vm.disksnapshotcreate"
+	VM_DISKSNAPSHOT_REVERT = "one.vm.disksnapshotrevert"
+	VM_DISKSNAPSHOT_DELETE = "one.vm.disksnapshotdelete"
+)
+
+// SnapshotVM takes a snapshot of the first disk of a vm in the node addr,
+// with the vm powered off. It returns the id of the snapshot in one.
+func (c *Cluster) SnapshotVM(ctx context.Context, addr, vmid, name string) (string, error) {
+	n, id, err := c.vmNode(addr, vmid)
+	if err != nil {
+		return "", err
+	}
+	var snapId string
+	err = c.offline(ctx, n, id, func(_ *vmInfo) error {
+		res, err := n.Client.Call(VM_DISKSNAPSHOT_CREATE, []interface{}{n.Client.Key, id, 0, name})
+		if err != nil {
+			return wrapErrorWithCmd(n, err, "snapshotVM")
+		}
+		value, err := response(res)
+		if err != nil {
+			return fmt.Errorf("no snapshot id of vm %d: %s", id, err)
+		}
+		snapId = fmt.Sprintf("%v", value)
+		return nil
+	})
+	return snapId, err
+}
+
+// RevertVM reverts the first disk of a vm in the node addr to the snapshot,
+// with the vm powered off.
+func (c *Cluster) RevertVM(ctx context.Context, addr, vmid, snapId string) error {
+	n, id, err := c.vmNode(addr, vmid)
+	if err != nil {
+		return err
+	}
+	sid, err := strconv.Atoi(snapId)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot id %q", snapId)
+	}
+	return c.offline(ctx, n, id, func(_ *vmInfo) error {
+		_, err := n.Client.Call(VM_DISKSNAPSHOT_REVERT, []interface{}{n.Client.Key, id, 0, sid})
+		return wrapErrorWithCmd(n, err, "revertVM")
+	})
+}
+
+// DeleteVMSnapshot deletes the snapshot of the first disk of a vm in the node addr.
+func (c *Cluster) DeleteVMSnapshot(addr, vmid, snapId string) error {
+	n, id, err := c.vmNode(addr, vmid)
+	if err != nil {
+		return err
+	}
+	sid, err := strconv.Atoi(snapId)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot id %q", snapId)
+	}
+	_, err = n.Client.Call(VM_DISKSNAPSHOT_DELETE, []interface{}{n.Client.Key, id, 0, sid})
+	return wrapErrorWithCmd(n, err, "deleteVMSnapshot")
+}
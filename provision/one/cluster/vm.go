@@ -0,0 +1,279 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	VM_INFO   = "one.vm.info"
+	VM_ACTION = "one.vm.action"
+
+	//the states of a vm in one which matter to the operations on its disk.
+	VM_ACTIVE   = 3
+	VM_FAILED   = 7
+	VM_POWEROFF = 8
+
+	//the lcm states of an active vm which a deploy waits upon.
+	LCM_RUNNING = 3
+	LCM_FAILURE = 14
+	LCM_UNKNOWN = 16
+)
+
+var (
+	// PoweroffTimeout is how long a running vm is waited upon to power off
+	// ahead of an operation which needs it off, eg: a resize.
+	PoweroffTimeout = 3 * time.Minute
+
+	// StateInterval is how often a vm is polled, till it is in the state an
+	// operation waits for.
+	StateInterval = 2 * time.Second
+
+	// RunningInterval is how often a vm being deployed is polled, till it is
+	// running with an ip or else RunningTimeout is past.
+	RunningInterval = 5 * time.Second
+	RunningTimeout  = 10 * time.Minute
+)
+
+//the names of the lcm states, as one tells them.
+var lcmStates = map[int]string{
+	0:  "LCM_INIT",
+	1:  "PROLOG",
+	2:  "BOOT",
+	3:  "RUNNING",
+	4:  "MIGRATE",
+	5:  "SAVE_STOP",
+	6:  "SAVE_SUSPEND",
+	7:  "SAVE_MIGRATE",
+	8:  "PROLOG_MIGRATE",
+	9:  "PROLOG_RESUME",
+	10: "EPILOG_STOP",
+	11: "EPILOG",
+	12: "SHUTDOWN",
+	14: "FAILURE",
+	15: "CLEANUP_RESUBMIT",
+	16: "UNKNOWN",
+	17: "HOTPLUG",
+	18: "SHUTDOWN_POWEROFF",
+	19: "BOOT_UNKNOWN",
+	20: "BOOT_POWEROFF",
+	21: "BOOT_SUSPENDED",
+	22: "BOOT_STOPPED",
+}
+
+func lcmState(s int) string {
+	if name, ok := lcmStates[s]; ok {
+		return name
+	}
+	return strconv.Itoa(s)
+}
+
+//the names of the states of a vm, as one tells them.
+var vmStates = map[int]string{
+	0: "INIT",
+	1: "PENDING",
+	2: "HOLD",
+	3: "ACTIVE",
+	4: "STOPPED",
+	5: "SUSPENDED",
+	6: "DONE",
+	7: "FAILED",
+	8: "POWEROFF",
+	9: "UNDEPLOYED",
+}
+
+func vmState(s int) string {
+	if name, ok := vmStates[s]; ok {
+		return name
+	}
+	return strconv.Itoa(s)
+}
+
+//the parts of the vm info we read.
+type vmInfo struct {
+	State    int       `xml:"STATE"`
+	LcmState int       `xml:"LCM_STATE"`
+	Disk     string    `xml:"TEMPLATE>DISK>SIZE"`
+	IPs      []string  `xml:"TEMPLATE>NIC>IP"`
+	Context  vmContext `xml:"TEMPLATE>CONTEXT"`
+}
+
+func (vm *vmInfo) hasIP() bool {
+	for _, ip := range vm.IPs {
+		if len(strings.TrimSpace(ip)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//the lcm state of an active vm, else its state.
+func (vm *vmInfo) state() string {
+	if vm.State != VM_ACTIVE {
+		return vmState(vm.State)
+	}
+	return lcmState(vm.LcmState)
+}
+
+//whether the vm is running with an ip, a vm which failed or whose state is
+//unknown is an error.
+func (vm *vmInfo) running(id int) (bool, error) {
+	switch {
+	case vm.State == VM_FAILED:
+		return false, fmt.Errorf("vm %d failed", id)
+	case vm.State == VM_ACTIVE && (vm.LcmState == LCM_FAILURE || vm.LcmState == LCM_UNKNOWN):
+		return false, fmt.Errorf("vm %d went into %s, check the host it runs on", id, vm.state())
+	}
+	return vm.State == VM_ACTIVE && vm.LcmState == LCM_RUNNING && vm.hasIP(), nil
+}
+
+//the node (at addr) which owns the vm, along with the vm id as one wants it.
+func (c *Cluster) vmNode(addr, vmid string) (node, int, error) {
+	n, err := c.vmOwner(addr)
+	if err != nil {
+		return node{}, 0, err
+	}
+	id, err := strconv.Atoi(vmid)
+	if err != nil {
+		return node{}, 0, fmt.Errorf("invalid vm id %q", vmid)
+	}
+	return n, id, nil
+}
+
+//runs fn with the vm powered off. A running vm is powered off for it and
+//resumed after, whether fn went through or not. Once ctx is done while
+//waiting on the poweroff, the vm is resumed without running fn.
+func (c *Cluster) offline(ctx context.Context, n node, id int, fn func(vm *vmInfo) error) (err error) {
+	vm, err := c.vmInfo(n, id)
+	if err != nil {
+		return wrapError(n, err)
+	}
+	if vm.State == VM_ACTIVE {
+		if err = c.vmAction(n, "poweroff", id); err != nil {
+			return wrapError(n, err)
+		}
+		defer func() {
+			if rerr := c.vmAction(n, "resume", id); rerr != nil && err == nil {
+				err = wrapError(n, rerr)
+			}
+		}()
+		if err = c.waitVMState(ctx, n, id, VM_POWEROFF, PoweroffTimeout); err != nil {
+			return err
+		}
+	}
+	return fn(vm)
+}
+
+func (c *Cluster) vmInfo(n node, id int) (*vmInfo, error) {
+	res, err := n.Client.Call(VM_INFO, []interface{}{n.Client.Key, id})
+	if err != nil {
+		return nil, err
+	}
+	value, err := response(res)
+	if err != nil {
+		return nil, fmt.Errorf("no info of vm %d: %s", id, err)
+	}
+	body, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unreadable info of vm %d", id)
+	}
+	vm := &vmInfo{}
+	if err := xml.Unmarshal([]byte(body), vm); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+//the value of a response of one, which is [success, value or else the error,
+//error code].
+func response(res []interface{}) (interface{}, error) {
+	if len(res) < 2 {
+		return nil, errors.New("empty response")
+	}
+	if ok, _ := res[0].(bool); !ok {
+		return nil, fmt.Errorf("%v", res[1])
+	}
+	return res[1], nil
+}
+
+func (c *Cluster) vmAction(n node, action string, id int) error {
+	_, err := n.Client.Call(VM_ACTION, []interface{}{n.Client.Key, action, id})
+	return err
+}
+
+// DeleteVM deletes the vm vmid in the node addr, a vm is told apart by its
+// id as a box being replaced has two vms of its name.
+func (c *Cluster) DeleteVM(addr, vmid string) error {
+	n, id, err := c.vmNode(addr, vmid)
+	if err != nil {
+		return err
+	}
+	if err := c.vmAction(n, "terminate-hard", id); err != nil {
+		return wrapError(n, err)
+	}
+	return nil
+}
+
+//polls the vm till it is in the state, or else fails after the timeout or
+//once ctx is done.
+func (c *Cluster) waitVMState(ctx context.Context, n node, id, state int, timeout time.Duration) error {
+	deadline := c.now().Add(timeout)
+	for {
+		vm, err := c.vmInfo(n, id)
+		if err != nil {
+			return wrapError(n, err)
+		}
+		if vm.State == state {
+			return nil
+		}
+		if c.now().After(deadline) {
+			return fmt.Errorf("vm %d didn't reach state %d in %s", id, state, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.Clock.After(StateInterval):
+		}
+	}
+}
+
+// WaitVMRunning polls the vm in the node addr every RunningInterval, till it
+// is running with an ip. Every state it goes through is told to progress.
+// A vm which fails or whose state is unknown aborts the wait, so does one
+// which isn't running once RunningTimeout is past or ctx is done.
+func (c *Cluster) WaitVMRunning(ctx context.Context, addr, vmid string, progress func(string)) error {
+	n, id, err := c.vmNode(addr, vmid)
+	if err != nil {
+		return err
+	}
+	deadline := c.now().Add(RunningTimeout)
+	last := ""
+	for {
+		vm, err := c.vmInfo(n, id)
+		if err != nil {
+			return wrapError(n, err)
+		}
+		state := vm.state()
+		if state != last && progress != nil {
+			progress(state)
+		}
+		last = state
+		if running, err := vm.running(id); running || err != nil {
+			return err
+		}
+		if c.now().After(deadline) {
+			return fmt.Errorf("vm %d isn't running with an ip in %s, it is %s", id, RunningTimeout, state)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.Clock.After(RunningInterval):
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestVMRunning(t *testing.T) {
+	tests := []struct {
+		body    string
+		state   string
+		running bool
+		fails   bool
+	}{
+		{`<VM><STATE>1</STATE><LCM_STATE>0</LCM_STATE></VM>`, "PENDING", false, false},
+		{`<VM><STATE>3</STATE><LCM_STATE>2</LCM_STATE><TEMPLATE><NIC><IP>10.0.0.9</IP></NIC></TEMPLATE></VM>`, "BOOT", false, false},
+		{`<VM><STATE>3</STATE><LCM_STATE>3</LCM_STATE><TEMPLATE><NIC><IP></IP></NIC></TEMPLATE></VM>`, "RUNNING", false, false},
+		{`<VM><STATE>3</STATE><LCM_STATE>3</LCM_STATE><TEMPLATE><NIC><IP></IP></NIC><NIC><IP>10.0.0.9</IP></NIC></TEMPLATE></VM>`, "RUNNING", true, false},
+		{`<VM><STATE>3</STATE><LCM_STATE>14</LCM_STATE></VM>`, "FAILURE", false, true},
+		{`<VM><STATE>3</STATE><LCM_STATE>16</LCM_STATE></VM>`, "UNKNOWN", false, true},
+		{`<VM><STATE>7</STATE><LCM_STATE>0</LCM_STATE></VM>`, "FAILED", false, true},
+	}
+	for _, tt := range tests {
+		vm := &vmInfo{}
+		if err := xml.Unmarshal([]byte(tt.body), vm); err != nil {
+			t.Fatal(err)
+		}
+		if vm.state() != tt.state {
+			t.Errorf("state of %s: want %s, got %s", tt.body, tt.state, vm.state())
+		}
+		running, err := vm.running(12)
+		if running != tt.running || (err != nil) != tt.fails {
+			t.Errorf("running of %s: want %v (fails %v), got %v (%v)", tt.body, tt.running, tt.fails, running, err)
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	v, err := response([]interface{}{true, "<VM/>", 0})
+	if err != nil || v != "<VM/>" {
+		t.Errorf("want the value of a success, got %v (%v)", v, err)
+	}
+	_, err = response([]interface{}{false, "[one.vm.info] Error getting virtual machine [12].", 1024})
+	if err == nil || err.Error() != "[one.vm.info] Error getting virtual machine [12]." {
+		t.Errorf("want the error of a failure, got %v", err)
+	}
+	if _, err = response([]interface{}{true}); err == nil {
+		t.Error("want an empty response to fail")
+	}
+}
@@ -37,5 +37,9 @@ func healthCheck() (interface{}, error) {
 	if len(nodes) < 1 {
 		return nil, errors.New("no nodes available for running vm")
 	}
-	return "one " + nodes[0].Address + " ready", nil
+	addrs := make([]string, len(nodes))
+	for i, n := range nodes {
+		addrs[i] = n.Address
+	}
+	return "one " + strings.Join(addrs, ", ") + " ready", nil
 }
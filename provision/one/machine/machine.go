@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -21,6 +23,7 @@ import (
 	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/one/cluster"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -30,6 +33,10 @@ const (
 	CONSUMED     = "Consumed"
 	STARTTIME    = "StartTime"
 	ENDTIME      = "EndTime"
+
+	//the context vars cloud-init reads the key and hostname of the vm from.
+	SSH_PUBLIC_KEY = "SSH_PUBLIC_KEY"
+	SET_HOSTNAME   = "SET_HOSTNAME"
 )
 
 type OneProvisioner interface {
@@ -37,19 +44,22 @@ type OneProvisioner interface {
 }
 
 type Machine struct {
-	Name       string
-	Id         string
-	CartonId   string
-	AccountsId string
-	Level      provision.BoxLevel
-	SSH        provision.BoxSSH
-	Image      string
+	Name         string
+	Id           string
+	Unit         int //the unit of the component, it keeps its outputs indexed by it
+	CartonId     string
+	AccountsId   string
+	Level        provision.BoxLevel
+	SSH          provision.BoxSSH
+	Image        string
 	VCPUThrottle string
-	VMId        string
+	VMId         string
+	VMNode       string //the node (one frontend) the vm is in
+	Region       string //the zone the vm is placed in, any when blank
 	VNCHost      string
 	VNCPort      string
-	Routable   bool
-	Status     utils.Status
+	Routable     bool
+	Status       utils.Status
 }
 
 type CreateArgs struct {
@@ -58,62 +68,119 @@ type CreateArgs struct {
 	Compute     provision.BoxCompute
 	Deploy      bool
 	Provisioner OneProvisioner
+	Writer      io.Writer //where the progress of the machine is logged
 }
 
-
 func (m *Machine) Create(args *CreateArgs) error {
 	log.Infof("  creating machine in one (%s, %s)", m.Name, m.Image)
-ThrottleFactor, _  := strconv.Atoi(m.VCPUThrottle)
-cpuThrottleFactor :=float64(ThrottleFactor)
-res := strconv.FormatInt(int64(args.Box.GetCpushare()), 10)
-ICpu, _ := strconv.Atoi(res)
-throttle := float64(ICpu)
-realCPU :=throttle/cpuThrottleFactor
-opts := compute.VirtualMachine{
+	ThrottleFactor, _ := strconv.Atoi(m.VCPUThrottle)
+	cpuThrottleFactor := float64(ThrottleFactor)
+	res := strconv.FormatInt(int64(args.Box.GetCpushare()), 10)
+	ICpu, _ := strconv.Atoi(res)
+	throttle := float64(ICpu)
+	realCPU := throttle / cpuThrottleFactor
+	opts := compute.VirtualMachine{
 		Name:   m.Name,
 		Image:  m.Image,
-		Cpu:     strconv.FormatFloat(realCPU, 'f', 6, 64),//ugly, compute has the info.
+		Cpu:    strconv.FormatFloat(realCPU, 'f', 6, 64), //ugly, compute has the info.
 		Memory: strconv.FormatInt(int64(args.Box.GetMemory()), 10),
 		HDD:    strconv.FormatInt(int64(args.Box.GetHDD()), 10),
 		ContextMap: map[string]string{compute.ASSEMBLY_ID: args.Box.CartonId,
 			compute.ASSEMBLIES_ID: args.Box.CartonsId},
+	}
+	for k, v := range boxContext(args.Box) {
+		opts.ContextMap[k] = v
+	}
+	sopts := cluster.SchedulerOptions{Zone: m.Region, Labels: args.Box.Labels}
+	if len(args.Box.AntiAffinity) > 0 {
+		//the vms of a group are placed one by one, each one apart from the ones placed before.
+		unlock := placing(args.Box)
+		defer unlock()
+		apart, err := apartNodes(args.Box)
+		if err != nil {
+			return err
 		}
-	//m.addEnvsToContext(m.BoxEnvs, &vm)
- _,	_, vmid, err := args.Provisioner.Cluster().CreateVM(opts)
+		sopts.Apart = apart
+	}
+	addr, _, vmid, err := args.Provisioner.Cluster().CreateVM(args.Box.Context(), opts, sopts)
 	if err != nil {
 		return err
 	}
 	m.VMId = vmid
+	m.VMNode = addr
 
 	var id = make(map[string][]string)
-		vm := []string{}
-		vm = []string{m.VMId}
-	 id[carton.VMID] = vm
-		if asm, err := carton.NewAmbly(m.CartonId); err != nil {
-			return err
-		} else if err = asm.NukeAndSetOutputs(id); err != nil {
-			return err
-		}
+	vm := []string{}
+	vm = []string{m.VMId}
+	id[provision.UnitKey(carton.VMID, m.Unit)] = vm
+	id[provision.UnitKey(carton.VMNODE, m.Unit)] = []string{m.VMNode}
+	if asm, err := carton.NewAmbly(m.CartonId); err != nil {
+		return err
+	} else if err = asm.NukeAndSetOutputs(id); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (m *Machine) VmHostIpPort(args *CreateArgs) error {
+var (
+	placingMu sync.Mutex
+	groups    = make(map[string]*sync.Mutex)
+)
 
-	//time.Sleep(time.Second * 25)
+//locks the anti-affinity group of the box in its assembly, till the unlock
+//returned is called.
+func placing(b *provision.Box) func() {
+	key := b.CartonId + "/" + b.AntiAffinity
+	placingMu.Lock()
+	mu, ok := groups[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		groups[key] = mu
+	}
+	placingMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
 
-  var Wait int
-    ch := make(chan int)
-		for i := 0; i < 100; i++ {
-		    go player(ch)
+//the nodes of the other vms of the assembly of the box (its other units),
+//which its vm is kept apart from.
+func apartNodes(b *provision.Box) ([]string, error) {
+	asm, err := carton.NewAssembly(b.CartonId)
+	if err != nil {
+		return nil, err
+	}
+	own := provision.UnitKey(carton.VMNODE, b.Unit)
+	nodes := make([]string, 0)
+	for _, o := range asm.Outputs {
+		if o.K == own || len(strings.TrimSpace(o.V)) == 0 {
+			continue
+		}
+		if o.K == carton.VMNODE || strings.HasPrefix(o.K, carton.VMNODE+"-") {
+			nodes = append(nodes, o.V)
 		}
-    ch <- Wait
-    time.Sleep(25 * time.Second)
-    <-ch
-
-   opts := virtualmachine.Vnc {
-		VmId:   m.VMId,
-			}
- 	vnchost, vncport, err := args.Provisioner.Cluster().GetIpPort(opts)
+	}
+	return nodes, nil
+}
+
+//waits on the vm till it is running with an ip, telling the box log of the
+//states it goes through, and then reads its vnc host and port.
+func (m *Machine) VmHostIpPort(args *CreateArgs) error {
+	w := args.Writer
+	if w == nil {
+		w = ioutil.Discard
+	}
+	err := args.Provisioner.Cluster().WaitVMRunning(args.Box.Context(), m.VMNode, m.VMId, func(state string) {
+		fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("  machine %s is %s", m.Name, state)))
+	})
+	if err != nil {
+		fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.ERROR, fmt.Sprintf("  machine %s isn't running %s", m.Name, err)))
+		return err
+	}
+
+	opts := virtualmachine.Vnc{
+		VmId: m.VMId,
+	}
+	vnchost, vncport, err := args.Provisioner.Cluster().GetIpPort(args.Box.Context(), m.VMNode, opts)
 	if err != nil {
 		return err
 	}
@@ -122,50 +189,50 @@ func (m *Machine) VmHostIpPort(args *CreateArgs) error {
 	return nil
 }
 
-func player(ch chan int) {
-    for {
-        wait := <-ch
-        wait++
-        time.Sleep(150 * time.Millisecond)
-        ch <- wait
-    }
-}
-
 func (m *Machine) UpdateVncHost() error {
 
 	var vnchost = make(map[string][]string)
-		host := []string{}
-		host = []string{m.VNCHost}
-	 vnchost[carton.VNCHOST] = host
-		if asm, err := carton.NewAmbly(m.CartonId); err != nil {
-			return err
-		} else if err = asm.NukeAndSetOutputs(vnchost); err != nil {
-			return err
-		}
-		return nil
+	host := []string{}
+	host = []string{m.VNCHost}
+	vnchost[provision.UnitKey(carton.VNCHOST, m.Unit)] = host
+	if asm, err := carton.NewAmbly(m.CartonId); err != nil {
+		return err
+	} else if err = asm.NukeAndSetOutputs(vnchost); err != nil {
+		return err
+	}
+	return nil
 }
 
 func (m *Machine) UpdateVncPort() error {
 
 	var vncport = make(map[string][]string)
-		port := []string{}
-		port = []string{m.VNCPort}
-	 vncport[carton.VNCPORT] = port
-		if asm, err := carton.NewAmbly(m.CartonId); err != nil {
-			return err
-		} else if err = asm.NukeAndSetOutputs(vncport); err != nil {
-			return err
-		}
-		return nil
+	port := []string{}
+	port = []string{m.VNCPort}
+	vncport[provision.UnitKey(carton.VNCPORT, m.Unit)] = port
+	if asm, err := carton.NewAmbly(m.CartonId); err != nil {
+		return err
+	} else if err = asm.NukeAndSetOutputs(vncport); err != nil {
+		return err
+	}
+	return nil
 }
 
+//removes the vm of the machine, the one it was created with when known or
+//else the one of its name.
 func (m *Machine) Remove(p OneProvisioner) error {
 	log.Debugf("  removing machine in one (%s)", m.Name)
+	if len(m.VMId) > 0 {
+		return p.Cluster().DeleteVM(m.VMNode, m.VMId)
+	}
 	opts := compute.VirtualMachine{
 		Name: m.Name,
 	}
+	addr, _, err := m.vm()
+	if err != nil {
+		return err
+	}
 
-	err := p.Cluster().DestroyVM(opts)
+	err = p.Cluster().DestroyVM(addr, opts)
 	if err != nil {
 		return err
 	}
@@ -202,12 +269,114 @@ func (m *Machine) Deduct() error {
 	return newEvent.Write()
 }
 
+// Resize resizes the vm of the machine to the compute of the box, the cpu
+// is throttled as it was on create.
+func (m *Machine) Resize(p OneProvisioner, b *provision.Box) error {
+	log.Infof("  resizing machine in one (%s) to %s", m.Name, b.Compute.String())
+	addr, vmid, err := m.vm()
+	if err != nil {
+		return err
+	}
+	throttle, _ := strconv.ParseFloat(m.VCPUThrottle, 64)
+	if throttle <= 0 {
+		throttle = 1
+	}
+	opts := cluster.ResizeOpts{
+		VMId:   vmid,
+		Cpu:    strconv.FormatFloat(float64(b.GetCpushare())/throttle, 'f', 6, 64),
+		VCpu:   strconv.FormatUint(b.GetCpushare(), 10),
+		Memory: strconv.FormatUint(b.GetMemory(), 10),
+		HDD:    strconv.FormatUint(b.GetHDD(), 10),
+	}
+	return p.Cluster().ResizeVM(b.Context(), addr, opts)
+}
+
+// Snapshot takes a disk snapshot of the vm of the machine, it returns the
+// id of the snapshot in one.
+func (m *Machine) Snapshot(ctx context.Context, p OneProvisioner, name string) (string, error) {
+	log.Infof("  snapshotting machine in one (%s, %s)", m.Name, name)
+	addr, vmid, err := m.vm()
+	if err != nil {
+		return "", err
+	}
+	return p.Cluster().SnapshotVM(ctx, addr, vmid, name)
+}
+
+// Restore reverts the disk of the vm of the machine to the snapshot.
+func (m *Machine) Restore(ctx context.Context, p OneProvisioner, snapId string) error {
+	log.Infof("  restoring machine in one (%s) from %s", m.Name, snapId)
+	addr, vmid, err := m.vm()
+	if err != nil {
+		return err
+	}
+	return p.Cluster().RevertVM(ctx, addr, vmid, snapId)
+}
+
+// DeleteSnapshot deletes the disk snapshot of the vm of the machine, a vm
+// which was destroyed took its snapshots along.
+func (m *Machine) DeleteSnapshot(p OneProvisioner, snapId string) error {
+	log.Infof("  deleting snapshot %s of machine in one (%s)", snapId, m.Name)
+	addr, vmid, err := m.vm()
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(vmid)) == 0 {
+		return nil
+	}
+	return p.Cluster().DeleteVMSnapshot(addr, vmid, snapId)
+}
+
+//the node which owns the vm and the id of the vm in one, as saved in the
+//outputs (of the unit) once created.
+func (m *Machine) vm() (string, string, error) {
+	asm, err := carton.NewAssembly(m.CartonId)
+	if err != nil {
+		return "", "", err
+	}
+	return asm.Outputs.Match(provision.UnitKey(carton.VMNODE, m.Unit)), asm.Outputs.Match(provision.UnitKey(carton.VMID, m.Unit)), nil
+}
+
+// VM returns the node which owns the vm of the machine and its id, blank
+// when it has none yet.
+func (m *Machine) VM() (string, string, error) {
+	return m.vm()
+}
+
+// PutVM saves the vm in the node addr as the one of the machine, eg: the vm
+// it had before a replace which failed.
+func (m *Machine) PutVM(addr, vmid string) error {
+	asm, err := carton.NewAmbly(m.CartonId)
+	if err != nil {
+		return err
+	}
+	return asm.NukeAndSetOutputs(map[string][]string{
+		provision.UnitKey(carton.VMID, m.Unit):   []string{vmid},
+		provision.UnitKey(carton.VMNODE, m.Unit): []string{addr},
+	})
+}
+
+// Restart boots the vm of the machine again with the envs, ssh key and
+// hostname of the box put in its context, so that the ones updated since
+// the create are picked up.
+func (m *Machine) Restart(p OneProvisioner, b *provision.Box) error {
+	log.Debugf("  restart machine in one (%s)", m.Name)
+	addr, vmid, err := m.vm()
+	if err != nil {
+		return err
+	}
+	return p.Cluster().RebootVMWithContext(b.Context(), addr, vmid, boxContext(b))
+}
+
 func (m *Machine) LifecycleOps(p OneProvisioner, action string) error {
 	log.Debugf("  %s machine in one (%s)", action, m.Name)
 	opts := compute.VirtualMachine{
 		Name: m.Name,
 	}
-	err := p.Cluster().VM(opts, action)
+	addr, _, err := m.vm()
+	if err != nil {
+		return err
+	}
+	err = p.Cluster().VM(addr, opts, action)
 	if err != nil {
 		return err
 	}
@@ -218,6 +387,14 @@ func (m *Machine) LifecycleOps(p OneProvisioner, action string) error {
 func (m *Machine) SetStatus(status utils.Status) error {
 	log.Debugf("  set status[%s] of machine (%s, %s)", m.Id, m.Name, status.String())
 
+	if m.Unit > 0 { //the assembly and the component tell the first unit, the others have their own.
+		comp, err := carton.NewComponent(m.Id)
+		if err != nil {
+			return err
+		}
+		return comp.SetUnitStatus(m.Unit, status)
+	}
+
 	if asm, err := carton.NewAmbly(m.CartonId); err != nil {
 		return err
 	} else if err = asm.SetStatus(status); err != nil {
@@ -296,13 +473,69 @@ func (m *Machine) SetRoutable(ip string) {
 	m.Routable = (len(strings.TrimSpace(ip)) > 0)
 }
 
-func (m *Machine) addEnvsToContext(envs string, cfg *compute.VirtualMachine) {
-	/*
-		for _, envData := range envs {
-			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, envData.Value))
+//the context vars of the vm of a box, read by the contextualization (or
+//cloud-init) in it: the envs of the box, the public ssh key and the hostname.
+//The names of the envs are listed in cluster.CONTEXT_ENVS, so that the ones
+//removed from the box are dropped on a restart.
+func boxContext(b *provision.Box) map[string]string {
+	vars := make(map[string]string)
+	names := []string{}
+	for _, env := range b.Envs {
+		name := strings.TrimSpace(env.Name)
+		if !validContextName(name) || reservedContextName(name) {
+			log.Warningf("  skipping the env %q of %s, it can't go in the vm context", env.Name, b.GetFullName())
+			continue
 		}
-			cfg.Env = append(cfg.Env, []string{
-				fmt.Sprintf("%s=%s", "MEGAM_HOST", host),
-			}...)
-	*/
+		vars[name] = env.Value
+		names = append(names, name)
+	}
+	vars[cluster.CONTEXT_ENVS] = strings.Join(names, " ")
+	if key := strings.TrimSpace(b.SSH.PublicKey); len(key) > 0 {
+		vars[SSH_PUBLIC_KEY] = key
+	}
+	if host := hostname(b.GetFullName()); len(host) > 0 {
+		vars[SET_HOSTNAME] = host
+	}
+	return vars
+}
+
+//the context vars which vertice or one set, an env can't override them.
+var reservedContextNames = []string{SSH_PUBLIC_KEY, SET_HOSTNAME, cluster.CONTEXT_ENVS,
+	"NETWORK", "TARGET", "FILES_DS", compute.ASSEMBLY_ID, compute.ASSEMBLIES_ID}
+
+func reservedContextName(name string) bool {
+	for _, r := range reservedContextNames {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+//a context var is named by letters, digits and _ and can't start with a digit.
+func validContextName(name string) bool {
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+//the name as a hostname, lower case with the chars a hostname can't have
+//turned to -.
+func hostname(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	h := make([]rune, 0, len(name))
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			h = append(h, r)
+		} else {
+			h = append(h, '-')
+		}
+	}
+	return strings.Trim(string(h), "-.")
 }
@@ -6,6 +6,9 @@ import (
 		"github.com/megamsys/vertice/provision"
 		"github.com/megamsys/vertice/provision/provisiontest"
 		"github.com/megamsys/opennebula-go/compute" */
+	"github.com/megamsys/vertice/carton/bind"
+	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/provision/one/cluster"
 	"gopkg.in/check.v1"
 )
 
@@ -68,3 +71,22 @@ func (s *S) TestMachineLogs(c *check.C) {
 		c.Assert(buff.String(), check.Not(check.Equals), "")
 }
 */
+
+func (s *S) TestBoxContext(c *check.C) {
+	b := &provision.Box{
+		CartonName: "Alpha_01",
+		DomainName: "megambox.com",
+		SSH:        provision.BoxSSH{PublicKey: "ssh-rsa AAAA me@plano\n"},
+		Envs: []bind.EnvVar{
+			bind.EnvVar{Name: "DB_HOST", Value: "10.0.0.9"},
+			bind.EnvVar{Name: "db-port", Value: "5432"},
+			bind.EnvVar{Name: "ssh_public_key", Value: "ssh-rsa BBBB"},
+		},
+	}
+	c.Assert(boxContext(b), check.DeepEquals, map[string]string{
+		"DB_HOST":            "10.0.0.9",
+		cluster.CONTEXT_ENVS: "DB_HOST",
+		SSH_PUBLIC_KEY:       "ssh-rsa AAAA me@plano",
+		SET_HOSTNAME:         "alpha-01.megambox.com",
+	})
+}
@@ -0,0 +1,81 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+
+package one
+
+import (
+	"time"
+
+	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/vertice/provision"
+	"github.com/megamsys/vertice/provision/one/cluster"
+)
+
+//the frontends, with and without health.
+func (p *oneProvisioner) Nodes() ([]provision.Node, error) {
+	nodes, err := p.Cluster().UnfilteredNodes()
+	if err != nil {
+		return nil, err
+	}
+	pn := make([]provision.Node, len(nodes))
+	for i, n := range nodes {
+		md := make(map[string]string, len(n.Metadata))
+		for k, v := range n.Metadata {
+			if k != api.PASSWORD {
+				md[k] = v
+			}
+		}
+		pn[i] = provision.Node{Address: n.Address, Metadata: md, Status: n.Status()}
+	}
+	return pn, nil
+}
+
+//adds a frontend, its template, image and vcpu percentage are the ones of
+//the main frontend unless set.
+func (p *oneProvisioner) AddNode(address string, metadata map[string]string) error {
+	md := withDefaults(metadata, p.main)
+	md[api.ENDPOINT] = address
+	err := p.Cluster().Register(cluster.Node{Address: address, Metadata: md})
+	if err == cluster.ErrDuplicatedNodeAddress {
+		return provision.ErrNodeExists
+	}
+	return err
+}
+
+func (p *oneProvisioner) RemoveNode(address string) error {
+	err := p.Cluster().Unregister(address)
+	if err == cluster.ErrNoSuchNode {
+		return provision.ErrNodeNotFound
+	}
+	return err
+}
+
+//the latest probes of a node, the oldest first.
+func (p *oneProvisioner) NodeHealth(address string) ([]provision.NodeHealth, error) {
+	if _, err := p.Cluster().GetNode(address); err != nil {
+		return nil, provision.ErrNodeNotFound
+	}
+	return p.Cluster().HealthHistory(address), nil
+}
+
+//probes the nodes every interval, none when zero.
+func (p *oneProvisioner) MonitorNodes(interval time.Duration) {
+	if interval <= 0 {
+		p.Cluster().StopActiveMonitoring()
+		return
+	}
+	p.Cluster().StartActiveMonitoring(interval)
+}
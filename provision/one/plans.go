@@ -0,0 +1,132 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package one
+
+import (
+	"github.com/megamsys/libgo/action"
+	"github.com/megamsys/vertice/provision"
+)
+
+//the pipelines of the operations on a box, each one but the destroy stops
+//once the operation is cancelled. A destroy stopped half way would leave the
+//box stuck, so it runs to its end.
+var (
+	deployActions = cancellable(
+		&updateStatusInScylla,
+		&createMachine,
+		&updateStatusInScylla,
+		&getVmHostIpPort,
+		&updateVnchostInScylla,
+		&updateVncportInScylla,
+		&updateStatusInScylla,
+		&deductCons,
+		&followLogs,
+	)
+
+	replaceActions = cancellable(
+		&updateStatusInScylla,
+		&createMachine,
+		&updateStatusInScylla,
+		&getVmHostIpPort,
+		&updateVnchostInScylla,
+		&updateVncportInScylla,
+		&updateStatusInScylla,
+		&deductCons,
+		&destroyReplacedMachine,
+		&followLogs,
+	)
+
+	destroyActions = []*action.Action{
+		&updateStatusInScylla,
+		&destroyOldMachine,
+		&destroyOldRoute,
+	}
+
+	stateActions = cancellable(
+		&changeStateofMachine,
+		&addNewRoute,
+	)
+
+	restartActions = cancellable(
+		&updateStatusInScylla,
+		&restartMachine,
+		&updateStatusInScylla,
+	)
+
+	startActions = cancellable(
+		&updateStatusInScylla,
+		&startMachine,
+		&updateStatusInScylla,
+	)
+
+	stopActions = cancellable(
+		&updateStatusInScylla,
+		&stopMachine,
+		&updateStatusInScylla,
+	)
+
+	resizeActions = cancellable(
+		&updateStatusInScylla,
+		&resizeMachine,
+		&updateStatusInScylla,
+	)
+
+	snapshotActions = cancellable(
+		&updateStatusInScylla,
+		&snapshotMachine,
+		&updateStatusInScylla,
+	)
+
+	restoreActions = cancellable(
+		&updateStatusInScylla,
+		&restoreMachine,
+		&updateStatusInScylla,
+	)
+
+	statusActions = cancellable(
+		&updateStatusInScylla,
+	)
+)
+
+var plans = map[string][]*action.Action{
+	provision.OP_DEPLOY:   deployActions,
+	provision.OP_DESTROY:  destroyActions,
+	provision.OP_STATE:    stateActions,
+	provision.OP_RESTART:  restartActions,
+	provision.OP_START:    startActions,
+	provision.OP_STOP:     stopActions,
+	provision.OP_STATUS:   statusActions,
+	provision.OP_RESIZE:   resizeActions,
+	provision.OP_SNAPSHOT: snapshotActions,
+	provision.OP_RESTORE:  restoreActions,
+	provision.OP_REPLACE:  replaceActions,
+}
+
+// PlanActions returns the names of the actions in the pipeline of the operation.
+func (p *oneProvisioner) PlanActions(op string, box *provision.Box) []string {
+	names := make([]string, 0, len(plans[op]))
+	for _, a := range plans[op] {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+//the actions of a pipeline on the box in its args.
+func cancellable(actions ...*action.Action) []*action.Action {
+	return provision.Cancellable(func(ctx action.FWContext) *provision.Box {
+		return ctx.Params[0].(runMachineActionsArgs).box
+	}, actions...)
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -33,8 +34,10 @@ import (
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/opennebula-go/api"
 	lb "github.com/megamsys/vertice/logbox"
+	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/one/cluster"
+	"github.com/megamsys/vertice/provision/one/machine"
 	"github.com/megamsys/vertice/repository"
 	"github.com/megamsys/vertice/router"
 	_ "github.com/megamsys/vertice/router/route53"
@@ -50,6 +53,7 @@ func init() {
 type oneProvisioner struct {
 	defaultImage string
 	vcpuThrottle string
+	main         map[string]string //the settings of the main frontend, the defaults of the others
 	cluster      *cluster.Cluster
 	storage      cluster.Storage
 }
@@ -69,10 +73,12 @@ func (p *oneProvisioner) String() string {
 }
 
 func (p *oneProvisioner) Initialize(m map[string]string, b map[string]string) error {
-	return p.initOneCluster(m)
+	return p.initOneCluster(m, b)
 }
 
-func (p *oneProvisioner) initOneCluster(m map[string]string) error {
+//m is the frontend one_endpoint points to, f has the other frontends with
+//their settings keyed as <name>.<setting>.
+func (p *oneProvisioner) initOneCluster(m map[string]string, f map[string]string) error {
 	var err error
 	if p.storage == nil {
 		p.storage, err = buildClusterStorage()
@@ -82,11 +88,13 @@ func (p *oneProvisioner) initOneCluster(m map[string]string) error {
 	}
 	p.defaultImage = m[api.IMAGE]
 	p.vcpuThrottle = m[api.VCPU_PERCENTAGE]
+	p.main = m
 	var nodes []cluster.Node = []cluster.Node{cluster.Node{
 		Address:  m[api.ENDPOINT],
 		Metadata: m,
 	},
 	}
+	nodes = append(nodes, frontends(f, m)...)
 	//register nodes using the map.
 	p.cluster, err = cluster.New(p.storage, nodes...)
 	if err != nil {
@@ -95,8 +103,57 @@ func (p *oneProvisioner) initOneCluster(m map[string]string) error {
 	return nil
 }
 
+//the nodes of the other frontends, those without an endpoint are skipped.
+func frontends(f map[string]string, m map[string]string) []cluster.Node {
+	byName := make(map[string]map[string]string)
+	for k, v := range f {
+		i := strings.Index(k, ".")
+		if i <= 0 {
+			continue
+		}
+		name := k[:i]
+		if _, ok := byName[name]; !ok {
+			byName[name] = make(map[string]string)
+		}
+		byName[name][k[i+1:]] = v
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nodes := make([]cluster.Node, 0, len(names))
+	for _, name := range names {
+		md := withDefaults(byName[name], m)
+		if len(strings.TrimSpace(md[api.ENDPOINT])) == 0 {
+			log.Warningf("  one frontend %s has no endpoint, skipped", name)
+			continue
+		}
+		nodes = append(nodes, cluster.Node{Address: md[api.ENDPOINT], Metadata: md})
+	}
+	return nodes
+}
+
+//the settings of a frontend, its template, image and vcpu percentage are the
+//ones of the main frontend unless set.
+func withDefaults(md map[string]string, m map[string]string) map[string]string {
+	d := map[string]string{
+		api.TEMPLATE:        m[api.TEMPLATE],
+		api.IMAGE:           m[api.IMAGE],
+		api.VCPU_PERCENTAGE: m[api.VCPU_PERCENTAGE],
+	}
+	for k, v := range md {
+		if len(strings.TrimSpace(v)) > 0 {
+			d[k] = v
+		}
+	}
+	return d
+}
+
+//the nodes are kept in scylla, so that the ones added while running and
+//their health outlive a restart.
 func buildClusterStorage() (cluster.Storage, error) {
-	return &cluster.MapStorage{}, nil
+	return cluster.NewScyllaStorage(meta.MC.Scylla, meta.MC.ScyllaKeyspace), nil
 }
 
 func getRouterForBox(box *provision.Box) (router.Router, error) {
@@ -154,18 +211,7 @@ func (p *oneProvisioner) deployPipeline(box *provision.Box, imageId string, w io
 
 	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- deploy box (%s, image:%s)", box.GetFullName(), imageId)))
 
-	actions := []*action.Action{
-		&updateStatusInScylla,
-		&createMachine,
-		&updateStatusInScylla,
-		&getVmHostIpPort,
-		&updateVnchostInScylla,
-		&updateVncportInScylla,
-		&updateStatusInScylla,
-		&deductCons,
-		&followLogs,
-	}
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(deployActions...)
 
 	args := runMachineActionsArgs{
 		box:           box,
@@ -186,6 +232,53 @@ func (p *oneProvisioner) deployPipeline(box *provision.Box, imageId string, w io
 	return imageId, nil
 }
 
+// Replace deploys a vm of the image for the box and destroys the vm it had
+// once the new one runs. When the deploy fails the box is left with the vm
+// it had.
+func (p *oneProvisioner) Replace(box *provision.Box, imageId string, w io.Writer) (string, error) {
+	if imageId == "" {
+		imageId = p.defaultImage
+		if box.Repo != nil {
+			imageId = p.getBuildImage(box.Repo, box.ImageVersion)
+		}
+	}
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- replace box (%s, image:%s)", box.GetFullName(), imageId)))
+	old := &machine.Machine{
+		Id:       box.Id,
+		Unit:     box.Unit,
+		CartonId: box.CartonId,
+		Name:     box.GetFullName(),
+	}
+	addr, vmid, err := old.VM()
+	if err != nil {
+		return "", err
+	}
+	old.VMNode, old.VMId = addr, vmid
+
+	args := runMachineActionsArgs{
+		box:           box,
+		imageId:       imageId,
+		writer:        w,
+		isDeploy:      true,
+		machineStatus: constants.StatusLaunching,
+		provisioner:   p,
+		replaced:      old,
+	}
+	pipeline := action.NewPipeline(replaceActions...)
+	if err := pipeline.Execute(args); err != nil {
+		fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.ERROR, fmt.Sprintf("--- replace box (%s, image:%s)\n --> %s", box.GetFullName(), imageId, err)))
+		if len(vmid) > 0 {
+			if perr := old.PutVM(addr, vmid); perr != nil {
+				log.Errorf("Failed to put back the vm %s of %q: %s", vmid, box.GetFullName(), perr)
+			}
+		}
+		return "", err
+	}
+
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- replace box (%s, image:%s)OK", box.GetFullName(), imageId)))
+	return imageId, nil
+}
+
 func (p *oneProvisioner) Destroy(box *provision.Box, w io.Writer) error {
 
 	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- destroying box (%s)", box.GetFullName())))
@@ -197,13 +290,7 @@ func (p *oneProvisioner) Destroy(box *provision.Box, w io.Writer) error {
 		provisioner:   p,
 	}
 
-	actions := []*action.Action{
-		&updateStatusInScylla,
-		&destroyOldMachine,
-		&destroyOldRoute,
-	}
-
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(destroyActions...)
 
 	err := pipeline.Execute(args)
 	if err != nil {
@@ -227,12 +314,7 @@ func (p *oneProvisioner) SetState(box *provision.Box, w io.Writer, changeto util
 		provisioner:   p,
 	}
 
-	actions := []*action.Action{
-		&changeStateofMachine,
-		&addNewRoute,
-	}
-
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(stateActions...)
 
 	err := pipeline.Execute(args)
 	if err != nil {
@@ -255,13 +337,7 @@ func (p *oneProvisioner) Restart(box *provision.Box, process string, w io.Writer
 		provisioner:   p,
 	}
 
-	actions := []*action.Action{
-		&updateStatusInScylla,
-		&restartMachine,
-		&updateStatusInScylla,
-	}
-
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(restartActions...)
 
 	err := pipeline.Execute(args)
 	if err != nil {
@@ -285,13 +361,7 @@ func (p *oneProvisioner) Start(box *provision.Box, process string, w io.Writer)
 		provisioner:   p,
 	}
 
-	actions := []*action.Action{
-		&updateStatusInScylla,
-		&startMachine,
-		&updateStatusInScylla,
-	}
-
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(startActions...)
 
 	err := pipeline.Execute(args)
 	if err != nil {
@@ -314,13 +384,7 @@ func (p *oneProvisioner) Stop(box *provision.Box, process string, w io.Writer) e
 		machineStatus: constants.StatusStopping,
 		provisioner:   p,
 	}
-	actions := []*action.Action{
-		&updateStatusInScylla,
-		&stopMachine,
-		&updateStatusInScylla,
-	}
-
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(stopActions...)
 
 	err := pipeline.Execute(args)
 	if err != nil {
@@ -333,6 +397,91 @@ func (p *oneProvisioner) Stop(box *provision.Box, process string, w io.Writer) e
 	return nil
 }
 
+// Resize resizes the vm of the box in place, a running vm is powered off
+// for the resize and resumed after.
+func (p *oneProvisioner) Resize(box *provision.Box, to provision.BoxCompute, w io.Writer) error {
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- resizing box (%s)", box.GetFullName())))
+	args := runMachineActionsArgs{
+		box:           box,
+		writer:        w,
+		isDeploy:      false,
+		machineStatus: provision.StatusResizing,
+		provisioner:   p,
+		resizeTo:      to,
+	}
+	pipeline := action.NewPipeline(resizeActions...)
+
+	if err := pipeline.Execute(args); err != nil {
+		fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.ERROR, fmt.Sprintf("--- resizing box (%s)-->%s", box.GetFullName(), err)))
+		return err
+	}
+
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- resizing box (%s) OK", box.GetFullName())))
+	return nil
+}
+
+// Snapshot takes a disk snapshot of the vm of the box, the ref is the id
+// of the snapshot in one.
+func (p *oneProvisioner) Snapshot(box *provision.Box, name string, w io.Writer) (string, error) {
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- snapshotting box (%s, %s)", box.GetFullName(), name)))
+	var ref string
+	args := runMachineActionsArgs{
+		box:           box,
+		writer:        w,
+		isDeploy:      false,
+		machineStatus: provision.StatusSnapshotting,
+		provisioner:   p,
+		snapshot:      name,
+		ref:           &ref,
+	}
+	pipeline := action.NewPipeline(snapshotActions...)
+
+	if err := pipeline.Execute(args); err != nil {
+		fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.ERROR, fmt.Sprintf("--- snapshotting box (%s)-->%s", box.GetFullName(), err)))
+		return "", err
+	}
+
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- snapshotting box (%s) OK", box.GetFullName())))
+	return ref, nil
+}
+
+// Restore reverts the disk of the vm of the box to the snapshot.
+func (p *oneProvisioner) Restore(box *provision.Box, ref string, w io.Writer) error {
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- restoring box (%s, %s)", box.GetFullName(), ref)))
+	args := runMachineActionsArgs{
+		box:           box,
+		writer:        w,
+		isDeploy:      false,
+		machineStatus: provision.StatusRestoring,
+		provisioner:   p,
+		snapshot:      ref,
+	}
+	pipeline := action.NewPipeline(restoreActions...)
+
+	if err := pipeline.Execute(args); err != nil {
+		fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.ERROR, fmt.Sprintf("--- restoring box (%s)-->%s", box.GetFullName(), err)))
+		return err
+	}
+
+	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- restoring box (%s) OK", box.GetFullName())))
+	return nil
+}
+
+// DeleteSnapshot deletes the disk snapshot of the vm of the box.
+func (p *oneProvisioner) DeleteSnapshot(box *provision.Box, ref string, w io.Writer) error {
+	mach := machine.Machine{
+		Id:       box.Id,
+		Unit:     box.Unit,
+		CartonId: box.CartonId,
+		Name:     box.GetFullName(),
+	}
+	return mach.DeleteSnapshot(p, ref)
+}
+
+// KeepsApart tells that the vms of an anti-affinity group are placed on
+// different nodes, see machine.Create.
+func (*oneProvisioner) KeepsApart() {}
+
 func (p *oneProvisioner) Shell(provision.ShellOptions) error {
 	return provision.ErrNotImplemented
 }
@@ -368,10 +517,7 @@ func (p *oneProvisioner) MetricEnvs(start int64, end int64, w io.Writer) ([]inte
 func (p *oneProvisioner) SetBoxStatus(box *provision.Box, w io.Writer, status utils.Status) error {
 
 	fmt.Fprintf(w, lb.W(lb.VM_DEPLOY, lb.INFO, fmt.Sprintf("--- status %s box %s", box.GetFullName(), status.String())))
-	actions := []*action.Action{
-		&updateStatusInScylla,
-	}
-	pipeline := action.NewPipeline(actions...)
+	pipeline := action.NewPipeline(statusActions...)
 
 	args := runMachineActionsArgs{
 		box:           box,
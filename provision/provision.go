@@ -21,6 +21,7 @@ import (
 	"github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton/bind"
 	"io"
+	"time"
 )
 
 var (
@@ -29,6 +30,8 @@ var (
 	ErrBoxNotFound    = errors.New("box not found")
 	ErrNoOutputsFound = errors.New("no outputs found in the box. Did you set it ? ")
 	ErrNotImplemented = errors.New("I'am on diet.")
+	ErrNodeNotFound   = errors.New("node not found")
+	ErrNodeExists     = errors.New("node already added")
 )
 
 // Named is something that has a name, providing the GetName method.
@@ -137,6 +140,84 @@ type Provisioner interface {
 	MetricEnvs(int64, int64, io.Writer) ([]interface{}, error)
 }
 
+const (
+	//the operations of a provisioner on a box.
+	OP_DEPLOY   = "deploy"
+	OP_DESTROY  = "destroy"
+	OP_START    = "start"
+	OP_STOP     = "stop"
+	OP_RESTART  = "restart"
+	OP_STATE    = "state"
+	OP_STATUS   = "status"
+	OP_RESIZE   = "resize"
+	OP_SNAPSHOT = "snapshot"
+	OP_RESTORE  = "restore"
+	OP_REPLACE  = "replace"
+)
+
+// Planner is a provisioner which can tell the actions it runs for an
+// operation on a box, without running them.
+type Planner interface {
+	PlanActions(op string, b *Box) []string
+}
+
+// Resizer is a provisioner which can change the compute of a box in place,
+// without destroying it.
+type Resizer interface {
+	Resize(b *Box, to BoxCompute, w io.Writer) error
+}
+
+// Snapshotter is a provisioner which can snapshot a box and restore it from
+// one. A snapshot is referred to by the ref the provisioner returns.
+type Snapshotter interface {
+	Snapshot(b *Box, name string, w io.Writer) (string, error)
+	Restore(b *Box, ref string, w io.Writer) error
+	DeleteSnapshot(b *Box, ref string, w io.Writer) error
+}
+
+// Replacer is a provisioner which can replace what runs a box (its vm or
+// containers) by one of an image, the box keeps its name. The old one is
+// removed once the new one runs, and left as it was when the new one fails.
+// A blank image is the one the box is deployed from.
+type Replacer interface {
+	Replace(b *Box, image string, w io.Writer) (string, error)
+}
+
+// Separator is a provisioner which places the boxes of an anti-affinity
+// group (see Box.AntiAffinity) on different nodes.
+type Separator interface {
+	KeepsApart()
+}
+
+// NodeManager is a provisioner whose nodes (eg: the frontends of a cloud)
+// can be added and removed while it runs.
+type NodeManager interface {
+	Nodes() ([]Node, error)
+	AddNode(address string, metadata map[string]string) error
+	RemoveNode(address string) error
+	NodeHealth(address string) ([]NodeHealth, error)
+}
+
+// NodeMonitor is a provisioner which probes its nodes every interval, a
+// node which fails is disabled until it comes back.
+type NodeMonitor interface {
+	MonitorNodes(interval time.Duration)
+}
+
+// Node is a node of a provisioner, its secrets are left out of the metadata.
+type Node struct {
+	Address  string            `json:"address"`
+	Metadata map[string]string `json:"metadata"`
+	Status   string            `json:"status"`
+}
+
+// NodeHealth is the outcome of a probe of a node.
+type NodeHealth struct {
+	Time  time.Time `json:"time"`
+	Up    bool      `json:"up"`
+	Error string    `json:"error,omitempty"`
+}
+
 type MessageProvisioner interface {
 	StartupMessage() (string, error)
 }
@@ -0,0 +1,166 @@
+package provisiontest
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/provision"
+)
+
+// Fake implementation for a provisioner, it records the operations
+// ran on the boxes of each component and fails (or hangs) the ones it is told to.
+type FakeProvisioner struct {
+	mu       sync.Mutex
+	ops      map[string][]string
+	failures map[string]error
+	hangs    map[string]chan struct{}
+}
+
+func NewFakeProvisioner() *FakeProvisioner {
+	return &FakeProvisioner{
+		ops:      make(map[string][]string),
+		failures: make(map[string]error),
+		hangs:    make(map[string]chan struct{}),
+	}
+}
+
+// PrepareFailure makes the next op on any box fail with err.
+func (p *FakeProvisioner) PrepareFailure(op string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[op] = err
+}
+
+// PrepareFailureOn makes the next op on the boxes of the component id (or
+// on the unit of the unit id) fail with err.
+func (p *FakeProvisioner) PrepareFailureOn(op, id string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[op+" "+id] = err
+}
+
+// PrepareHang makes the next op on any box hang till the context of the box
+// is done, and fail with its error. The channel returned is closed once the
+// op hangs.
+func (p *FakeProvisioner) PrepareHang(op string) <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hung := make(chan struct{})
+	p.hangs[op] = hung
+	return hung
+}
+
+// Ops returns the operations ran on the boxes of a component, or on a unit
+// when id is the unit id, oldest first.
+func (p *FakeProvisioner) Ops(id string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ops := make([]string, len(p.ops[id]))
+	copy(ops, p.ops[id])
+	return ops
+}
+
+func (p *FakeProvisioner) run(op string, b *provision.Box, w io.Writer) error {
+	p.mu.Lock()
+	if hung, ok := p.hangs[op]; ok {
+		delete(p.hangs, op)
+		p.mu.Unlock()
+		close(hung)
+		<-b.Context().Done()
+		return b.Context().Err()
+	}
+	defer p.mu.Unlock()
+	for _, key := range []string{op + " " + b.UnitId(), op + " " + b.Id, op} {
+		if err, ok := p.failures[key]; ok {
+			delete(p.failures, key)
+			return err
+		}
+	}
+	p.ops[b.Id] = append(p.ops[b.Id], op)
+	if b.Unit > 0 {
+		p.ops[b.UnitId()] = append(p.ops[b.UnitId()], op)
+	}
+	if w != nil {
+		fmt.Fprintf(w, "%s %s\n", op, b.GetFullName())
+	}
+	return nil
+}
+
+func (p *FakeProvisioner) ImageDeploy(b *provision.Box, image string, w io.Writer) (string, error) {
+	if err := p.run(provision.OP_DEPLOY, b, w); err != nil {
+		return "", err
+	}
+	return image, nil
+}
+
+func (p *FakeProvisioner) Replace(b *provision.Box, image string, w io.Writer) (string, error) {
+	if err := p.run(provision.OP_REPLACE, b, w); err != nil {
+		return "", err
+	}
+	return image, nil
+}
+
+func (p *FakeProvisioner) Destroy(b *provision.Box, w io.Writer) error {
+	return p.run(provision.OP_DESTROY, b, w)
+}
+
+func (p *FakeProvisioner) SetBoxStatus(b *provision.Box, w io.Writer, status utils.Status) error {
+	return p.run(provision.OP_STATUS, b, w)
+}
+
+func (p *FakeProvisioner) SetState(b *provision.Box, w io.Writer, changed utils.Status) error {
+	return p.run(provision.OP_STATE, b, w)
+}
+
+func (p *FakeProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, b *provision.Box, cmd string, args ...string) error {
+	return p.run(cmd, b, stdout)
+}
+
+func (p *FakeProvisioner) Restart(b *provision.Box, process string, w io.Writer) error {
+	return p.run(provision.OP_RESTART, b, w)
+}
+
+func (p *FakeProvisioner) Start(b *provision.Box, process string, w io.Writer) error {
+	return p.run(provision.OP_START, b, w)
+}
+
+func (p *FakeProvisioner) Stop(b *provision.Box, process string, w io.Writer) error {
+	return p.run(provision.OP_STOP, b, w)
+}
+
+func (p *FakeProvisioner) Resize(b *provision.Box, to provision.BoxCompute, w io.Writer) error {
+	return p.run(provision.OP_RESIZE, b, w)
+}
+
+func (p *FakeProvisioner) Snapshot(b *provision.Box, name string, w io.Writer) (string, error) {
+	if err := p.run(provision.OP_SNAPSHOT, b, w); err != nil {
+		return "", err
+	}
+	return b.Id + "/" + name, nil
+}
+
+func (p *FakeProvisioner) Restore(b *provision.Box, ref string, w io.Writer) error {
+	return p.run(provision.OP_RESTORE, b, w)
+}
+
+func (p *FakeProvisioner) DeleteSnapshot(b *provision.Box, ref string, w io.Writer) error {
+	return p.run("deletesnapshot", b, w)
+}
+
+func (p *FakeProvisioner) Shell(opts provision.ShellOptions) error {
+	return p.run("shell", opts.Box, nil)
+}
+
+func (p *FakeProvisioner) Addr(b *provision.Box) (string, error) {
+	return b.PublicIp, nil
+}
+
+func (p *FakeProvisioner) MetricEnvs(start int64, end int64, w io.Writer) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (p *FakeProvisioner) PlanActions(op string, b *provision.Box) []string {
+	return []string{op}
+}
@@ -0,0 +1,302 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package provision
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/megamsys/libgo/utils"
+	constants "github.com/megamsys/libgo/utils"
+)
+
+//the status of a box whose state is brought down, the reverse of StatusStateup.
+const StatusStatedown = utils.Status("statedown")
+
+//the status of a box whose compute is changed in place, and once changed.
+const (
+	StatusResizing = utils.Status("resizing")
+	StatusResized  = utils.Status("resized")
+)
+
+//the status of a box being snapshot or restored from a snapshot, and once done.
+const (
+	StatusSnapshotting = utils.Status("snapshotting")
+	StatusSnapshotted  = utils.Status("snapshotted")
+	StatusRestoring    = utils.Status("restoring")
+	StatusRestored     = utils.Status("restored")
+)
+
+//the statuses a box can move to from a status. A status which isn't here
+//(or a blank one of a new box) isn't gated, we only know of ours.
+var transitions = map[utils.Status][]utils.Status{
+	constants.StatusLaunching: {
+		constants.StatusLaunched,
+		constants.StatusBootstrapped,
+		constants.StatusRunning,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusLaunched: {
+		constants.StatusLaunching, //a redeploy or an upgrade of a box just launched.
+		constants.StatusBootstrapped,
+		constants.StatusStarting,
+		constants.StatusStopping,
+		constants.StatusRunning,
+		utils.StatusStateup,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusBootstrapped: {
+		constants.StatusLaunching,
+		utils.StatusStateup,
+		constants.StatusStarting,
+		constants.StatusStopping,
+		constants.StatusRunning,
+		constants.StatusUpgraded,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	utils.StatusStateup: {
+		StatusStatedown,
+		constants.StatusBootstrapped,
+		constants.StatusRunning,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	StatusStatedown: {
+		utils.StatusStateup,
+		constants.StatusLaunching,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusRunning:  running,
+	constants.StatusStarted:  running,
+	constants.StatusUpgraded: running,
+	StatusResized:            running,
+	StatusSnapshotted:        running,
+	StatusRestored:           running,
+	constants.StatusStarting: {
+		constants.StatusStarted,
+		constants.StatusRunning,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusStopping: {
+		constants.StatusStopped,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusStopped: {
+		constants.StatusStarting,
+		constants.StatusLaunching,
+		constants.StatusUpgraded,
+		StatusResizing,
+		StatusSnapshotting,
+		StatusRestoring,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusError: {
+		constants.StatusLaunching,
+		utils.StatusStateup,
+		constants.StatusStarting,
+		constants.StatusStopping,
+		constants.StatusRunning,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	StatusResizing: {
+		StatusResized,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	StatusSnapshotting: {
+		StatusSnapshotted,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	StatusRestoring: {
+		StatusRestored,
+		constants.StatusError,
+		constants.StatusDestroying,
+	},
+	constants.StatusDestroying: {
+		constants.StatusError, //a destroy which crashed is run again from here, see Recover.
+	},
+}
+
+//a box which is up can be cycled, redeployed, upgraded or torn down.
+var running = []utils.Status{
+	constants.StatusRunning,
+	constants.StatusStarting,
+	constants.StatusStopping,
+	constants.StatusLaunching,
+	constants.StatusUpgraded,
+	StatusResizing,
+	StatusSnapshotting,
+	StatusRestoring,
+	utils.StatusStateup,
+	StatusStatedown,
+	constants.StatusError,
+	constants.StatusDestroying,
+}
+
+//the status a box settles in after the provisioner moved it, the ones
+//not here settle as is. A box is in one of these (or destroying) while it is
+//being moved.
+var settles = map[utils.Status]utils.Status{
+	constants.StatusLaunching: constants.StatusLaunched,
+	constants.StatusStarting:  constants.StatusStarted,
+	constants.StatusStopping:  constants.StatusStopped,
+	StatusResizing:            StatusResized,
+	StatusSnapshotting:        StatusSnapshotted,
+	StatusRestoring:           StatusRestored,
+}
+
+// TransitionError is returned when a box can't move from its status to another.
+type TransitionError struct {
+	Box  string
+	From utils.Status
+	To   utils.Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("box %s can't move from %s to %s", e.Box, e.From.String(), e.To.String())
+}
+
+// ErrMovedAway is returned when the status saved of a box changed while
+// moving it, as another daemon moved it first.
+var ErrMovedAway = errors.New("the box was moved by another operation")
+
+// StatusStore keeps the statuses of the boxes, so that a box is moved from
+// the status saved (and not the one it was read with, which another daemon
+// may have moved since) and the move is saved with a compare-and-set.
+type StatusStore interface {
+	// Saved returns the status saved of the box, blank when it has none.
+	Saved(b *Box) (utils.Status, error)
+	// Swap saves the status to of the box when the one saved is still from,
+	// it returns false and saves nothing when it isn't.
+	Swap(b *Box, from, to utils.Status) (bool, error)
+}
+
+// Statuses keeps the statuses of the boxes, a move is checked against the
+// status of the box alone when it isn't set.
+var Statuses StatusStore
+
+// CanTransition returns true if a box can move from a status to another.
+func CanTransition(from, to utils.Status) bool {
+	allowed, ok := transitions[from]
+	if !ok {
+		return true
+	}
+	for _, s := range allowed {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanMove returns a TransitionError when the box can't move to the status.
+func (b *Box) CanMove(to utils.Status) error {
+	if !CanTransition(b.Status, to) {
+		return &TransitionError{Box: b.GetFullName(), From: b.Status, To: to}
+	}
+	return nil
+}
+
+// Move moves the box to the status, fn asks the provisioner to do it.
+// The box is left in error when fn fails, or else it settles in the status.
+// When the statuses are kept the box is moved from the one saved, and fn
+// isn't called when another moved the box in the meantime. A box with none
+// saved was never moved, whatever the status the gateway gave its assembly.
+// A box isn't moved to the status it is in, so that two operations can't
+// both win the swap; one left in the middle of a move is recovered first.
+func (b *Box) Move(to utils.Status, fn func() error) error {
+	if Statuses == nil {
+		return b.move(to, fn)
+	}
+	saved, err := Statuses.Saved(b)
+	if err != nil {
+		return err
+	}
+	b.Status = saved
+	if err := b.CanMove(to); err != nil {
+		return err
+	}
+	if ok, err := Statuses.Swap(b, saved, to); err != nil {
+		return err
+	} else if !ok {
+		return ErrMovedAway
+	}
+	err = b.move(to, fn)
+	//the provisioner may have saved a status of its own by now, which is kept.
+	if _, serr := Statuses.Swap(b, to, b.Status); serr != nil && err == nil {
+		return serr
+	}
+	return err
+}
+
+// Recover moves the box to error when it was left in the middle of a move,
+// as the daemon moving it went away, so that the operation can be run on it
+// once more. It swaps from the status the box was left in, and tells if the
+// box was moved.
+func (b *Box) Recover() (bool, error) {
+	if Statuses == nil {
+		if !moving(b.Status) {
+			return false, nil
+		}
+		b.Status = constants.StatusError
+		return true, nil
+	}
+	saved, err := Statuses.Saved(b)
+	if err != nil || !moving(saved) {
+		return false, err
+	}
+	ok, err := Statuses.Swap(b, saved, constants.StatusError)
+	if ok {
+		b.Status = constants.StatusError
+	}
+	return ok, err
+}
+
+//whether a box in the status is being moved.
+func moving(s utils.Status) bool {
+	_, ok := settles[s]
+	return ok || s == constants.StatusDestroying
+}
+
+// DryMove moves the box to the status as Move would with fn not failing,
+// nothing is asked of the provisioner nor saved. It is used to plan.
+func (b *Box) DryMove(to utils.Status) error {
+	return b.move(to, func() error { return nil })
+}
+
+func (b *Box) move(to utils.Status, fn func() error) error {
+	if err := b.CanMove(to); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		b.Status = constants.StatusError
+		return err
+	}
+	if s, ok := settles[to]; ok {
+		to = s
+	}
+	b.Status = to
+	return nil
+}
@@ -0,0 +1,169 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/megamsys/libgo/utils"
+	constants "github.com/megamsys/libgo/utils"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type StatusSuite struct{}
+
+var _ = check.Suite(&StatusSuite{})
+
+func (s *StatusSuite) TestCanTransition(c *check.C) {
+	c.Assert(CanTransition(constants.StatusRunning, constants.StatusStopping), check.Equals, true)
+	c.Assert(CanTransition(constants.StatusStopped, constants.StatusStarting), check.Equals, true)
+	c.Assert(CanTransition(constants.StatusLaunching, constants.StatusStopping), check.Equals, false)
+	c.Assert(CanTransition(constants.StatusDestroying, constants.StatusDestroying), check.Equals, false)
+	c.Assert(CanTransition(constants.StatusLaunching, constants.StatusLaunching), check.Equals, false)
+	c.Assert(CanTransition(constants.StatusDestroying, constants.StatusError), check.Equals, true)
+	c.Assert(CanTransition(constants.StatusDestroying, constants.StatusRunning), check.Equals, false)
+	c.Assert(CanTransition(constants.StatusError, constants.StatusDestroying), check.Equals, true)
+	c.Assert(CanTransition(constants.StatusLaunched, constants.StatusLaunching), check.Equals, true)
+	c.Assert(CanTransition(constants.StatusBootstrapped, constants.StatusLaunching), check.Equals, true)
+	c.Assert(CanTransition("", constants.StatusLaunching), check.Equals, true)
+}
+
+func (s *StatusSuite) TestMoveRejectsIllegalMove(c *check.C) {
+	b := &Box{CartonName: "myapp", Status: constants.StatusLaunching}
+	ran := false
+	err := b.Move(constants.StatusStopping, func() error {
+		ran = true
+		return nil
+	})
+	c.Assert(err, check.FitsTypeOf, &TransitionError{})
+	c.Assert(err.Error(), check.Equals, "box myapp can't move from launching to stopping")
+	c.Assert(ran, check.Equals, false)
+}
+
+func (s *StatusSuite) TestMoveSettles(c *check.C) {
+	b := &Box{Status: constants.StatusRunning}
+	c.Assert(b.Move(constants.StatusStopping, func() error { return nil }), check.IsNil)
+	c.Assert(b.Status, check.Equals, constants.StatusStopped)
+	c.Assert(b.Move(constants.StatusStarting, func() error { return errors.New("boom") }), check.ErrorMatches, "boom")
+	c.Assert(b.Status, check.Equals, constants.StatusError)
+}
+
+func (s *StatusSuite) TestMoveResizeSettles(c *check.C) {
+	b := &Box{Status: constants.StatusStopped}
+	c.Assert(b.Move(StatusResizing, func() error { return nil }), check.IsNil)
+	c.Assert(b.Status, check.Equals, StatusResized)
+	c.Assert(CanTransition(StatusResized, constants.StatusStopping), check.Equals, true)
+	c.Assert(CanTransition(StatusResizing, StatusResizing), check.Equals, false)
+}
+
+func (s *StatusSuite) TestAvailable(c *check.C) {
+	c.Assert((&Box{Status: constants.StatusRunning}).Available(), check.Equals, true)
+	c.Assert((&Box{Status: constants.StatusLaunching}).Available(), check.Equals, false)
+	c.Assert((&Box{Status: constants.StatusDestroying}).Available(), check.Equals, false)
+}
+
+//the statuses saved in memory, a box is keyed by its unit id.
+type fakeStatuses struct {
+	saved map[string]utils.Status
+}
+
+func (f *fakeStatuses) Saved(b *Box) (utils.Status, error) {
+	return f.saved[b.UnitId()], nil
+}
+
+func (f *fakeStatuses) Swap(b *Box, from, to utils.Status) (bool, error) {
+	if f.saved[b.UnitId()] != from {
+		return false, nil
+	}
+	f.saved[b.UnitId()] = to
+	return true, nil
+}
+
+func (s *StatusSuite) TestMoveFromTheStatusSaved(c *check.C) {
+	st := &fakeStatuses{saved: map[string]utils.Status{"CMP001": constants.StatusStopped}}
+	Statuses = st
+	defer func() { Statuses = nil }()
+	b := &Box{Id: "CMP001", CartonName: "myapp", Status: constants.StatusRunning}
+	c.Assert(b.Move(constants.StatusStopping, func() error { return nil }), check.ErrorMatches, "box myapp can't move from stopped to stopping")
+	c.Assert(b.Move(constants.StatusStarting, func() error { return nil }), check.IsNil)
+	c.Assert(b.Status, check.Equals, constants.StatusStarted)
+	c.Assert(st.saved["CMP001"], check.Equals, constants.StatusStarted)
+}
+
+func (s *StatusSuite) TestMoveKeepsTheStatusTheProvisionerSaved(c *check.C) {
+	st := &fakeStatuses{saved: map[string]utils.Status{"CMP001": constants.StatusStopped}}
+	Statuses = st
+	defer func() { Statuses = nil }()
+	b := &Box{Id: "CMP001", Status: constants.StatusStopped}
+	c.Assert(b.Move(constants.StatusLaunching, func() error {
+		st.saved["CMP001"] = constants.StatusBootstrapped
+		return nil
+	}), check.IsNil)
+	c.Assert(st.saved["CMP001"], check.Equals, constants.StatusBootstrapped)
+}
+
+func (s *StatusSuite) TestMoveWhichLostTheSwap(c *check.C) {
+	Statuses = &racingStatuses{&fakeStatuses{saved: map[string]utils.Status{"CMP001": constants.StatusRunning}}}
+	defer func() { Statuses = nil }()
+	b := &Box{Id: "CMP001", Status: constants.StatusRunning}
+	ran := false
+	err := b.Move(constants.StatusStopping, func() error {
+		ran = true
+		return nil
+	})
+	c.Assert(err, check.Equals, ErrMovedAway)
+	c.Assert(ran, check.Equals, false)
+}
+
+//another daemon moves the box between the read and the swap.
+type racingStatuses struct {
+	*fakeStatuses
+}
+
+func (r *racingStatuses) Saved(b *Box) (utils.Status, error) {
+	saved, _ := r.fakeStatuses.Saved(b)
+	r.saved[b.UnitId()] = constants.StatusStopping
+	return saved, nil
+}
+
+func (s *StatusSuite) TestMoveANewBoxFromNoStatus(c *check.C) {
+	st := &fakeStatuses{saved: map[string]utils.Status{}}
+	Statuses = st
+	defer func() { Statuses = nil }()
+	b := &Box{Id: "CMP001", Status: constants.StatusLaunching}
+	c.Assert(b.Move(constants.StatusLaunching, func() error { return nil }), check.IsNil)
+	c.Assert(st.saved["CMP001"], check.Equals, constants.StatusLaunched)
+}
+
+func (s *StatusSuite) TestOnlyOneDestroyWinsTheSwap(c *check.C) {
+	st := &fakeStatuses{saved: map[string]utils.Status{"CMP001": constants.StatusRunning}}
+	Statuses = st
+	defer func() { Statuses = nil }()
+	b := &Box{Id: "CMP001", CartonName: "myapp"}
+	c.Assert(b.Move(constants.StatusDestroying, func() error {
+		other := &Box{Id: "CMP001", CartonName: "myapp"}
+		return other.Move(constants.StatusDestroying, func() error {
+			c.Fatal("the second destroy ran")
+			return nil
+		})
+	}), check.ErrorMatches, "box myapp can't move from destroying to destroying")
+}
+
+func (s *StatusSuite) TestRecoverABoxLeftMoving(c *check.C) {
+	st := &fakeStatuses{saved: map[string]utils.Status{"CMP001": constants.StatusDestroying, "CMP002": constants.StatusRunning}}
+	Statuses = st
+	defer func() { Statuses = nil }()
+	b := &Box{Id: "CMP001", CartonName: "myapp"}
+	ok, err := b.Recover()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(st.saved["CMP001"], check.Equals, constants.StatusError)
+	c.Assert(b.Move(constants.StatusDestroying, func() error { return nil }), check.IsNil)
+	ok, err = (&Box{Id: "CMP002"}).Recover()
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(st.saved["CMP002"], check.Equals, constants.StatusRunning)
+}
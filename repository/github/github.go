@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -68,8 +69,40 @@ func (m githubManager) CreateHook(r repository.Repository) (string, error) {
 
 }
 
+//https://developer.github.com/v3/repos/hooks/#delete-a-hook
 func (m githubManager) RemoveHook(r repository.Repository) error {
-	//get  a single hook saved id and remove the hook.
+	if len(strings.TrimSpace(r.GetHookId())) == 0 {
+		log.Debugf("  [github] no webhook saved for [%s], skipped.", r.Gitr())
+		return nil
+	}
+
+	id, err := strconv.Atoi(r.GetHookId())
+	if err != nil {
+		return fmt.Errorf("invalid webhook id %s: %s", r.GetHookId(), err)
+	}
+
+	repoName, err := r.GetShortName()
+	if err != nil {
+		return err
+	}
+
+	client := m.client(r.GetToken())
+	log.Debugf("  [github] removing hook(%s, %s, %d)", r.GetUserName(), repoName, id)
+
+	response, err := client.Repositories.DeleteHook(r.GetUserName(), repoName, id)
+	if response != nil {
+		m.debugResp(response.Response)
+		if response.StatusCode == http.StatusNotFound {
+			log.Debugf("  [github] webhook [%s,%d] is already gone.", r.Gitr(), id)
+			return nil
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("  [github] removed webhook [%s,%d] successfully.", r.Gitr(), id)
 	return nil
 }
 
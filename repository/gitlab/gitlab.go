@@ -1,6 +1,8 @@
 package gitlab
 
 import (
+	"strconv"
+
 	"github.com/megamsys/vertice/repository"
 	"github.com/plouc/go-gitlab-client"
 )
@@ -30,10 +32,24 @@ func (m gitlabManager) CreateHook(r repository.Repository) (string, error) {
 
 }
 
+//gitlab doesn't hand out the id of a hook when added, so the hooks of the
+//project which call our trigger are removed.
 func (m gitlabManager) RemoveHook(r repository.Repository) error {
-	/*client, err := m.client()
+	client, err := m.client(r.GetToken())
 	if err != nil {
 		return err
-	}*/
+	}
+	hooks, err := client.ProjectHooks(r.GetUserName())
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.Url != r.Trigger() {
+			continue
+		}
+		if err = client.RemoveProjectHook(r.GetUserName(), strconv.Itoa(h.Id)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -42,6 +42,7 @@ type Repo struct {
 }
 
 type Hook struct {
+	Id       string //the id the manager gave the hook once created
 	Enabled  bool
 	Token    string
 	UserName string
@@ -73,6 +74,10 @@ func (r Repo) GetUserName() string {
 	return r.Hook.UserName
 }
 
+func (r Repo) GetHookId() string {
+	return r.Hook.Id
+}
+
 //Check on CartonId, BoxId if it exists (r.Hook.BoxId)
 func (r Repo) Trigger() string {
 	return meta.MC.Api + "/assembly/upgrade/" + r.Hook.CartonId
@@ -101,6 +106,7 @@ type Repository interface {
 	GetType() string
 	GetToken() string
 	GetUserName() string
+	GetHookId() string
 	Gitr() string
 	Trigger() string
 	GetShortName() (string, error)
@@ -6,8 +6,14 @@ import (
 	"github.com/megamsys/libgo/cmd"
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision/one/cluster"
+	"github.com/megamsys/vertice/toml"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 const (
@@ -29,30 +35,84 @@ const (
 
 	// DefaultOneZone is the default zone for the IaaS service (OpenNebula).
 	DefaultOneZone = "plano01"
+
+	// DefaultProbeInterval is how often the frontends are probed.
+	DefaultProbeInterval = 1 * time.Minute
 )
 
 type Config struct {
-	Provider    string `toml:"provider"`
-	OneEndPoint string `toml:"one_endpoint"`
-	OneUserid   string `toml:"one_userid"`
-	OnePassword string `toml:"one_password"`
-	OneTemplate string `toml:"one_template"`
-	OneZone     string `toml:"one_zone"`
-	Certificate string `toml:"certificate"`
-	Image       string `toml:"image"`
+	Provider       string `toml:"provider"`
+	OneEndPoint    string `toml:"one_endpoint"`
+	OneUserid      string `toml:"one_userid"`
+	OnePassword    string `toml:"one_password"`
+	OneTemplate    string `toml:"one_template"`
+	OneZone        string `toml:"one_zone"`
+	OneLabels      string `toml:"one_labels"`
+	Certificate    string `toml:"certificate"`
+	Image          string `toml:"image"`
 	VCPUPercentage string `toml:"vcpu_percentage"`
+	Concurrency    int    `toml:"concurrency"`
+
+	//the nodes are probed every interval, a zero is never.
+	ProbeInterval toml.Duration `toml:"probe_interval"`
+
+	//a vm being deployed is polled every interval till it is running with
+	//an ip, it fails past the timeout.
+	RunningInterval toml.Duration `toml:"running_interval"`
+	RunningTimeout  toml.Duration `toml:"running_timeout"`
+
+	//the deadline of an operation on an assembly, a zero is none.
+	OperationTimeout toml.Duration `toml:"operation_timeout"`
+
+	//the requests left running by a daemon which went away are looked for
+	//every interval and run again, a zero is never.
+	SweepInterval toml.Duration `toml:"sweep_interval"`
+
+	//the retries of a request which fails with a transient error, the
+	//backoff doubles after every attempt up to the max.
+	RetryAttempts   int           `toml:"retry_attempts"`
+	RetryBackoff    toml.Duration `toml:"retry_backoff"`
+	RetryMaxBackoff toml.Duration `toml:"retry_max_backoff"`
+
+	//the default quota of an account, a zero is unlimited.
+	QuotaCpushare uint64 `toml:"quota_cpushare"`
+	QuotaMemory   uint64 `toml:"quota_memory"`
+	QuotaHDD      uint64 `toml:"quota_hdd"`
+	QuotaBoxes    uint64 `toml:"quota_boxes"`
+
+	//the other one frontends vms are scheduled on, keyed by their name.
+	Frontends map[string]Frontend `toml:"frontends"`
+}
+
+// Frontend is an other OpenNebula frontend, as [deployd.frontends.<name>].
+type Frontend struct {
+	EndPoint string `toml:"endpoint"`
+	Userid   string `toml:"userid"`
+	Password string `toml:"password"`
+	Template string `toml:"template"`
+	Zone     string `toml:"zone"`
+	Labels   string `toml:"labels"` //eg: storage=ssd,gpu=true
 }
 
 func NewConfig() *Config {
 	return &Config{
-		Provider:    DefaultProvider,
-		OneEndPoint: DefaultOneEndpoint,
-		OneUserid:   DefaultOneUserid,
-		OnePassword: DefaultOnePassword,
-		OneTemplate: DefaultOneTemplate,
-		OneZone:     DefaultOneZone,
-		Certificate: "/var/lib/megam/vertice/id_rsa.pub",
-		Image:       DefaultImage,
+		Provider:         DefaultProvider,
+		OneEndPoint:      DefaultOneEndpoint,
+		OneUserid:        DefaultOneUserid,
+		OnePassword:      DefaultOnePassword,
+		OneTemplate:      DefaultOneTemplate,
+		OneZone:          DefaultOneZone,
+		Certificate:      "/var/lib/megam/vertice/id_rsa.pub",
+		Image:            DefaultImage,
+		Concurrency:      carton.DefaultConcurrency,
+		ProbeInterval:    toml.Duration(DefaultProbeInterval),
+		RunningInterval:  toml.Duration(cluster.RunningInterval),
+		RunningTimeout:   toml.Duration(cluster.RunningTimeout),
+		OperationTimeout: toml.Duration(carton.DefaultOperationTimeout),
+		SweepInterval:    toml.Duration(carton.DefaultSweepInterval),
+		RetryAttempts:    carton.DefaultAttempts,
+		RetryBackoff:     toml.Duration(carton.DefaultBackoff),
+		RetryMaxBackoff:  toml.Duration(carton.DefaultMaxBackoff),
 	}
 }
 
@@ -68,7 +128,19 @@ func (c Config) String() string {
 	b.Write([]byte(api.TEMPLATE + "\t" + c.OneTemplate + "\n"))
 	b.Write([]byte(api.IMAGE + "    \t" + c.Image + "\n"))
 	b.Write([]byte(api.PASSWORD + "\t" + c.OnePassword + "\n"))
-		b.Write([]byte(api.VCPU_PERCENTAGE+ "\t" + c.VCPUPercentage + "\n"))
+	b.Write([]byte(cluster.ZONE + "    \t" + c.OneZone + "\n"))
+	b.Write([]byte(api.VCPU_PERCENTAGE + "\t" + c.VCPUPercentage + "\n"))
+	b.Write([]byte("concurrency" + "\t" + strconv.Itoa(c.Concurrency) + "\n"))
+	b.Write([]byte("probe_interval" + "\t" + c.ProbeInterval.String() + "\n"))
+	b.Write([]byte("running" + "\t" + fmt.Sprintf("every %s, timeout %s", c.RunningInterval, c.RunningTimeout) + "\n"))
+	b.Write([]byte("operation_timeout" + "\t" + c.OperationTimeout.String() + "\n"))
+	b.Write([]byte("sweep_interval" + "\t" + c.SweepInterval.String() + "\n"))
+	b.Write([]byte("retry" + "\t" + fmt.Sprintf("attempts %d, backoff %s, max backoff %s", c.RetryAttempts, c.RetryBackoff, c.RetryMaxBackoff) + "\n"))
+	b.Write([]byte("quota" + "\t" + fmt.Sprintf("cpushare %d, memory %d, hdd %d, boxes %d", c.QuotaCpushare, c.QuotaMemory, c.QuotaHDD, c.QuotaBoxes) + "\n"))
+	for _, name := range c.frontendNames() {
+		f := c.Frontends[name]
+		b.Write([]byte("frontend " + name + "\t" + f.EndPoint + " (" + f.Zone + ")\n"))
+	}
 	b.Write([]byte("---\n"))
 	fmt.Fprintln(w)
 	w.Flush()
@@ -83,6 +155,50 @@ func (c Config) toMap() map[string]string {
 	m[api.PASSWORD] = c.OnePassword
 	m[api.TEMPLATE] = c.OneTemplate
 	m[api.IMAGE] = c.Image
-		m[api.VCPU_PERCENTAGE] = c.VCPUPercentage
+	m[api.VCPU_PERCENTAGE] = c.VCPUPercentage
+	m[cluster.ZONE] = c.OneZone
+	m[cluster.LABELS] = c.OneLabels
 	return m
 }
+
+//the other frontends flattened to a map keyed as <name>.<setting>.
+func (c Config) frontendsMap() map[string]string {
+	m := make(map[string]string)
+	for name, f := range c.Frontends {
+		m[name+"."+api.ENDPOINT] = f.EndPoint
+		m[name+"."+api.USERID] = f.Userid
+		m[name+"."+api.PASSWORD] = f.Password
+		m[name+"."+api.TEMPLATE] = f.Template
+		m[name+"."+cluster.ZONE] = f.Zone
+		m[name+"."+cluster.LABELS] = f.Labels
+	}
+	return m
+}
+
+func (c Config) frontendNames() []string {
+	names := make([]string, 0, len(c.Frontends))
+	for name := range c.Frontends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//the retry policy of the requests.
+func (c Config) retry() carton.RetryPolicy {
+	return carton.RetryPolicy{
+		Attempts:   c.RetryAttempts,
+		Backoff:    time.Duration(c.RetryBackoff),
+		MaxBackoff: time.Duration(c.RetryMaxBackoff),
+	}
+}
+
+//the default quota of an account.
+func (c Config) quota() carton.Resources {
+	return carton.Resources{
+		Cpushare: c.QuotaCpushare,
+		Memory:   c.QuotaMemory,
+		HDD:      c.QuotaHDD,
+		Boxes:    c.QuotaBoxes,
+	}
+}
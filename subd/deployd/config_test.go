@@ -1,7 +1,11 @@
 package deployd
 
 import (
+	"time"
+
 	"github.com/BurntSushi/toml"
+	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/vertice/provision/one/cluster"
 	"gopkg.in/check.v1"
 )
 
@@ -16,7 +20,20 @@ func (s *S) TestDeploydConfig_Parse(c *check.C) {
 		one_template = "megam"
 		one_zone     = "plano01"
 		certificate = "/etc/ssl/cert.pem"
+		concurrency = 4
+		operation_timeout = "10m"
+		probe_interval = "30s"
+		running_timeout = "5m"
+		retry_attempts = 5
+		retry_backoff = "1s"
+		quota_memory = 8192
+		quota_boxes = 5
 
+		[frontends.dallas]
+		endpoint = "http://dallas:2633/RPC2"
+		userid   = "oneadmin"
+		zone     = "dallas01"
+		labels   = "storage=ssd"
 `, &cm); err != nil {
 		c.Fatal(err)
 	}
@@ -25,5 +42,19 @@ func (s *S) TestDeploydConfig_Parse(c *check.C) {
 	c.Assert(cm.OneUserid, check.Equals, "oneadmin")
 	c.Assert(cm.OnePassword, check.Equals, "password")
 	c.Assert(cm.OneTemplate, check.Equals, "megam")
+	c.Assert(cm.Concurrency, check.Equals, 4)
+	c.Assert(time.Duration(cm.OperationTimeout), check.Equals, 10*time.Minute)
+	c.Assert(time.Duration(cm.ProbeInterval), check.Equals, 30*time.Second)
+	c.Assert(time.Duration(cm.RunningTimeout), check.Equals, 5*time.Minute)
+	c.Assert(cm.retry().Attempts, check.Equals, 5)
+	c.Assert(cm.retry().Backoff, check.Equals, time.Second)
+	c.Assert(cm.QuotaMemory, check.Equals, uint64(8192))
+	c.Assert(cm.QuotaBoxes, check.Equals, uint64(5))
+	c.Assert(cm.QuotaCpushare, check.Equals, uint64(0))
+	c.Assert(cm.Frontends, check.HasLen, 1)
+	f := cm.frontendsMap()
+	c.Assert(f["dallas."+api.ENDPOINT], check.Equals, "http://dallas:2633/RPC2")
+	c.Assert(f["dallas."+cluster.ZONE], check.Equals, "dallas01")
+	c.Assert(f["dallas."+cluster.LABELS], check.Equals, "storage=ssd")
 
 }
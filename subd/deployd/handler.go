@@ -1,27 +1,80 @@
 package deployd
 
 import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton"
 )
 
 type Handler struct {
 	d            *Config
 	EventChannel chan bool
+	deadLetter   func(d *carton.DeadLetter) error
 }
 
-func NewHandler(c *Config) *Handler {
-	return &Handler{d: c}
+func NewHandler(c *Config, deadLetter func(d *carton.DeadLetter) error) *Handler {
+	return &Handler{d: c, deadLetter: deadLetter}
 
 }
 
+//runs the request, retried while it fails with a transient error. Once it
+//runs out of attempts it is parked and handed to the dead letter topic.
 func (h *Handler) serveNSQ(r *carton.Requests) error {
-	p, err := carton.ParseRequest(r.CatId, r.Category, r.Action)
+	err := r.Run(func() error {
+		return r.Retried(carton.Retry, func() error {
+			return h.process(r)
+		})
+	})
+	if carton.Transient(err) {
+		d, perr := r.Park(TOPIC, err)
+		if perr == nil {
+			perr = h.deadLetter(d)
+		}
+		if perr != nil {
+			log.Errorf("unable to park request (%s) %s", r.Id, perr)
+		}
+	}
+	return err
+}
+
+//runs again the requests on its assemblies which a daemon left running once
+//their lease lapsed, as the daemon went away. The claim of a request makes
+//sure a single daemon runs it.
+func (h *Handler) sweep(now time.Time) error {
+	rs, err := carton.StaleRequestsOn(now, func(provider string) bool {
+		return provider != constants.PROVIDER_DOCKER
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range rs {
+		log.Warnf("  request (%s, %s %s) was left running, running it again", r.Id, r.Category, r.Action)
+		go func(r *carton.Requests) {
+			if err := h.serveNSQ(r); err != nil {
+				log.Errorf("request (%s, %s %s) failed %s", r.Id, r.Category, r.Action, err)
+			}
+		}(r)
+	}
+	return nil
+}
+
+func (h *Handler) process(r *carton.Requests) error {
+	p, err := r.Parse()
 	if err != nil {
 		return err
 	}
 
 	if rp := carton.NewReqOperator(r.CatId); rp != nil {
-		return rp.Accept(&p) //error is swalled here.
+		if r.DryRun {
+			pl, err := rp.Plan(&p)
+			if err != nil {
+				return err
+			}
+			return r.SavePlan(pl)
+		}
+		return rp.Accept(&p)
 	}
 
 	return nil
@@ -1,22 +1,29 @@
 package deployd
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	nsq "github.com/crackcomm/nsqueue/consumer"
+	nsqp "github.com/crackcomm/nsqueue/producer"
 	"github.com/megamsys/libgo/cmd"
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton"
 	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/provision"
 	_ "github.com/megamsys/vertice/provision/one"
+	"github.com/megamsys/vertice/provision/one/cluster"
 )
 
 const (
 	TOPIC       = "vms"
 	maxInFlight = 150
+
+	//where the requests which ran out of attempts are parked.
+	DEADLETTER_TOPIC = TOPIC + "_dead"
 )
 
 // Service manages the listener and handler for an HTTP endpoint.
@@ -25,6 +32,7 @@ type Service struct {
 	err      chan error
 	Handler  *Handler
 	Consumer *nsq.Consumer
+	stop     chan struct{}
 	Meta     *meta.Config
 	Deployd  *Config
 }
@@ -36,7 +44,7 @@ func NewService(c *meta.Config, d *Config) *Service {
 		Meta:    c,
 		Deployd: d,
 	}
-	s.Handler = NewHandler(s.Deployd)
+	s.Handler = NewHandler(s.Deployd, s.deadLetter)
 	c.MkGlobal() //a setter for global meta config
 	return s
 }
@@ -59,6 +67,21 @@ func (s *Service) Open() error {
 	if err := s.setProvisioner(constants.PROVIDER_ONE); err != nil {
 		return err
 	}
+	carton.SetConcurrency(constants.PROVIDER_ONE, s.Deployd.Concurrency)
+	carton.SetOperationTimeout(constants.PROVIDER_ONE, time.Duration(s.Deployd.OperationTimeout))
+	if s.Deployd.SweepInterval > 0 && s.stop == nil {
+		s.stop = make(chan struct{})
+		s.wg.Add(1)
+		go s.sweepLoop(time.Duration(s.Deployd.SweepInterval), s.stop)
+	}
+	if s.Deployd.RunningInterval > 0 {
+		cluster.RunningInterval = time.Duration(s.Deployd.RunningInterval)
+	}
+	if s.Deployd.RunningTimeout > 0 {
+		cluster.RunningTimeout = time.Duration(s.Deployd.RunningTimeout)
+	}
+	carton.Retry = s.Deployd.retry()
+	carton.DefaultQuota = s.Deployd.quota()
 	return nil
 }
 
@@ -70,17 +93,57 @@ func (s *Service) processNSQ(msg *nsq.Message) {
 
 	re, err := p.Convert()
 	if err != nil {
+		log.Errorf("unable to fetch request (%s) %s", p.Id, err)
 		return
 	}
-	go s.Handler.serveNSQ(re)
+	go func() {
+		if err := s.Handler.serveNSQ(re); err != nil {
+			log.Errorf("request (%s, %s %s) failed %s", re.Id, re.Category, re.Action, err)
+		}
+	}()
 	return
 }
 
+//publishes the parked request along with its error to the dead letter topic.
+func (s *Service) deadLetter(d *carton.DeadLetter) error {
+	pons := nsqp.New()
+	if err := pons.Connect(s.Meta.NSQd[0]); err != nil {
+		return err
+	}
+	defer pons.Stop()
+
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return pons.Publish(DEADLETTER_TOPIC, bytes)
+}
+
+//runs the requests left running by a daemon which went away every interval,
+//till stop is closed.
+func (s *Service) sweepLoop(interval time.Duration, stop chan struct{}) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+			if err := s.Handler.sweep(time.Now()); err != nil {
+				log.Errorf("unable to run the requests left running %s", err)
+			}
+		}
+	}
+}
+
 // Close closes the underlying subscribe channel.
 func (s *Service) Close() error {
 	if s.Consumer != nil {
 		s.Consumer.Stop()
 	}
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
 
 	s.wg.Wait()
 	return nil
@@ -98,7 +161,7 @@ func (s *Service) setProvisioner(pt string) error {
 		return err
 	}
 	log.Debugf(cmd.Colorfy("  > configuring ", "blue", "", "bold") + fmt.Sprintf("%s ", pt))
-	var b map[string]string
+	b := s.Deployd.frontendsMap()
 	if initializableProvisioner, ok := tempProv.(provision.InitializableProvisioner); ok {
 		err = initializableProvisioner.Initialize(s.Deployd.toMap(), b)
 		if err != nil {
@@ -108,6 +171,10 @@ func (s *Service) setProvisioner(pt string) error {
 		}
 	}
 
+	if nodeMonitor, ok := tempProv.(provision.NodeMonitor); ok {
+		nodeMonitor.MonitorNodes(time.Duration(s.Deployd.ProbeInterval))
+	}
+
 	if messageProvisioner, ok := tempProv.(provision.MessageProvisioner); ok {
 		startupMessage, err := messageProvisioner.StartupMessage()
 		if err == nil && startupMessage != "" {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/vertice/carton"
 	"github.com/megamsys/vertice/provision/docker"
 	"github.com/megamsys/vertice/toml"
 )
@@ -32,6 +33,9 @@ const (
 
 	// DefaultCPUQuota is the default cpu quota allocated for every cpu cycle for the launched container in ms
 	DefaultCPUQuota = 25000 * time.Millisecond
+
+	// DefaultProbeInterval is how often the docker nodes are probed.
+	DefaultProbeInterval = 1 * time.Minute
 )
 
 type Config struct {
@@ -44,6 +48,25 @@ type Config struct {
 	GulpPort  string        `toml:"gulp_port"`
 	CPUPeriod toml.Duration `toml:"cpu_period"`
 	CPUQuota  toml.Duration `toml:"cpu_quota"`
+
+	//the nodes are probed every interval, a zero is never.
+	ProbeInterval toml.Duration `toml:"probe_interval"`
+
+	//the containers operated upon at once, and the deadline of an operation
+	//on an assembly (a zero is none).
+	Concurrency      int           `toml:"concurrency"`
+	OperationTimeout toml.Duration `toml:"operation_timeout"`
+
+	//the requests left running by a daemon which went away are looked for
+	//every interval and run again, a zero is never.
+	SweepInterval toml.Duration `toml:"sweep_interval"`
+
+	//the default quota of an account, a zero is unlimited. An account has
+	//one quota whatever runs its boxes, this one is used when set.
+	QuotaCpushare uint64 `toml:"quota_cpushare"`
+	QuotaMemory   uint64 `toml:"quota_memory"`
+	QuotaHDD      uint64 `toml:"quota_hdd"`
+	QuotaBoxes    uint64 `toml:"quota_boxes"`
 }
 
 func NewConfig() *Config {
@@ -55,6 +78,11 @@ func NewConfig() *Config {
 		SwapSize:  DefaultSwapSize,
 		CPUPeriod: toml.Duration(DefaultCPUPeriod),
 		CPUQuota:  toml.Duration(DefaultCPUQuota),
+
+		ProbeInterval:    toml.Duration(DefaultProbeInterval),
+		Concurrency:      carton.DefaultConcurrency,
+		OperationTimeout: toml.Duration(carton.DefaultOperationTimeout),
+		SweepInterval:    toml.Duration(carton.DefaultSweepInterval),
 	}
 }
 
@@ -71,6 +99,11 @@ func (c Config) String() string {
 	b.Write([]byte(docker.DOCKER_SWAPSIZE + "    \t" + strconv.Itoa(c.SwapSize) + "\n"))
 	b.Write([]byte(docker.DOCKER_CPUPERIOD + "    \t" + c.CPUPeriod.String() + "\n"))
 	b.Write([]byte(docker.DOCKER_CPUQUOTA + "    \t" + c.CPUQuota.String() + "\n"))
+	b.Write([]byte("probe_interval" + "\t" + c.ProbeInterval.String() + "\n"))
+	b.Write([]byte("concurrency" + "\t" + strconv.Itoa(c.Concurrency) + "\n"))
+	b.Write([]byte("operation_timeout" + "\t" + c.OperationTimeout.String() + "\n"))
+	b.Write([]byte("sweep_interval" + "\t" + c.SweepInterval.String() + "\n"))
+	b.Write([]byte("quota" + "\t" + fmt.Sprintf("cpushare %d, memory %d, hdd %d, boxes %d", c.QuotaCpushare, c.QuotaMemory, c.QuotaHDD, c.QuotaBoxes) + "\n"))
 	b.Write([]byte("---\n"))
 	fmt.Fprintln(w)
 	w.Flush()
@@ -89,3 +122,13 @@ func (c Config) toMap() map[string]string {
 	m[docker.DOCKER_CPUQUOTA] = c.CPUQuota.String()
 	return m
 }
+
+//the default quota of an account.
+func (c Config) quota() carton.Resources {
+	return carton.Resources{
+		Cpushare: c.QuotaCpushare,
+		Memory:   c.QuotaMemory,
+		HDD:      c.QuotaHDD,
+		Boxes:    c.QuotaBoxes,
+	}
+}
@@ -1,7 +1,10 @@
 package docker
 
 import (
+	"time"
+
 	"github.com/BurntSushi/toml"
+	"github.com/megamsys/vertice/carton"
 	"gopkg.in/check.v1"
 )
 
@@ -12,10 +15,17 @@ func (s *S) TestDockerConfig_Parse(c *check.C) {
 	if _, err := toml.Decode(`
 	enabled = false
 	swarm = "http://192.168.1.241:2375"
+	quota_memory = 4096
+	quota_boxes = 4
+	concurrency = 4
+	operation_timeout = "10m"
 
 	`, &cm); err != nil {
 		c.Fatal(err)
 	}
 
 	c.Assert(cm.Swarm, check.Equals, "http://192.168.1.241:2375")
+	c.Assert(cm.quota(), check.DeepEquals, carton.Resources{Memory: 4096, Boxes: 4})
+	c.Assert(cm.Concurrency, check.Equals, 4)
+	c.Assert(time.Duration(cm.OperationTimeout), check.Equals, 10*time.Minute)
 }
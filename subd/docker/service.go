@@ -1,11 +1,14 @@
 package docker
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	nsq "github.com/crackcomm/nsqueue/consumer"
+	nsqp "github.com/crackcomm/nsqueue/producer"
 	"github.com/megamsys/libgo/cmd"
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton"
@@ -16,6 +19,9 @@ import (
 const (
 	TOPIC       = "containers"
 	maxInFlight = 150
+
+	//where the requests which ran out of attempts are parked.
+	DEADLETTER_TOPIC = TOPIC + "_dead"
 )
 
 // Service manages the listener and handler for an HTTP endpoint.
@@ -24,6 +30,7 @@ type Service struct {
 	err      chan error
 	Handler  *Handler
 	Consumer *nsq.Consumer
+	stop     chan struct{}
 	Meta     *meta.Config
 	Dockerd  *Config
 	Bridges  *Bridges
@@ -37,7 +44,7 @@ func NewService(c *meta.Config, d *Config, b *Bridges) *Service {
 		Dockerd: d,
 		Bridges: b,
 	}
-	s.Handler = NewHandler(s.Dockerd)
+	s.Handler = NewHandler(s.Dockerd, s.deadLetter)
 	return s
 }
 
@@ -59,6 +66,16 @@ func (s *Service) Open() error {
 	if err := s.setProvisioner(constants.PROVIDER_DOCKER); err != nil {
 		return err
 	}
+	carton.SetConcurrency(constants.PROVIDER_DOCKER, s.Dockerd.Concurrency)
+	carton.SetOperationTimeout(constants.PROVIDER_DOCKER, time.Duration(s.Dockerd.OperationTimeout))
+	if s.Dockerd.SweepInterval > 0 && s.stop == nil {
+		s.stop = make(chan struct{})
+		s.wg.Add(1)
+		go s.sweepLoop(time.Duration(s.Dockerd.SweepInterval), s.stop)
+	}
+	if q := s.Dockerd.quota(); q != (carton.Resources{}) {
+		carton.DefaultQuota = q
+	}
 	return nil
 }
 
@@ -70,17 +87,57 @@ func (s *Service) processNSQ(msg *nsq.Message) {
 
 	re, err := p.Convert()
 	if err != nil {
+		log.Errorf("unable to fetch request (%s) %s", p.Id, err)
 		return
 	}
-	go s.Handler.serveNSQ(re)
+	go func() {
+		if err := s.Handler.serveNSQ(re); err != nil {
+			log.Errorf("request (%s, %s %s) failed %s", re.Id, re.Category, re.Action, err)
+		}
+	}()
 	return
 }
 
+//publishes the parked request along with its error to the dead letter topic.
+func (s *Service) deadLetter(d *carton.DeadLetter) error {
+	pons := nsqp.New()
+	if err := pons.Connect(s.Meta.NSQd[0]); err != nil {
+		return err
+	}
+	defer pons.Stop()
+
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return pons.Publish(DEADLETTER_TOPIC, bytes)
+}
+
+//runs the requests left running by a daemon which went away every interval,
+//till stop is closed.
+func (s *Service) sweepLoop(interval time.Duration, stop chan struct{}) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+			if err := s.Handler.sweep(time.Now()); err != nil {
+				log.Errorf("unable to run the requests left running %s", err)
+			}
+		}
+	}
+}
+
 // Close closes the underlying subscribe channel.
 func (s *Service) Close() error {
 	if s.Consumer != nil {
 		s.Consumer.Stop()
 	}
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
 
 	s.wg.Wait()
 	return nil
@@ -107,6 +164,10 @@ func (s *Service) setProvisioner(pt string) error {
 		}
 	}
 
+	if nodeMonitor, ok := tempProv.(provision.NodeMonitor); ok {
+		nodeMonitor.MonitorNodes(time.Duration(s.Dockerd.ProbeInterval))
+	}
+
 	if messageProvisioner, ok := tempProv.(provision.MessageProvisioner); ok {
 		startupMessage, err := messageProvisioner.StartupMessage()
 		if err == nil && startupMessage != "" {
@@ -0,0 +1,100 @@
+package scheduled
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/megamsys/libgo/cmd"
+	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/toml"
+)
+
+const (
+	DefaultInterval = 1 * time.Minute
+
+	//the topics of the daemons of the vms and the containers.
+	DefaultTopic           = "vms"
+	DefaultContainersTopic = "containers"
+)
+
+//the owners of an expiring assembly are warned this long before.
+var DefaultExpiryWarnings = []toml.Duration{toml.Duration(24 * time.Hour), toml.Duration(1 * time.Hour)}
+
+type Config struct {
+	Enabled         bool            `toml:"enabled"`
+	Interval        toml.Duration   `toml:"interval"`
+	Missed          string          `toml:"missed"`
+	MissedGrace     toml.Duration   `toml:"missed_grace"`
+	Topic           string          `toml:"topic"`
+	ContainersTopic string          `toml:"containers_topic"`
+	ExpiryWarnings  []toml.Duration `toml:"expiry_warnings"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Enabled:         false,
+		Interval:        toml.Duration(DefaultInterval),
+		Missed:          carton.MISSED_ONCE,
+		Topic:           DefaultTopic,
+		ContainersTopic: DefaultContainersTopic,
+		ExpiryWarnings:  DefaultExpiryWarnings,
+	}
+}
+
+func (c Config) String() string {
+	w := new(tabwriter.Writer)
+	var b bytes.Buffer
+	w.Init(&b, 0, 8, 0, '\t', 0)
+	b.Write([]byte(cmd.Colorfy("Config:", "white", "", "bold") + "\t" +
+		cmd.Colorfy("Scheduled", "cyan", "", "") + "\n"))
+	b.Write([]byte("enabled" + "\t" + strconv.FormatBool(c.Enabled) + "\n"))
+	b.Write([]byte("interval" + "\t" + c.Interval.String() + "\n"))
+	b.Write([]byte("missed" + "\t" + c.Missed + "\n"))
+	b.Write([]byte("missed_grace" + "\t" + c.MissedGrace.String() + "\n"))
+	b.Write([]byte("topic" + "\t" + c.Topic + "\n"))
+	b.Write([]byte("containers_topic" + "\t" + c.ContainersTopic + "\n"))
+	b.Write([]byte("expiry_warnings" + "\t" + strings.Join(c.warnings(), ", ") + "\n"))
+	b.Write([]byte("---\n"))
+	fmt.Fprintln(w)
+	w.Flush()
+	return strings.TrimSpace(b.String())
+}
+
+func (c Config) warnings() []string {
+	w := make([]string, len(c.ExpiryWarnings))
+	for i, d := range c.ExpiryWarnings {
+		w[i] = d.String()
+	}
+	return w
+}
+
+//how late a run is made before it is missed, by default two intervals so
+//that a tick which runs late doesn't skip a run on time.
+func (c Config) grace() time.Duration {
+	if c.MissedGrace > 0 {
+		return time.Duration(c.MissedGrace)
+	}
+	return 2 * time.Duration(c.Interval)
+}
+
+func (c Config) leads() []time.Duration {
+	l := make([]time.Duration, len(c.ExpiryWarnings))
+	for i, d := range c.ExpiryWarnings {
+		l[i] = time.Duration(d)
+	}
+	return l
+}
+
+//the request on the assemblies of the provider is run by the daemon of the
+//topic, the vms one unless they are containers.
+func (c Config) topic(provider string) string {
+	if provider == constants.PROVIDER_DOCKER {
+		return c.ContainersTopic
+	}
+	return c.Topic
+}
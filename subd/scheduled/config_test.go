@@ -0,0 +1,34 @@
+package scheduled
+
+import (
+	"time"
+
+	"github.com/BurntSushi/toml"
+	constants "github.com/megamsys/libgo/utils"
+	"gopkg.in/check.v1"
+)
+
+// Ensure the configuration can be parsed.
+func (s *S) TestScheduled_Parse(c *check.C) {
+	// Parse configuration.
+	cm := NewConfig()
+	if _, err := toml.Decode(`
+		enabled = true
+		interval  = "30s"
+		missed = "skip"
+		missed_grace = "5m"
+		expiry_warnings = ["48h", "2h"]
+`, cm); err != nil {
+		c.Fatal(err)
+	}
+
+	c.Assert(time.Duration(cm.Interval), check.Equals, 30*time.Second)
+	c.Assert(cm.Enabled, check.Equals, true)
+	c.Assert(cm.Missed, check.Equals, "skip")
+	c.Assert(cm.grace(), check.Equals, 5*time.Minute)
+	c.Assert(NewConfig().grace(), check.Equals, 2*DefaultInterval)
+	c.Assert(cm.Topic, check.Equals, DefaultTopic)
+	c.Assert(cm.topic(constants.PROVIDER_DOCKER), check.Equals, DefaultContainersTopic)
+	c.Assert(cm.topic(constants.PROVIDER_ONE), check.Equals, DefaultTopic)
+	c.Assert(cm.leads(), check.DeepEquals, []time.Duration{48 * time.Hour, 2 * time.Hour})
+}
@@ -0,0 +1,90 @@
+package scheduled
+
+import (
+	"time"
+
+	"github.com/megamsys/vertice/carton"
+	"gopkg.in/check.v1"
+)
+
+//AMS001 of ACT001 which expires at noon of the 3rd.
+func (s *S) expiring(c *check.C) {
+	c.Assert(s.repo.StoreAssemblies(&carton.Assemblies{Id: "AMS001", AccountsId: "ACT001", Name: "trial"}), check.IsNil)
+	_, err := carton.SetExpiry("AMS001", at("2016-03-03T12:00:00Z"))
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestReapWarnsAhead(c *check.C) {
+	s.expiring(c)
+	c.Assert(s.handler.reap(at("2016-03-02T11:00:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 0)
+
+	c.Assert(s.handler.reap(at("2016-03-02T12:00:30Z")), check.IsNil)
+	c.Assert(s.notified, check.DeepEquals, []string{"expiring: trial expires at 2016-03-03T12:00:00Z, in 23h59m0s."})
+	c.Assert(s.handler.reap(at("2016-03-02T13:00:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 1)
+
+	c.Assert(s.handler.reap(at("2016-03-03T11:00:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 2)
+	c.Assert(s.published, check.HasLen, 0)
+}
+
+func (s *S) TestReapWarnsOnceOfTheMissed(c *check.C) {
+	s.expiring(c)
+	c.Assert(s.handler.reap(at("2016-03-03T11:30:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 1)
+	c.Assert(s.handler.reap(at("2016-03-03T11:31:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 1)
+}
+
+func (s *S) TestReapDestroysTheExpired(c *check.C) {
+	s.expiring(c)
+	c.Assert(s.handler.reap(at("2016-03-03T12:00:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+	c.Assert(s.published[0].CatId, check.Equals, "AMS001")
+	c.Assert(s.published[0].Category, check.Equals, carton.STATE)
+	c.Assert(s.published[0].Action, check.Equals, carton.DESTROY)
+	c.Assert(s.notified, check.HasLen, 1)
+
+	c.Assert(s.handler.reap(at("2016-03-03T12:01:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+	e, err := carton.GetExpiry("AMS001")
+	c.Assert(err, check.IsNil)
+	c.Assert(e.RequestId, check.Equals, s.published[0].Id)
+}
+
+func (s *S) TestReapDestroysAgainAfterAFailedDestroy(c *check.C) {
+	s.expiring(c)
+	c.Assert(s.handler.reap(at("2016-03-03T12:00:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+	c.Assert(s.repo.UpdateRequest(s.published[0].Id, map[string]interface{}{"Status": carton.REQ_FAILED}), check.IsNil)
+
+	c.Assert(s.handler.reap(at("2016-03-03T12:01:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 2)
+	c.Assert(s.published[1].Action, check.Equals, carton.DESTROY)
+	c.Assert(s.notified, check.HasLen, 1)
+}
+
+func (s *S) TestReapForgetsTheDestroyed(c *check.C) {
+	s.expiring(c)
+	c.Assert(s.handler.reap(at("2016-03-03T12:00:00Z")), check.IsNil)
+	c.Assert(s.repo.UpdateRequest(s.published[0].Id, map[string]interface{}{"Status": carton.REQ_SUCCEEDED}), check.IsNil)
+
+	c.Assert(s.handler.reap(at("2016-03-03T12:01:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+	ids, _ := carton.ExpiringIds()
+	c.Assert(ids, check.HasLen, 0)
+}
+
+func (s *S) TestReapAfterExtend(c *check.C) {
+	s.expiring(c)
+	c.Assert(s.handler.reap(at("2016-03-03T11:00:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 1)
+	_, err := carton.ExtendExpiry("AMS001", 48*time.Hour)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(s.handler.reap(at("2016-03-03T12:00:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 0)
+	c.Assert(s.handler.reap(at("2016-03-04T12:00:00Z")), check.IsNil)
+	c.Assert(s.notified, check.HasLen, 2)
+}
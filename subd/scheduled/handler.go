@@ -0,0 +1,153 @@
+package scheduled
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/carton"
+)
+
+type Handler struct {
+	Config  *Config
+	publish func(r *carton.Requests) error
+	notify  func(e *carton.Expiry, status, description string) error
+}
+
+func NewHandler(c *Config, publish func(r *carton.Requests) error) *Handler {
+	return &Handler{Config: c, publish: publish, notify: (*carton.Expiry).Event}
+}
+
+//runs the schedules of all the assemblies which are due at now.
+func (h *Handler) serve(now time.Time) error {
+	ids, err := carton.ScheduledIds()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		ss, err := carton.ListSchedules(id)
+		if err != nil {
+			log.Errorf("unable to list the schedules of (%s) %s", id, err)
+			continue
+		}
+		for _, sc := range ss {
+			if err := h.serveSchedule(sc, now); err != nil {
+				log.Errorf("schedule (%s, %s %s) failed %s", sc.Id, sc.Category, sc.Action, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) serveSchedule(sc *carton.Schedule, now time.Time) error {
+	run, ran, err := due(sc, h.Config.Missed, h.Config.grace(), now)
+	if err != nil || ran.IsZero() {
+		return err
+	}
+	if run {
+		r, err := sc.Enqueue()
+		if err != nil {
+			return err
+		}
+		log.Debugf("  schedule (%s) enqueued request (%s, %s %s)", sc.Id, r.Id, r.Category, r.Action)
+		if err := h.publish(r); err != nil {
+			return err
+		}
+	}
+	return sc.Ran(ran)
+}
+
+//decides whether the schedule is to run at now, and what its last run is
+//from now on; a zero time when it isn't due.
+//The runs missed beyond the grace, eg: when the engine was down, are made up
+//with a single run unless the policy of the schedule (or else the default)
+//is to skip them.
+func due(sc *carton.Schedule, policy string, grace time.Duration, now time.Time) (bool, time.Time, error) {
+	next, err := sc.Next()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if next.IsZero() || next.After(now) {
+		return false, time.Time{}, nil
+	}
+	if len(sc.Missed) > 0 {
+		policy = sc.Missed
+	}
+	if policy == carton.MISSED_SKIP && now.Sub(next) > grace {
+		log.Warnf("  schedule (%s) missed its run at %s, skipping", sc.Id, next.Format(time.RFC3339))
+		return false, now, nil
+	}
+	return true, now, nil
+}
+
+//warns the owners of the assemblies about to expire, and destroys the
+//expired ones.
+func (h *Handler) reap(now time.Time) error {
+	ids, err := carton.ExpiringIds()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		e, err := carton.GetExpiry(id)
+		if err != nil {
+			log.Errorf("unable to fetch the expiry of (%s) %s", id, err)
+			continue
+		}
+		if err := h.reapOne(e, now); err != nil {
+			log.Errorf("expiry of (%s) failed %s", id, err)
+		}
+	}
+	return nil
+}
+
+//the warnings missed (eg: when the engine was down) are sent as one.
+func (h *Handler) reapOne(e *carton.Expiry, now time.Time) error {
+	at, err := e.At()
+	if err != nil {
+		return err
+	}
+	if !now.Before(at) {
+		return h.expire(e)
+	}
+	due := e.Due(h.Config.leads(), now)
+	if len(due) == 0 {
+		return nil
+	}
+	left := at.Sub(now).Truncate(time.Minute)
+	h.event(e, carton.EXPIRING, fmt.Sprintf("%s expires at %s, in %s.", e.Name, e.ExpiresAt, left))
+	return e.MarkWarned(due)
+}
+
+//the expired assembly is destroyed by a request. The expiry is kept till the
+//destroy succeeds (which removes it), so that a failed destroy is queued
+//again on a later reap.
+func (h *Handler) expire(e *carton.Expiry) error {
+	status, err := e.Destroying()
+	if err != nil {
+		return err
+	}
+	switch status {
+	case carton.REQ_QUEUED, carton.REQ_RUNNING:
+		return nil
+	case carton.REQ_SUCCEEDED:
+		return carton.RemoveExpiry(e.CatId)
+	}
+	r, err := e.Expire()
+	if err != nil {
+		return err
+	}
+	log.Debugf("  expiry of (%s) enqueued request (%s, %s %s)", e.CatId, r.Id, r.Category, r.Action)
+	if err := h.publish(r); err != nil {
+		return err
+	}
+	if len(status) == 0 {
+		h.event(e, carton.EXPIRED, fmt.Sprintf("%s expired at %s, it is destroyed.", e.Name, e.ExpiresAt))
+	}
+	return e.MarkExpired(r.Id)
+}
+
+func (h *Handler) event(e *carton.Expiry, status, description string) {
+	if err := h.notify(e, status, description); err != nil {
+		log.Errorf("  unable to notify the expiry of (%s) %s", e.CatId, err)
+	}
+}
@@ -0,0 +1,82 @@
+package scheduled
+
+import (
+	"time"
+
+	"github.com/megamsys/vertice/carton"
+	"gopkg.in/check.v1"
+)
+
+func at(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+//a schedule to stop ASM001 at 20:00 every day, created in the morning.
+func (s *S) stopAtEight(c *check.C, missed string) *carton.Schedule {
+	sc, err := carton.NewSchedule("ASM001", carton.CONTROL, carton.STOP, "0 20 * * *", missed)
+	c.Assert(err, check.IsNil)
+	sc.CreatedAt = "2016-03-01T09:00:00Z"
+	c.Assert(carton.AddSchedule(sc), check.IsNil)
+	return sc
+}
+
+func (s *S) lastRun(c *check.C) string {
+	ss, err := carton.ListSchedules("ASM001")
+	c.Assert(err, check.IsNil)
+	c.Assert(ss, check.HasLen, 1)
+	return ss[0].LastRun
+}
+
+func (s *S) TestServeWhenDue(c *check.C) {
+	s.stopAtEight(c, "")
+	c.Assert(s.handler.serve(at("2016-03-01T19:59:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 0)
+
+	c.Assert(s.handler.serve(at("2016-03-01T20:00:30Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+	c.Assert(s.published[0].CatId, check.Equals, "ASM001")
+	c.Assert(s.published[0].Category, check.Equals, carton.CONTROL)
+	c.Assert(s.published[0].Action, check.Equals, carton.STOP)
+	c.Assert(s.lastRun(c), check.Equals, "2016-03-01T20:00:30Z")
+
+	r, err := carton.NewPayload([]byte(`{"id":"` + s.published[0].Id + `"}`))
+	c.Assert(err, check.IsNil)
+	queued, err := r.Convert()
+	c.Assert(err, check.IsNil)
+	c.Assert(queued.Status, check.Equals, carton.REQ_QUEUED)
+
+	c.Assert(s.handler.serve(at("2016-03-01T20:01:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+}
+
+func (s *S) TestMissedRunsAreMadeUpOnce(c *check.C) {
+	s.stopAtEight(c, carton.MISSED_ONCE)
+	c.Assert(s.handler.serve(at("2016-03-04T10:00:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+	c.Assert(s.handler.serve(at("2016-03-04T10:01:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+}
+
+func (s *S) TestMissedRunsAreSkipped(c *check.C) {
+	s.stopAtEight(c, carton.MISSED_SKIP)
+	c.Assert(s.handler.serve(at("2016-03-04T10:00:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 0)
+	c.Assert(s.lastRun(c), check.Equals, "2016-03-04T10:00:00Z")
+
+	c.Assert(s.handler.serve(at("2016-03-04T20:00:30Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+}
+
+func (s *S) TestARunLateByATickIsntSkipped(c *check.C) {
+	s.stopAtEight(c, carton.MISSED_SKIP)
+	c.Assert(s.handler.serve(at("2016-03-01T20:01:30Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 1)
+}
+
+func (s *S) TestMissedFallsBackToTheConfig(c *check.C) {
+	s.handler.Config.Missed = carton.MISSED_SKIP
+	s.stopAtEight(c, "")
+	c.Assert(s.handler.serve(at("2016-03-04T10:00:00Z")), check.IsNil)
+	c.Assert(s.published, check.HasLen, 0)
+}
@@ -0,0 +1,100 @@
+package scheduled
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	nsqp "github.com/crackcomm/nsqueue/producer"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/meta"
+)
+
+// Service runs the schedules of the assemblies as they come due and destroys
+// the expired ones, the requests are queued for the daemons. The requests
+// left running by a daemon which went away are run again by the daemons, see
+// their sweep.
+type Service struct {
+	err     chan error
+	Handler *Handler
+	stop    chan struct{}
+	Meta    *meta.Config
+	Config  *Config
+}
+
+// NewService returns a new instance of Service.
+func NewService(c *meta.Config, f *Config) *Service {
+	s := &Service{
+		err:    make(chan error),
+		Meta:   c,
+		Config: f,
+	}
+	s.Handler = NewHandler(f, s.publish)
+	return s
+}
+
+// Open starts the service
+func (s *Service) Open() error {
+	log.Info("starting scheduled service")
+	if s.stop != nil {
+		return nil
+	}
+
+	s.stop = make(chan struct{})
+	go s.backgroundLoop(s.stop)
+	return nil
+}
+
+func (s *Service) backgroundLoop(stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			log.Info("scheduled terminating")
+			return
+		case <-time.After(time.Duration(s.Config.Interval)):
+			now := time.Now()
+			if err := s.Handler.serve(now); err != nil {
+				log.Errorf("unable to run the schedules %s", err)
+			}
+			if err := s.Handler.reap(now); err != nil {
+				log.Errorf("unable to reap the expired assemblies %s", err)
+			}
+		}
+	}
+}
+
+//queues the request as a pointer for the daemon of the provider of its
+//assemblies, which fetches it by id.
+func (s *Service) publish(r *carton.Requests) error {
+	a, err := carton.Get(r.CatId)
+	if err != nil {
+		return err
+	}
+	provider, err := a.Provider()
+	if err != nil {
+		return err
+	}
+	pons := nsqp.New()
+	if err := pons.Connect(s.Meta.NSQd[0]); err != nil {
+		return err
+	}
+	defer pons.Stop()
+
+	bytes, err := json.Marshal(carton.Payload{Id: r.Id})
+	if err != nil {
+		return err
+	}
+	return pons.Publish(s.Config.topic(provider), bytes)
+}
+
+func (s *Service) Close() error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	s.stop = nil
+	return nil
+}
+
+// Err returns a channel for fatal errors that occur on the listener.
+func (s *Service) Err() <-chan error { return s.err }
@@ -0,0 +1,36 @@
+package scheduled
+
+import (
+	"testing"
+
+	"github.com/megamsys/vertice/carton"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	repo      *carton.MemRepository
+	handler   *Handler
+	published []*carton.Requests
+	notified  []string
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	s.repo = carton.NewMemRepository()
+	carton.SetRepository(s.repo)
+	s.published = nil
+	s.notified = nil
+	s.handler = NewHandler(NewConfig(), func(r *carton.Requests) error {
+		s.published = append(s.published, r)
+		return nil
+	})
+	s.handler.notify = func(e *carton.Expiry, status, description string) error {
+		s.notified = append(s.notified, status+": "+description)
+		return nil
+	}
+}